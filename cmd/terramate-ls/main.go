@@ -9,10 +9,11 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
-
+	"net"
 	"os"
 	"os/signal"
 	"syscall"
@@ -31,7 +32,8 @@ const (
 )
 
 var (
-	modeFlag     = flag.String("mode", "stdio", "communication mode (stdio)")
+	modeFlag     = flag.String("mode", "stdio", "communication mode (stdio, tcp or unix)")
+	addrFlag     = flag.String("addr", "", "address to listen on: host:port for tcp mode or the socket path for unix mode")
 	versionFlag  = flag.Bool("version", false, "print version and exit")
 	logLevelFlag = flag.String(
 		"log-level", defaultLogLevel,
@@ -53,35 +55,88 @@ func main() {
 		os.Exit(0)
 	}
 
-	// TODO(i4k): implement other modes.
-	if *modeFlag != "stdio" {
-		fmt.Println("terramate-ls only supports stdio mode")
+	switch *modeFlag {
+	case "stdio":
+	case "tcp", "unix":
+		if *addrFlag == "" {
+			fmt.Fprintf(defaultLogWriter, "error: -mode=%s requires -addr\n", *modeFlag)
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintf(defaultLogWriter, "error: mode %q not supported\n", *modeFlag)
 		os.Exit(1)
 	}
 
 	configureLogging(*logLevelFlag, *logFmtFlag, defaultLogWriter)
-	runServer(&readWriter{os.Stdin, os.Stdout})
-}
-
-func runServer(conn io.ReadWriteCloser) {
-	logger := log.With().
-		Str("action", "main.runServer()").
-		Logger()
-
-	logger.Trace().Msg("Creating context for OS signals.")
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill, syscall.SIGTERM)
 	defer stop()
 
-	logger.Info().
+	log.Info().
 		Str("mode", *modeFlag).
 		Msg("Starting Terramate Language Server")
 
+	if *modeFlag == "stdio" {
+		runServer(ctx, &readWriter{os.Stdin, os.Stdout}, log.Logger)
+		return
+	}
+
+	listener, err := net.Listen(*modeFlag, *addrFlag)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("failed to listen on %s", *addrFlag)
+	}
+
+	log.Info().Msgf("listening on %s", listener.Addr())
+
+	if err := serve(ctx, listener); err != nil {
+		log.Fatal().Err(err).Msg("failed to accept connections")
+	}
+}
+
+// serve accepts client connections on the listener until the context is
+// cancelled. Each client has its own language server, so they don't share
+// any state.
+func serve(ctx context.Context, listener net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		_ = listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		logger := log.With().
+			Str("client", conn.RemoteAddr().String()).
+			Logger()
+
+		go func() {
+			logger.Info().Msg("client connected")
+			runServer(ctx, conn, logger)
+			logger.Info().Msg("client disconnected")
+		}()
+	}
+}
+
+// runServer runs a language server for the connection until the client
+// disconnects or the context is cancelled.
+func runServer(ctx context.Context, conn io.ReadWriteCloser, logger zerolog.Logger) {
 	rpcConn := jsonrpc2.NewConn(jsonrpc2.NewStream(conn))
-	server := tmls.NewServer(rpcConn)
+	server := tmls.ServerWithLogger(rpcConn, logger)
 
 	rpcConn.Go(ctx, server.Handler)
-	<-rpcConn.Done()
+
+	select {
+	case <-rpcConn.Done():
+	case <-ctx.Done():
+		_ = rpcConn.Close()
+		<-rpcConn.Done()
+	}
 }
 
 type readWriter struct {
@@ -0,0 +1,102 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/madlambda/spells/assert"
+	"github.com/rs/zerolog"
+	"github.com/terramate-io/terramate/test/sandbox"
+	"go.lsp.dev/jsonrpc2"
+	lsp "go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
+)
+
+func TestServeTCP(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	testServe(t, listener)
+}
+
+func TestServeUnixSocket(t *testing.T) {
+	t.Parallel()
+
+	// the path of unix sockets is limited to ~100 bytes, then the test temp
+	// dir can't be used.
+	dir, err := os.MkdirTemp("", "tmls")
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+
+	listener, err := net.Listen("unix", filepath.Join(dir, "tmls.sock"))
+	assert.NoError(t, err)
+	testServe(t, listener)
+}
+
+func testServe(t *testing.T, listener net.Listener) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- serve(ctx, listener)
+	}()
+
+	connect := func() jsonrpc2.Conn {
+		netConn, err := net.Dial(listener.Addr().Network(), listener.Addr().String())
+		assert.NoError(t, err)
+		conn := jsonrpc2.NewConn(jsonrpc2.NewStream(netConn))
+		conn.Go(ctx, func(ctx context.Context, reply jsonrpc2.Replier, _ jsonrpc2.Request) error {
+			return reply(ctx, nil, nil)
+		})
+		return conn
+	}
+
+	initialize := func(conn jsonrpc2.Conn, workspace string) {
+		var got lsp.InitializeResult
+		_, err := conn.Call(ctx, lsp.MethodInitialize, lsp.InitializeParams{
+			RootURI: uri.File(workspace),
+		}, &got)
+		assert.NoError(t, err)
+		assert.IsTrue(t, got.Capabilities.HoverProvider != nil)
+	}
+
+	s1 := sandbox.NoGit(t)
+	s1.BuildTree([]string{"f:globals.tm:globals {\n  a = 1\n}"})
+	s2 := sandbox.NoGit(t)
+	s2.BuildTree([]string{"f:globals.tm:globals {\n  a = 2\n}"})
+
+	client1 := connect()
+	client2 := connect()
+	initialize(client1, s1.RootDir())
+	initialize(client2, s2.RootDir())
+
+	// a disconnected client doesn't affect the other ones.
+	assert.NoError(t, client1.Close())
+	<-client1.Done()
+
+	var locations []lsp.Location
+	_, err := client2.Call(ctx, lsp.MethodTextDocumentDefinition, lsp.DefinitionParams{
+		TextDocumentPositionParams: lsp.TextDocumentPositionParams{
+			TextDocument: lsp.TextDocumentIdentifier{
+				URI: uri.File(filepath.Join(s2.RootDir(), "globals.tm")),
+			},
+		},
+	}, &locations)
+	assert.NoError(t, err)
+
+	cancel()
+	assert.NoError(t, <-done)
+	<-client2.Done()
+}
+
+func init() {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+}
@@ -82,8 +82,6 @@ const (
 	defaultLogDest  = "stderr"
 )
 
-const defaultVendorDir = "/modules"
-
 const terramateUserConfigDir = ".terramate.d"
 
 const (
@@ -171,7 +169,14 @@ type cliSpec struct {
 
 		Metadata struct{} `cmd:"" help:"Shows metadata available on the project"`
 
-		Globals struct{} `cmd:"" help:"List globals for all stacks"`
+		Globals struct {
+			Explain string `name:"explain" placeholder:"global" help:"Explain how the given global (eg.: global.a.b) is defined for each stack"`
+		} `cmd:"" help:"List globals for all stacks"`
+
+		RenameGlobal struct {
+			Global  string `arg:"" name:"global" help:"Global to be renamed (eg.: global.a.b)"`
+			NewName string `arg:"" name:"newname" help:"New name of the last key of the global"`
+		} `cmd:"" help:"Renames a global in all its definitions and references"`
 
 		Generate struct {
 			Debug struct{} `cmd:"" help:"Shows generate debug information"`
@@ -194,6 +199,8 @@ type cliSpec struct {
 				Source    string `arg:"" name:"source" help:"Terraform module source URL, must be Git/Github and should not contain a reference"`
 				Reference string `arg:"" name:"ref" help:"Reference of the Terraform module to vendor"`
 			} `cmd:"" help:"Downloads a Terraform module and stores it on the project vendor dir"`
+
+			Imports struct{} `cmd:"" help:"Downloads the remote sources of the import blocks and stores them on the project vendor dir"`
 		} `cmd:"" help:"Manages vendored Terraform modules"`
 
 		Eval struct {
@@ -453,6 +460,14 @@ func newCLI(version string, args []string, stdin io.Reader, stdout, stderr io.Wr
 
 	logger.Trace().Msg("Running in directory")
 
+	if ctx.Command() == "experimental vendor imports" {
+		// the project configuration can't be loaded while there are remote
+		// imports not vendored, so they are vendored before loading it.
+		c := &cli{output: output}
+		c.vendorImports(wd)
+		return &cli{exit: true}
+	}
+
 	prj, foundRoot, err := lookupProject(wd)
 	if err != nil {
 		fatal(err, "looking up project root")
@@ -542,7 +557,13 @@ func (c *cli) run() {
 		c.vendorDownload()
 	case "experimental globals":
 		c.setupGit()
-		c.printStacksGlobals()
+		if c.parsedArgs.Experimental.Globals.Explain != "" {
+			c.explainStacksGlobal(c.parsedArgs.Experimental.Globals.Explain)
+		} else {
+			c.printStacksGlobals()
+		}
+	case "experimental rename-global <global> <newname>":
+		c.renameGlobal()
 	case "experimental generate debug":
 		c.setupGit()
 		c.generateDebug()
@@ -646,6 +667,46 @@ func (c *cli) vendorDownload() {
 	c.output.MsgStdOut(report.String())
 }
 
+func (c *cli) vendorImports(wd string) {
+	logger := log.With().
+		Str("workingDir", wd).
+		Str("action", "cli.vendorImports()").
+		Logger()
+
+	rootdir, found, err := lookupImportsRoot(wd)
+	if err != nil {
+		fatal(err, "looking up project root")
+	}
+	if !found {
+		log.Fatal().Msg("Project root not found")
+	}
+
+	vendorDir, err := hcl.LoadVendorDir(rootdir)
+	if err != nil {
+		fatal(err, "loading vendor dir configuration")
+	}
+
+	eventsStream := download.NewEventStream()
+	eventsHandled := c.handleVendorProgressEvents(eventsStream)
+
+	logger.Debug().Msg("vendoring imports")
+
+	report := download.VendorImports(rootdir, vendorDir, eventsStream)
+
+	close(eventsStream)
+	<-eventsHandled
+
+	if report.Error != nil {
+		fatal(report.Error, "vendoring imports")
+	}
+
+	c.output.MsgStdOut(report.String())
+
+	if report.HasFailures() {
+		os.Exit(1)
+	}
+}
+
 func (c *cli) handleVendorProgressEvents(eventsStream download.ProgressEventStream) <-chan struct{} {
 	eventsHandled := make(chan struct{})
 
@@ -683,47 +744,13 @@ func (c *cli) vendorDir() prj.Path {
 		return prj.NewPath(dir)
 	}
 
-	checkVendorDir := func(dir string) prj.Path {
-		if !path.IsAbs(dir) {
-			log.Fatal().Msgf("vendorDir %s defined is not an absolute path", dir)
-		}
-		return prj.NewPath(dir)
-	}
-
-	dotTerramate := filepath.Join(c.rootdir(), ".terramate")
-	dotTerramateInfo, err := os.Stat(dotTerramate)
-
-	if err == nil && dotTerramateInfo.IsDir() {
-		logger.Trace().Msg("no CLI config, checking .terramate")
-
-		cfg, err := hcl.ParseDir(c.rootdir(), filepath.Join(c.rootdir(), ".terramate"))
-		if err != nil {
-			fatal(err, "parsing vendor dir configuration on .terramate")
-		}
-
-		if hasVendorDirConfig(cfg) {
-			logger.Trace().Msg("using .terramate config")
-
-			return checkVendorDir(cfg.Vendor.Dir)
-		}
-	}
-
-	logger.Trace().Msg("no .terramate config, checking root")
-
-	hclcfg := c.rootNode()
-	if hasVendorDirConfig(hclcfg) {
-		logger.Trace().Msg("using root config")
+	logger.Trace().Msg("no CLI config, checking project config")
 
-		return checkVendorDir(hclcfg.Vendor.Dir)
+	dir, err := hcl.LoadVendorDir(c.rootdir())
+	if err != nil {
+		fatal(err, "loading vendor dir configuration")
 	}
-
-	logger.Trace().Msg("no configuration provided, fallback to default")
-
-	return prj.NewPath(defaultVendorDir)
-}
-
-func hasVendorDirConfig(cfg hcl.Config) bool {
-	return cfg.Vendor != nil && cfg.Vendor.Dir != ""
+	return dir
 }
 
 func (c *cli) triggerStackByFilter() {
@@ -1075,7 +1102,7 @@ func (c *cli) initTerraform() {
 		return
 	}
 
-	root, err := config.LoadRoot(c.rootdir())
+	root, err := config.LoadRootWithEvaluator(c.rootdir(), globals.EvalStackAttrs)
 	if err != nil {
 		fatal(err, "reloading the configuration")
 	}
@@ -1601,9 +1628,33 @@ func (c *cli) generateDebug() {
 			}
 		}
 
+		st, err := config.LoadStack(c.cfg(), res.Dir)
+		if err != nil {
+			fatal(err, "generate debug: loading stack %s", res.Dir)
+		}
+
 		for _, file := range files {
 			filepath := path.Join(res.Dir.String(), file.Label())
 			c.output.MsgStdOut("%s origin: %v", filepath, file.Range())
+
+			prov, err := generate.LoadProvenance(c.cfg(), st, file)
+			if err != nil {
+				fatal(err, "generate debug: loading provenance of %s", filepath)
+			}
+
+			for _, ref := range prov.Globals {
+				var via string
+				if len(ref.ReferencedBy) > 0 {
+					via = stdfmt.Sprintf(" (referenced by global.%s)", strings.Join(ref.ReferencedBy, "."))
+				}
+				if !ref.Found {
+					c.output.MsgStdOut("\t%s undefined%s", ref, via)
+					continue
+				}
+				c.output.MsgStdOut("\t%s = %s defined at %v%s", ref,
+					indentLines(string(ast.TokensForValue(ref.Value).Bytes()), "\t"),
+					ref.Expr.Origin, via)
+			}
 		}
 	}
 }
@@ -1645,6 +1696,105 @@ func (c *cli) printStacksGlobals() {
 	}
 }
 
+func (c *cli) explainStacksGlobal(name string) {
+	logger := log.With().
+		Str("action", "explainStacksGlobal()").
+		Str("global", name).
+		Logger()
+
+	path := strings.Split(strings.TrimPrefix(name, "global."), ".")
+	for _, part := range path {
+		if part == "" {
+			fatal(errors.E("--explain %q is not a valid global accessor", name))
+		}
+	}
+
+	mgr := stack.NewManager(c.cfg(), c.prj.baseRef)
+	report, err := c.listStacks(mgr, c.parsedArgs.Changed, cloudstack.NoFilter)
+	if err != nil {
+		fatal(err, "explaining global: listing stacks")
+	}
+
+	for _, stackEntry := range c.filterStacks(report.Stacks) {
+		st := stackEntry.Stack
+		explanation, err := globals.Explain(c.cfg(), st, path)
+		if err != nil {
+			logger := logger.With().
+				Stringer("stack", st.Dir).
+				Logger()
+
+			errlog.Fatal(logger, err, "explaining global: loading stack")
+		}
+
+		c.output.MsgStdOut("\nstack %q:", st.Dir)
+		if explanation.Found {
+			c.output.MsgStdOut("\tglobal.%s = %s", strings.Join(path, "."),
+				indentLines(string(ast.TokensForValue(explanation.Value).Bytes()), "\t"))
+		} else {
+			c.output.MsgStdOut("\tglobal.%s is undefined", strings.Join(path, "."))
+		}
+
+		for _, def := range explanation.Definitions {
+			c.output.MsgStdOut("\t%s: global.%s at %s (dir %s)", def.Status,
+				strings.Join(def.Path, "."), def.Expr.Origin, def.Expr.ConfigDir)
+			c.output.MsgStdOut("\t\texpr:  %s", indentLines(def.Source, "\t\t"))
+			switch {
+			case def.Status == globals.StatusUnset:
+			case def.Err != nil:
+				c.output.MsgStdOut("\t\terror: %v", def.Err)
+			default:
+				c.output.MsgStdOut("\t\tvalue: %s",
+					indentLines(string(ast.TokensForValue(def.Value).Bytes()), "\t\t"))
+			}
+		}
+	}
+}
+
+func (c *cli) renameGlobal() {
+	name := c.parsedArgs.Experimental.RenameGlobal.Global
+	newName := c.parsedArgs.Experimental.RenameGlobal.NewName
+	logger := log.With().
+		Str("action", "renameGlobal()").
+		Str("global", name).
+		Str("newName", newName).
+		Logger()
+
+	path := strings.Split(strings.TrimPrefix(name, "global."), ".")
+	for _, part := range path {
+		if part == "" {
+			fatal(errors.E("%q is not a valid global accessor", name))
+		}
+	}
+
+	dir := prj.PrjAbsPath(c.rootdir(), c.wd())
+	renamed, err := globals.Rename(c.cfg(), dir, path, newName, nil)
+	if err != nil {
+		fatal(err, "renaming global.%s", strings.Join(path, "."))
+	}
+
+	for _, file := range renamed {
+		logger.Trace().Str("file", file.Path).Msg("saving renamed file")
+
+		st, err := os.Stat(file.Path)
+		if err != nil {
+			fatal(err, "renaming global: reading %s", file.Path)
+		}
+		if err := os.WriteFile(file.Path, file.Content, st.Mode()); err != nil {
+			fatal(err, "renaming global: writing %s", file.Path)
+		}
+		c.output.MsgStdOut("Updated %s", prj.PrjAbsPath(c.rootdir(), file.Path))
+	}
+
+	if len(renamed) == 0 {
+		c.output.MsgStdOut("Nothing to rename")
+	}
+}
+
+// indentLines indents all lines but the first one.
+func indentLines(s string, indent string) string {
+	return strings.ReplaceAll(s, "\n", "\n"+indent)
+}
+
 func (c *cli) printMetadata() {
 	logger := log.With().
 		Str("action", "cli.printMetadata()").
@@ -1692,6 +1842,10 @@ func (c *cli) printMetadata() {
 		c.output.MsgStdOut("\tterramate.stack.path.basename=%q", stack.PathBase())
 		c.output.MsgStdOut("\tterramate.stack.path.relative=%q", stack.RelPath())
 		c.output.MsgStdOut("\tterramate.stack.path.to_root=%q", stack.RelPathToRoot(c.cfg()))
+		if len(stack.Defaults) > 0 {
+			defaultsVal, _ := stdjson.Marshal(stack.Defaults.Strings())
+			c.output.MsgStdOut("\tstack_defaults=%s", string(defaultsVal))
+		}
 	}
 }
 
@@ -1738,13 +1892,10 @@ func (c *cli) ensureStackID() {
 }
 
 func (c *cli) eval() {
-	ctx := c.detectEvalContext(c.parsedArgs.Experimental.Eval.Global)
-	for _, exprStr := range c.parsedArgs.Experimental.Eval.Exprs {
-		expr, err := ast.ParseExpression(exprStr, "<cmdline>")
-		if err != nil {
-			fatal(err)
-		}
-		val, err := ctx.Eval(expr)
+	exprs := parseCmdlineExprs(c.parsedArgs.Experimental.Eval.Exprs)
+	ctx := c.detectEvalContext(c.parsedArgs.Experimental.Eval.Global, exprs)
+	for i, exprStr := range c.parsedArgs.Experimental.Eval.Exprs {
+		val, err := ctx.Eval(exprs[i])
 		if err != nil {
 			fatal(err, "eval %q", exprStr)
 		}
@@ -1753,13 +1904,10 @@ func (c *cli) eval() {
 }
 
 func (c *cli) partialEval() {
-	ctx := c.detectEvalContext(c.parsedArgs.Experimental.PartialEval.Global)
-	for _, exprStr := range c.parsedArgs.Experimental.PartialEval.Exprs {
-		expr, err := ast.ParseExpression(exprStr, "<cmdline>")
-		if err != nil {
-			fatal(err)
-		}
-		newexpr, err := ctx.PartialEval(expr)
+	exprs := parseCmdlineExprs(c.parsedArgs.Experimental.PartialEval.Exprs)
+	ctx := c.detectEvalContext(c.parsedArgs.Experimental.PartialEval.Global, exprs)
+	for i, exprStr := range c.parsedArgs.Experimental.PartialEval.Exprs {
+		newexpr, err := ctx.PartialEval(exprs[i])
 		if err != nil {
 			fatal(err, "partial eval %q", exprStr)
 		}
@@ -1768,15 +1916,20 @@ func (c *cli) partialEval() {
 }
 
 func (c *cli) evalRunArgs(st *config.Stack, cmd []string) []string {
-	ctx := c.setupEvalContext(st, map[string]string{})
-	var newargs []string
-	for _, arg := range cmd {
+	exprs := make([]hhcl.Expression, len(cmd))
+	for i, arg := range cmd {
 		exprStr := `"` + arg + `"`
 		expr, err := ast.ParseExpression(exprStr, "<cmd arg>")
 		if err != nil {
 			fatal(err, "parsing %s", exprStr)
 		}
-		val, err := ctx.Eval(expr)
+		exprs[i] = expr
+	}
+	ctx := c.setupEvalContext(st, map[string]string{}, exprs)
+	var newargs []string
+	for i, arg := range cmd {
+		exprStr := `"` + arg + `"`
+		val, err := ctx.Eval(exprs[i])
 		if err != nil {
 			fatal(err, "eval %q", exprStr)
 		}
@@ -1794,12 +1947,10 @@ func (c *cli) getConfigValue() {
 		Str("action", "cli.getConfigValue()").
 		Logger()
 
-	ctx := c.detectEvalContext(c.parsedArgs.Experimental.GetConfigValue.Global)
-	for _, exprStr := range c.parsedArgs.Experimental.GetConfigValue.Vars {
-		expr, err := ast.ParseExpression(exprStr, "<cmdline>")
-		if err != nil {
-			fatal(err)
-		}
+	exprs := parseCmdlineExprs(c.parsedArgs.Experimental.GetConfigValue.Vars)
+	ctx := c.detectEvalContext(c.parsedArgs.Experimental.GetConfigValue.Global, exprs)
+	for i, exprStr := range c.parsedArgs.Experimental.GetConfigValue.Vars {
+		expr := exprs[i]
 
 		iteratorTraversal, diags := hhcl.AbsTraversalForExpr(expr)
 		if diags.HasErrors() {
@@ -1840,7 +1991,20 @@ func (c *cli) outputEvalResult(val cty.Value, asJSON bool) {
 	c.output.MsgStdOut(string(data))
 }
 
-func (c *cli) detectEvalContext(overrideGlobals map[string]string) *eval.Context {
+// parseCmdlineExprs parses the expressions given in the command line.
+func parseCmdlineExprs(exprStrs []string) []hhcl.Expression {
+	exprs := make([]hhcl.Expression, len(exprStrs))
+	for i, exprStr := range exprStrs {
+		expr, err := ast.ParseExpression(exprStr, "<cmdline>")
+		if err != nil {
+			fatal(err)
+		}
+		exprs[i] = expr
+	}
+	return exprs
+}
+
+func (c *cli) detectEvalContext(overrideGlobals map[string]string, refs []hhcl.Expression) *eval.Context {
 	var st *config.Stack
 	if config.IsStack(c.cfg(), c.wd()) {
 		var err error
@@ -1849,10 +2013,17 @@ func (c *cli) detectEvalContext(overrideGlobals map[string]string) *eval.Context
 			fatal(err, "setup eval context: loading stack config")
 		}
 	}
-	return c.setupEvalContext(st, overrideGlobals)
+	return c.setupEvalContext(st, overrideGlobals, refs)
 }
 
-func (c *cli) setupEvalContext(st *config.Stack, overrideGlobals map[string]string) *eval.Context {
+// setupEvalContext creates the evaluation context for the stack (or the
+// working dir if st is nil). If lazy evaluation of globals is enabled, only
+// the globals referenced by refs are evaluated.
+func (c *cli) setupEvalContext(
+	st *config.Stack,
+	overrideGlobals map[string]string,
+	refs []hhcl.Expression,
+) *eval.Context {
 	runtime := c.cfg().Runtime()
 
 	var tdir string
@@ -1871,6 +2042,7 @@ func (c *cli) setupEvalContext(st *config.Stack, overrideGlobals map[string]stri
 	if !ok {
 		fatal(errors.E("configuration at %s not found", wdPath))
 	}
+	tree.SetFunctions(ctx)
 	exprs, err := globals.LoadExprs(tree)
 	if err != nil {
 		fatal(err, "loading globals expressions")
@@ -1895,6 +2067,9 @@ func (c *cli) setupEvalContext(st *config.Stack, overrideGlobals map[string]stri
 			}),
 		)
 	}
+	if c.cfg().Tree().Node.HasLazyGlobals() {
+		exprs = exprs.Referenced(refs)
+	}
 	_ = exprs.Eval(ctx)
 	return ctx
 }
@@ -2181,7 +2356,7 @@ func lookupProject(wd string) (prj project, found bool, err error) {
 
 	logger.Trace().Msg("Create new git wrapper.")
 
-	rootcfg, rootCfgPath, rootfound, err := config.TryLoadConfig(wd)
+	rootcfg, rootCfgPath, rootfound, err := config.TryLoadConfigWithEvaluator(wd, globals.EvalStackAttrs)
 	if err != nil {
 		return project{}, false, err
 	}
@@ -2216,7 +2391,7 @@ func lookupProject(wd string) (prj project, found bool, err error) {
 
 			logger.Trace().Msg("Load root config.")
 
-			cfg, err := config.LoadRoot(rootdir)
+			cfg, err := config.LoadRootWithEvaluator(rootdir, globals.EvalStackAttrs)
 			if err != nil {
 				return project{}, false, err
 			}
@@ -2269,6 +2444,42 @@ func configureLogging(logLevel, logFmt, logdest string, stdout, stderr io.Writer
 	}
 }
 
+// lookupImportsRoot looks up the project root without loading the project
+// configuration, as done by [lookupProject], so it can be used while there
+// are remote imports not vendored.
+func lookupImportsRoot(wd string) (string, bool, error) {
+	gw, err := newGit(wd, false)
+	if err == nil {
+		gitdir, err := gw.Root()
+		if err == nil {
+			gitabs := gitdir
+			if !filepath.IsAbs(gitabs) {
+				gitabs = filepath.Join(wd, gitdir)
+			}
+			rootdir, err := filepath.EvalSymlinks(gitabs)
+			if err != nil {
+				return "", false, errors.E(err, "failed evaluating symlinks of %q", gitabs)
+			}
+			return rootdir, true, nil
+		}
+	}
+
+	for dir := wd; ; {
+		cfg, _, err := hcl.ParseDirMissingImports(dir, dir)
+		if err != nil && !errors.IsKind(err, hcl.ErrImport) {
+			return "", false, err
+		}
+		if err == nil && cfg.Terramate != nil && cfg.Terramate.Config != nil {
+			return dir, true, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false, nil
+		}
+		dir = parent
+	}
+}
+
 func fatal(err error, args ...any) {
 	errlog.Fatal(log.Logger, err, args...)
 }
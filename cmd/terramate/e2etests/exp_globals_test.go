@@ -259,3 +259,63 @@ stack "/stacks/stack-name":
 		})
 	}
 }
+
+func TestStacksGlobalsExplain(t *testing.T) {
+	t.Parallel()
+
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack",
+		`f:globals.tm:globals {
+  a   = 1
+  obj = { x = 1 }
+}
+`,
+		`f:stack/globals.tm:globals {
+  a = global.obj.x + 1
+}
+
+globals "obj" {
+  y = "b"
+}
+`,
+	})
+	test.WriteRootConfig(t, s.RootDir())
+
+	tm := newCLI(t, s.RootDir())
+	assertRunResult(t, tm.run("experimental", "globals", "--explain", "global.a"), runExpected{
+		Stdout: `
+stack "/stack":
+	global.a = 2
+	overridden: global.a at /globals.tm:2,3-10 (dir /)
+		expr:  1
+		value: 1
+	defined: global.a at /stack/globals.tm:2,3-23 (dir /stack)
+		expr:  global.obj.x + 1
+		value: 2
+`,
+	})
+	assertRunResult(t, tm.run("experimental", "globals", "--explain", "obj"), runExpected{
+		Stdout: `
+stack "/stack":
+	global.obj = {
+	  x = 1
+	  y = "b"
+	}
+	defined: global.obj at /globals.tm:3,3-18 (dir /)
+		expr:  { x = 1 }
+		value: {
+		  x = 1
+		}
+	merged: global.obj.y at /stack/globals.tm:6,3-10 (dir /stack)
+		expr:  "b"
+		value: "b"
+`,
+	})
+	assertRunResult(t, tm.run("experimental", "globals", "--explain", "undefined"), runExpected{
+		Stdout: `
+stack "/stack":
+	global.undefined is undefined
+`,
+	})
+}
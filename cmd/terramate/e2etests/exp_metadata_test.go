@@ -223,6 +223,72 @@ stack "/stack":
 	terramate.stack.path.basename="stack"
 	terramate.stack.path.relative="stack"
 	terramate.stack.path.to_root=".."
+`,
+			},
+		},
+		{
+			name: "stacks with stack_defaults",
+			layout: []string{
+				`f:envs/defaults.tm:stack_defaults {
+					description = "${terramate.stack.name} environment"
+					tags        = ["env"]
+				}`,
+				"s:envs/prod",
+				`s:envs/dev:tags=["dev"]`,
+			},
+			want: runExpected{
+				Stdout: `Available metadata:
+
+project metadata:
+	terramate.stacks.list=[/envs/dev /envs/prod]
+
+stack "/envs/dev":
+	terramate.stack.name="dev"
+	terramate.stack.description="dev environment"
+	terramate.stack.tags=["dev"]
+	terramate.stack.path.absolute="/envs/dev"
+	terramate.stack.path.basename="dev"
+	terramate.stack.path.relative="envs/dev"
+	terramate.stack.path.to_root="../.."
+	stack_defaults=["/envs"]
+
+stack "/envs/prod":
+	terramate.stack.name="prod"
+	terramate.stack.description="prod environment"
+	terramate.stack.tags=["env"]
+	terramate.stack.path.absolute="/envs/prod"
+	terramate.stack.path.basename="prod"
+	terramate.stack.path.relative="envs/prod"
+	terramate.stack.path.to_root="../.."
+	stack_defaults=["/envs"]
+`,
+			},
+		},
+		{
+			name: "stack with attributes computed from globals",
+			layout: []string{
+				`f:globals.tm:globals {
+					env = "prod"
+				}`,
+				`f:stack/stack.tm:stack {
+					description = "${terramate.stack.name} in ${global.env}"
+					tags        = ["env-${global.env}"]
+				}`,
+			},
+			want: runExpected{
+				Stdout: `Available metadata:
+
+project metadata:
+	terramate.stacks.list=[/stack]
+
+stack "/stack":
+	terramate.stack.name="stack"
+	terramate.stack.description="stack in prod"
+	terramate.stack.tags=["env-prod"]
+	terramate.stack.path.absolute="/stack"
+	terramate.stack.path.basename="stack"
+	terramate.stack.path.relative="stack"
+	terramate.stack.path.to_root=".."
 `,
 			},
 		},
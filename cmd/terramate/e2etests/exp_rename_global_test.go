@@ -0,0 +1,62 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package e2etest
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/terramate-io/terramate/test"
+	"github.com/terramate-io/terramate/test/sandbox"
+)
+
+func TestRenameGlobal(t *testing.T) {
+	t.Parallel()
+
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		`f:globals.tm:globals {
+  env = "prod" # environment
+}
+`,
+		"s:stack",
+		`f:stack/gen.tm:generate_hcl "env.hcl" {
+  content {
+    env = global.env
+  }
+}
+`,
+		`f:other/globals.tm:globals {
+  env = "dev"
+}
+`,
+	})
+
+	tmcli := newCLI(t, filepath.Join(s.RootDir(), "stack"))
+	assertRunResult(t, tmcli.run("experimental", "rename-global", "global.env", "environment"),
+		runExpected{
+			Stdout: "Updated /globals.tm\nUpdated /other/globals.tm\nUpdated /stack/gen.tm\n",
+		})
+
+	test.AssertFileContentEquals(t, filepath.Join(s.RootDir(), "globals.tm"), `globals {
+  environment = "prod" # environment
+}
+`)
+	test.AssertFileContentEquals(t, filepath.Join(s.RootDir(), "other/globals.tm"), `globals {
+  environment = "dev"
+}
+`)
+	test.AssertFileContentEquals(t, filepath.Join(s.RootDir(), "stack/gen.tm"), `generate_hcl "env.hcl" {
+  content {
+    env = global.environment
+  }
+}
+`)
+
+	assertRunResult(t, tmcli.run("experimental", "rename-global", "global.environment", "1env"),
+		runExpected{
+			Status:      1,
+			StderrRegex: "not a valid global name",
+		})
+}
@@ -329,6 +329,34 @@ func vendorHCLConfig(dir string) string {
 	`, dir)
 }
 
+func TestVendorImports(t *testing.T) {
+	t.Parallel()
+
+	gitSource := newGitSource(t, "globals.tm.hcl", Globals(
+		Str("origin", "vendored"),
+	).String())
+
+	s := sandbox.New(t)
+	s.CreateStack("stack")
+	s.RootEntry().CreateFile("import.tm", Import(
+		Str("source", gitSource+"//globals.tm.hcl?ref=main"),
+	).String())
+
+	tmcli := newCLI(t, s.RootDir())
+	assertRunResult(t, tmcli.run("list"), runExpected{
+		Status:      1,
+		StderrRegex: "is not vendored",
+	})
+	assertRunResult(t, tmcli.run("experimental", "vendor", "imports"),
+		runExpected{IgnoreStdout: true})
+
+	modsrc := test.ParseSource(t, gitSource+"//globals.tm.hcl?ref=main")
+	clonedir := modvendor.AbsVendorDir(s.RootDir(), project.NewPath("/modules"), modsrc)
+	test.ReadFile(t, clonedir, ".tmskip")
+
+	assertRunResult(t, tmcli.run("list"), runExpected{Stdout: "stack\n"})
+}
+
 func newGitSource(t *testing.T, filename, content string) string {
 	repoSandbox := sandbox.New(t)
 	repoSandbox.RootEntry().CreateFile(filename, content)
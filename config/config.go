@@ -10,6 +10,7 @@ import (
 	"sort"
 	"strings"
 
+	hhcl "github.com/hashicorp/hcl/v2"
 	"github.com/rs/zerolog/log"
 	"github.com/terramate-io/terramate"
 	"github.com/terramate-io/terramate/config/filter"
@@ -36,9 +37,17 @@ const (
 // This type is just for ensure better type checking for the cases where a
 // configuration for the root directory is expected and not from anywhere else.
 type Root struct {
-	tree Tree
+	tree *Tree
 
 	runtime project.Runtime
+
+	// stacksErrs are the errors of the stacks in the terramate.stacks
+	// namespace, by stack dir. The stacksGlobalsErrs are the errors of the
+	// evaluation of their globals, see [Root.WithStacksGlobals].
+	stacksErrs        map[project.Path]error
+	stacksGlobalsErrs map[project.Path]error
+
+	evaluator StackAttrsEvaluator
 }
 
 // Tree is the configuration tree.
@@ -71,7 +80,15 @@ type List[T DirElem] []T
 // the config in fromdir and all parent directories until / is reached.
 // If the configuration is found, it returns the whole configuration tree,
 // configpath != "" and found as true.
+// The stacks cannot have computed attributes, see [TryLoadConfigWithEvaluator].
 func TryLoadConfig(fromdir string) (tree *Root, configpath string, found bool, err error) {
+	return TryLoadConfigWithEvaluator(fromdir, nil)
+}
+
+// TryLoadConfigWithEvaluator try to load the Terramate configuration tree like
+// [TryLoadConfig] but the computed stack attributes are evaluated with the
+// given evaluator, see [LoadRootWithEvaluator].
+func TryLoadConfigWithEvaluator(fromdir string, evaluator StackAttrsEvaluator) (tree *Root, configpath string, found bool, err error) {
 	for {
 		logger := log.With().
 			Str("action", "config.TryLoadConfig()").
@@ -80,7 +97,7 @@ func TryLoadConfig(fromdir string) (tree *Root, configpath string, found bool, e
 
 		logger.Trace().Msg("Parse Terramate config.")
 
-		cfg, err := hcl.ParseDir(fromdir, fromdir)
+		cfg, err := parseDir(fromdir, fromdir, nil, evaluator != nil)
 		if err != nil {
 			// the imports only works for the correct rootdir.
 			// As we are looking for the rootdir, we should ignore ErrImport
@@ -89,11 +106,15 @@ func TryLoadConfig(fromdir string) (tree *Root, configpath string, found bool, e
 				return nil, "", false, err
 			}
 		} else if cfg.Terramate != nil && cfg.Terramate.Config != nil {
-			tree, err := loadTree(fromdir, fromdir, &cfg)
+			tree, err := loadTree(fromdir, fromdir, &cfg, nil, evaluator != nil)
 			if err != nil {
 				return nil, fromdir, true, err
 			}
-			return NewRoot(tree), fromdir, true, err
+			root, err := newRoot(tree, evaluator)
+			if err != nil {
+				return nil, fromdir, true, err
+			}
+			return root, fromdir, true, nil
 		}
 
 		parent, ok := parentDir(fromdir)
@@ -106,25 +127,61 @@ func TryLoadConfig(fromdir string) (tree *Root, configpath string, found bool, e
 }
 
 // NewRoot creates a new [Root] tree for the cfg tree.
+// The computed stack attributes are not evaluated.
 func NewRoot(tree *Tree) *Root {
 	r := &Root{
-		tree: *tree,
+		tree: tree,
 	}
+	setStackDefaults(r.tree, nil)
 	r.initRuntime()
 	return r
 }
 
+func newRoot(tree *Tree, evaluator StackAttrsEvaluator) (*Root, error) {
+	r := NewRoot(tree)
+	r.evaluator = evaluator
+	if err := r.evalComputedStackAttrs(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
 // LoadRoot loads the root configuration tree.
+// The stacks cannot have attributes referencing globals or the terramate
+// metadata, loading them fails. See [LoadRootWithEvaluator].
 func LoadRoot(rootdir string) (*Root, error) {
-	cfgtree, err := LoadTree(rootdir, rootdir)
+	return LoadRootOverlayWithEvaluator(rootdir, nil, nil)
+}
+
+// LoadRootOverlay loads the root configuration tree like [LoadRoot] but the
+// content of the files in the overlay is used instead of their content on
+// disk, like the unsaved documents of an editor. The overlay is not used by
+// [Root.LoadSubTree].
+func LoadRootOverlay(rootdir string, overlay map[string][]byte) (*Root, error) {
+	return LoadRootOverlayWithEvaluator(rootdir, overlay, nil)
+}
+
+// LoadRootWithEvaluator loads the root configuration tree like [LoadRoot] but
+// the computed attributes of the stacks are evaluated with the given
+// evaluator, which is also used for the stacks loaded later by
+// [Root.LoadSubTree]. See [hcl.Stack.Computed].
+func LoadRootWithEvaluator(rootdir string, evaluator StackAttrsEvaluator) (*Root, error) {
+	return LoadRootOverlayWithEvaluator(rootdir, nil, evaluator)
+}
+
+// LoadRootOverlayWithEvaluator loads the root configuration tree with the
+// overlay, like [LoadRootOverlay], and evaluates the computed attributes of
+// the stacks with the given evaluator, like [LoadRootWithEvaluator].
+func LoadRootOverlayWithEvaluator(rootdir string, overlay map[string][]byte, evaluator StackAttrsEvaluator) (*Root, error) {
+	cfgtree, err := loadTree(rootdir, rootdir, nil, overlay, evaluator != nil)
 	if err != nil {
 		return nil, err
 	}
-	return NewRoot(cfgtree), nil
+	return newRoot(cfgtree, evaluator)
 }
 
 // Tree returns the root configuration tree.
-func (root *Root) Tree() *Tree { return &root.tree }
+func (root *Root) Tree() *Tree { return root.tree }
 
 // HostDir returns the root directory.
 func (root *Root) HostDir() string { return root.tree.RootDir() }
@@ -194,6 +251,8 @@ func (root *Root) StacksByTagsFilters(filters []string) (project.Paths, error) {
 }
 
 // LoadSubTree loads a subtree located at cfgdir into the current tree.
+// The computed attributes of the loaded stacks are evaluated with the
+// evaluator of the root.
 func (root *Root) LoadSubTree(cfgdir project.Path) error {
 	var parent project.Path
 
@@ -220,19 +279,25 @@ func (root *Root) LoadSubTree(cfgdir project.Path) error {
 	nextComponent := components[0]
 	subtreeDir := filepath.Join(rootdir, parent.String(), nextComponent)
 
-	node, err := LoadTree(rootdir, subtreeDir)
+	node, err := loadTree(rootdir, subtreeDir, nil, nil, root.evaluator != nil)
 	if err != nil {
 		return errors.E(err, "failed to load config from %s", subtreeDir)
 	}
 
 	if node.HostDir() == rootdir {
 		// root configuration reloaded
-		*root = *NewRoot(node)
-	} else {
-		node.Parent = parentNode
-		parentNode.Children[nextComponent] = node
+		newroot, err := newRoot(node, root.evaluator)
+		if err != nil {
+			return err
+		}
+		*root = *newroot
+		return nil
 	}
-	return nil
+
+	node.Parent = parentNode
+	parentNode.Children[nextComponent] = node
+	setStackDefaults(node, parentStackDefaults(node))
+	return root.evalComputedStackAttrs()
 }
 
 // Stacks return the stacks paths.
@@ -261,20 +326,168 @@ func (root *Root) initRuntime() {
 	rootNS := cty.ObjectVal(map[string]cty.Value{
 		"path": rootpath,
 	})
-	stacksNs := cty.ObjectVal(map[string]cty.Value{
-		"list": toCtyStringList(root.Stacks().Strings()),
-	})
 	root.runtime = project.Runtime{
 		"root":    rootNS,
-		"stacks":  stacksNs,
+		"stacks":  root.stacksRuntime(nil),
 		"version": cty.StringVal(terramate.Version()),
 	}
 }
 
+// WithStacksGlobals returns a copy of the root where the metadata of each
+// stack in the terramate.stacks.by_id and terramate.stacks.by_path namespaces
+// also has the given evaluated globals of the stack, in the global attribute.
+// The errs are the errors evaluating the globals of the stacks, which are
+// reported when the globals of the stack are accessed, see
+// [Root.StacksNamespaceErr].
+// The returned root shares the configuration tree with the original root.
+func (root *Root) WithStacksGlobals(globals map[project.Path]cty.Value, errs map[project.Path]error) *Root {
+	newroot := &Root{
+		tree:              root.tree,
+		evaluator:         root.evaluator,
+		stacksGlobalsErrs: errs,
+	}
+	newroot.runtime = root.Runtime()
+	newroot.runtime["stacks"] = newroot.stacksRuntime(globals)
+	return newroot
+}
+
+// StacksNamespaceErr returns the error of the stack accessed by the given
+// terramate.stacks.by_id or terramate.stacks.by_path traversal. The stacks
+// with an invalid configuration are not part of the namespace, so accessing
+// them fails with their configuration error instead. Accessing the globals of
+// a stack whose globals failed to evaluate fails with the evaluation error.
+// It returns nil if the traversal doesn't access a failed stack.
+func (root *Root) StacksNamespaceErr(traversal hhcl.Traversal) error {
+	if traversal.RootName() != "terramate" || len(traversal) < 4 ||
+		traversalStep(traversal[1]) != "stacks" {
+		return nil
+	}
+
+	key := traversalStep(traversal[3])
+	if key == "" {
+		return nil
+	}
+
+	var stackdir project.Path
+	switch traversalStep(traversal[2]) {
+	case "by_path":
+		stackdir = project.NewPath(key)
+	case "by_id":
+		dir, ok := root.stackDirByID(key)
+		if !ok {
+			return nil
+		}
+		stackdir = dir
+	default:
+		return nil
+	}
+
+	if err, ok := root.stacksErrs[stackdir]; ok {
+		return err
+	}
+	if len(traversal) > 4 && traversalStep(traversal[4]) == "global" {
+		if err, ok := root.stacksGlobalsErrs[stackdir]; ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// stackDirByID returns the dir of the first stack with the given ID, as it
+// is defined in the configuration.
+func (root *Root) stackDirByID(id string) (project.Path, bool) {
+	for _, stackNode := range root.tree.Stacks() {
+		if stackNode.Node.Stack.ID == id {
+			return stackNode.Dir(), true
+		}
+	}
+	return project.Path{}, false
+}
+
+// stacksRuntime returns the terramate.stacks namespace. The by_id and by_path
+// metadata of the stacks is only available if cross stack references are
+// enabled in the project. Stacks with an invalid configuration are not added
+// to by_id and by_path, their errors are reported when they are accessed,
+// see [Root.StacksNamespaceErr]. Stacks with computed attributes not
+// evaluated yet are also not added, the namespace is created again once they
+// are evaluated. If multiple stacks have the same ID, only the first one is
+// added to by_id.
+func (root *Root) stacksRuntime(globals map[project.Path]cty.Value) cty.Value {
+	ns := map[string]cty.Value{
+		"list": toCtyStringList(root.Stacks().Strings()),
+	}
+
+	root.stacksErrs = nil
+	if !root.tree.Node.HasCrossStackReferences() {
+		return cty.ObjectVal(ns)
+	}
+
+	byID := map[string]cty.Value{}
+	byPath := map[string]cty.Value{}
+	seenIDs := map[string]struct{}{}
+	for _, stackNode := range root.tree.Stacks() {
+		if len(stackNode.Node.Stack.Computed) > 0 {
+			continue
+		}
+		st, err := NewStackFromHCL(root.HostDir(), stackNode.Node)
+		if err != nil {
+			if root.stacksErrs == nil {
+				root.stacksErrs = map[project.Path]error{}
+			}
+			root.stacksErrs[stackNode.Dir()] = err
+			continue
+		}
+		meta := map[string]cty.Value{
+			"name":        cty.StringVal(st.Name),
+			"description": cty.StringVal(st.Description),
+			"tags":        toCtyStringList(st.Tags),
+			"path": cty.ObjectVal(map[string]cty.Value{
+				"absolute": cty.StringVal(st.Dir.String()),
+				"relative": cty.StringVal(st.RelPath()),
+				"basename": cty.StringVal(st.PathBase()),
+			}),
+		}
+		if st.ID != "" {
+			meta["id"] = cty.StringVal(st.ID)
+		}
+		if stackGlobals, ok := globals[st.Dir]; ok {
+			meta["global"] = stackGlobals
+		}
+		metaVal := cty.ObjectVal(meta)
+		byPath[st.Dir.String()] = metaVal
+		if st.ID != "" {
+			id := strings.ToLower(st.ID)
+			if _, ok := seenIDs[id]; !ok {
+				seenIDs[id] = struct{}{}
+				byID[st.ID] = metaVal
+			}
+		}
+	}
+	ns["by_id"] = cty.ObjectVal(byID)
+	ns["by_path"] = cty.ObjectVal(byPath)
+	return cty.ObjectVal(ns)
+}
+
+// traversalStep returns the name of an attribute step or the key of a string
+// index step of a traversal. It returns an empty string for other steps.
+func traversalStep(step hhcl.Traverser) string {
+	switch s := step.(type) {
+	case hhcl.TraverseRoot:
+		return s.Name
+	case hhcl.TraverseAttr:
+		return s.Name
+	case hhcl.TraverseIndex:
+		if s.Key.Type() == cty.String && s.Key.IsKnown() && !s.Key.IsNull() {
+			return s.Key.AsString()
+		}
+	}
+	return ""
+}
+
 // LoadTree loads the whole hierarchical configuration from cfgdir downwards
 // using rootdir as project root.
 func LoadTree(rootdir string, cfgdir string) (*Tree, error) {
-	return loadTree(rootdir, cfgdir, nil)
+	return loadTree(rootdir, cfgdir, nil, nil, false)
 }
 
 // HostDir is the node absolute directory in the host.
@@ -367,7 +580,7 @@ func (l List[T]) Len() int           { return len(l) }
 func (l List[T]) Less(i, j int) bool { return l[i].Dir().String() < l[j].Dir().String() }
 func (l List[T]) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
 
-func loadTree(rootdir string, cfgdir string, rootcfg *hcl.Config) (_ *Tree, err error) {
+func loadTree(rootdir string, cfgdir string, rootcfg *hcl.Config, overlay map[string][]byte, computedAttrs bool) (_ *Tree, err error) {
 	logger := log.With().
 		Str("action", "config.loadTree()").
 		Str("dir", rootdir).
@@ -400,7 +613,7 @@ func loadTree(rootdir string, cfgdir string, rootcfg *hcl.Config) (_ *Tree, err
 	if rootcfg != nil {
 		tree.Node = *rootcfg
 	} else {
-		cfg, err := hcl.ParseDir(rootdir, cfgdir)
+		cfg, err := parseDir(rootdir, cfgdir, overlay, computedAttrs)
 		if err != nil {
 			return nil, err
 		}
@@ -428,7 +641,7 @@ func loadTree(rootdir string, cfgdir string, rootcfg *hcl.Config) (_ *Tree, err
 
 		logger.Trace().Msg("loading children tree")
 
-		node, err := LoadTree(rootdir, dir)
+		node, err := loadTree(rootdir, dir, nil, overlay, computedAttrs)
 		if err != nil {
 			return nil, errors.E(err, "loading from %s", dir)
 		}
@@ -439,6 +652,24 @@ func loadTree(rootdir string, cfgdir string, rootcfg *hcl.Config) (_ *Tree, err
 	return tree, nil
 }
 
+// parseDir parses the configuration of the dir. The computed stack attributes
+// are only accepted when computedAttrs is true.
+func parseDir(rootdir string, dir string, overlay map[string][]byte, computedAttrs bool) (hcl.Config, error) {
+	p, err := hcl.NewTerramateParser(rootdir, dir)
+	if err != nil {
+		return hcl.Config{}, err
+	}
+	p.SetOverlay(overlay)
+	if computedAttrs {
+		p.EnableComputedStackAttrs()
+	}
+	err = p.AddDir(dir)
+	if err != nil {
+		return hcl.Config{}, errors.E("adding files to parser", err)
+	}
+	return p.ParseConfig()
+}
+
 // IsEmptyConfig tells if the configuration is empty.
 func (tree *Tree) IsEmptyConfig() bool {
 	return tree.Node.IsEmpty()
@@ -453,6 +684,27 @@ func (tree *Tree) NonEmptyGlobalsParent() *Tree {
 	return parent
 }
 
+// VendoredImports returns the vendored files imported from remote sources by
+// this tree node, which includes the imports of all parent directories.
+// The returned list is sorted and has no duplicates.
+func (tree *Tree) VendoredImports() project.Paths {
+	seen := map[project.Path]struct{}{}
+	var files project.Paths
+	for cfg := tree; cfg != nil; cfg = cfg.Parent {
+		for _, file := range cfg.Node.VendoredImports {
+			if _, ok := seen[file]; ok {
+				continue
+			}
+			seen[file] = struct{}{}
+			files = append(files, file)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].String() < files[j].String()
+	})
+	return files
+}
+
 // IsStack returns true if the given directory is a stack, false otherwise.
 func IsStack(root *Root, dir string) bool {
 	node, ok := root.Lookup(project.PrjAbsPath(root.HostDir(), dir))
@@ -8,12 +8,16 @@ import (
 	"path/filepath"
 	"testing"
 
+	hhcl "github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/madlambda/spells/assert"
 	"github.com/rs/zerolog"
 	"github.com/terramate-io/terramate/config"
 	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/hcl"
 	"github.com/terramate-io/terramate/project"
 	"github.com/terramate-io/terramate/test/sandbox"
+	"github.com/zclconf/go-cty/cty"
 )
 
 func TestIsStack(t *testing.T) {
@@ -113,6 +117,149 @@ func TestConfigLookup(t *testing.T) {
 	assert.EqualStrings(t, "/stacks/child/non-stack/stack", stacks[2].Dir().String())
 }
 
+func TestConfigWithStacksGlobals(t *testing.T) {
+	t.Parallel()
+	s := sandbox.NoGit(t)
+	s.BuildTree([]string{
+		"s:/stacks/a:id=a",
+		"s:/stacks/b",
+		"s:/stacks/c:id=c",
+		`f:terramate.tm:terramate {
+			config {
+				globals {
+					cross_stack_references = true
+				}
+			}
+		}`,
+	})
+
+	root := s.Config()
+	globalsErr := errors.E("globals of stack c failed")
+	newroot := root.WithStacksGlobals(map[project.Path]cty.Value{
+		project.NewPath("/stacks/a"): cty.ObjectVal(map[string]cty.Value{
+			"env": cty.StringVal("prod"),
+		}),
+	}, map[project.Path]error{
+		project.NewPath("/stacks/c"): globalsErr,
+	})
+
+	// the configuration tree is shared, so the parent of the child nodes is
+	// the tree of the new root.
+	assert.IsTrue(t, newroot.Tree() == root.Tree())
+	node, found := newroot.Lookup(project.NewPath("/stacks/a"))
+	assert.IsTrue(t, found)
+	assert.IsTrue(t, node.Parent.Parent == newroot.Tree())
+
+	stacks := newroot.Runtime()["stacks"]
+	env := stacks.GetAttr("by_id").GetAttr("a").GetAttr("global").GetAttr("env")
+	assert.EqualStrings(t, "prod", env.AsString())
+	assert.IsTrue(t, !stacks.GetAttr("by_path").GetAttr("/stacks/b").Type().HasAttribute("global"))
+	assert.IsTrue(t, !root.Runtime()["stacks"].GetAttr("by_id").GetAttr("a").Type().HasAttribute("global"))
+
+	assert.NoError(t, newroot.StacksNamespaceErr(traversal(t, `terramate.stacks.by_id.c.name`)))
+	assert.IsError(t, newroot.StacksNamespaceErr(traversal(t, `terramate.stacks.by_id.c.global.env`)), globalsErr)
+	assert.IsError(t, newroot.StacksNamespaceErr(traversal(t, `terramate.stacks.by_path["/stacks/c"].global`)), globalsErr)
+	assert.NoError(t, root.StacksNamespaceErr(traversal(t, `terramate.stacks.by_id.c.global.env`)))
+}
+
+func TestConfigStacksNamespace(t *testing.T) {
+	t.Parallel()
+
+	const crossStackRefsCfg = `f:terramate.tm:terramate {
+		config {
+			globals {
+				cross_stack_references = true
+			}
+		}
+	}`
+
+	t.Run("by_id and by_path are only available with cross_stack_references", func(t *testing.T) {
+		t.Parallel()
+		s := sandbox.NoGit(t)
+		s.BuildTree([]string{"s:/stacks/a:id=a"})
+
+		stacks := s.Config().Runtime()["stacks"]
+		assert.IsTrue(t, stacks.Type().HasAttribute("list"))
+		assert.IsTrue(t, !stacks.Type().HasAttribute("by_id"))
+		assert.IsTrue(t, !stacks.Type().HasAttribute("by_path"))
+	})
+
+	t.Run("invalid stack is reported when accessed", func(t *testing.T) {
+		t.Parallel()
+		s := sandbox.NoGit(t)
+		s.BuildTree([]string{
+			"s:/stacks/a:id=a",
+			"s:/stacks/invalid:id=cachaça",
+			crossStackRefsCfg,
+		})
+
+		root, err := config.LoadRoot(s.RootDir())
+		assert.NoError(t, err)
+
+		stacks := root.Runtime()["stacks"]
+		assert.IsTrue(t, stacks.GetAttr("by_id").Type().HasAttribute("a"))
+		assert.IsTrue(t, !stacks.GetAttr("by_id").Type().HasAttribute("cachaça"))
+
+		assert.NoError(t, root.StacksNamespaceErr(traversal(t, `terramate.stacks.by_id.a.name`)))
+		assert.IsError(t, root.StacksNamespaceErr(traversal(t, `terramate.stacks.by_id["cachaça"].name`)),
+			errors.E(config.ErrStackValidation))
+		assert.IsError(t, root.StacksNamespaceErr(traversal(t, `terramate.stacks.by_path["/stacks/invalid"]`)),
+			errors.E(config.ErrStackValidation))
+	})
+}
+
+func traversal(t *testing.T, expr string) hhcl.Traversal {
+	t.Helper()
+	trav, diags := hclsyntax.ParseTraversalAbs([]byte(expr), "<test>", hhcl.InitialPos)
+	if diags.HasErrors() {
+		t.Fatal(diags.Error())
+	}
+	return trav
+}
+
+func TestConfigImportConditionStackMetadata(t *testing.T) {
+	t.Parallel()
+
+	const importCfg = `import {
+		source    = "/imports/aws.tm.hcl"
+		condition = tm_contains(terramate.stack.tags, "aws")
+	}`
+
+	layout := []string{
+		`f:imports/aws.tm.hcl:globals {
+			cloud = "aws"
+		}`,
+		`s:envs/aws:tags=["aws"]`,
+		`s:envs/gcp:tags=["gcp"]`,
+	}
+
+	t.Run("import condition is evaluated for the stack dir", func(t *testing.T) {
+		t.Parallel()
+		s := sandbox.NoGit(t)
+		s.BuildTree(layout)
+		s.DirEntry("envs/aws").CreateFile("import.tm", importCfg)
+		s.DirEntry("envs/gcp").CreateFile("import.tm", importCfg)
+
+		root, err := config.LoadRoot(s.RootDir())
+		assert.NoError(t, err)
+
+		aws, _ := root.Lookup(project.NewPath("/envs/aws"))
+		assert.EqualInts(t, 1, len(aws.Node.Globals.AsList()))
+		gcp, _ := root.Lookup(project.NewPath("/envs/gcp"))
+		assert.EqualInts(t, 0, len(gcp.Node.Globals.AsList()))
+	})
+
+	t.Run("import condition of parent dir has no stack metadata", func(t *testing.T) {
+		t.Parallel()
+		s := sandbox.NoGit(t)
+		s.BuildTree(layout)
+		s.DirEntry("envs").CreateFile("import.tm", importCfg)
+
+		_, err := config.LoadRoot(s.RootDir())
+		assert.IsError(t, err, errors.E(hcl.ErrImport))
+	})
+}
+
 func TestConfigStacksByPaths(t *testing.T) {
 	type testcase struct {
 		name     string
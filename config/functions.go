@@ -0,0 +1,82 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	hhcl "github.com/hashicorp/hcl/v2"
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/hcl"
+	"github.com/terramate-io/terramate/hcl/eval"
+	"github.com/terramate-io/terramate/stdlib"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// Functions returns the user functions visible in the tree directory, by
+// their tm_ prefixed name. They are the functions defined in the directory
+// and in its parent directories, with the functions of the closest directory
+// taking precedence.
+//
+// The functions are lexically scoped, so the result of a function can only
+// call the functions visible in the directory defining it.
+func (tree *Tree) Functions() map[string]function.Function {
+	funcs := map[string]function.Function{}
+	if tree.Parent != nil {
+		for name, fn := range tree.Parent.Functions() {
+			funcs[name] = fn
+		}
+	}
+	if len(tree.Node.Functions) == 0 {
+		return funcs
+	}
+
+	scope := stdlib.Functions(tree.HostDir())
+	for _, fn := range tree.Node.Functions {
+		funcs[stdlib.Name(fn.Name)] = newUserFunction(fn, scope)
+	}
+	for name, fn := range funcs {
+		scope[name] = fn
+	}
+	return funcs
+}
+
+// SetFunctions sets the user functions visible in the tree directory into the
+// evaluation context. See [Tree.Functions].
+func (tree *Tree) SetFunctions(ctx *eval.Context) {
+	for name, fn := range tree.Functions() {
+		ctx.SetFunction(name, fn)
+	}
+}
+
+// newUserFunction creates the function defined by the function block, which
+// result is evaluated with the given functions in scope.
+func newUserFunction(fn hcl.Function, scope map[string]function.Function) function.Function {
+	params := make([]function.Parameter, len(fn.Params))
+	for i, name := range fn.Params {
+		params[i] = function.Parameter{
+			Name:             name,
+			Type:             cty.DynamicPseudoType,
+			AllowNull:        true,
+			AllowDynamicType: true,
+		}
+	}
+	return function.New(&function.Spec{
+		Params: params,
+		Type:   function.StaticReturnType(cty.DynamicPseudoType),
+		Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+			vars := map[string]cty.Value{}
+			for i, name := range fn.Params {
+				vars[name] = args[i]
+			}
+			val, diags := fn.Result.Value(&hhcl.EvalContext{
+				Variables: vars,
+				Functions: scope,
+			})
+			if diags.HasErrors() {
+				return cty.NilVal, errors.E(diags, "evaluating %s", stdlib.Name(fn.Name))
+			}
+			return val, nil
+		},
+	})
+}
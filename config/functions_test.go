@@ -0,0 +1,169 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package config_test
+
+import (
+	"testing"
+
+	"github.com/madlambda/spells/assert"
+	"github.com/terramate-io/terramate/config"
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/hcl/eval"
+	"github.com/terramate-io/terramate/project"
+	"github.com/terramate-io/terramate/stdlib"
+	"github.com/terramate-io/terramate/test"
+	errtest "github.com/terramate-io/terramate/test/errors"
+	"github.com/terramate-io/terramate/test/sandbox"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestUserFunctions(t *testing.T) {
+	t.Parallel()
+
+	type testcase struct {
+		name    string
+		layout  []string
+		dir     string
+		expr    string
+		want    cty.Value
+		wantErr error
+	}
+
+	for _, tc := range []testcase{
+		{
+			name: "function defined in the directory",
+			layout: []string{
+				`f:funcs.tm:function "slug" {
+					params = ["name", "sep"]
+					result = tm_replace(tm_lower(name), " ", sep)
+				}`,
+			},
+			dir:  "/",
+			expr: `tm_slug("My Stack", "-")`,
+			want: cty.StringVal("my-stack"),
+		},
+		{
+			name: "function defined in parent directory",
+			layout: []string{
+				`f:funcs.tm:function "double" {
+					params = ["x"]
+					result = x * 2
+				}`,
+				"d:dir/child",
+			},
+			dir:  "/dir/child",
+			expr: `tm_double(21)`,
+			want: cty.NumberIntVal(42),
+		},
+		{
+			name: "function not visible in parent directory",
+			layout: []string{
+				`f:dir/funcs.tm:function "double" {
+					params = ["x"]
+					result = x * 2
+				}`,
+			},
+			dir:     "/",
+			expr:    `tm_double(21)`,
+			wantErr: errors.E(eval.ErrEval),
+		},
+		{
+			name: "child function overrides parent function",
+			layout: []string{
+				`f:funcs.tm:function "env" {
+					result = "default"
+				}`,
+				`f:dir/funcs.tm:function "env" {
+					result = "dir"
+				}`,
+			},
+			dir:  "/dir",
+			expr: `tm_env()`,
+			want: cty.StringVal("dir"),
+		},
+		{
+			name: "functions are lexically scoped",
+			layout: []string{
+				`f:funcs.tm:
+				function "name" {
+					result = "root"
+				}
+				function "greet" {
+					result = "hello ${tm_name()}"
+				}`,
+				`f:dir/funcs.tm:function "name" {
+					result = "dir"
+				}`,
+			},
+			dir:  "/dir",
+			expr: `"${tm_greet()} and ${tm_name()}"`,
+			want: cty.StringVal("hello root and dir"),
+		},
+		{
+			name: "function calling parent function",
+			layout: []string{
+				`f:funcs.tm:function "double" {
+					params = ["x"]
+					result = x * 2
+				}`,
+				`f:dir/funcs.tm:function "quadruple" {
+					params = ["x"]
+					result = tm_double(tm_double(x))
+				}`,
+			},
+			dir:  "/dir",
+			expr: `tm_quadruple(2)`,
+			want: cty.NumberIntVal(8),
+		},
+		{
+			name: "function with wrong number of arguments fails",
+			layout: []string{
+				`f:funcs.tm:function "double" {
+					params = ["x"]
+					result = x * 2
+				}`,
+			},
+			dir:     "/",
+			expr:    `tm_double(1, 2)`,
+			wantErr: errors.E(eval.ErrEval),
+		},
+		{
+			name: "function failing evaluation fails",
+			layout: []string{
+				`f:funcs.tm:function "double" {
+					params = ["x"]
+					result = x * 2
+				}`,
+			},
+			dir:     "/",
+			expr:    `tm_double("a")`,
+			wantErr: errors.E(eval.ErrEval),
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := sandbox.NoGit(t)
+			s.BuildTree(tc.layout)
+			root, err := config.LoadRoot(s.RootDir())
+			assert.NoError(t, err)
+
+			tree, ok := root.Lookup(project.NewPath(tc.dir))
+			assert.IsTrue(t, ok)
+
+			ctx := eval.NewContext(stdlib.Functions(tree.HostDir()))
+			tree.SetFunctions(ctx)
+
+			got, err := ctx.Eval(test.NewExpr(t, tc.expr))
+			errtest.Assert(t, err, tc.wantErr)
+			if tc.wantErr != nil {
+				return
+			}
+			if !got.RawEquals(tc.want) {
+				t.Fatalf("got %s but want %s", got.GoString(), tc.want.GoString())
+			}
+		})
+	}
+}
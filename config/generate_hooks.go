@@ -0,0 +1,102 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/hcl"
+	"github.com/terramate-io/terramate/hcl/eval"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// GenerateHooks represents the evaluated post_process and validate
+// commands of a generate block.
+type GenerateHooks struct {
+	// PostProcess is the list of commands that the generated content is piped
+	// through, in order. Each command receives the content on stdin and must
+	// write the final content on stdout.
+	PostProcess [][]string
+
+	// Validate is the command that validates the final generated content,
+	// which is received on stdin. Generation fails if the command fails.
+	Validate []string
+}
+
+// Empty returns true if no hook is defined.
+func (hooks GenerateHooks) Empty() bool {
+	return len(hooks.PostProcess) == 0 && len(hooks.Validate) == 0
+}
+
+// EvalGenerateHooks evaluates the post_process and validate attributes of a
+// generate block. The post_process attribute must be a list of commands and
+// the validate attribute must be a single command, where a command is a
+// non-empty list of strings. Any of the attributes can be nil.
+func EvalGenerateHooks(
+	evalctx *eval.Context,
+	postProcess *hclsyntax.Attribute,
+	validate *hclsyntax.Attribute,
+) (GenerateHooks, error) {
+	hooks := GenerateHooks{}
+	errs := errors.L()
+
+	if postProcess != nil {
+		val, err := evalctx.Eval(postProcess.Expr)
+		if err != nil {
+			errs.Append(errors.E(err, "evaluating post_process"))
+		} else if val.IsNull() {
+			// no commands
+		} else if !val.Type().IsTupleType() && !val.Type().IsListType() {
+			errs.Append(errors.E(ErrSchema, postProcess.Expr.Range(),
+				"post_process must be a list of commands, got %v",
+				val.Type().FriendlyName()))
+		} else {
+			it := val.ElementIterator()
+			for it.Next() {
+				_, elem := it.Element()
+				cmd, err := commandFromValue(elem)
+				if err != nil {
+					errs.Append(errors.E(ErrSchema, postProcess.Expr.Range(), err,
+						"post_process"))
+					continue
+				}
+				hooks.PostProcess = append(hooks.PostProcess, cmd)
+			}
+		}
+	}
+
+	if validate != nil {
+		val, err := evalctx.Eval(validate.Expr)
+		if err != nil {
+			errs.Append(errors.E(err, "evaluating validate"))
+		} else if !val.IsNull() {
+			cmd, err := commandFromValue(val)
+			if err != nil {
+				errs.Append(errors.E(ErrSchema, validate.Expr.Range(), err,
+					"validate"))
+			} else {
+				hooks.Validate = cmd
+			}
+		}
+	}
+
+	if err := errs.AsError(); err != nil {
+		return GenerateHooks{}, err
+	}
+	return hooks, nil
+}
+
+func commandFromValue(val cty.Value) ([]string, error) {
+	if val.IsNull() || !val.IsKnown() {
+		return nil, errors.E("command must be a non-empty list of strings")
+	}
+	cmd, err := hcl.ValueAsStringList(val)
+	if err != nil {
+		return nil, err
+	}
+	if len(cmd) == 0 || cmd[0] == "" {
+		return nil, errors.E("command must be a non-empty list of strings")
+	}
+	return cmd, nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/hcl"
+	"github.com/terramate-io/terramate/project"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// TemplatePath returns the project path of the template file src referenced
+// by the template attribute of the given generate_file block.
+// A relative path is relative to the directory of the file where the block
+// is defined and an absolute path is relative to the project root.
+func TemplatePath(block hcl.GenFileBlock, src string) (project.Path, error) {
+	relpath := path.Clean(strings.TrimPrefix(src, "/"))
+	if !path.IsAbs(src) {
+		cfgdir := block.Range.Path().Dir()
+		relpath = path.Join(strings.TrimPrefix(cfgdir.String(), "/"), src)
+	}
+	if relpath == ".." || strings.HasPrefix(relpath, "../") {
+		return project.Path{}, errors.E(block.Template.Expr.Range(),
+			"template %q is outside the project", src)
+	}
+	return project.NewPath("/" + relpath), nil
+}
+
+// GenerateTemplates returns the template files of the generate_file blocks
+// with stack context of this tree node, which includes the blocks of all
+// parent directories. Only templates which path is a constant expression are
+// returned, as the others depend on the stack evaluation.
+// The returned list is sorted and has no duplicates.
+func (tree *Tree) GenerateTemplates() project.Paths {
+	seen := map[project.Path]struct{}{}
+	var files project.Paths
+	for cfg := tree; cfg != nil; cfg = cfg.Parent {
+		for _, block := range cfg.Node.Generate.Files {
+			if block.Template == nil || block.Context != hcl.StackContext {
+				continue
+			}
+			val, diags := block.Template.Expr.Value(nil)
+			if diags.HasErrors() || !val.IsKnown() || val.IsNull() || val.Type() != cty.String {
+				continue
+			}
+			file, err := TemplatePath(block, val.AsString())
+			if err != nil {
+				continue
+			}
+			if _, ok := seen[file]; ok {
+				continue
+			}
+			seen[file] = struct{}{}
+			files = append(files, file)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].String() < files[j].String()
+	})
+	return files
+}
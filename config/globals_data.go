@@ -0,0 +1,98 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/hcl/ast"
+	"github.com/terramate-io/terramate/project"
+)
+
+// GlobalsDataFile is a data file loaded into the globals by a
+// globals.data_file block.
+type GlobalsDataFile struct {
+	// Path is the project path of the data file.
+	Path project.Path
+
+	// Format is the data format of the file: json, yaml or tfvars.
+	Format string
+
+	// Block is the data_file block which references the file.
+	Block *ast.Block
+}
+
+// NewGlobalsDataFile creates a [GlobalsDataFile] from the given data_file block.
+// A relative path is relative to the directory of the file where the block
+// is defined and an absolute path is relative to the project root.
+// The format is inferred from the file extension if not explicitly defined.
+func NewGlobalsDataFile(block *ast.Block) (GlobalsDataFile, error) {
+	src := block.Labels[0]
+	relpath := path.Clean(strings.TrimPrefix(src, "/"))
+	if !path.IsAbs(src) {
+		cfgdir := block.Range.Path().Dir()
+		relpath = path.Join(strings.TrimPrefix(cfgdir.String(), "/"), src)
+	}
+	if relpath == ".." || strings.HasPrefix(relpath, "../") {
+		return GlobalsDataFile{}, errors.E(ErrSchema, block.LabelRanges(),
+			"data_file %q is outside the project", src)
+	}
+
+	var format string
+	if attr, ok := block.Attributes["format"]; ok {
+		val, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			return GlobalsDataFile{}, errors.E(ErrSchema, diags)
+		}
+		format = val.AsString()
+	} else {
+		switch path.Ext(src) {
+		case ".json":
+			format = "json"
+		case ".yaml", ".yml":
+			format = "yaml"
+		case ".tfvars":
+			format = "tfvars"
+		default:
+			return GlobalsDataFile{}, errors.E(ErrSchema, block.LabelRanges(),
+				"cannot infer the format of data_file %q, set the format attribute", src)
+		}
+	}
+
+	return GlobalsDataFile{
+		Path:   project.NewPath("/" + relpath),
+		Format: format,
+		Block:  block,
+	}, nil
+}
+
+// GlobalsDataFiles returns the data files loaded into the globals of this
+// tree node, which includes the data files of all parent directories.
+// The returned list is sorted and has no duplicates.
+func (tree *Tree) GlobalsDataFiles() (project.Paths, error) {
+	seen := map[project.Path]struct{}{}
+	var files project.Paths
+	for cfg := tree; cfg != nil; cfg = cfg.Parent {
+		for _, block := range cfg.Node.Globals.AsList() {
+			for _, raw := range block.RawBlocks["data_file"] {
+				datafile, err := NewGlobalsDataFile(raw)
+				if err != nil {
+					return nil, err
+				}
+				if _, ok := seen[datafile.Path]; ok {
+					continue
+				}
+				seen[datafile.Path] = struct{}{}
+				files = append(files, datafile.Path)
+			}
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].String() < files[j].String()
+	})
+	return files, nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/hcl"
+)
+
+// MissingRemoteImports returns the sources of the remote imports of the
+// project which are not vendored yet, sorted and without duplicates.
+// The files of a remote import are unknown until it is vendored, so the
+// remote imports of these files are only returned once they are vendored.
+func MissingRemoteImports(rootdir string) ([]string, error) {
+	seen := map[string]struct{}{}
+	var sources []string
+
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return errors.E(err, "failed to read files in %s", dir)
+		}
+		for _, entry := range entries {
+			if entry.Name() == SkipFilename {
+				return nil
+			}
+		}
+
+		_, missing, err := hcl.ParseDirMissingImports(rootdir, dir)
+		if err != nil {
+			return err
+		}
+		for _, src := range missing {
+			if _, ok := seen[src]; !ok {
+				seen[src] = struct{}{}
+				sources = append(sources, src)
+			}
+		}
+
+		for _, entry := range entries {
+			if Skip(entry.Name()) || !entry.IsDir() {
+				continue
+			}
+			if err := walk(filepath.Join(dir, entry.Name())); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := walk(rootdir); err != nil {
+		return nil, err
+	}
+	sort.Strings(sources)
+	return sources, nil
+}
@@ -54,6 +54,10 @@ type (
 		// Watch is the list of files to be watched for changes.
 		Watch []project.Path
 
+		// Defaults are the directories of the stack_defaults blocks applied
+		// to the stack, sorted from the closest directory to the project root.
+		Defaults project.Paths
+
 		// IsChanged tells if this is a changed stack.
 		IsChanged bool
 	}
@@ -82,16 +86,37 @@ const (
 
 	// ErrStackInvalidWantedBy indicates the stack.wanted_by is invalid.
 	ErrStackInvalidWantedBy errors.Kind = "invalid stack.wanted_by entry"
+
+	// ErrStackInvalidDefaults indicates the stack_defaults applied to the stack are invalid.
+	ErrStackInvalidDefaults errors.Kind = "invalid stack_defaults"
+
+	// ErrStackComputedAttrs indicates the computed attributes of the stack
+	// could not be evaluated.
+	ErrStackComputedAttrs errors.Kind = "evaluating stack computed attributes"
 )
 
 // NewStackFromHCL creates a new stack from raw configuration cfg.
+// The stack_defaults of the parent directories are applied to the attributes
+// not defined in the stack block. See [hcl.Stack.Defaults].
+// It fails if the computed attributes of the stack were not evaluated yet.
+// See [LoadRoot].
 func NewStackFromHCL(root string, cfg hcl.Config) (*Stack, error) {
+	if len(cfg.Stack.Computed) > 0 {
+		return nil, errors.E(ErrStackComputedAttrs,
+			"stack %s has computed attributes not evaluated",
+			project.PrjAbsPath(root, cfg.AbsDir()))
+	}
+	return newStackFromHCL(root, cfg)
+}
+
+func newStackFromHCL(root string, cfg hcl.Config) (*Stack, error) {
 	name := cfg.Stack.Name
 	if name == "" {
 		name = filepath.Base(cfg.AbsDir())
 	}
 
-	watchFiles, err := validateWatchPaths(root, cfg.AbsDir(), cfg.Stack.Watch)
+	stackcfg := withStackDefaults(*cfg.Stack)
+	watchFiles, err := validateWatchPaths(root, cfg.AbsDir(), stackcfg.Watch)
 	if err != nil {
 		return nil, errors.E(err, ErrStackInvalidWatch)
 	}
@@ -100,14 +125,23 @@ func NewStackFromHCL(root string, cfg hcl.Config) (*Stack, error) {
 		Name:        name,
 		ID:          cfg.Stack.ID,
 		Description: cfg.Stack.Description,
-		Tags:        cfg.Stack.Tags,
-		After:       cfg.Stack.After,
-		Before:      cfg.Stack.Before,
-		Wants:       cfg.Stack.Wants,
+		Tags:        stackcfg.Tags,
+		After:       stackcfg.After,
+		Before:      stackcfg.Before,
+		Wants:       stackcfg.Wants,
 		WantedBy:    cfg.Stack.WantedBy,
 		Watch:       watchFiles,
 		Dir:         project.PrjAbsPath(root, cfg.AbsDir()),
 	}
+	for _, defaults := range cfg.Stack.Defaults {
+		stack.Defaults = append(stack.Defaults, defaults.Dir)
+	}
+	if !cfg.Stack.IsDefined("description") {
+		stack.Description, err = evalDefaultDescription(root, stack, cfg.Stack.Defaults)
+		if err != nil {
+			return nil, errors.E(ErrStackInvalidDefaults, err)
+		}
+	}
 	err = stack.Validate()
 	if err != nil {
 		return nil, err
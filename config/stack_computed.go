@@ -0,0 +1,82 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	hhcl "github.com/hashicorp/hcl/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/terramate-io/terramate/errors"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// StackAttrsEvaluator evaluates the computed attributes of the stack and
+// returns their values by attribute name. The given stack only has the
+// attributes which are not computed.
+type StackAttrsEvaluator func(root *Root, stack *Stack, attrs []*hhcl.Attribute) (map[string]cty.Value, error)
+
+// evalComputedStackAttrs evaluates the computed attributes of all stacks with
+// the evaluator of the root and sets the values into the stacks
+// configuration. Stacks without computed attributes, or already evaluated, are
+// ignored. See [hcl.Stack.Computed].
+func (root *Root) evalComputedStackAttrs() error {
+	logger := log.With().
+		Str("action", "root.evalComputedStackAttrs()").
+		Logger()
+
+	if root.evaluator == nil {
+		return nil
+	}
+
+	errs := errors.L()
+	evaluated := false
+	for _, stackNode := range root.tree.Stacks() {
+		stackcfg := stackNode.Node.Stack
+		if len(stackcfg.Computed) == 0 {
+			continue
+		}
+
+		stack, err := newStackFromHCL(root.HostDir(), stackNode.Node)
+		if err != nil {
+			errs.Append(err)
+			continue
+		}
+
+		logger.Trace().
+			Stringer("stack", stack.Dir).
+			Msg("evaluating computed attributes")
+
+		vals, err := root.evaluator(root, stack, stackcfg.Computed)
+		if err != nil {
+			errs.Append(errors.E(ErrStackComputedAttrs, err,
+				"evaluating computed attributes of stack %s", stack.Dir))
+			continue
+		}
+
+		newcfg := *stackcfg
+		newcfg.Computed = nil
+
+		attrErrs := errors.L()
+		for _, attr := range stackcfg.Computed {
+			val, ok := vals[attr.Name]
+			if !ok {
+				attrErrs.Append(errors.E(errors.ErrInternal,
+					"stack.%s attribute not evaluated", attr.Name))
+				continue
+			}
+			attrErrs.Append(newcfg.SetComputedAttr(attr, val))
+		}
+		if err := attrErrs.AsError(); err != nil {
+			errs.AppendWrap(ErrStackComputedAttrs, err)
+			continue
+		}
+
+		*stackcfg = newcfg
+		evaluated = true
+	}
+
+	if evaluated {
+		root.initRuntime()
+	}
+	return errs.AsError()
+}
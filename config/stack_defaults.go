@@ -0,0 +1,111 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"path/filepath"
+
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/hcl"
+	"github.com/terramate-io/terramate/hcl/eval"
+	"github.com/terramate-io/terramate/project"
+	"github.com/terramate-io/terramate/stdlib"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// setStackDefaults sets the stack_defaults blocks of the parent directories
+// into each stack of the tree. The defaults are the stack_defaults blocks of
+// the parent directories of the tree, sorted from the closest directory.
+func setStackDefaults(tree *Tree, defaults []*hcl.StackDefaults) {
+	if tree.Node.Stack != nil {
+		tree.Node.Stack.Defaults = defaults
+	}
+	if tree.Node.StackDefaults != nil {
+		defaults = append([]*hcl.StackDefaults{tree.Node.StackDefaults}, defaults...)
+	}
+	for _, child := range tree.Children {
+		setStackDefaults(child, defaults)
+	}
+}
+
+// parentStackDefaults returns the stack_defaults blocks of the parent
+// directories of the tree, sorted from the closest directory.
+func parentStackDefaults(tree *Tree) []*hcl.StackDefaults {
+	var defaults []*hcl.StackDefaults
+	for parent := tree.Parent; parent != nil; parent = parent.Parent {
+		if parent.Node.StackDefaults != nil {
+			defaults = append(defaults, parent.Node.StackDefaults)
+		}
+	}
+	return defaults
+}
+
+// withStackDefaults returns a copy of the stack block where each attribute
+// not defined in the block is set from the closest stack_defaults defining it.
+// An attribute explicitly defined in the block, even if empty, is never
+// overridden.
+func withStackDefaults(stack hcl.Stack) hcl.Stack {
+	for _, defaults := range stack.Defaults {
+		if !stack.IsDefined("tags") && stack.Tags == nil {
+			stack.Tags = defaults.Tags
+		}
+		if !stack.IsDefined("after") && stack.After == nil {
+			stack.After = defaults.After
+		}
+		if !stack.IsDefined("before") && stack.Before == nil {
+			stack.Before = defaults.Before
+		}
+		if !stack.IsDefined("wants") && stack.Wants == nil {
+			stack.Wants = defaults.Wants
+		}
+		if !stack.IsDefined("watch") && stack.Watch == nil {
+			stack.Watch = defaults.Watch
+		}
+	}
+	return stack
+}
+
+// evalDefaultDescription evaluates the description template of the closest
+// stack_defaults defining it, if any. The template can reference the
+// terramate.stack.id, terramate.stack.name and terramate.stack.path metadata.
+func evalDefaultDescription(rootdir string, stack *Stack, defaults []*hcl.StackDefaults) (string, error) {
+	for _, def := range defaults {
+		if def.Description == nil {
+			continue
+		}
+
+		torel, err := filepath.Rel(project.AbsPath(rootdir, stack.Dir.String()), rootdir)
+		if err != nil {
+			return "", errors.E(errors.ErrInternal, err)
+		}
+		stackVals := map[string]cty.Value{
+			"name": cty.StringVal(stack.Name),
+			"path": cty.ObjectVal(map[string]cty.Value{
+				"absolute": cty.StringVal(stack.Dir.String()),
+				"relative": cty.StringVal(stack.RelPath()),
+				"basename": cty.StringVal(stack.PathBase()),
+				"to_root":  cty.StringVal(filepath.ToSlash(torel)),
+			}),
+		}
+		if stack.ID != "" {
+			stackVals["id"] = cty.StringVal(stack.ID)
+		}
+
+		ctx := eval.NewContext(stdlib.Functions(project.AbsPath(rootdir, stack.Dir.String())))
+		ctx.SetNamespace("terramate", map[string]cty.Value{
+			"stack": cty.ObjectVal(stackVals),
+		})
+		val, err := ctx.Eval(def.Description)
+		if err != nil {
+			return "", errors.E(err, "evaluating stack_defaults.description defined at %s", def.Dir)
+		}
+		if val.Type() != cty.String || val.IsNull() {
+			return "", errors.E(def.Description.Range(),
+				"stack_defaults.description must be a string but is %s",
+				val.Type().FriendlyName())
+		}
+		return val.AsString(), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,197 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package config_test
+
+import (
+	"testing"
+
+	"github.com/madlambda/spells/assert"
+	"github.com/terramate-io/terramate/config"
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/project"
+	"github.com/terramate-io/terramate/test"
+	errtest "github.com/terramate-io/terramate/test/errors"
+	"github.com/terramate-io/terramate/test/sandbox"
+)
+
+func TestStackDefaults(t *testing.T) {
+	t.Parallel()
+
+	type want struct {
+		description string
+		tags        []string
+		after       []string
+		watch       []string
+		defaults    []string
+	}
+
+	type testcase struct {
+		name    string
+		layout  []string
+		stack   string
+		want    want
+		wantErr error
+	}
+
+	for _, tc := range []testcase{
+		{
+			name:   "no stack_defaults",
+			layout: []string{"s:envs/prod/stack"},
+			stack:  "/envs/prod/stack",
+			want: want{
+				defaults: []string{},
+			},
+		},
+		{
+			name: "stack_defaults of parent directory",
+			layout: []string{
+				`f:envs/defaults.tm:stack_defaults {
+					description = "${terramate.stack.name} at ${terramate.stack.path.relative}"
+					tags        = ["prod"]
+					after       = ["/network"]
+					watch       = ["/policies.json"]
+				}`,
+				"s:envs/prod/stack",
+			},
+			stack: "/envs/prod/stack",
+			want: want{
+				description: "stack at envs/prod/stack",
+				tags:        []string{"prod"},
+				after:       []string{"/network"},
+				watch:       []string{"/policies.json"},
+				defaults:    []string{"/envs"},
+			},
+		},
+		{
+			name: "closest stack_defaults overrides parent ones",
+			layout: []string{
+				`f:defaults.tm:stack_defaults {
+					description = "default"
+					tags        = ["root"]
+					after       = ["/network"]
+				}`,
+				`f:envs/prod/defaults.tm:stack_defaults {
+					tags = ["prod"]
+				}`,
+				"s:envs/prod/stack",
+			},
+			stack: "/envs/prod/stack",
+			want: want{
+				description: "default",
+				tags:        []string{"prod"},
+				after:       []string{"/network"},
+				defaults:    []string{"/envs/prod", "/"},
+			},
+		},
+		{
+			name: "stack attributes override stack_defaults",
+			layout: []string{
+				`f:defaults.tm:stack_defaults {
+					description = "default"
+					tags        = ["root"]
+					after       = ["/network"]
+				}`,
+				`f:envs/prod/stack/stack.tm:stack {
+					description = "my stack"
+					tags        = ["mine"]
+				}`,
+			},
+			stack: "/envs/prod/stack",
+			want: want{
+				description: "my stack",
+				tags:        []string{"mine"},
+				after:       []string{"/network"},
+				defaults:    []string{"/"},
+			},
+		},
+		{
+			name: "stack attributes explicitly empty override stack_defaults",
+			layout: []string{
+				`f:defaults.tm:stack_defaults {
+					description = "default"
+					tags        = ["root"]
+					after       = ["/network"]
+				}`,
+				`f:stack/stack.tm:stack {
+					description = ""
+					tags        = []
+				}`,
+			},
+			stack: "/stack",
+			want: want{
+				after:    []string{"/network"},
+				defaults: []string{"/"},
+			},
+		},
+		{
+			name: "invalid tags from stack_defaults fails",
+			layout: []string{
+				`f:defaults.tm:stack_defaults {
+					tags = ["_invalid"]
+				}`,
+				"s:stack",
+			},
+			stack:   "/stack",
+			wantErr: errors.E(config.ErrStackValidation),
+		},
+		{
+			name: "invalid description template fails",
+			layout: []string{
+				`f:defaults.tm:stack_defaults {
+					description = global.description
+				}`,
+				"s:stack",
+			},
+			stack:   "/stack",
+			wantErr: errors.E(config.ErrStackInvalidDefaults),
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := sandbox.NoGit(t)
+			s.BuildTree(tc.layout)
+			root, err := config.LoadRoot(s.RootDir())
+			assert.NoError(t, err)
+
+			st, err := config.LoadStack(root, project.NewPath(tc.stack))
+			errtest.Assert(t, err, tc.wantErr)
+			if tc.wantErr != nil {
+				return
+			}
+
+			assert.EqualStrings(t, tc.want.description, st.Description)
+			test.AssertDiff(t, st.Tags, tc.want.tags, "tags mismatch")
+			test.AssertDiff(t, st.After, tc.want.after, "after mismatch")
+			if len(st.Watch) > 0 || len(tc.want.watch) > 0 {
+				test.AssertDiff(t, project.Paths(st.Watch).Strings(), tc.want.watch, "watch mismatch")
+			}
+			test.AssertDiff(t, st.Defaults.Strings(), tc.want.defaults, "defaults mismatch")
+		})
+	}
+}
+
+func TestStackDefaultsLoadSubTree(t *testing.T) {
+	t.Parallel()
+
+	s := sandbox.NoGit(t)
+	s.BuildTree([]string{
+		`f:envs/defaults.tm:stack_defaults {
+			tags = ["prod"]
+		}`,
+		"d:envs/prod",
+	})
+	root, err := config.LoadRoot(s.RootDir())
+	assert.NoError(t, err)
+
+	// stacks created after loading the project, eg.: by terramate create.
+	s.BuildTree([]string{"s:envs/prod/stack"})
+	assert.NoError(t, root.LoadSubTree(project.NewPath("/envs/prod/stack")))
+
+	st, err := config.LoadStack(root, project.NewPath("/envs/prod/stack"))
+	assert.NoError(t, err)
+	test.AssertDiff(t, st.Tags, []string{"prod"}, "tags mismatch")
+	test.AssertDiff(t, st.Defaults.Strings(), []string{"/envs"}, "defaults mismatch")
+}
@@ -269,6 +269,7 @@ export default defineConfig({
           { text: 'run', link: 'cmdline/run' },
           { text: 'trigger', link: 'cmdline/trigger' },
           { text: 'vendor download', link: 'cmdline/vendor-download' },
+          { text: 'vendor imports', link: 'cmdline/vendor-imports' },
           { text: 'version', link: 'cmdline/version' },
         ],
       },
@@ -19,7 +19,6 @@ import (
 	"github.com/terramate-io/terramate/event"
 	"github.com/terramate-io/terramate/generate/genfile"
 	"github.com/terramate-io/terramate/generate/genhcl"
-	"github.com/terramate-io/terramate/globals"
 	"github.com/terramate-io/terramate/hcl"
 	"github.com/terramate-io/terramate/hcl/eval"
 	"github.com/terramate-io/terramate/hcl/info"
@@ -66,6 +65,8 @@ type GenFile interface {
 	Condition() bool
 	// Asserts is the origin generate block assert blocks.
 	Asserts() []config.Assert
+	// Hooks is the origin generate block post_process and validate commands.
+	Hooks() config.GenerateHooks
 }
 
 // LoadResult represents all generated files of a specific directory.
@@ -86,6 +87,9 @@ type LoadResult struct {
 // The given vendorDir is used when calculating the vendor path using tm_vendor
 // on the generate blocks.
 //
+// The post_process and validate commands are not run, so the files have the
+// content as evaluated from the generate blocks.
+//
 // If a critical error that fails the loading of all results happens it returns
 // a non-nil error. In this case the error is not specific to generating code
 // for a specific dir.
@@ -95,17 +99,18 @@ func Load(root *config.Root, vendorDir project.Path) ([]LoadResult, error) {
 		return nil, err
 	}
 	results := make([]LoadResult, len(stacks))
+	genroot := codegenRoot(root, stacks)
 
 	for i, st := range stacks {
 		res := LoadResult{Dir: st.Dir()}
-		loadres := globals.ForStack(root, st.Stack)
+		loadres := loadStackGlobals(root, st.Stack)
 		if err := loadres.AsError(); err != nil {
 			res.Err = err
 			results[i] = res
 			continue
 		}
 
-		generated, err := loadStackCodeCfgs(root, st.Stack, loadres.Globals, vendorDir, nil)
+		generated, err := loadStackCodeCfgs(genroot, st.Stack, loadres.Globals, vendorDir, nil)
 		if err != nil {
 			res.Err = errors.E(err, "while loading configs of stack %s", st.Dir())
 			results[i] = res
@@ -121,21 +126,13 @@ func Load(root *config.Root, vendorDir project.Path) ([]LoadResult, error) {
 		}
 		res := LoadResult{Dir: dircfg.Dir()}
 		evalctx := eval.NewContext(stdlib.Functions(dircfg.HostDir()))
+		dircfg.SetFunctions(evalctx)
 
-		var generated []GenFile
-		for _, block := range dircfg.Node.Generate.Files {
-			if block.Context != genfile.RootContext {
-				continue
-			}
-
-			file, err := genfile.Eval(block, evalctx)
-			if err != nil {
-				res.Err = errors.L(res.Err, err).AsError()
-				results = append(results, res)
-				continue
-			}
-
-			generated = append(generated, file)
+		generated, err := loadRootCodeCfgs(root, dircfg, evalctx)
+		if err != nil {
+			res.Err = err
+			results = append(results, res)
+			continue
 		}
 		if len(generated) > 0 {
 			res.Files = generated
@@ -158,10 +155,10 @@ func Load(root *config.Root, vendorDir project.Path) ([]LoadResult, error) {
 //
 // - context=root
 //
-// In this case, all of the generate_file blocks with context=root from the
-// project are loaded, checked for conflicts, evaluated using a "Root Evaluation
-// Context" and generated. A Root Evaluation Context contains just the Project
-// Metadata.
+// In this case, all of the generate_file and generate_hcl blocks with
+// context=root from the project are loaded, checked for conflicts, evaluated
+// using a "Root Evaluation Context" and generated. A Root Evaluation Context
+// contains just the Project Metadata.
 //
 // The given vendorDir is used when calculating the vendor path using tm_vendor
 // on the generate blocks. The vendorRequests channel will be used on tm_vendor
@@ -184,6 +181,46 @@ func Do(
 	return cleanupOrphaned(root, report)
 }
 
+// DoStack will generate code for the stack in the given directory, like Do
+// does for all stacks. The root generate blocks are not generated and the
+// orphaned files of other directories are not cleaned up.
+func DoStack(
+	root *config.Root,
+	dir project.Path,
+	vendorDir project.Path,
+	vendorRequests chan<- event.VendorRequest,
+) Report {
+	report := Report{}
+
+	stacks, err := config.LoadAllStacks(root.Tree())
+	if err != nil {
+		report.BootstrapErr = err
+		return report
+	}
+
+	genroot := codegenRoot(root, stacks)
+
+	for _, elem := range stacks {
+		if elem.Dir() != dir {
+			continue
+		}
+
+		globalsReport := loadStackGlobals(root, elem.Stack)
+		if err := globalsReport.AsError(); err != nil {
+			report.addFailure(elem.Dir(), errors.E(ErrLoadingGlobals, err))
+			return report
+		}
+
+		stackReport := doStackGeneration(genroot, elem.Stack, globalsReport.Globals, vendorDir, vendorRequests)
+		report.addDirReport(elem.Dir(), stackReport)
+		report.sort()
+		return report
+	}
+
+	report.BootstrapErr = errors.E("no stack found at %s", dir)
+	return report
+}
+
 func doStackGeneration(
 	root *config.Root,
 	stack *config.Stack,
@@ -223,6 +260,12 @@ func doStackGeneration(
 		return report
 	}
 
+	generated, err = runHooks(stackpath, generated)
+	if err != nil {
+		report.err = err
+		return report
+	}
+
 	allFiles, err := allStackGeneratedFiles(root, stack.HostDir(root), generated)
 	if err != nil {
 		report.err = errors.E(err, "listing all generated files")
@@ -294,6 +337,12 @@ func doStackGeneration(
 		delete(allFiles, filename)
 	}
 
+	err = saveProcessedManifest(stackpath, hcl.StackContext, generated)
+	if err != nil {
+		report.err = errors.E(err, "saving %s", ProcessedManifestFilename)
+		return report
+	}
+
 	logger.Debug().Msg("finished generating files")
 	return report
 }
@@ -304,8 +353,6 @@ func doRootGeneration(root *config.Root) Report {
 		Logger()
 
 	report := Report{}
-	evalctx := eval.NewContext(stdlib.Functions(root.HostDir()))
-	evalctx.SetNamespace("terramate", root.Runtime())
 
 	var files []GenFile
 	for _, cfg := range root.Tree().AsList() {
@@ -320,12 +367,11 @@ func doRootGeneration(root *config.Root) Report {
 			continue
 		}
 
-		blocks := cfg.Node.Generate.Files
-		if len(blocks) == 0 {
-			continue
-		}
+		evalctx := eval.NewContext(stdlib.Functions(root.HostDir()))
+		evalctx.SetNamespace("terramate", root.Runtime())
+		cfg.SetFunctions(evalctx)
 
-		for _, block := range blocks {
+		for _, block := range cfg.Node.Generate.Files {
 			logger := genFileBlockLogger(logger, block)
 
 			if block.Context != genfile.RootContext {
@@ -337,7 +383,35 @@ func doRootGeneration(root *config.Root) Report {
 			// Here we use path.Clean("/"+path.Dir(label)) to ensure the
 			// report.Dir is always absolute.
 			targetDir := project.NewPath(path.Clean("/" + path.Dir(block.Label)))
-			err := validateRootGenerateBlock(root, block)
+			err := validateRootGenerateBlock(root, block.Label, block.Range)
+			if err != nil {
+				report.addFailure(targetDir, err)
+				return report
+			}
+
+			logger.Debug().Msg("block validated successfully")
+
+			file, err := genfile.Eval(root, block, evalctx)
+			if err != nil {
+				report.addFailure(targetDir, err)
+				return report
+			}
+
+			logger.Debug().Msg("block evaluated successfully")
+
+			files = append(files, file)
+		}
+
+		for _, block := range cfg.Node.Generate.HCLs {
+			logger := genBlockLogger(logger, "generate_hcl", block.Label, block.Context)
+
+			if block.Context != genhcl.RootContext {
+				logger.Debug().Msg("ignoring block")
+				continue
+			}
+
+			targetDir := project.NewPath(path.Clean("/" + path.Dir(block.Label)))
+			err := validateRootGenerateBlock(root, block.Label, block.Range)
 			if err != nil {
 				report.addFailure(targetDir, err)
 				return report
@@ -345,7 +419,9 @@ func doRootGeneration(root *config.Root) Report {
 
 			logger.Debug().Msg("block validated successfully")
 
-			file, err := genfile.Eval(block, evalctx)
+			// generate_hcl blocks can define lets and set functions, so each
+			// block gets its own copy of the root evaluation context.
+			file, err := genhcl.Eval(block, evalctx.Copy())
 			if err != nil {
 				report.addFailure(targetDir, err)
 				return report
@@ -357,7 +433,7 @@ func doRootGeneration(root *config.Root) Report {
 		}
 	}
 
-	logger.Debug().Msg("checking generate_file.context=root conflicts")
+	logger.Debug().Msg("checking context=root conflicts")
 
 	errsmap := checkFileConflict(files)
 	if len(errsmap) > 0 {
@@ -372,7 +448,24 @@ func doRootGeneration(root *config.Root) Report {
 
 	logger.Debug().Msg("no conflicts found")
 
-	generateRootFiles(root, files, &report)
+	processed := make([]GenFile, 0, len(files))
+	for _, file := range files {
+		res, err := runHooks(root.HostDir(), []GenFile{file})
+		if err != nil {
+			targetDir := project.NewPath(path.Clean("/" + path.Dir(file.Label())))
+			report.addFailure(targetDir, err)
+			return report
+		}
+		processed = append(processed, res...)
+	}
+
+	generateRootFiles(root, processed, &report)
+
+	err := saveProcessedManifest(root.HostDir(), hcl.RootContext, processed)
+	if err != nil {
+		report.addFailure(project.NewPath("/"),
+			errors.E(err, "saving %s", ProcessedManifestFilename))
+	}
 	return report
 }
 
@@ -503,8 +596,9 @@ func DetectOutdated(root *config.Root, vendorDir project.Path) ([]string, error)
 
 	logger.Debug().Msg("checking outdated code inside stacks")
 
+	genroot := codegenRoot(root, stacks)
 	for _, stack := range stacks {
-		outdated, err := stackOutdated(root, stack.Stack, vendorDir)
+		outdated, err := stackOutdated(root, genroot, stack.Stack, vendorDir)
 		if err != nil {
 			errs.Append(err)
 			continue
@@ -528,9 +622,17 @@ func DetectOutdated(root *config.Root, vendorDir project.Path) ([]string, error)
 		return outdatedFiles, nil
 	}
 
+	logger.Debug().Msg("checking outdated code generated with context=root")
+
+	outdated, err := rootOutdated(root)
+	if err != nil {
+		errs.Append(err)
+	}
+	outdatedFiles = append(outdatedFiles, outdated...)
+
 	logger.Debug().Msg("checking for orphaned files")
 
-	orphanedFiles, err := ListGenFiles(root, root.HostDir())
+	orphanedFiles, err := listOrphanedGenFiles(root)
 	if err != nil {
 		errs.Append(err)
 	}
@@ -546,9 +648,11 @@ func DetectOutdated(root *config.Root, vendorDir project.Path) ([]string, error)
 
 // stackOutdated will verify if a given stack has outdated code and return a list
 // of filenames that are outdated, ordered lexicographically.
+// The genroot is used for evaluating the generate blocks, see [codegenRoot].
 // If the stack has an invalid configuration it will return an error.
 func stackOutdated(
 	root *config.Root,
+	genroot *config.Root,
 	st *config.Stack,
 	vendorDir project.Path,
 ) ([]string, error) {
@@ -557,13 +661,13 @@ func stackOutdated(
 		Stringer("stack", st).
 		Logger()
 
-	report := globals.ForStack(root, st)
+	report := loadStackGlobals(root, st)
 	if err := report.AsError(); err != nil {
 		return nil, errors.E(err, "checking for outdated code")
 	}
 
 	globals := report.Globals
-	generated, err := loadStackCodeCfgs(root, st, globals, vendorDir, nil)
+	generated, err := loadStackCodeCfgs(genroot, st, globals, vendorDir, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -604,6 +708,11 @@ func updateOutdatedFiles(
 		Str("stack", stackpath).
 		Logger()
 
+	processed, err := loadProcessedManifest(stackpath)
+	if err != nil {
+		return err
+	}
+
 	// So we can properly check blocks with condition false/true in any order
 	blocksCondTrue := map[string]struct{}{}
 
@@ -651,6 +760,22 @@ func updateOutdatedFiles(
 			continue
 		}
 
+		if len(genfile.Hooks().PostProcess) > 0 {
+			// the post_process commands are only run when generating code,
+			// so the code on fs is compared with the hashes recorded when
+			// it was written.
+			if processed.isOutdated(genfile, currentCode) {
+				logger.Debug().Msg("outdated: post processed code on fs differs from the recorded one")
+
+				outdatedFiles.add(filename)
+			} else {
+				logger.Debug().Msg("not outdated: post processed code on fs matches the recorded one")
+
+				outdatedFiles.remove(filename)
+			}
+			continue
+		}
+
 		generatedCode := genfile.Header() + genfile.Body()
 		if generatedCode != currentCode {
 			logger.Debug().Msg("outdated: code on fs differs from generated from config")
@@ -792,6 +917,8 @@ func forEachStack(
 		return report
 	}
 
+	genroot := codegenRoot(root, stacks)
+
 	for _, elem := range stacks {
 		logger := logger.With().
 			Stringer("stack", elem).
@@ -799,7 +926,7 @@ func forEachStack(
 
 		logger.Trace().Msg("Load stack globals.")
 
-		globalsReport := globals.ForStack(root, elem.Stack)
+		globalsReport := loadStackGlobals(root, elem.Stack)
 		if err := globalsReport.AsError(); err != nil {
 			report.addFailure(elem.Dir(), errors.E(ErrLoadingGlobals, err))
 			continue
@@ -807,7 +934,7 @@ func forEachStack(
 
 		logger.Trace().Msg("Calling stack callback.")
 
-		stackReport := fn(root, elem.Stack, globalsReport.Globals, vendorDir, vendorRequests)
+		stackReport := fn(genroot, elem.Stack, globalsReport.Globals, vendorDir, vendorRequests)
 		report.addDirReport(elem.Dir(), stackReport)
 	}
 
@@ -1076,11 +1203,10 @@ func validateStackGeneratedFiles(root *config.Root, stackpath string, generated
 	return errs.AsError()
 }
 
-func validateRootGenerateBlock(root *config.Root, block hcl.GenFileBlock) error {
-	target := block.Label
+func validateRootGenerateBlock(root *config.Root, target string, blockRange info.Range) error {
 	if !path.IsAbs(target) {
 		return errors.E(
-			ErrInvalidGenBlockLabel, block.Range,
+			ErrInvalidGenBlockLabel, blockRange,
 			"%s: is not an absolute path", target,
 		)
 	}
@@ -1105,7 +1231,7 @@ func validateRootGenerateBlock(root *config.Root, block hcl.GenFileBlock) error
 			}
 			return errors.E(
 				ErrInvalidGenBlockLabel, err,
-				block.Range,
+				blockRange,
 				"%s: checking if dest dir is a symlink",
 				target,
 			)
@@ -1113,7 +1239,7 @@ func validateRootGenerateBlock(root *config.Root, block hcl.GenFileBlock) error
 		if (info.Mode() & fs.ModeSymlink) == fs.ModeSymlink {
 			return errors.E(
 				ErrInvalidGenBlockLabel, err,
-				block.Range,
+				blockRange,
 				"%s: generates code inside a symlink",
 				target,
 			)
@@ -1121,8 +1247,8 @@ func validateRootGenerateBlock(root *config.Root, block hcl.GenFileBlock) error
 
 		if config.IsStack(root, destdir) {
 			return errors.E(ErrInvalidGenBlockLabel,
-				block.Range,
-				"%s: generate_file.context=root generates inside a stack %s",
+				blockRange,
+				"%s: context=root generates inside a stack %s",
 				target,
 				project.PrjAbsPath(root.HostDir(), destdir),
 			)
@@ -1163,24 +1289,31 @@ func (ss *stringSet) slice() []string {
 	return res
 }
 
+// checkFileConflict checks that no two generated files with condition=true
+// have the same target. The labels are cleaned before comparison, so labels
+// like "dir/file" and "dir/./file" are detected as conflicting. This works for
+// both context=stack labels (relative to the stack) and context=root labels
+// (relative to the project root), which allows the detection of conflicts
+// between blocks defined anywhere in the project.
 func checkFileConflict(generated []GenFile) map[string]error {
 	genset := map[string]GenFile{}
 	errsmap := map[string]error{}
 	for _, file := range generated {
-		if other, ok := genset[file.Label()]; ok && file.Condition() {
-			errsmap[file.Label()] = errors.E(ErrConflictingConfig,
+		target := path.Clean(file.Label())
+		if other, ok := genset[target]; ok && file.Condition() {
+			errsmap[target] = errors.E(ErrConflictingConfig,
 				"configs from %q and %q generate a file with same name %q have "+
 					"`condition = true`",
 				file.Range().Path(),
 				other.Range().Path(),
-				file.Label(),
+				target,
 			)
 			continue
 		}
 		if !file.Condition() {
 			continue
 		}
-		genset[file.Label()] = file
+		genset[target] = file
 	}
 	return errsmap
 }
@@ -1235,6 +1368,10 @@ func loadStackCodeCfgs(
 	vendorDir project.Path,
 	vendorRequests chan<- event.VendorRequest,
 ) ([]GenFile, error) {
+	if err := stacksNamespaceErr(root, st); err != nil {
+		return nil, err
+	}
+
 	asserts, err := loadAsserts(root, st, globals)
 	if err != nil {
 		return nil, err
@@ -1276,6 +1413,134 @@ func loadStackCodeCfgs(
 	return genfilesConfigs, nil
 }
 
+// loadRootCodeCfgs evaluates all generate_file and generate_hcl blocks with
+// context=root defined in the given directory config.
+func loadRootCodeCfgs(root *config.Root, cfg *config.Tree, evalctx *eval.Context) ([]GenFile, error) {
+	var generated []GenFile
+
+	errs := errors.L()
+	for _, block := range cfg.Node.Generate.Files {
+		if block.Context != genfile.RootContext {
+			continue
+		}
+
+		file, err := genfile.Eval(root, block, evalctx)
+		if err != nil {
+			errs.Append(err)
+			continue
+		}
+
+		generated = append(generated, file)
+	}
+
+	for _, block := range cfg.Node.Generate.HCLs {
+		if block.Context != genhcl.RootContext {
+			continue
+		}
+
+		file, err := genhcl.Eval(block, evalctx.Copy())
+		if err != nil {
+			errs.Append(err)
+			continue
+		}
+
+		generated = append(generated, file)
+	}
+
+	if err := errs.AsError(); err != nil {
+		return nil, err
+	}
+	return generated, nil
+}
+
+// rootGeneratedTargets returns the target paths of all generate_hcl blocks
+// with context=root in the project. The paths are relative to the project
+// root, using the same format returned by [ListGenFiles], so they can be
+// excluded from the orphaned files.
+func rootGeneratedTargets(root *config.Root) map[string]struct{} {
+	targets := map[string]struct{}{}
+	for _, cfg := range root.Tree().AsList() {
+		if cfg.IsEmptyConfig() || cfg.IsStack() {
+			continue
+		}
+		for _, block := range cfg.Node.Generate.HCLs {
+			if block.Context != genhcl.RootContext {
+				continue
+			}
+			targets[strings.TrimPrefix(path.Clean(block.Label), "/")] = struct{}{}
+		}
+	}
+	return targets
+}
+
+// listOrphanedGenFiles lists all generated files inside the project that are
+// not owned by any stack nor by any generate block with context=root.
+func listOrphanedGenFiles(root *config.Root) ([]string, error) {
+	genfiles, err := ListGenFiles(root, root.HostDir())
+	if err != nil {
+		return nil, err
+	}
+
+	rootTargets := rootGeneratedTargets(root)
+	orphaned := []string{}
+	for _, genfile := range genfiles {
+		if _, ok := rootTargets[genfile]; ok {
+			continue
+		}
+		orphaned = append(orphaned, genfile)
+	}
+	return orphaned, nil
+}
+
+// rootOutdated will verify if the generate blocks with context=root have
+// outdated code and return a list of filenames (relative to the project root)
+// that are outdated.
+func rootOutdated(root *config.Root) ([]string, error) {
+	var generated []GenFile
+	for _, cfg := range root.Tree().AsList() {
+		if cfg.IsEmptyConfig() || cfg.IsStack() {
+			continue
+		}
+		evalctx := eval.NewContext(stdlib.Functions(root.HostDir()))
+		evalctx.SetNamespace("terramate", root.Runtime())
+		cfg.SetFunctions(evalctx)
+
+		files, err := loadRootCodeCfgs(root, cfg, evalctx)
+		if err != nil {
+			return nil, err
+		}
+		generated = append(generated, files...)
+	}
+
+	for _, file := range generated {
+		err := validateRootGenerateBlock(root, file.Label(), file.Range())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	errsmap := checkFileConflict(generated)
+	if len(errsmap) > 0 {
+		errs := errors.L()
+		for _, err := range errsmap {
+			errs.Append(err)
+		}
+		return nil, errs.AsError()
+	}
+
+	outdatedFiles := newStringSet()
+	err := updateOutdatedFiles(root.HostDir(), generated, outdatedFiles)
+	if err != nil {
+		return nil, errors.E(err, "checking for outdated files")
+	}
+
+	outdated := []string{}
+	for _, file := range outdatedFiles.slice() {
+		outdated = append(outdated, strings.TrimPrefix(path.Clean(file), "/"))
+	}
+	return outdated, nil
+}
+
 func cleanupOrphaned(root *config.Root, report Report) Report {
 	logger := log.With().
 		Str("action", "generate.cleanupOrphaned()").
@@ -1290,7 +1555,7 @@ func cleanupOrphaned(root *config.Root, report Report) Report {
 
 	logger.Debug().Msg("listing orphaned generated files")
 
-	orphanedGenFiles, err := ListGenFiles(root, root.HostDir())
+	orphanedGenFiles, err := listOrphanedGenFiles(root)
 	if err != nil {
 		report.CleanupErr = err
 		return report
@@ -16,6 +16,7 @@ import (
 	"github.com/terramate-io/terramate/errors"
 	"github.com/terramate-io/terramate/generate"
 	"github.com/terramate-io/terramate/generate/genhcl"
+	"github.com/terramate-io/terramate/globals"
 	"github.com/terramate-io/terramate/project"
 	"github.com/terramate-io/terramate/test"
 	"github.com/terramate-io/terramate/test/hclwrite"
@@ -470,6 +471,246 @@ func TestGenerateHCL(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "generate HCL with terramate.stacks.by_id and terramate.stacks.by_path",
+			layout: []string{
+				"s:stacks/stack-1:id=stack-1-id",
+				"s:stacks/stack-2",
+			},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: Terramate(
+						Config(
+							Block("globals",
+								Bool("cross_stack_references", true),
+							),
+						),
+					),
+				},
+				{
+					path: "/stacks/stack-2",
+					add: Doc(
+						GenerateHCL(
+							Labels("stacks.hcl"),
+							Content(
+								Expr("name", `terramate.stacks.by_id["stack-1-id"].name`),
+								Expr("path", `terramate.stacks.by_id["stack-1-id"].path.absolute`),
+								Expr("id", `terramate.stacks.by_path["/stacks/stack-1"].id`),
+							),
+						),
+					),
+				},
+			},
+			want: []generatedFile{
+				{
+					dir: "/stacks/stack-2",
+					files: map[string]fmt.Stringer{
+						"stacks.hcl": Doc(
+							Str("id", "stack-1-id"),
+							Str("name", "stack-1"),
+							Str("path", "/stacks/stack-1"),
+						),
+					},
+				},
+			},
+			wantReport: generate.Report{
+				Successes: []generate.Result{
+					{
+						Dir:     project.NewPath("/stacks/stack-2"),
+						Created: []string{"stacks.hcl"},
+					},
+				},
+			},
+		},
+		{
+			name: "generate HCL with globals of other stacks",
+			layout: []string{
+				"s:stacks/stack-1:id=stack-1-id",
+				"s:stacks/stack-2",
+			},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: Terramate(
+						Config(
+							Block("globals",
+								Bool("cross_stack_references", true),
+							),
+						),
+					),
+				},
+				{
+					path: "/stacks",
+					add: Globals(
+						Expr("backend_key", `"${terramate.stack.path.relative}/terraform.tfstate"`),
+					),
+				},
+				{
+					path: "/stacks/stack-2",
+					add: Doc(
+						GenerateHCL(
+							Labels("remote.hcl"),
+							Content(
+								Block("data",
+									Labels("terraform_remote_state", "stack_1"),
+									Str("backend", "gcs"),
+									Block("config",
+										Expr("prefix", `terramate.stacks.by_id["stack-1-id"].global.backend_key`),
+									),
+								),
+							),
+						),
+					),
+				},
+			},
+			want: []generatedFile{
+				{
+					dir: "/stacks/stack-2",
+					files: map[string]fmt.Stringer{
+						"remote.hcl": Doc(
+							Block("data",
+								Labels("terraform_remote_state", "stack_1"),
+								Str("backend", "gcs"),
+								Block("config",
+									Str("prefix", "stacks/stack-1/terraform.tfstate"),
+								),
+							),
+						),
+					},
+				},
+			},
+			wantReport: generate.Report{
+				Successes: []generate.Result{
+					{
+						Dir:     project.NewPath("/stacks/stack-2"),
+						Created: []string{"remote.hcl"},
+					},
+				},
+			},
+		},
+		{
+			name: "generate HCL with lazy evaluated globals of other stacks",
+			layout: []string{
+				"s:stacks/stack-1:id=stack-1-id",
+				"s:stacks/stack-2",
+			},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: Terramate(
+						Config(
+							Block("globals",
+								Bool("lazy_evaluation", true),
+								Bool("cross_stack_references", true),
+							),
+						),
+					),
+				},
+				{
+					path: "/stacks",
+					add: Globals(
+						Expr("backend_key", `"${terramate.stack.path.relative}/terraform.tfstate"`),
+					),
+				},
+				{
+					path: "/stacks/stack-1",
+					add: Globals(
+						Expr("broken", `unknown.attr`),
+					),
+				},
+				{
+					path: "/stacks/stack-2",
+					add: Doc(
+						GenerateHCL(
+							Labels("remote.hcl"),
+							Content(
+								Expr("prefix", `terramate.stacks.by_id["stack-1-id"].global.backend_key`),
+							),
+						),
+					),
+				},
+			},
+			want: []generatedFile{
+				{
+					dir: "/stacks/stack-2",
+					files: map[string]fmt.Stringer{
+						"remote.hcl": Doc(
+							Str("prefix", "stacks/stack-1/terraform.tfstate"),
+						),
+					},
+				},
+			},
+			wantReport: generate.Report{
+				Successes: []generate.Result{
+					{
+						Dir:     project.NewPath("/stacks/stack-2"),
+						Created: []string{"remote.hcl"},
+					},
+				},
+				Failures: []generate.FailureResult{
+					{
+						Result: generate.Result{
+							Dir: project.NewPath("/stacks/stack-1"),
+						},
+						Error: errors.E(globals.ErrEval),
+					},
+				},
+			},
+		},
+		{
+			name: "referencing the failing globals of other stacks reports their error",
+			layout: []string{
+				"s:stacks/stack-1:id=stack-1-id",
+				"s:stacks/stack-2",
+			},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: Terramate(
+						Config(
+							Block("globals",
+								Bool("cross_stack_references", true),
+							),
+						),
+					),
+				},
+				{
+					path: "/stacks/stack-1",
+					add: Globals(
+						Expr("broken", `unknown.attr`),
+					),
+				},
+				{
+					path: "/stacks/stack-2",
+					add: Doc(
+						GenerateHCL(
+							Labels("remote.hcl"),
+							Content(
+								Expr("name", `terramate.stacks.by_id["stack-1-id"].name`),
+								Expr("broken", `terramate.stacks.by_id["stack-1-id"].global.broken`),
+							),
+						),
+					),
+				},
+			},
+			wantReport: generate.Report{
+				Failures: []generate.FailureResult{
+					{
+						Result: generate.Result{
+							Dir: project.NewPath("/stacks/stack-1"),
+						},
+						Error: errors.E(globals.ErrEval),
+					},
+					{
+						Result: generate.Result{
+							Dir: project.NewPath("/stacks/stack-2"),
+						},
+						Error: errors.E(generate.ErrLoadingGlobals),
+					},
+				},
+			},
+		},
 		{
 			name: "generate HCL with stack on root",
 			layout: []string{
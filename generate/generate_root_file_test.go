@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/madlambda/spells/assert"
 	"github.com/terramate-io/terramate/errors"
 	"github.com/terramate-io/terramate/project"
 	. "github.com/terramate-io/terramate/test/hclwrite/hclutils"
@@ -772,3 +773,171 @@ func TestGenerateFileWithRootContextRemoveFilesWhenConditionIsFalse(t *testing.T
 	})
 	assertFileDontExist(filename)
 }
+
+func TestGenerateHCLRootContext(t *testing.T) {
+	t.Parallel()
+
+	testCodeGeneration(t, []testcase{
+		{
+			name: "generate_hcl.context=root generates in target dir",
+			layout: []string{
+				"s:stacks/stack-1",
+				"s:stacks/stack-2",
+			},
+			configs: []hclconfig{
+				{
+					path: "/source",
+					add: GenerateHCL(
+						Labels("/target/stacks.tf"),
+						Expr("context", "root"),
+						Content(
+							Expr("stacks", "terramate.stacks.list"),
+						),
+					),
+				},
+			},
+			want: []generatedFile{
+				{
+					dir: "/target",
+					files: map[string]fmt.Stringer{
+						"stacks.tf": Doc(
+							EvalExpr(t, "stacks", `["/stacks/stack-1", "/stacks/stack-2"]`),
+						),
+					},
+				},
+			},
+			wantReport: generate.Report{
+				Successes: []generate.Result{
+					{
+						Dir:     project.NewPath("/target"),
+						Created: []string{"stacks.tf"},
+					},
+				},
+			},
+		},
+		{
+			name: "generate_hcl.context=root with false condition generates nothing",
+			configs: []hclconfig{
+				{
+					path: "/source",
+					add: GenerateHCL(
+						Labels("/target/file.tf"),
+						Expr("context", "root"),
+						Bool("condition", false),
+						Content(
+							Str("a", "b"),
+						),
+					),
+				},
+			},
+		},
+		{
+			name: "generate_hcl.context=root is disallowed to generate inside stacks",
+			layout: []string{
+				"s:stack",
+			},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: GenerateHCL(
+						Labels("/stack/sub/file.tf"),
+						Expr("context", "root"),
+						Content(
+							Str("a", "b"),
+						),
+					),
+				},
+			},
+			wantReport: generate.Report{
+				Failures: []generate.FailureResult{
+					{
+						Result: generate.Result{
+							Dir: project.NewPath("/stack/sub"),
+						},
+						Error: errors.E(generate.ErrInvalidGenBlockLabel),
+					},
+				},
+			},
+		},
+		{
+			name: "generate_hcl and generate_file with context=root conflicting",
+			configs: []hclconfig{
+				{
+					path: "/dir1",
+					add: GenerateHCL(
+						Labels("/target/file.tf"),
+						Expr("context", "root"),
+						Content(
+							Str("a", "b"),
+						),
+					),
+				},
+				{
+					path: "/dir2",
+					add: GenerateFile(
+						Labels("/target/./file.tf"),
+						Expr("context", "root"),
+						Str("content", "test"),
+					),
+				},
+			},
+			wantReport: generate.Report{
+				Failures: []generate.FailureResult{
+					{
+						Result: generate.Result{
+							Dir: project.NewPath("/target"),
+						},
+						Error: errors.E(generate.ErrConflictingConfig),
+					},
+				},
+			},
+		},
+	})
+}
+
+func TestGenerateHCLRootContextIsNotOrphaned(t *testing.T) {
+	t.Parallel()
+
+	s := sandbox.New(t)
+	s.BuildTree([]string{"s:stack"})
+	s.RootEntry().CreateConfig(
+		GenerateHCL(
+			Labels("/target/file.tf"),
+			Expr("context", "root"),
+			Content(
+				Str("a", "b"),
+			),
+		).String(),
+	)
+
+	report := s.Generate()
+	assertEqualReports(t, report, generate.Report{
+		Successes: []generate.Result{
+			{
+				Dir:     project.NewPath("/target"),
+				Created: []string{"file.tf"},
+			},
+		},
+	})
+
+	outdated, err := generate.DetectOutdated(s.Config(), project.NewPath("/modules"))
+	assert.NoError(t, err)
+	assertEqualStringList(t, outdated, []string{})
+
+	report = s.Generate()
+	assertEqualReports(t, report, generate.Report{})
+
+	s.RootEntry().CreateConfig(
+		GenerateHCL(
+			Labels("/target/file.tf"),
+			Expr("context", "root"),
+			Content(
+				Str("a", "changed"),
+			),
+		).String(),
+	)
+
+	outdated, err = generate.DetectOutdated(s.ReloadConfig(), project.NewPath("/modules"))
+	assert.NoError(t, err)
+	assertEqualStringList(t, outdated, []string{"target/file.tf"})
+}
@@ -6,6 +6,7 @@ package generate_test
 import (
 	"fmt"
 	"io/fs"
+	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -582,6 +583,39 @@ func TestGenerateCleanupFailsToReadFiles(t *testing.T) {
 	assert.Error(t, report.CleanupErr)
 }
 
+func TestGenerateStack(t *testing.T) {
+	t.Parallel()
+
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stacks/a",
+		"s:stacks/b",
+		`f:gen.tm:generate_file "file.txt" {
+		  content = terramate.stack.name
+		}`,
+	})
+
+	report := generate.DoStack(s.Config(), project.NewPath("/stacks/a"),
+		project.NewPath("/modules"), nil)
+	assertEqualReports(t, report, generate.Report{
+		Successes: []generate.Result{
+			{
+				Dir:     project.NewPath("/stacks/a"),
+				Created: []string{"file.txt"},
+			},
+		},
+	})
+
+	test.AssertFileContentEquals(t,
+		filepath.Join(s.RootDir(), "stacks/a/file.txt"), "a")
+	_, err := os.Stat(filepath.Join(s.RootDir(), "stacks/b/file.txt"))
+	assert.IsTrue(t, errors.Is(err, fs.ErrNotExist), "stack b must not be generated")
+
+	report = generate.DoStack(s.Config(), project.NewPath("/stacks"),
+		project.NewPath("/modules"), nil)
+	assert.Error(t, report.BootstrapErr)
+}
+
 func TestGenerateConflictsBetweenGenerateTypes(t *testing.T) {
 	t.Parallel()
 
@@ -828,8 +862,10 @@ func testCodeGeneration(t *testing.T, tcases []testcase) {
 					return nil
 				}
 
-				// sandbox creates README.md inside test dirs
+				// sandbox creates README.md inside test dirs and the
+				// post processed files are recorded on the manifest.
 				if d.Name() == config.DefaultFilename ||
+					d.Name() == generate.ProcessedManifestFilename ||
 					d.Name() == stackpkg.DefaultFilename ||
 					d.Name() == "README.md" ||
 					d.Name() == ".gitignore" {
@@ -6,8 +6,14 @@ package genfile
 
 import (
 	"fmt"
+	"os"
 	"path"
+	"path/filepath"
 	"sort"
+	"strings"
+
+	hhcl "github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
 
 	"github.com/terramate-io/terramate/config"
 	"github.com/terramate-io/terramate/errors"
@@ -41,14 +47,18 @@ const (
 	// ErrLabelConflict indicates the two generate_file blocks
 	// have the same label.
 	ErrLabelConflict errors.Kind = "label conflict detected"
+
+	// ErrInvalidTemplate indicates the template attribute is invalid or
+	// the template file could not be loaded.
+	ErrInvalidTemplate errors.Kind = "invalid template"
 )
 
 const (
 	// StackContext is the stack context name.
-	StackContext = "stack"
+	StackContext = hcl.StackContext
 
 	// RootContext is the root context name.
-	RootContext = "root"
+	RootContext = hcl.RootContext
 )
 
 // File represents generated file from a single generate_file block.
@@ -59,6 +69,7 @@ type File struct {
 	body      string
 	condition bool
 	asserts   []config.Assert
+	hooks     config.GenerateHooks
 }
 
 // Label of the original generate_file block.
@@ -94,6 +105,12 @@ func (f File) Asserts() []config.Assert {
 	return f.asserts
 }
 
+// Hooks returns the evaluated post_process and validate commands of the
+// generate_file block.
+func (f File) Hooks() config.GenerateHooks {
+	return f.hooks
+}
+
 // Header returns the header of this file.
 func (f File) Header() string {
 	// For now we don't support headers for arbitrary files
@@ -144,7 +161,7 @@ func Load(
 
 		evalctx.SetFunction(stdlib.Name("vendor"), stdlib.VendorFunc(vendorTargetDir, vendorDir, vendorRequests))
 
-		file, err := Eval(genFileBlock, evalctx.Context)
+		file, err := Eval(root, genFileBlock, evalctx.Context)
 		if err != nil {
 			return nil, err
 		}
@@ -158,8 +175,8 @@ func Load(
 	return files, nil
 }
 
-// Eval the generate_file block.
-func Eval(block hcl.GenFileBlock, evalctx *eval.Context) (File, error) {
+// Eval the generate_file block of the given project root.
+func Eval(root *config.Root, block hcl.GenFileBlock, evalctx *eval.Context) (File, error) {
 	name := block.Label
 	err := lets.Load(block.Lets, evalctx)
 	if err != nil {
@@ -221,7 +238,22 @@ func Eval(block hcl.GenFileBlock, evalctx *eval.Context) (File, error) {
 		}, nil
 	}
 
-	value, err := evalctx.Eval(block.Content.Expr)
+	hooks, err := config.EvalGenerateHooks(evalctx, block.PostProcess, block.Validate)
+	if err != nil {
+		return File{}, err
+	}
+
+	var contentExpr hhcl.Expression
+	if block.Template != nil {
+		contentExpr, err = loadTemplate(root, block, evalctx)
+		if err != nil {
+			return File{}, err
+		}
+	} else {
+		contentExpr = block.Content.Expr
+	}
+
+	value, err := evalctx.Eval(contentExpr)
 	if err != nil {
 		return File{}, errors.E(ErrContentEval, err)
 	}
@@ -241,9 +273,62 @@ func Eval(block hcl.GenFileBlock, evalctx *eval.Context) (File, error) {
 		condition: condition,
 		context:   block.Context,
 		asserts:   asserts,
+		hooks:     hooks,
 	}, nil
 }
 
+// loadTemplate loads the template file referenced by the template attribute
+// of the block. Relative paths are relative to the directory of the file
+// where the block is defined and absolute paths are relative to the project
+// root. The template cannot reference files outside the project, including
+// through symbolic links.
+func loadTemplate(root *config.Root, block hcl.GenFileBlock, evalctx *eval.Context) (hhcl.Expression, error) {
+	val, err := evalctx.Eval(block.Template.Expr)
+	if err != nil {
+		return nil, errors.E(ErrInvalidTemplate, err)
+	}
+	if val.Type() != cty.String {
+		return nil, errors.E(ErrInvalidTemplate, block.Template.Expr.Range(),
+			"template has type %s but must be string",
+			val.Type().FriendlyName(),
+		)
+	}
+
+	src := val.AsString()
+	tmplpath, err := config.TemplatePath(block, src)
+	if err != nil {
+		return nil, errors.E(ErrInvalidTemplate, err)
+	}
+
+	hostpath := tmplpath.HostPath(root.HostDir())
+	realpath, err := filepath.EvalSymlinks(hostpath)
+	if err != nil {
+		return nil, errors.E(ErrInvalidTemplate, block.Template.Expr.Range(), err,
+			"reading template %q", src)
+	}
+	realroot, err := filepath.EvalSymlinks(root.HostDir())
+	if err != nil {
+		return nil, errors.E(ErrInvalidTemplate, err, "resolving project root")
+	}
+	relpath, err := filepath.Rel(realroot, realpath)
+	if err != nil || relpath == ".." || strings.HasPrefix(relpath, ".."+string(filepath.Separator)) {
+		return nil, errors.E(ErrInvalidTemplate, block.Template.Expr.Range(),
+			"template %q is outside the project", src)
+	}
+
+	content, err := os.ReadFile(realpath)
+	if err != nil {
+		return nil, errors.E(ErrInvalidTemplate, block.Template.Expr.Range(), err,
+			"reading template %q", src)
+	}
+
+	expr, diags := hclsyntax.ParseTemplate(content, hostpath, hhcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, errors.E(ErrInvalidTemplate, diags)
+	}
+	return expr, nil
+}
+
 // loadGenFileBlocks will load all generate_file blocks.
 // The returned map maps the name of the block (its label)
 // to the original block and the path (relative to project root) of the config
@@ -0,0 +1,310 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package genfile_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/madlambda/spells/assert"
+	"github.com/terramate-io/terramate/config"
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/generate/genfile"
+	"github.com/terramate-io/terramate/hcl"
+	"github.com/terramate-io/terramate/project"
+	"github.com/terramate-io/terramate/test"
+	errtest "github.com/terramate-io/terramate/test/errors"
+	. "github.com/terramate-io/terramate/test/hclwrite/hclutils"
+	"github.com/terramate-io/terramate/test/sandbox"
+)
+
+// rawFile is a non-HCL file content. Note that the test helpers always
+// prepend a newline to the file content.
+type rawFile string
+
+func (r rawFile) String() string { return string(r) }
+
+func TestGenerateFileTemplate(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []testcase{
+		{
+			name:  "template relative to the config dir with full stack context",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/stack/globals.tm",
+					add: Globals(
+						Str("name", "app"),
+						Expr("replicas", "3"),
+					),
+				},
+				{
+					path: "/stack/templates/values.yaml.tpl",
+					add: rawFile(`name: ${global.name}
+replicas: ${global.replicas}
+image: ${let.image}
+path: ${terramate.stack.path.absolute}
+upper: ${tm_upper(global.name)}
+%{ for i in tm_range(2) ~}
+- item${i}
+%{ endfor ~}
+`),
+				},
+				{
+					path: "/stack/gen.tm",
+					add: GenerateFile(
+						Labels("values.yaml"),
+						Lets(
+							Str("image", "nginx"),
+						),
+						Str("template", "templates/values.yaml.tpl"),
+					),
+				},
+			},
+			want: []result{
+				{
+					name: "values.yaml",
+					file: genFile{
+						condition: true,
+						body: `
+name: app
+replicas: 3
+image: nginx
+path: /stack
+upper: APP
+- item0
+- item1
+`,
+					},
+				},
+			},
+		},
+		{
+			name:  "template with project absolute path defined on parent dir",
+			stack: "/dir/stack",
+			configs: []hclconfig{
+				{
+					path: "/templates/file.tpl",
+					add:  rawFile(`stack=${terramate.stack.path.basename}`),
+				},
+				{
+					path: "/dir/gen.tm",
+					add: GenerateFile(
+						Labels("file.txt"),
+						Str("template", "/templates/file.tpl"),
+					),
+				},
+			},
+			want: []result{
+				{
+					name: "file.txt",
+					file: genFile{
+						condition: true,
+						body:      "\nstack=stack",
+					},
+				},
+			},
+		},
+		{
+			name:  "template path evaluated with globals",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/globals.tm",
+					add: Globals(
+						Str("tpl", "file.tpl"),
+					),
+				},
+				{
+					path: "/file.tpl",
+					add:  rawFile(`content`),
+				},
+				{
+					path: "/gen.tm",
+					add: GenerateFile(
+						Labels("file.txt"),
+						Expr("template", "global.tpl"),
+					),
+				},
+			},
+			want: []result{
+				{
+					name: "file.txt",
+					file: genFile{
+						condition: true,
+						body:      "\ncontent",
+					},
+				},
+			},
+		},
+		{
+			name:  "template is not loaded if condition is false",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/stack/gen.tm",
+					add: GenerateFile(
+						Labels("file.txt"),
+						Bool("condition", false),
+						Str("template", "not-found.tpl"),
+					),
+				},
+			},
+			want: []result{
+				{
+					name: "file.txt",
+					file: genFile{
+						condition: false,
+					},
+				},
+			},
+		},
+		{
+			name:  "content and template are mutually exclusive",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/stack/gen.tm",
+					add: GenerateFile(
+						Labels("file.txt"),
+						Str("content", "data"),
+						Str("template", "file.tpl"),
+					),
+				},
+			},
+			wantErr: errors.E(hcl.ErrTerramateSchema),
+		},
+		{
+			name:  "template must be a string",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/stack/gen.tm",
+					add: GenerateFile(
+						Labels("file.txt"),
+						Expr("template", "[]"),
+					),
+				},
+			},
+			wantErr: errors.E(genfile.ErrInvalidTemplate),
+		},
+		{
+			name:  "template file not found",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/stack/gen.tm",
+					add: GenerateFile(
+						Labels("file.txt"),
+						Str("template", "not-found.tpl"),
+					),
+				},
+			},
+			wantErr: errors.E(genfile.ErrInvalidTemplate),
+		},
+		{
+			name:  "template outside of the project",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/stack/gen.tm",
+					add: GenerateFile(
+						Labels("file.txt"),
+						Str("template", "../../file.tpl"),
+					),
+				},
+			},
+			wantErr: errors.E(genfile.ErrInvalidTemplate),
+		},
+		{
+			name:  "template with invalid syntax",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/stack/file.tpl",
+					add:  rawFile(`${global.a`),
+				},
+				{
+					path: "/stack/gen.tm",
+					add: GenerateFile(
+						Labels("file.txt"),
+						Str("template", "file.tpl"),
+					),
+				},
+			},
+			wantErr: errors.E(genfile.ErrInvalidTemplate),
+		},
+		{
+			name:  "template evaluation failure",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/stack/file.tpl",
+					add:  rawFile(`${global.undefined}`),
+				},
+				{
+					path: "/stack/gen.tm",
+					add: GenerateFile(
+						Labels("file.txt"),
+						Str("template", "file.tpl"),
+					),
+				},
+			},
+			wantErr: errors.E(genfile.ErrContentEval),
+		},
+	} {
+		testGenfile(t, tcase)
+	}
+}
+
+func TestGenerateFileTemplateSymlink(t *testing.T) {
+	t.Parallel()
+
+	load := func(t *testing.T, s sandbox.S) ([]genfile.File, error) {
+		test.AppendFile(t, s.RootDir(), "stack/gen.tm", GenerateFile(
+			Labels("file.txt"),
+			Str("template", "file.tpl"),
+		).String())
+		root, err := config.LoadRoot(s.RootDir())
+		assert.NoError(t, err)
+		stack := s.LoadStacks()[0].Stack
+		globals := s.LoadStackGlobals(root, stack)
+		return genfile.Load(root, stack, globals, project.NewPath("/modules"), nil)
+	}
+
+	t.Run("symlink inside the project", func(t *testing.T) {
+		t.Parallel()
+
+		s := sandbox.New(t)
+		s.BuildTree([]string{
+			"s:stack",
+			"f:templates/file.tpl:${terramate.stack.name}",
+		})
+		err := os.Symlink(
+			filepath.Join(s.RootDir(), "templates/file.tpl"),
+			filepath.Join(s.RootDir(), "stack/file.tpl"),
+		)
+		assert.NoError(t, err)
+
+		files, err := load(t, s)
+		assert.NoError(t, err)
+		assert.EqualInts(t, 1, len(files))
+		assert.EqualStrings(t, "stack", files[0].Body())
+	})
+
+	t.Run("symlink outside the project", func(t *testing.T) {
+		t.Parallel()
+
+		s := sandbox.New(t)
+		s.BuildTree([]string{"s:stack"})
+		outside := filepath.Join(t.TempDir(), "file.tpl")
+		test.WriteFile(t, filepath.Dir(outside), "file.tpl", "secret")
+		assert.NoError(t, os.Symlink(outside, filepath.Join(s.RootDir(), "stack/file.tpl")))
+
+		_, err := load(t, s)
+		errtest.Assert(t, err, errors.E(genfile.ErrInvalidTemplate))
+	})
+}
@@ -0,0 +1,321 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package genhcl_test
+
+import (
+	"testing"
+
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/generate/genhcl"
+	. "github.com/terramate-io/terramate/test/hclwrite/hclutils"
+)
+
+func TestGenerateHCLDynamicAttributes(t *testing.T) {
+	t.Parallel()
+
+	tcases := []testcase{
+		{
+			name:  "tm_dynamic_attributes without for_each",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/stack",
+					add: GenerateHCL(
+						Labels("file.tf"),
+						Content(
+							Block("locals",
+								Str("static", "value"),
+								TmDynamicAttributes(
+									Expr("attributes", `{ a = 1, b = "b" }`),
+								),
+							),
+						),
+					),
+				},
+			},
+			want: []result{
+				{
+					name: "file.tf",
+					hcl: genHCL{
+						condition: true,
+						body: Doc(
+							Block("locals",
+								Str("static", "value"),
+								Number("a", 1),
+								Str("b", "b"),
+							),
+						),
+					},
+				},
+			},
+		},
+		{
+			name:  "tm_dynamic_attributes iterating over globals map",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/stack",
+					add: Doc(
+						Globals(
+							Expr("tags", `{ env = "prod", team = "infra" }`),
+						),
+						GenerateHCL(
+							Labels("file.tf"),
+							Content(
+								Block("locals",
+									TmDynamicAttributes(
+										Expr("for_each", `global.tags`),
+										Expr("iterator", `tag`),
+										Expr("attributes", `{
+											"tag_${tag.key}" = tag.value
+										}`),
+									),
+									Block("sub",
+										Str("a", "b"),
+									),
+								),
+							),
+						),
+					),
+				},
+			},
+			want: []result{
+				{
+					name: "file.tf",
+					hcl: genHCL{
+						condition: true,
+						body: Doc(
+							Block("locals",
+								Str("tag_env", "prod"),
+								Str("tag_team", "infra"),
+								Block("sub",
+									Str("a", "b"),
+								),
+							),
+						),
+					},
+				},
+			},
+		},
+		{
+			name:  "tm_dynamic_attributes with default iterator and partial evaluation",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/stack",
+					add: GenerateHCL(
+						Labels("file.tf"),
+						Content(
+							Block("locals",
+								TmDynamicAttributes(
+									Expr("for_each", `["a", "b"]`),
+									Expr("attributes", `{
+										"${element.value}" = local.other[element.key]
+									}`),
+								),
+							),
+						),
+					),
+				},
+			},
+			want: []result{
+				{
+					name: "file.tf",
+					hcl: genHCL{
+						condition: true,
+						body: Doc(
+							Block("locals",
+								Expr("a", "local.other[0]"),
+								Expr("b", "local.other[1]"),
+							),
+						),
+					},
+				},
+			},
+		},
+		{
+			name:  "tm_dynamic_attributes with condition false generates nothing",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/stack",
+					add: GenerateHCL(
+						Labels("file.tf"),
+						Content(
+							Block("locals",
+								Str("a", "a"),
+								TmDynamicAttributes(
+									Bool("condition", false),
+									Expr("attributes", `{ b = 1 }`),
+								),
+							),
+						),
+					),
+				},
+			},
+			want: []result{
+				{
+					name: "file.tf",
+					hcl: genHCL{
+						condition: true,
+						body: Doc(
+							Block("locals",
+								Str("a", "a"),
+							),
+						),
+					},
+				},
+			},
+		},
+		{
+			name:  "tm_dynamic_attributes inside tm_dynamic content",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/stack",
+					add: GenerateHCL(
+						Labels("file.tf"),
+						Content(
+							TmDynamic(
+								Labels("block"),
+								Expr("for_each", `["x", "y"]`),
+								Content(
+									TmDynamicAttributes(
+										Expr("attributes", `{
+											"${block.value}" = block.key
+										}`),
+									),
+								),
+							),
+						),
+					),
+				},
+			},
+			want: []result{
+				{
+					name: "file.tf",
+					hcl: genHCL{
+						condition: true,
+						body: Doc(
+							Block("block",
+								Number("x", 0),
+							),
+							Block("block",
+								Number("y", 1),
+							),
+						),
+					},
+				},
+			},
+		},
+		{
+			name:  "tm_dynamic_attributes conflicting with static attribute fails",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/stack",
+					add: GenerateHCL(
+						Labels("file.tf"),
+						Content(
+							Block("locals",
+								Str("a", "a"),
+								TmDynamicAttributes(
+									Expr("attributes", `{ a = 1 }`),
+								),
+							),
+						),
+					),
+				},
+			},
+			wantErr: errors.E(genhcl.ErrDynamicAttrsDuplicated),
+		},
+		{
+			name:  "tm_dynamic_attributes generating duplicated attributes fails",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/stack",
+					add: GenerateHCL(
+						Labels("file.tf"),
+						Content(
+							Block("locals",
+								TmDynamicAttributes(
+									Expr("for_each", `["a", "b"]`),
+									Expr("attributes", `{ same = element.value }`),
+								),
+							),
+						),
+					),
+				},
+			},
+			wantErr: errors.E(genhcl.ErrDynamicAttrsDuplicated),
+		},
+		{
+			name:  "tm_dynamic_attributes without attributes fails",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/stack",
+					add: GenerateHCL(
+						Labels("file.tf"),
+						Content(
+							Block("locals",
+								TmDynamicAttributes(
+									Expr("for_each", `["a", "b"]`),
+								),
+							),
+						),
+					),
+				},
+			},
+			wantErr: errors.E(genhcl.ErrParsing),
+		},
+		{
+			name:  "tm_dynamic_attributes with labels fails",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/stack",
+					add: GenerateHCL(
+						Labels("file.tf"),
+						Content(
+							Block("locals",
+								TmDynamicAttributes(
+									Labels("label"),
+									Expr("attributes", `{ a = 1 }`),
+								),
+							),
+						),
+					),
+				},
+			},
+			wantErr: errors.E(genhcl.ErrParsing),
+		},
+		{
+			name:  "tm_dynamic_attributes with iterator and no for_each fails",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/stack",
+					add: GenerateHCL(
+						Labels("file.tf"),
+						Content(
+							Block("locals",
+								TmDynamicAttributes(
+									Expr("iterator", "it"),
+									Expr("attributes", `{ a = 1 }`),
+								),
+							),
+						),
+					),
+				},
+			},
+			wantErr: errors.E(genhcl.ErrInvalidDynamicIterator),
+		},
+	}
+
+	for _, tcase := range tcases {
+		tcase.run(t)
+	}
+}
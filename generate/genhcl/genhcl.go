@@ -34,12 +34,22 @@ import (
 // about the origin of the generated code.
 type HCL struct {
 	label     string
+	context   string
 	origin    info.Range
 	body      string
 	condition bool
 	asserts   []config.Assert
+	hooks     config.GenerateHooks
 }
 
+const (
+	// StackContext is the stack context name.
+	StackContext = hcl.StackContext
+
+	// RootContext is the root context name.
+	RootContext = hcl.RootContext
+)
+
 const (
 	// Header is the current header string used by generate_hcl code generation.
 	Header = "// TERRAMATE: GENERATED AUTOMATICALLY DO NOT EDIT"
@@ -77,6 +87,19 @@ const (
 
 	// ErrDynamicAttrsConflict indicates fields of tm_dynamic conflicts.
 	ErrDynamicAttrsConflict errors.Kind = "tm_dynamic.attributes and tm_dynamic.content have conflicting fields"
+
+	// ErrDynamicAttrsDuplicated indicates that a tm_dynamic_attributes block
+	// generates an attribute that is already defined in the enclosing block.
+	ErrDynamicAttrsDuplicated errors.Kind = "tm_dynamic_attributes generates duplicated attributes"
+)
+
+const (
+	dynamicBlockType      = "tm_dynamic"
+	dynamicAttributesType = "tm_dynamic_attributes"
+
+	// defaultDynamicAttrsIterator is the iterator name used by
+	// tm_dynamic_attributes if none is provided.
+	defaultDynamicAttrsIterator = "element"
 )
 
 // Label of the original generate_hcl block.
@@ -91,6 +114,12 @@ func (h HCL) Asserts() []config.Assert {
 	return h.asserts
 }
 
+// Hooks returns the evaluated post_process and validate commands of the
+// generate_hcl block.
+func (h HCL) Hooks() config.GenerateHooks {
+	return h.hooks
+}
+
 // Header returns the header of the generated HCL file.
 func (h HCL) Header() string {
 	return Header + "\n\n"
@@ -114,7 +143,7 @@ func (h HCL) Condition() bool {
 
 // Context of the generate_hcl block.
 func (h HCL) Context() string {
-	return "stack"
+	return h.context
 }
 
 func (h HCL) String() string {
@@ -156,6 +185,10 @@ func Load(
 
 	var hcls []HCL
 	for _, hclBlock := range hclBlocks {
+		if hclBlock.Context != StackContext {
+			continue
+		}
+
 		name := hclBlock.Label
 		evalctx := stack.NewEvalCtx(root, st, globals)
 
@@ -168,95 +201,111 @@ func Load(
 			stdlib.VendorFunc(vendorTargetDir, vendorDir, vendorRequests),
 		)
 
-		err := lets.Load(hclBlock.Lets, evalctx.Context)
+		hcl, err := Eval(hclBlock, evalctx.Context)
 		if err != nil {
 			return nil, err
 		}
+		hcls = append(hcls, hcl)
+	}
 
-		condition := true
-		if hclBlock.Condition != nil {
-			value, err := evalctx.Eval(hclBlock.Condition.Expr)
-			if err != nil {
-				return nil, errors.E(ErrConditionEval, err)
-			}
-			if value.Type() != cty.Bool {
-				return nil, errors.E(
-					ErrInvalidConditionType,
-					"condition has type %s but must be boolean",
-					value.Type().FriendlyName(),
-				)
-			}
-			condition = value.True()
-		}
-
-		if !condition {
-			hcls = append(hcls, HCL{
-				label:     name,
-				origin:    hclBlock.Range,
-				condition: condition,
-			})
-
-			continue
-		}
+	sort.SliceStable(hcls, func(i, j int) bool {
+		return hcls[i].Label() < hcls[j].Label()
+	})
 
-		asserts := make([]config.Assert, len(hclBlock.Asserts))
-		assertsErrs := errors.L()
-		assertFailed := false
+	logger.Trace().Msg("evaluated all blocks with success")
+	return hcls, nil
+}
 
-		for i, assertCfg := range hclBlock.Asserts {
-			assert, err := config.EvalAssert(evalctx.Context, assertCfg)
-			if err != nil {
-				assertsErrs.Append(err)
-				continue
-			}
-			asserts[i] = assert
-			if !assert.Assertion && !assert.Warning {
-				assertFailed = true
-			}
-		}
+// Eval the generate_hcl block using the given evaluation context.
+func Eval(block hcl.GenHCLBlock, evalctx *eval.Context) (HCL, error) {
+	name := block.Label
+	err := lets.Load(block.Lets, evalctx)
+	if err != nil {
+		return HCL{}, err
+	}
 
-		if err := assertsErrs.AsError(); err != nil {
-			return nil, err
+	condition := true
+	if block.Condition != nil {
+		value, err := evalctx.Eval(block.Condition.Expr)
+		if err != nil {
+			return HCL{}, errors.E(ErrConditionEval, err)
 		}
-
-		if assertFailed {
-			hcls = append(hcls, HCL{
-				label:     name,
-				origin:    hclBlock.Range,
-				condition: condition,
-				asserts:   asserts,
-			})
-			continue
+		if value.Type() != cty.Bool {
+			return HCL{}, errors.E(
+				ErrInvalidConditionType,
+				"condition has type %s but must be boolean",
+				value.Type().FriendlyName(),
+			)
 		}
+		condition = value.True()
+	}
 
-		evalctx.SetFunction(stdlib.Name("hcl_expression"), stdlib.HCLExpressionFunc())
+	if !condition {
+		return HCL{
+			label:     name,
+			context:   block.Context,
+			origin:    block.Range,
+			condition: condition,
+		}, nil
+	}
 
-		gen := hclwrite.NewEmptyFile()
-		if err := copyBody(gen.Body(), hclBlock.Content.Body, evalctx); err != nil {
-			return nil, errors.E(ErrContentEval, err, "generate_hcl %q", name)
-		}
+	asserts := make([]config.Assert, len(block.Asserts))
+	assertsErrs := errors.L()
+	assertFailed := false
 
-		formatted, err := fmt.FormatMultiline(string(gen.Bytes()), hclBlock.Range.HostPath())
+	for i, assertCfg := range block.Asserts {
+		assert, err := config.EvalAssert(evalctx, assertCfg)
 		if err != nil {
-			panic(errors.E(err,
-				"internal error: formatting generated code for generate_hcl %q:%s", name, string(gen.Bytes()),
-			))
+			assertsErrs.Append(err)
+			continue
 		}
-		hcls = append(hcls, HCL{
+		asserts[i] = assert
+		if !assert.Assertion && !assert.Warning {
+			assertFailed = true
+		}
+	}
+
+	if err := assertsErrs.AsError(); err != nil {
+		return HCL{}, err
+	}
+
+	if assertFailed {
+		return HCL{
 			label:     name,
-			origin:    hclBlock.Range,
-			body:      formatted,
+			context:   block.Context,
+			origin:    block.Range,
 			condition: condition,
 			asserts:   asserts,
-		})
+		}, nil
 	}
 
-	sort.SliceStable(hcls, func(i, j int) bool {
-		return hcls[i].Label() < hcls[j].Label()
-	})
+	hooks, err := config.EvalGenerateHooks(evalctx, block.PostProcess, block.Validate)
+	if err != nil {
+		return HCL{}, errors.E(err, "generate_hcl %q", name)
+	}
 
-	logger.Trace().Msg("evaluated all blocks with success")
-	return hcls, nil
+	evalctx.SetFunction(stdlib.Name("hcl_expression"), stdlib.HCLExpressionFunc())
+
+	gen := hclwrite.NewEmptyFile()
+	if err := copyBody(gen.Body(), block.Content.Body, evalctx); err != nil {
+		return HCL{}, errors.E(ErrContentEval, err, "generate_hcl %q", name)
+	}
+
+	formatted, err := fmt.FormatMultiline(string(gen.Bytes()), block.Range.HostPath())
+	if err != nil {
+		panic(errors.E(err,
+			"internal error: formatting generated code for generate_hcl %q:%s", name, string(gen.Bytes()),
+		))
+	}
+	return HCL{
+		label:     name,
+		context:   block.Context,
+		origin:    block.Range,
+		body:      formatted,
+		condition: condition,
+		asserts:   asserts,
+		hooks:     hooks,
+	}, nil
 }
 
 type dynBlockAttributes struct {
@@ -307,6 +356,7 @@ func copyBody(dest *hclwrite.Body, src *hclsyntax.Body, eval hcl.Evaluator) erro
 
 	logger.Trace().Msg("sorting attributes")
 
+	attrNames := map[string]struct{}{}
 	attrs := ast.SortRawAttributes(ast.AsHCLAttributes(src.Attributes))
 	for _, attr := range attrs {
 		logger := logger.With().
@@ -328,11 +378,29 @@ func copyBody(dest *hclwrite.Body, src *hclsyntax.Body, eval hcl.Evaluator) erro
 
 		logger.Trace().Str("attribute", attr.Name).Msg("Setting evaluated attribute.")
 		dest.SetAttributeRaw(attr.Name, ast.TokensForExpression(newexpr))
+		attrNames[attr.Name] = struct{}{}
+	}
+
+	logger.Trace().Msg("appending dynamic attributes")
+
+	// WHY: dynamic attributes are handled before any block so the generated
+	// attributes are kept together with the static ones.
+	for _, block := range src.Blocks {
+		if block.Type != dynamicAttributesType {
+			continue
+		}
+		err := appendDynamicAttributes(dest, block, eval, attrNames)
+		if err != nil {
+			return err
+		}
 	}
 
 	logger.Trace().Msg("appending blocks")
 
 	for _, block := range src.Blocks {
+		if block.Type == dynamicAttributesType {
+			continue
+		}
 		err := appendBlock(dest, block, eval)
 		if err != nil {
 			return err
@@ -343,7 +411,7 @@ func copyBody(dest *hclwrite.Body, src *hclsyntax.Body, eval hcl.Evaluator) erro
 }
 
 func appendBlock(target *hclwrite.Body, block *hclsyntax.Block, eval hcl.Evaluator) error {
-	if block.Type == "tm_dynamic" {
+	if block.Type == dynamicBlockType {
 		return appendDynamicBlocks(target, block, eval)
 	}
 
@@ -390,64 +458,9 @@ func appendDynamicBlock(
 			return errors.E(ErrDynamicAttrsEval, err, attrs.attributes.Range())
 		}
 
-		tmAttrs := []tmAttribute{}
-		switch objectExpr := attrsExpr.(type) {
-		case *hclsyntax.LiteralValueExpr:
-			val := objectExpr.Val
-			if val.IsNull() {
-				return errors.E(ErrParsing, objectExpr.Range(), "attributes is null")
-			}
-			if !val.Type().IsObjectType() {
-				return attrErr(attrs.attributes,
-					"tm_dynamic attributes must be an object, got %s instead", val.Type().FriendlyName())
-			}
-			iter := val.ElementIterator()
-			for iter.Next() {
-				key, val := iter.Element()
-				if key.Type() != cty.String {
-					panic("unreachable")
-				}
-				tmAttrs = append(tmAttrs, tmAttribute{
-					name:   key.AsString(),
-					tokens: ast.TokensForValue(val),
-					info:   objectExpr.Range(),
-				})
-			}
-
-		case *hclsyntax.ObjectConsExpr:
-			for _, item := range objectExpr.Items {
-				keyVal, err := evaluator.Eval(item.KeyExpr)
-				if err != nil {
-					return errors.E(ErrDynamicAttrsEval, err,
-						item.KeyExpr.Range(),
-						"evaluating tm_dynamic.attributes key")
-				}
-				if keyVal.Type() != cty.String {
-					return errors.E(ErrParsing, item.KeyExpr.Range(),
-						"tm_dynamic.attributes key %q has type %q, must be a string",
-						keyVal.GoString(),
-						keyVal.Type().FriendlyName())
-				}
-
-				valExpr, err := evaluator.PartialEval(item.ValueExpr)
-				if err != nil {
-					return errors.E(
-						ErrDynamicAttrsEval,
-						item.ValueExpr.Range(),
-						"failed to evaluate attribute value: %s",
-						ast.TokensForExpression(item.ValueExpr),
-					)
-				}
-				tmAttrs = append(tmAttrs, tmAttribute{
-					name:   keyVal.AsString(),
-					tokens: ast.TokensForExpression(valExpr),
-					info:   item.ValueExpr.Range(),
-				})
-			}
-
-		default:
-			return attrErr(attrs.attributes,
-				"tm_dynamic attributes must be an object, got %T instead", attrsExpr)
+		tmAttrs, err := evalDynamicAttributes(evaluator, attrs.attributes, attrsExpr)
+		if err != nil {
+			return err
 		}
 
 		err = setBodyAttributes(newblock.Body(), tmAttrs)
@@ -480,6 +493,75 @@ func appendDynamicBlock(
 	return nil
 }
 
+// evalDynamicAttributes evaluates the (already partially evaluated)
+// attributes object of the tm_dynamic and tm_dynamic_attributes blocks.
+func evalDynamicAttributes(
+	evaluator hcl.Evaluator,
+	attrsAttr *hclsyntax.Attribute,
+	attrsExpr hhcl.Expression,
+) ([]tmAttribute, error) {
+	tmAttrs := []tmAttribute{}
+	switch objectExpr := attrsExpr.(type) {
+	case *hclsyntax.LiteralValueExpr:
+		val := objectExpr.Val
+		if val.IsNull() {
+			return nil, errors.E(ErrParsing, objectExpr.Range(), "attributes is null")
+		}
+		if !val.Type().IsObjectType() {
+			return nil, attrErr(attrsAttr,
+				"tm_dynamic attributes must be an object, got %s instead", val.Type().FriendlyName())
+		}
+		iter := val.ElementIterator()
+		for iter.Next() {
+			key, val := iter.Element()
+			if key.Type() != cty.String {
+				panic("unreachable")
+			}
+			tmAttrs = append(tmAttrs, tmAttribute{
+				name:   key.AsString(),
+				tokens: ast.TokensForValue(val),
+				info:   objectExpr.Range(),
+			})
+		}
+
+	case *hclsyntax.ObjectConsExpr:
+		for _, item := range objectExpr.Items {
+			keyVal, err := evaluator.Eval(item.KeyExpr)
+			if err != nil {
+				return nil, errors.E(ErrDynamicAttrsEval, err,
+					item.KeyExpr.Range(),
+					"evaluating tm_dynamic.attributes key")
+			}
+			if keyVal.Type() != cty.String {
+				return nil, errors.E(ErrParsing, item.KeyExpr.Range(),
+					"tm_dynamic.attributes key %q has type %q, must be a string",
+					keyVal.GoString(),
+					keyVal.Type().FriendlyName())
+			}
+
+			valExpr, err := evaluator.PartialEval(item.ValueExpr)
+			if err != nil {
+				return nil, errors.E(
+					ErrDynamicAttrsEval,
+					item.ValueExpr.Range(),
+					"failed to evaluate attribute value: %s",
+					ast.TokensForExpression(item.ValueExpr),
+				)
+			}
+			tmAttrs = append(tmAttrs, tmAttribute{
+				name:   keyVal.AsString(),
+				tokens: ast.TokensForExpression(valExpr),
+				info:   item.ValueExpr.Range(),
+			})
+		}
+
+	default:
+		return nil, attrErr(attrsAttr,
+			"tm_dynamic attributes must be an object, got %T instead", attrsExpr)
+	}
+	return tmAttrs, nil
+}
+
 type tmAttribute struct {
 	name   string
 	tokens hclwrite.Tokens
@@ -622,6 +704,177 @@ func appendDynamicBlocks(target *hclwrite.Body, dynblock *hclsyntax.Block, evalu
 	return tmDynamicErr
 }
 
+// appendDynamicAttributes evaluates the tm_dynamic_attributes block and sets
+// the generated attributes on the target body. The names of the attributes
+// already defined in the target body must be provided in attrNames and it is
+// updated with the generated attributes, so duplicated attributes are
+// detected.
+func appendDynamicAttributes(
+	target *hclwrite.Body,
+	dynblock *hclsyntax.Block,
+	evaluator hcl.Evaluator,
+	attrNames map[string]struct{},
+) error {
+	logger := log.With().
+		Str("action", "genhcl.appendDynamicAttributes").
+		Logger()
+
+	logger.Trace().Msg("appending tm_dynamic_attributes block")
+
+	errs := errors.L()
+	if len(dynblock.Labels) != 0 {
+		errs.Append(errors.E(ErrParsing,
+			dynblock.LabelRanges, "tm_dynamic_attributes must have no labels"))
+	}
+
+	for _, b := range dynblock.Body.Blocks {
+		errs.Append(errors.E(ErrParsing,
+			b.TypeRange, "unrecognized block %s", b.Type))
+	}
+
+	attrs, err := getDynamicAttributesAttrs(dynblock)
+	errs.Append(err)
+
+	if err == nil && attrs.attributes == nil {
+		errs.Append(errors.E(ErrParsing, dynblock.Body.Range(),
+			"tm_dynamic_attributes.attributes must be defined"))
+	}
+
+	if err := errs.AsError(); err != nil {
+		return err
+	}
+
+	if attrs.condition != nil {
+		condition, err := evaluator.Eval(attrs.condition.Expr)
+		if err != nil {
+			return errors.E(ErrDynamicConditionEval, err)
+		}
+		if condition.Type() != cty.Bool {
+			return errors.E(ErrDynamicConditionEval, "want boolean got %s", condition.Type().FriendlyName())
+		}
+		if !condition.True() {
+			logger.Trace().Msg("condition is false, ignoring block")
+			return nil
+		}
+	}
+
+	appendAttrs := func() error {
+		attrsExpr, err := evaluator.PartialEval(attrs.attributes.Expr)
+		if err != nil {
+			return errors.E(ErrDynamicAttrsEval, err, attrs.attributes.Range())
+		}
+
+		tmAttrs, err := evalDynamicAttributes(evaluator, attrs.attributes, attrsExpr)
+		if err != nil {
+			return err
+		}
+
+		for _, attr := range tmAttrs {
+			if _, ok := attrNames[attr.name]; ok {
+				return errors.E(
+					ErrDynamicAttrsDuplicated,
+					attr.info,
+					"attribute %s is already defined",
+					attr.name,
+				)
+			}
+			attrNames[attr.name] = struct{}{}
+		}
+
+		return setBodyAttributes(target, tmAttrs)
+	}
+
+	if attrs.foreach == nil {
+		logger.Trace().Msg("no for_each, generating attributes once")
+
+		if attrs.iterator != nil {
+			return errors.E(ErrInvalidDynamicIterator,
+				attrs.iterator.Range(),
+				"iterator should not be defined when for_each is omitted")
+		}
+
+		return appendAttrs()
+	}
+
+	logger.Trace().Msg("evaluating for_each attribute")
+
+	foreach, err := evaluator.Eval(attrs.foreach.Expr)
+	if err != nil {
+		return wrapAttrErr(err, attrs.foreach, "evaluating `for_each` expression")
+	}
+
+	if foreach.IsNull() {
+		return nil
+	}
+
+	if !foreach.CanIterateElements() {
+		return attrErr(attrs.foreach,
+			"`for_each` expression of type %s cannot be iterated",
+			foreach.Type().FriendlyName())
+	}
+
+	iterator := defaultDynamicAttrsIterator
+	if attrs.iterator != nil {
+		iteratorTraversal, diags := hhcl.AbsTraversalForExpr(attrs.iterator.Expr)
+		if diags.HasErrors() || len(iteratorTraversal) != 1 {
+			return errors.E(ErrInvalidDynamicIterator,
+				attrs.iterator.Range(),
+				"dynamic iterator must be a single variable name")
+		}
+		iterator = iteratorTraversal.RootName()
+	}
+
+	logger.Trace().Str("iterator", iterator).Msg("generating attributes")
+
+	var tmDynamicErr error
+
+	foreach.ForEachElement(func(key, value cty.Value) (stop bool) {
+		evaluator.SetNamespace(iterator, map[string]cty.Value{
+			"key":   key,
+			"value": value,
+		})
+
+		if err := appendAttrs(); err != nil {
+			tmDynamicErr = err
+			return true
+		}
+
+		return false
+	})
+
+	evaluator.DeleteNamespace(iterator)
+	return tmDynamicErr
+}
+
+func getDynamicAttributesAttrs(block *hclsyntax.Block) (dynBlockAttributes, error) {
+	dynAttrs := dynBlockAttributes{}
+	errs := errors.L()
+
+	for name, attr := range block.Body.Attributes {
+		switch name {
+		case "attributes":
+			dynAttrs.attributes = attr
+			dynAttrs.attributes.Expr = &ast.CloneExpression{
+				Expression: attr.Expr,
+			}
+		case "for_each":
+			dynAttrs.foreach = attr
+			dynAttrs.foreach.Expr = &ast.CloneExpression{
+				Expression: attr.Expr,
+			}
+		case "iterator":
+			dynAttrs.iterator = attr
+		case "condition":
+			dynAttrs.condition = attr
+		default:
+			errs.Append(attrErr(
+				attr, "tm_dynamic_attributes unsupported attribute %q", name))
+		}
+	}
+
+	return dynAttrs, errs.AsError()
+}
+
 func getDynamicBlockAttrs(block *hclsyntax.Block) (dynBlockAttributes, error) {
 	dynAttrs := dynBlockAttributes{}
 	errs := errors.L()
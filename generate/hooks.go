@@ -0,0 +1,222 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package generate
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/hcl"
+)
+
+// Errors returned when running the generate hooks.
+const (
+	// ErrPostProcess indicates that a post_process command failed.
+	ErrPostProcess errors.Kind = "post_process command failed"
+
+	// ErrValidate indicates that a validate command failed.
+	ErrValidate errors.Kind = "validate command failed"
+)
+
+// processedFile is a generated file whose body was rewritten by the
+// post_process commands of its origin block.
+type processedFile struct {
+	GenFile
+	body string
+}
+
+// Body returns the post processed body.
+func (f processedFile) Body() string {
+	return f.body
+}
+
+// ProcessedManifestFilename is the name of the file, created on the stack
+// directory (or the project root for the root context), that records the
+// hashes of the files written after running their post_process commands.
+// It must be committed together with the generated files.
+const ProcessedManifestFilename = ".terramate-processed.json"
+
+// processedManifest records, for each post processed file, the hash of the
+// content generated from the configuration and the hash of the content
+// written to disk. It's used to detect outdated post processed files without
+// running the post_process commands again.
+type processedManifest struct {
+	Files map[string]processedHashes `json:"files"`
+}
+
+type processedHashes struct {
+	Input  string `json:"input"`
+	Output string `json:"output"`
+}
+
+// isOutdated tells if the file with the given label, whose content generated
+// from the configuration is given by genfile, is outdated compared to the
+// current content on disk.
+func (m processedManifest) isOutdated(genfile GenFile, current string) bool {
+	hashes, ok := m.Files[genfile.Label()]
+	if !ok {
+		return true
+	}
+	return hashes.Input != hashContent(genfile.Header()+genfile.Body()) ||
+		hashes.Output != hashContent(current)
+}
+
+func loadProcessedManifest(dir string) (processedManifest, error) {
+	manifest := processedManifest{}
+	data, found, err := readFile(filepath.Join(dir, ProcessedManifestFilename))
+	if err != nil || !found {
+		return manifest, err
+	}
+	if err := json.Unmarshal([]byte(data), &manifest); err != nil {
+		return manifest, errors.E(err, "parsing %s", ProcessedManifestFilename)
+	}
+	return manifest, nil
+}
+
+// saveProcessedManifest records the hashes of the post processed files with a
+// true condition, replacing the entries of the files generated with the same
+// context. The files with context=root have absolute labels, so they never
+// clash with the files of a stack on the project root. The manifest is
+// removed if there are no post processed files left.
+func saveProcessedManifest(dir string, context string, files []GenFile) error {
+	manifest, err := loadProcessedManifest(dir)
+	if err != nil {
+		return err
+	}
+	if manifest.Files == nil {
+		manifest.Files = map[string]processedHashes{}
+	}
+	for label := range manifest.Files {
+		if path.IsAbs(label) == (context == hcl.RootContext) {
+			delete(manifest.Files, label)
+		}
+	}
+	for _, file := range files {
+		processed, ok := file.(processedFile)
+		if !ok || !file.Condition() || len(file.Hooks().PostProcess) == 0 {
+			continue
+		}
+		manifest.Files[file.Label()] = processedHashes{
+			Input:  hashContent(file.Header() + processed.GenFile.Body()),
+			Output: hashContent(file.Header() + processed.body),
+		}
+	}
+
+	path := filepath.Join(dir, ProcessedManifestFilename)
+	current, found, err := readFile(path)
+	if err != nil {
+		return errors.E(err, "reading %s", ProcessedManifestFilename)
+	}
+
+	if len(manifest.Files) == 0 {
+		if found {
+			return os.Remove(path)
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return errors.E(err, "encoding %s", ProcessedManifestFilename)
+	}
+	data = append(data, '\n')
+	if found && current == string(data) {
+		return nil
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func hashContent(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// runHooks runs the post_process and validate commands of each generated file
+// with a true condition. The commands are executed inside dir and receive
+// the file body on stdin. The returned list has the same order as the given
+// files, with the bodies replaced by the output of the post_process commands.
+//
+// It must only be called when writing the generated files, loading the code
+// generation configuration never runs external commands.
+func runHooks(dir string, files []GenFile) ([]GenFile, error) {
+	errs := errors.L()
+	res := make([]GenFile, 0, len(files))
+	for _, file := range files {
+		hooks := file.Hooks()
+		if !file.Condition() || hooks.Empty() {
+			res = append(res, file)
+			continue
+		}
+
+		logger := log.With().
+			Str("action", "generate.runHooks()").
+			Str("dir", dir).
+			Str("file", file.Label()).
+			Logger()
+
+		body := file.Body()
+		failed := false
+		for _, cmd := range hooks.PostProcess {
+			logger.Debug().Strs("command", cmd).Msg("running post_process command")
+
+			out, err := runHookCmd(dir, cmd, body)
+			if err != nil {
+				errs.Append(errors.E(ErrPostProcess, file.Range(), err,
+					"processing %q", file.Label()))
+				failed = true
+				break
+			}
+			body = out
+		}
+
+		if failed {
+			continue
+		}
+
+		if len(hooks.Validate) > 0 {
+			logger.Debug().Strs("command", hooks.Validate).Msg("running validate command")
+
+			if _, err := runHookCmd(dir, hooks.Validate, body); err != nil {
+				errs.Append(errors.E(ErrValidate, file.Range(), err,
+					"validating %q", file.Label()))
+				continue
+			}
+		}
+
+		res = append(res, processedFile{
+			GenFile: file,
+			body:    body,
+		})
+	}
+
+	if err := errs.AsError(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func runHookCmd(dir string, cmdline []string, input string) (string, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command(cmdline[0], cmdline[1:]...)
+	cmd.Dir = dir
+	cmd.Stdin = strings.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", errors.E(err, "command %q: %s",
+			strings.Join(cmdline, " "), strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,416 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package generate_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/madlambda/spells/assert"
+	"github.com/terramate-io/terramate/config"
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/generate"
+	"github.com/terramate-io/terramate/project"
+	. "github.com/terramate-io/terramate/test/hclwrite/hclutils"
+	"github.com/terramate-io/terramate/test/sandbox"
+)
+
+func TestGenerateHooks(t *testing.T) {
+	t.Parallel()
+
+	testCodeGeneration(t, []testcase{
+		{
+			name:   "generate_file with post_process and validate",
+			skipOn: "windows",
+			layout: []string{
+				"s:stack",
+			},
+			configs: []hclconfig{
+				{
+					path: "/stack",
+					add: GenerateFile(
+						Labels("file.txt"),
+						Str("content", "hello world"),
+						Expr("post_process", `[
+						  ["tr", "a-z", "A-Z"],
+						  ["tr", " ", "_"],
+						]`),
+						Expr("validate", `["grep", "-q", "HELLO_WORLD"]`),
+					),
+				},
+			},
+			want: []generatedFile{
+				{
+					dir: "/stack",
+					files: map[string]fmt.Stringer{
+						"file.txt": stringer("HELLO_WORLD"),
+					},
+				},
+			},
+			wantReport: generate.Report{
+				Successes: []generate.Result{
+					{
+						Dir:     project.NewPath("/stack"),
+						Created: []string{"file.txt"},
+					},
+				},
+			},
+		},
+		{
+			name:   "generate_hcl post_process receives the body without header",
+			skipOn: "windows",
+			layout: []string{
+				"s:stack",
+			},
+			configs: []hclconfig{
+				{
+					path: "/stack",
+					add: GenerateHCL(
+						Labels("file.hcl"),
+						Expr("post_process", `[["sed", "s/value/processed/"]]`),
+						Expr("validate", `["grep", "-qv", "TERRAMATE"]`),
+						Content(
+							Str("a", "value"),
+						),
+					),
+				},
+			},
+			want: []generatedFile{
+				{
+					dir: "/stack",
+					files: map[string]fmt.Stringer{
+						"file.hcl": Doc(
+							Str("a", "processed"),
+						),
+					},
+				},
+			},
+			wantReport: generate.Report{
+				Successes: []generate.Result{
+					{
+						Dir:     project.NewPath("/stack"),
+						Created: []string{"file.hcl"},
+					},
+				},
+			},
+		},
+		{
+			name:   "hooks are not executed if condition is false",
+			skipOn: "windows",
+			layout: []string{
+				"s:stack",
+			},
+			configs: []hclconfig{
+				{
+					path: "/stack",
+					add: GenerateFile(
+						Labels("file.txt"),
+						Bool("condition", false),
+						Str("content", "data"),
+						Expr("validate", `["false"]`),
+					),
+				},
+			},
+		},
+		{
+			name:   "failed validate fails the generation",
+			skipOn: "windows",
+			layout: []string{
+				"s:stack",
+			},
+			configs: []hclconfig{
+				{
+					path: "/stack",
+					add: GenerateFile(
+						Labels("file.txt"),
+						Str("content", "data"),
+						Expr("validate", `["grep", "-q", "other"]`),
+					),
+				},
+			},
+			wantReport: generate.Report{
+				Failures: []generate.FailureResult{
+					{
+						Result: generate.Result{
+							Dir: project.NewPath("/stack"),
+						},
+						Error: errors.E(generate.ErrValidate),
+					},
+				},
+			},
+		},
+		{
+			name:   "failed post_process fails the generation",
+			skipOn: "windows",
+			layout: []string{
+				"s:stack",
+			},
+			configs: []hclconfig{
+				{
+					path: "/stack",
+					add: GenerateFile(
+						Labels("file.txt"),
+						Str("content", "data"),
+						Expr("post_process", `[["false"]]`),
+					),
+				},
+			},
+			wantReport: generate.Report{
+				Failures: []generate.FailureResult{
+					{
+						Result: generate.Result{
+							Dir: project.NewPath("/stack"),
+						},
+						Error: errors.E(generate.ErrPostProcess),
+					},
+				},
+			},
+		},
+		{
+			name:   "generate_file with context=root runs post_process",
+			skipOn: "windows",
+			configs: []hclconfig{
+				{
+					path: "/source",
+					add: GenerateFile(
+						Labels("/target/file.txt"),
+						Expr("context", "root"),
+						Str("content", "root"),
+						Expr("post_process", `[["tr", "a-z", "A-Z"]]`),
+					),
+				},
+			},
+			want: []generatedFile{
+				{
+					dir: "/target",
+					files: map[string]fmt.Stringer{
+						"file.txt": stringer("ROOT"),
+					},
+				},
+			},
+			wantReport: generate.Report{
+				Successes: []generate.Result{
+					{
+						Dir:     project.NewPath("/target"),
+						Created: []string{"file.txt"},
+					},
+				},
+			},
+		},
+		{
+			name:   "failed validate with context=root fails the generation",
+			skipOn: "windows",
+			configs: []hclconfig{
+				{
+					path: "/source",
+					add: GenerateFile(
+						Labels("/target/file.txt"),
+						Expr("context", "root"),
+						Str("content", "root"),
+						Expr("validate", `["false"]`),
+					),
+				},
+			},
+			wantReport: generate.Report{
+				Failures: []generate.FailureResult{
+					{
+						Result: generate.Result{
+							Dir: project.NewPath("/target"),
+						},
+						Error: errors.E(generate.ErrValidate),
+					},
+				},
+			},
+		},
+		{
+			name: "post_process must be a list of commands",
+			layout: []string{
+				"s:stack",
+			},
+			configs: []hclconfig{
+				{
+					path: "/stack",
+					add: GenerateFile(
+						Labels("file.txt"),
+						Str("content", "data"),
+						Expr("post_process", `["cat"]`),
+					),
+				},
+			},
+			wantReport: generate.Report{
+				Failures: []generate.FailureResult{
+					{
+						Result: generate.Result{
+							Dir: project.NewPath("/stack"),
+						},
+						Error: errors.E(config.ErrSchema),
+					},
+				},
+			},
+		},
+		{
+			name: "validate must be a non-empty command",
+			layout: []string{
+				"s:stack",
+			},
+			configs: []hclconfig{
+				{
+					path: "/stack",
+					add: GenerateHCL(
+						Labels("file.hcl"),
+						Expr("validate", `[]`),
+						Content(
+							Str("a", "b"),
+						),
+					),
+				},
+			},
+			wantReport: generate.Report{
+				Failures: []generate.FailureResult{
+					{
+						Result: generate.Result{
+							Dir: project.NewPath("/stack"),
+						},
+						Error: errors.E(config.ErrSchema),
+					},
+				},
+			},
+		},
+	})
+}
+
+func TestGenerateHooksOutdatedDetection(t *testing.T) {
+	t.Parallel()
+
+	s := sandbox.New(t)
+	s.BuildTree([]string{"s:stack"})
+	s.DirEntry("stack").CreateConfig(
+		GenerateFile(
+			Labels("file.txt"),
+			Str("content", "data"),
+			Expr("post_process", `[["tr", "a-z", "A-Z"]]`),
+		).String(),
+	)
+
+	vendorDir := project.NewPath("/modules")
+	outdated, err := generate.DetectOutdated(s.Config(), vendorDir)
+	assert.NoError(t, err)
+	assertEqualStringList(t, outdated, []string{"stack/file.txt"})
+
+	s.Generate()
+
+	assert.EqualStrings(t, "DATA", string(s.DirEntry("stack").ReadFile("file.txt")))
+
+	outdated, err = generate.DetectOutdated(s.Config(), vendorDir)
+	assert.NoError(t, err)
+	assertEqualStringList(t, outdated, []string{})
+
+	s.DirEntry("stack").CreateFile("file.txt", "edited by hand")
+
+	outdated, err = generate.DetectOutdated(s.Config(), vendorDir)
+	assert.NoError(t, err)
+	assertEqualStringList(t, outdated, []string{"stack/file.txt"})
+
+	s.Generate()
+
+	outdated, err = generate.DetectOutdated(s.Config(), vendorDir)
+	assert.NoError(t, err)
+	assertEqualStringList(t, outdated, []string{})
+
+	s.DirEntry("stack").CreateConfig(
+		GenerateFile(
+			Labels("file.txt"),
+			Str("content", "changed"),
+			Expr("post_process", `[["tr", "a-z", "A-Z"]]`),
+		).String(),
+	)
+
+	outdated, err = generate.DetectOutdated(s.ReloadConfig(), vendorDir)
+	assert.NoError(t, err)
+	assertEqualStringList(t, outdated, []string{"stack/file.txt"})
+
+	s.Generate()
+
+	assert.EqualStrings(t, "CHANGED", string(s.DirEntry("stack").ReadFile("file.txt")))
+
+	// the validate command is not run by the outdated detection, the file
+	// is outdated because it's not post processed anymore.
+	s.DirEntry("stack").CreateConfig(
+		GenerateFile(
+			Labels("file.txt"),
+			Str("content", "data"),
+			Expr("validate", `["false"]`),
+		).String(),
+	)
+
+	outdated, err = generate.DetectOutdated(s.ReloadConfig(), vendorDir)
+	assert.NoError(t, err)
+	assertEqualStringList(t, outdated, []string{"stack/file.txt"})
+}
+
+func TestGenerateHooksOutdatedDetectionRootContext(t *testing.T) {
+	t.Parallel()
+
+	s := sandbox.New(t)
+	s.RootEntry().CreateConfig(
+		GenerateHCL(
+			Labels("/target/file.hcl"),
+			Expr("context", "root"),
+			Expr("post_process", `[["sed", "s/value/processed/"]]`),
+			Content(
+				Str("a", "value"),
+			),
+		).String(),
+	)
+
+	vendorDir := project.NewPath("/modules")
+	s.Generate()
+
+	outdated, err := generate.DetectOutdated(s.Config(), vendorDir)
+	assert.NoError(t, err)
+	assertEqualStringList(t, outdated, []string{})
+
+	s.RootEntry().CreateFile("target/file.hcl", "a = \"edited\"\n")
+
+	outdated, err = generate.DetectOutdated(s.Config(), vendorDir)
+	assert.NoError(t, err)
+	assertEqualStringList(t, outdated, []string{"target/file.hcl"})
+}
+
+func TestGenerateHooksNotRunOnLoad(t *testing.T) {
+	t.Parallel()
+
+	s := sandbox.New(t)
+	s.BuildTree([]string{"s:stack"})
+	s.DirEntry("stack").CreateConfig(
+		GenerateFile(
+			Labels("file.txt"),
+			Str("content", "data"),
+			Expr("post_process", `[["tr", "a-z", "A-Z"]]`),
+			Expr("validate", `["false"]`),
+		).String(),
+	)
+	s.RootEntry().CreateConfig(
+		GenerateFile(
+			Labels("/root.txt"),
+			Expr("context", "root"),
+			Str("content", "root"),
+			Expr("validate", `["false"]`),
+		).String(),
+	)
+
+	results, err := generate.Load(s.Config(), project.NewPath("/modules"))
+	assert.NoError(t, err)
+
+	bodies := map[string]string{}
+	for _, res := range results {
+		assert.NoError(t, res.Err)
+		for _, file := range res.Files {
+			bodies[file.Label()] = file.Body()
+		}
+	}
+	assert.EqualInts(t, 2, len(bodies))
+	assert.EqualStrings(t, "data", bodies["file.txt"])
+	assert.EqualStrings(t, "root", bodies["/root.txt"])
+}
@@ -0,0 +1,96 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package generate
+
+import (
+	hhcl "github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/terramate-io/terramate/config"
+	"github.com/terramate-io/terramate/generate/genfile"
+	"github.com/terramate-io/terramate/generate/genhcl"
+	"github.com/terramate-io/terramate/globals"
+)
+
+// loadStackGlobals loads the globals of the stack. When lazy evaluation of
+// globals is enabled in the project, only the globals referenced by the code
+// generation of the stack are evaluated.
+func loadStackGlobals(root *config.Root, st *config.Stack) globals.EvalReport {
+	if !root.Tree().Node.HasLazyGlobals() {
+		return globals.ForStack(root, st)
+	}
+	refs, ok := stackGlobalsRefs(root, st)
+	if !ok {
+		return globals.ForStack(root, st)
+	}
+	return globals.ForStackLazy(root, st, refs)
+}
+
+// stackGlobalsRefs returns all the expressions which can reference globals
+// when generating code for the stack, walking from the stack dir up to the
+// root dir. It returns false if the referenced globals cannot be statically
+// determined, which happens when a generate_file block uses a template file.
+func stackGlobalsRefs(root *config.Root, st *config.Stack) ([]hhcl.Expression, bool) {
+	nodes, ok := stackCodegenNodes(root, st)
+	if !ok {
+		return nil, false
+	}
+
+	var refs []hhcl.Expression
+	for _, node := range nodes {
+		_ = hclsyntax.VisitAll(node, func(n hclsyntax.Node) hhcl.Diagnostics {
+			if expr, ok := n.(*hclsyntax.ScopeTraversalExpr); ok {
+				refs = append(refs, expr)
+			}
+			return nil
+		})
+	}
+	return refs, true
+}
+
+// stackCodegenNodes returns the nodes of all the expressions evaluated when
+// generating code for the stack, walking from the stack dir up to the root
+// dir. It returns false if some expressions cannot be statically determined,
+// which happens when a generate_file block uses a template file.
+func stackCodegenNodes(root *config.Root, st *config.Stack) ([]hclsyntax.Node, bool) {
+	var nodes []hclsyntax.Node
+	complete := true
+	curdir := st.Dir
+	for {
+		if cfg, ok := root.Lookup(curdir); ok {
+			nodes = append(nodes, blockNodes(nil, nil, cfg.Node.Asserts)...)
+
+			for _, block := range cfg.Node.Generate.HCLs {
+				if block.Context != genhcl.StackContext {
+					continue
+				}
+				nodes = append(nodes, blockNodes(block.Condition, block.Lets, block.Asserts)...)
+				nodes = append(nodes, attrNodes(block.PostProcess, block.Validate)...)
+				if block.Content != nil {
+					nodes = append(nodes, block.Content.Body)
+				}
+			}
+
+			for _, block := range cfg.Node.Generate.Files {
+				if block.Context != genfile.StackContext {
+					continue
+				}
+				if block.Template != nil {
+					complete = false
+				}
+				nodes = append(nodes, blockNodes(block.Condition, block.Lets, block.Asserts)...)
+				nodes = append(nodes, attrNodes(block.PostProcess, block.Validate)...)
+				if block.Content != nil {
+					nodes = append(nodes, block.Content.Expr)
+				}
+			}
+		}
+
+		if p := curdir.Dir(); p != curdir {
+			curdir = p
+		} else {
+			break
+		}
+	}
+	return nodes, complete
+}
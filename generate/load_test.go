@@ -452,6 +452,121 @@ func TestLoad(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "lazy globals evaluation reports unreferenced globals errors",
+			layout: []string{
+				"s:stack-1",
+				"s:stack-2",
+			},
+			configs: []file{
+				{
+					path: "terramate.tm",
+					body: Doc(
+						Terramate(
+							Config(
+								Block("globals",
+									Bool("lazy_evaluation", true),
+								),
+							),
+						),
+					),
+				},
+				{
+					path: "config.tm",
+					body: Doc(
+						GenerateHCL(
+							Labels("test.hcl"),
+							Content(
+								Expr("value", "global.a"),
+							),
+						),
+					),
+				},
+				{
+					path: "stack-1/globals.tm",
+					body: Doc(
+						Globals(
+							Number("a", 1),
+							Expr("unused", "global.undefined"),
+						),
+					),
+				},
+				{
+					path: "stack-2/globals.tm",
+					body: Doc(
+						Globals(
+							Expr("a", "global.undefined"),
+						),
+					),
+				},
+			},
+			want: []result{
+				{
+					dir: "/stack-1",
+					err: errors.E(globals.ErrEval),
+				},
+				{
+					dir: "/stack-2",
+					err: errors.E(globals.ErrEval),
+				},
+			},
+		},
+		{
+			name: "lazy globals evaluation ignores unreferenced globals values",
+			layout: []string{
+				"s:stack",
+			},
+			configs: []file{
+				{
+					path: "terramate.tm",
+					body: Doc(
+						Terramate(
+							Config(
+								Block("globals",
+									Bool("lazy_evaluation", true),
+								),
+							),
+						),
+					),
+				},
+				{
+					path: "config.tm",
+					body: Doc(
+						GenerateHCL(
+							Labels("test.hcl"),
+							Content(
+								Expr("value", "global.a"),
+							),
+						),
+					),
+				},
+				{
+					path: "stack/globals.tm",
+					body: Doc(
+						Globals(
+							Number("a", 1),
+							Expr("unused", "tm_element([], 0)"),
+						),
+					),
+				},
+			},
+			want: []result{
+				{
+					dir: "/stack",
+					files: []genfile{
+						{
+							label:     "test.hcl",
+							condition: true,
+							blockRange: Range(
+								"/config.tm",
+								Start(1, 1, 0),
+								End(5, 2, 64),
+							),
+						},
+					},
+				},
+			},
+		},
 		{
 			name: "partial result failing to generate code",
 			layout: []string{
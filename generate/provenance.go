@@ -0,0 +1,246 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package generate
+
+import (
+	"sort"
+	"strings"
+
+	hhcl "github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/terramate-io/terramate/config"
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/globals"
+	"github.com/terramate-io/terramate/hcl"
+	"github.com/terramate-io/terramate/hcl/ast"
+	"github.com/terramate-io/terramate/hcl/eval"
+	"github.com/terramate-io/terramate/hcl/info"
+	"github.com/terramate-io/terramate/project"
+	"github.com/zclconf/go-cty/cty"
+)
+
+type (
+	// Provenance describes where a generated file comes from.
+	Provenance struct {
+		// Range is the range of the origin generate block.
+		Range info.Range
+
+		// Globals are the globals read when evaluating the origin generate
+		// block, sorted by their accessor path. It includes the globals
+		// referenced by the block and, recursively, the globals referenced by
+		// their definitions.
+		Globals []GlobalRef
+	}
+
+	// GlobalRef is a global read when evaluating a generate block.
+	GlobalRef struct {
+		// Path is the accessor path of the global, eg.: ["a", "b"] for global.a.b
+		Path eval.ObjectPath
+
+		// ReferencedBy is the accessor path of the global which definition
+		// references this global. It is empty for the globals referenced by
+		// the generate block itself.
+		ReferencedBy eval.ObjectPath
+
+		// Value is the evaluated value of the global, valid only if Found is true.
+		Value cty.Value
+
+		// Expr is the expression which defined the value of the global, which
+		// can be the definition of one of its parent objects. It's valid only
+		// if Found is true.
+		Expr globals.Expr
+
+		// Found is true if the global is defined for the stack.
+		Found bool
+	}
+)
+
+// String returns the global reference as it is written in the configuration.
+func (ref GlobalRef) String() string {
+	return "global." + strings.Join(ref.Path, ".")
+}
+
+// LoadProvenance loads the provenance of the given file generated for the stack.
+// The provenance includes the origin generate block and all globals read when
+// evaluating the block (condition, lets, asserts and content), together with
+// their values and where each of them is defined in the hierarchy of the stack,
+// as recorded by the evaluation of the stack globals.
+func LoadProvenance(root *config.Root, st *config.Stack, file GenFile) (Provenance, error) {
+	prov := Provenance{
+		Range: file.Range(),
+	}
+
+	tree, ok := root.Lookup(st.Dir)
+	if !ok {
+		return Provenance{}, errors.E("stack %s not found in the configuration", st.Dir)
+	}
+
+	nodes, found := findOriginNodes(root, st.Dir, file)
+	if !found {
+		return Provenance{}, errors.E(file.Range(),
+			"origin block for generated file %q not found", file.Label())
+	}
+
+	report := loadStackGlobals(root, st)
+	if err := report.AsError(); err != nil {
+		return Provenance{}, err
+	}
+
+	exprs, err := globals.LoadExprs(tree)
+	if err != nil {
+		return Provenance{}, err
+	}
+
+	type pending struct {
+		path         eval.ObjectPath
+		referencedBy eval.ObjectPath
+	}
+
+	var queue []pending
+	for _, path := range globalRefs(nodes) {
+		queue = append(queue, pending{path: path})
+	}
+
+	seen := map[string]bool{}
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+
+		key := strings.Join(next.path, ".")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		ref := GlobalRef{
+			Path:         next.path,
+			ReferencedBy: next.referencedBy,
+		}
+		if value, ok := report.Globals.GetKeyPath(next.path); ok {
+			ref.Value = globalValue(value)
+			ref.Found = true
+		}
+		if expr, ok := exprs.Origin(report, next.path); ok {
+			ref.Expr = expr
+			// globals loaded from data files have no syntax nodes.
+			if node, ok := expr.Expression.(hclsyntax.Node); ok {
+				for _, path := range globalRefs([]hclsyntax.Node{node}) {
+					queue = append(queue, pending{path: path, referencedBy: next.path})
+				}
+			}
+		}
+		prov.Globals = append(prov.Globals, ref)
+	}
+
+	sort.Slice(prov.Globals, func(i, j int) bool {
+		return prov.Globals[i].String() < prov.Globals[j].String()
+	})
+	return prov, nil
+}
+
+// globalValue returns the cty value of the evaluated global.
+func globalValue(value eval.Value) cty.Value {
+	switch v := value.(type) {
+	case *eval.Object:
+		return cty.ObjectVal(v.AsValueMap())
+	case eval.CtyValue:
+		return v.Raw()
+	default:
+		panic(errors.E(errors.ErrInternal, "unexpected global value type %T", value))
+	}
+}
+
+// findOriginNodes looks for the generate block that generated the file,
+// searching from the stack dir up to the root dir, and returns all syntax nodes
+// that were evaluated when generating it.
+func findOriginNodes(root *config.Root, stackdir project.Path, file GenFile) ([]hclsyntax.Node, bool) {
+	curdir := stackdir
+	for {
+		if cfg, ok := root.Lookup(curdir); ok {
+			for _, block := range cfg.Node.Generate.HCLs {
+				if block.Label == file.Label() && block.Range == file.Range() {
+					nodes := blockNodes(block.Condition, block.Lets, block.Asserts)
+					if block.Content != nil {
+						nodes = append(nodes, block.Content.Body)
+					}
+					return nodes, true
+				}
+			}
+			for _, block := range cfg.Node.Generate.Files {
+				if block.Label == file.Label() && block.Range == file.Range() {
+					nodes := blockNodes(block.Condition, block.Lets, block.Asserts)
+					if block.Content != nil {
+						nodes = append(nodes, block.Content.Expr)
+					}
+					if block.Template != nil {
+						nodes = append(nodes, block.Template.Expr)
+					}
+					return nodes, true
+				}
+			}
+		}
+
+		if p := curdir.Dir(); p != curdir {
+			curdir = p
+		} else {
+			return nil, false
+		}
+	}
+}
+
+func blockNodes(
+	cond *hclsyntax.Attribute,
+	lets *ast.MergedBlock,
+	asserts []hcl.AssertConfig,
+) []hclsyntax.Node {
+	var nodes []hclsyntax.Node
+	if cond != nil {
+		nodes = append(nodes, cond.Expr)
+	}
+	if lets != nil {
+		for _, rawlets := range lets.RawOrigins {
+			nodes = append(nodes, rawlets.Body)
+		}
+	}
+	for _, assert := range asserts {
+		for _, expr := range []hhcl.Expression{assert.Assertion, assert.Message, assert.Warning} {
+			if node, ok := expr.(hclsyntax.Node); ok {
+				nodes = append(nodes, node)
+			}
+		}
+	}
+	return nodes
+}
+
+// globalRefs returns all the distinct global accessor paths referenced by the
+// given nodes. Only the static part of the traversals is considered, eg.:
+// global.a.b[local.x] is recorded as global.a.b.
+func globalRefs(nodes []hclsyntax.Node) []eval.ObjectPath {
+	seen := map[string]eval.ObjectPath{}
+	for _, node := range nodes {
+		_ = hclsyntax.VisitAll(node, func(n hclsyntax.Node) hhcl.Diagnostics {
+			trav, ok := n.(*hclsyntax.ScopeTraversalExpr)
+			if !ok || trav.Traversal.RootName() != "global" {
+				return nil
+			}
+			path := globals.TraversalPath(trav.Traversal)
+			if len(path) > 0 {
+				seen[strings.Join(path, ".")] = path
+			}
+			return nil
+		})
+	}
+
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	paths := make([]eval.ObjectPath, 0, len(keys))
+	for _, key := range keys {
+		paths = append(paths, seen[key])
+	}
+	return paths
+}
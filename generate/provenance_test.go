@@ -0,0 +1,114 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package generate_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/madlambda/spells/assert"
+	"github.com/terramate-io/terramate/generate"
+	"github.com/terramate-io/terramate/project"
+	"github.com/terramate-io/terramate/test/sandbox"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestLoadProvenance(t *testing.T) {
+	t.Parallel()
+
+	s := sandbox.New(t)
+	s.BuildTree([]string{"s:stack"})
+	s.RootEntry().CreateFile("globals.tm", `
+globals {
+  enabled = true
+  prefix  = "my"
+  obj = {
+    x = 1
+  }
+}
+
+globals "cfg" "db" {
+  port = 5432
+}
+`)
+	stack := s.DirEntry("stack")
+	stack.CreateFile("globals.tm", `
+globals {
+  name = "${global.prefix}-stack"
+}
+
+globals "obj" {
+  y = 2
+}
+`)
+	stack.CreateFile("gen.tm", `
+generate_hcl "file.tf" {
+  condition = global.enabled
+
+  lets {
+    name = global.name
+  }
+
+  content {
+    name = let.name
+    x    = global.obj.x
+    y    = global.obj["y"]
+    z    = tm_try(global.undefined, null)
+    w    = tm_try(global.cfg.other, null)
+  }
+}
+`)
+
+	results, err := generate.Load(s.Config(), project.NewPath("/modules"))
+	assert.NoError(t, err)
+	assert.EqualInts(t, 1, len(results))
+	assert.NoError(t, results[0].Err)
+	assert.EqualInts(t, 1, len(results[0].Files))
+
+	file := results[0].Files[0]
+	st := s.LoadStack(project.NewPath("/stack"))
+	prov, err := generate.LoadProvenance(s.Config(), st, file)
+	assert.NoError(t, err)
+
+	if prov.Range != file.Range() {
+		t.Fatalf("got range %v, want %v", prov.Range, file.Range())
+	}
+
+	type want struct {
+		ref          string
+		value        cty.Value
+		origin       string
+		referencedBy string
+	}
+
+	wantGlobals := []want{
+		{ref: "global.cfg.other"},
+		{ref: "global.enabled", value: cty.True, origin: "/globals.tm:3,3-17"},
+		{ref: "global.name", value: cty.StringVal("my-stack"), origin: "/stack/globals.tm:3,3-34"},
+		{ref: "global.obj.x", value: cty.NumberIntVal(1), origin: "/globals.tm:5,3-7,4"},
+		{ref: "global.obj.y", value: cty.NumberIntVal(2), origin: "/stack/globals.tm:7,3-8"},
+		{
+			ref:          "global.prefix",
+			value:        cty.StringVal("my"),
+			origin:       "/globals.tm:4,3-17",
+			referencedBy: "name",
+		},
+		{ref: "global.undefined"},
+	}
+
+	assert.EqualInts(t, len(wantGlobals), len(prov.Globals), "globals: %v", prov.Globals)
+	for i, want := range wantGlobals {
+		got := prov.Globals[i]
+		assert.EqualStrings(t, want.ref, got.String())
+		assert.EqualStrings(t, want.referencedBy, strings.Join(got.ReferencedBy, "."))
+		if want.origin == "" {
+			assert.IsTrue(t, !got.Found, "global %s must be undefined", got)
+			continue
+		}
+		assert.IsTrue(t, got.Found, "global %s must be defined", got)
+		assert.IsTrue(t, got.Value.RawEquals(want.value),
+			"global %s: want %#v, got %#v", got, want.value, got.Value)
+		assert.EqualStrings(t, want.origin, got.Expr.Origin.String())
+	}
+}
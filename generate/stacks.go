@@ -0,0 +1,190 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package generate
+
+import (
+	hhcl "github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/terramate-io/terramate/config"
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/globals"
+	"github.com/terramate-io/terramate/project"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// codegenRoot returns the root used to evaluate the generate blocks of the
+// given stacks. When cross stack references are enabled in the project, the
+// returned root exposes the evaluated globals of each stack in the
+// terramate.stacks namespace. Stacks with failing globals are exposed without
+// globals, the failure is reported when generating code for them and when
+// their globals are referenced, see [config.Root.StacksNamespaceErr].
+//
+// When lazy evaluation of globals is also enabled, only the globals of the
+// other stacks referenced through terramate.stacks by the generate blocks of
+// the project are evaluated.
+func codegenRoot(root *config.Root, stacks config.List[*config.SortableStack]) *config.Root {
+	if !root.Tree().Node.HasCrossStackReferences() {
+		return root
+	}
+
+	var refs []hhcl.Expression
+	lazy := root.Tree().Node.HasLazyGlobals()
+	if lazy {
+		refs = stacksGlobalsRefs(root)
+	}
+
+	stacksGlobals := map[project.Path]cty.Value{}
+	stacksErrs := map[project.Path]error{}
+	for _, st := range stacks {
+		var report globals.EvalReport
+		if lazy {
+			report = globals.ForStackRefs(root, st.Stack, refs)
+		} else {
+			report = globals.ForStack(root, st.Stack)
+		}
+		if err := report.AsError(); err != nil {
+			stacksErrs[st.Dir()] = errors.E(ErrLoadingGlobals, err,
+				"referencing the globals of stack %s", st.Dir())
+			continue
+		}
+		stacksGlobals[st.Dir()] = cty.ObjectVal(report.Globals.AsValueMap())
+	}
+	return root.WithStacksGlobals(stacksGlobals, stacksErrs)
+}
+
+// stacksNamespaceErr returns the errors of the stacks accessed through the
+// terramate.stacks namespace by the generate blocks of the stack, like the
+// stacks with an invalid configuration or failing globals.
+// See [config.Root.StacksNamespaceErr].
+func stacksNamespaceErr(root *config.Root, st *config.Stack) error {
+	if !root.Tree().Node.HasCrossStackReferences() {
+		return nil
+	}
+
+	nodes, _ := stackCodegenNodes(root, st)
+	errs := errors.L()
+	seen := map[error]struct{}{}
+	for _, node := range nodes {
+		_ = hclsyntax.VisitAll(node, func(n hclsyntax.Node) hhcl.Diagnostics {
+			expr, ok := n.(*hclsyntax.ScopeTraversalExpr)
+			if !ok {
+				return nil
+			}
+			err := root.StacksNamespaceErr(expr.Traversal)
+			if err == nil {
+				return nil
+			}
+			if _, ok := seen[err]; !ok {
+				seen[err] = struct{}{}
+				errs.Append(errors.E(err, expr.Range()))
+			}
+			return nil
+		})
+	}
+	return errs.AsError()
+}
+
+// stacksGlobalsRefs returns the globals of other stacks referenced by the
+// generate blocks of the project, as expressions accessing the global
+// namespace. If the accessed globals cannot be statically determined, eg.: a
+// dynamic index into terramate.stacks.by_id or a generate_file template, a
+// reference to the whole global namespace is returned.
+func stacksGlobalsRefs(root *config.Root) []hhcl.Expression {
+	var nodes []hclsyntax.Node
+	for _, cfg := range root.Tree().AsList() {
+		nodes = append(nodes, blockNodes(nil, nil, cfg.Node.Asserts)...)
+		for _, block := range cfg.Node.Generate.HCLs {
+			nodes = append(nodes, blockNodes(block.Condition, block.Lets, block.Asserts)...)
+			nodes = append(nodes, attrNodes(block.PostProcess, block.Validate)...)
+			if block.Content != nil {
+				nodes = append(nodes, block.Content.Body)
+			}
+		}
+		for _, block := range cfg.Node.Generate.Files {
+			if block.Template != nil {
+				return []hhcl.Expression{allGlobalsRef()}
+			}
+			nodes = append(nodes, blockNodes(block.Condition, block.Lets, block.Asserts)...)
+			nodes = append(nodes, attrNodes(block.PostProcess, block.Validate)...)
+			if block.Content != nil {
+				nodes = append(nodes, block.Content.Expr)
+			}
+		}
+	}
+
+	var refs []hhcl.Expression
+	for _, node := range nodes {
+		_ = hclsyntax.VisitAll(node, func(n hclsyntax.Node) hhcl.Diagnostics {
+			expr, ok := n.(*hclsyntax.ScopeTraversalExpr)
+			if !ok {
+				return nil
+			}
+			if ref, ok := stacksGlobalsRef(expr.Traversal); ok {
+				refs = append(refs, &hclsyntax.ScopeTraversalExpr{
+					Traversal: ref,
+					SrcRange:  expr.SrcRange,
+				})
+			}
+			return nil
+		})
+	}
+	return refs
+}
+
+// stacksGlobalsRef converts a terramate.stacks.by_id[<id>].global.<path> or
+// terramate.stacks.by_path[<path>].global.<path> traversal into the
+// global.<path> traversal. It returns false if the traversal doesn't access
+// the globals of the stacks.
+func stacksGlobalsRef(traversal hhcl.Traversal) (hhcl.Traversal, bool) {
+	if traversal.RootName() != "terramate" || len(traversal) < 2 ||
+		stepName(traversal[1]) != "stacks" {
+		return nil, false
+	}
+	ref := hhcl.Traversal{hhcl.TraverseRoot{Name: "global"}}
+	if len(traversal) < 3 {
+		return ref, true
+	}
+	switch stepName(traversal[2]) {
+	case "by_id", "by_path":
+	default:
+		return nil, false
+	}
+	if len(traversal) < 5 {
+		return ref, true
+	}
+	if stepName(traversal[4]) != "global" {
+		return nil, false
+	}
+	return append(ref, traversal[5:]...), true
+}
+
+// stepName returns the name accessed by an attribute or string index step of
+// a traversal.
+func stepName(step hhcl.Traverser) string {
+	switch step := step.(type) {
+	case hhcl.TraverseAttr:
+		return step.Name
+	case hhcl.TraverseIndex:
+		if step.Key.Type() == cty.String && step.Key.IsKnown() && !step.Key.IsNull() {
+			return step.Key.AsString()
+		}
+	}
+	return ""
+}
+
+func allGlobalsRef() hhcl.Expression {
+	return &hclsyntax.ScopeTraversalExpr{
+		Traversal: hhcl.Traversal{hhcl.TraverseRoot{Name: "global"}},
+	}
+}
+
+func attrNodes(attrs ...*hclsyntax.Attribute) []hclsyntax.Node {
+	var nodes []hclsyntax.Node
+	for _, attr := range attrs {
+		if attr != nil {
+			nodes = append(nodes, attr.Expr)
+		}
+	}
+	return nodes
+}
@@ -0,0 +1,111 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package globals
+
+import (
+	"os"
+
+	hhcl "github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/terramate-io/terramate/config"
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/hcl/info"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+
+	ctyyaml "github.com/zclconf/go-cty-yaml"
+)
+
+// ErrDataFile indicates a failure loading a globals data file.
+const ErrDataFile errors.Kind = "loading globals data file"
+
+// dataFileExpr is a global expression loaded from a data file.
+type dataFileExpr struct {
+	name   string
+	origin info.Range
+	expr   hhcl.Expression
+}
+
+// loadDataFile loads the top-level keys of the data file as global
+// expressions. For tfvars files the original expressions (and ranges) of the
+// file are used, for json and yaml files each key is a literal value
+// originated from the data_file block.
+func loadDataFile(rootdir string, datafile config.GlobalsDataFile) ([]dataFileExpr, error) {
+	hostpath := datafile.Path.HostPath(rootdir)
+	content, err := os.ReadFile(hostpath)
+	if err != nil {
+		return nil, errors.E(ErrDataFile, datafile.Block.LabelRanges(), err)
+	}
+
+	if datafile.Format == "tfvars" {
+		file, diags := hclsyntax.ParseConfig(content, hostpath, hhcl.InitialPos)
+		if diags.HasErrors() {
+			return nil, errors.E(ErrDataFile, diags)
+		}
+		body := file.Body.(*hclsyntax.Body)
+		if len(body.Blocks) > 0 {
+			return nil, errors.E(ErrDataFile, body.Blocks[0].TypeRange,
+				"tfvars data file must only contain attributes")
+		}
+		var exprs []dataFileExpr
+		for name, attr := range body.Attributes {
+			exprs = append(exprs, dataFileExpr{
+				name:   name,
+				origin: info.NewRange(rootdir, attr.SrcRange),
+				expr:   attr.Expr,
+			})
+		}
+		return exprs, nil
+	}
+
+	var val cty.Value
+	switch datafile.Format {
+	case "json":
+		val, err = decodeData(content, ctyjson.ImpliedType, ctyjson.Unmarshal)
+	case "yaml":
+		val, err = decodeData(content, ctyyaml.ImpliedType, ctyyaml.Unmarshal)
+	default:
+		panic(errors.E(errors.ErrInternal, "unexpected data file format %q", datafile.Format))
+	}
+	if err != nil {
+		return nil, errors.E(ErrDataFile, datafile.Block.LabelRanges(), err,
+			"decoding %s file %s", datafile.Format, datafile.Path)
+	}
+
+	if val.IsNull() {
+		return nil, nil
+	}
+
+	if !val.Type().IsObjectType() && !val.Type().IsMapType() {
+		return nil, errors.E(ErrDataFile, datafile.Block.LabelRanges(),
+			"data file %s must contain an object but got %s",
+			datafile.Path, val.Type().FriendlyName())
+	}
+
+	origin := datafile.Block.Range
+	var exprs []dataFileExpr
+	for name, v := range val.AsValueMap() {
+		exprs = append(exprs, dataFileExpr{
+			name:   name,
+			origin: origin,
+			expr: &hclsyntax.LiteralValueExpr{
+				Val:      v,
+				SrcRange: origin.ToHCLRange(),
+			},
+		})
+	}
+	return exprs, nil
+}
+
+func decodeData(
+	content []byte,
+	impliedType func([]byte) (cty.Type, error),
+	unmarshal func([]byte, cty.Type) (cty.Value, error),
+) (cty.Value, error) {
+	typ, err := impliedType(content)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	return unmarshal(content, typ)
+}
@@ -0,0 +1,347 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package globals_test
+
+import (
+	"testing"
+
+	"github.com/terramate-io/terramate/config"
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/globals"
+	"github.com/terramate-io/terramate/hcl"
+	"github.com/terramate-io/terramate/test/hclwrite"
+	. "github.com/terramate-io/terramate/test/hclwrite/hclutils"
+)
+
+func TestLoadGlobalsDataFile(t *testing.T) {
+	t.Parallel()
+
+	tcases := []testcase{
+		{
+			name: "json data file",
+			layout: []string{
+				"s:stack",
+				`f:env.json:{"region": "eu-west-1", "zones": ["a", "b"], "obj": {"a": 1}}`,
+			},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: Globals(
+						DataFile(
+							Labels("env.json"),
+						),
+					),
+				},
+			},
+			want: map[string]*hclwrite.Block{
+				"/stack": Globals(
+					Str("region", "eu-west-1"),
+					EvalExpr(t, "zones", `["a", "b"]`),
+					EvalExpr(t, "obj", `{ a = 1 }`),
+				),
+			},
+		},
+		{
+			name: "yaml data file relative to the config dir",
+			layout: []string{
+				"s:stack",
+				"f:stack/data/env.yml:region: eu-west-1\nreplicas: 3\n",
+			},
+			configs: []hclconfig{
+				{
+					path: "/stack",
+					add: Globals(
+						DataFile(
+							Labels("data/env.yml"),
+						),
+					),
+				},
+			},
+			want: map[string]*hclwrite.Block{
+				"/stack": Globals(
+					Str("region", "eu-west-1"),
+					Number("replicas", 3),
+				),
+			},
+		},
+		{
+			name: "tfvars data file with project absolute path",
+			layout: []string{
+				"s:dir/stack",
+				"f:vars/env.tfvars:region = \"eu-west-1\"\nzones = [\"a\"]\n",
+			},
+			configs: []hclconfig{
+				{
+					path: "/dir",
+					add: Globals(
+						DataFile(
+							Labels("/vars/env.tfvars"),
+						),
+					),
+				},
+			},
+			want: map[string]*hclwrite.Block{
+				"/dir/stack": Globals(
+					Str("region", "eu-west-1"),
+					EvalExpr(t, "zones", `["a"]`),
+				),
+			},
+		},
+		{
+			name: "explicit format and labeled globals",
+			layout: []string{
+				"s:stack",
+				"f:env.data:region: eu-west-1\n",
+			},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: Globals(
+						Labels("env"),
+						DataFile(
+							Labels("env.data"),
+							Str("format", "yaml"),
+						),
+					),
+				},
+			},
+			want: map[string]*hclwrite.Block{
+				"/stack": Globals(
+					EvalExpr(t, "env", `{ region = "eu-west-1" }`),
+				),
+			},
+		},
+		{
+			name: "data file globals are overridden by child globals",
+			layout: []string{
+				"s:stack",
+				`f:env.json:{"region": "eu-west-1", "replicas": 1}`,
+			},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: Globals(
+						DataFile(
+							Labels("env.json"),
+						),
+					),
+				},
+				{
+					path: "/stack",
+					add: Globals(
+						Number("replicas", 3),
+						Expr("name", `"app-${global.region}"`),
+					),
+				},
+			},
+			want: map[string]*hclwrite.Block{
+				"/stack": Globals(
+					Str("region", "eu-west-1"),
+					Number("replicas", 3),
+					Str("name", "app-eu-west-1"),
+				),
+			},
+		},
+		{
+			name: "data file key conflicting with attribute fails",
+			layout: []string{
+				"s:stack",
+				`f:env.json:{"region": "eu-west-1"}`,
+			},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: Globals(
+						Str("region", "us-east-1"),
+						DataFile(
+							Labels("env.json"),
+						),
+					),
+				},
+			},
+			wantErr: errors.E(globals.ErrRedefined),
+		},
+		{
+			name: "data file key defined by other data file fails",
+			layout: []string{
+				"s:stack",
+				`f:env.json:{"region": "eu-west-1", "name": "app"}`,
+				`f:other.yaml:region: us-east-1`,
+			},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: Globals(
+						DataFile(
+							Labels("env.json"),
+						),
+						DataFile(
+							Labels("other.yaml"),
+						),
+					),
+				},
+			},
+			wantErr: errors.E(globals.ErrRedefined),
+		},
+		{
+			name: "data file keys defined in different files of the same dir fails",
+			layout: []string{
+				"s:stack",
+				`f:env.json:{"region": "eu-west-1"}`,
+				`f:other.json:{"region": "us-east-1"}`,
+			},
+			configs: []hclconfig{
+				{
+					path:     "/",
+					filename: "env.tm",
+					add: Globals(
+						DataFile(
+							Labels("env.json"),
+						),
+					),
+				},
+				{
+					path:     "/",
+					filename: "other.tm",
+					add: Globals(
+						DataFile(
+							Labels("other.json"),
+						),
+					),
+				},
+			},
+			wantErr: errors.E(globals.ErrRedefined),
+		},
+		{
+			name: "data file keys with different labels do not conflict",
+			layout: []string{
+				"s:stack",
+				`f:env.json:{"region": "eu-west-1"}`,
+				`f:other.json:{"region": "us-east-1"}`,
+			},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: Doc(
+						Globals(
+							DataFile(
+								Labels("env.json"),
+							),
+						),
+						Globals(
+							Labels("other"),
+							DataFile(
+								Labels("other.json"),
+							),
+						),
+					),
+				},
+			},
+			want: map[string]*hclwrite.Block{
+				"/stack": Globals(
+					Str("region", "eu-west-1"),
+					EvalExpr(t, "other", `{ region = "us-east-1" }`),
+				),
+			},
+		},
+		{
+			name: "data file key conflicting with map block fails",
+			layout: []string{
+				"s:stack",
+				`f:env.json:{"region": "eu-west-1"}`,
+			},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: Globals(
+						DataFile(
+							Labels("env.json"),
+						),
+						Map(
+							Labels("region"),
+							Expr("for_each", "[]"),
+							Str("key", "a"),
+							Str("value", "a"),
+						),
+					),
+				},
+			},
+			wantErr: errors.E(globals.ErrRedefined),
+		},
+		{
+			name: "data file must contain an object",
+			layout: []string{
+				"s:stack",
+				`f:env.json:["a"]`,
+			},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: Globals(
+						DataFile(
+							Labels("env.json"),
+						),
+					),
+				},
+			},
+			wantErr: errors.E(globals.ErrDataFile),
+		},
+		{
+			name: "data file not found",
+			layout: []string{
+				"s:stack",
+			},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: Globals(
+						DataFile(
+							Labels("env.json"),
+						),
+					),
+				},
+			},
+			wantErr: errors.E(globals.ErrDataFile),
+		},
+		{
+			name: "data file outside of the project",
+			layout: []string{
+				"s:stack",
+			},
+			configs: []hclconfig{
+				{
+					path: "/stack",
+					add: Globals(
+						DataFile(
+							Labels("../../env.json"),
+						),
+					),
+				},
+			},
+			wantErr: errors.E(config.ErrSchema),
+		},
+		{
+			name: "unknown format",
+			layout: []string{
+				"s:stack",
+			},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: Globals(
+						DataFile(
+							Labels("env.json"),
+							Str("format", "xml"),
+						),
+					),
+				},
+			},
+			wantErr: errors.E(hcl.ErrTerramateSchema),
+		},
+	}
+
+	for _, tcase := range tcases {
+		testGlobals(t, tcase)
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package globals
+
+import (
+	"os"
+	"sort"
+
+	hhcl "github.com/hashicorp/hcl/v2"
+	"github.com/terramate-io/terramate/config"
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/hcl/eval"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// DefinitionStatus describes how a global definition contributes to the
+// final value of the explained global.
+type DefinitionStatus string
+
+const (
+	// StatusDefined is the status of a definition which provides the final
+	// value of the explained global.
+	StatusDefined DefinitionStatus = "defined"
+
+	// StatusMerged is the status of a definition which extends the final
+	// value of the explained global with new keys.
+	StatusMerged DefinitionStatus = "merged"
+
+	// StatusOverridden is the status of a definition which is overridden by
+	// a definition in a more specific directory.
+	StatusOverridden DefinitionStatus = "overridden"
+
+	// StatusUnset is the status of a definition which unsets the global.
+	StatusUnset DefinitionStatus = "unset"
+)
+
+type (
+	// Explanation describes how a global is defined for a given stack.
+	Explanation struct {
+		// Path is the accessor path of the explained global.
+		Path eval.ObjectPath
+
+		// Value is the final value of the global. It is only valid if Found is true.
+		Value cty.Value
+
+		// Found tells if the global is defined for the stack.
+		Found bool
+
+		// Definitions are all the definitions which affect the global, sorted
+		// from the root directory down to the stack directory.
+		Definitions []Definition
+	}
+
+	// Definition is a single global definition affecting an explained global.
+	// It can be a definition of the global itself, of one of its parent
+	// objects or of one of its keys.
+	Definition struct {
+		// Path is the accessor path of the definition.
+		Path eval.ObjectPath
+
+		// Expr is the definition expression.
+		Expr Expr
+
+		// Source is the source code of the expression.
+		Source string
+
+		// Value is the result of evaluating the expression with the final
+		// globals of the stack. It is not valid for unset definitions or if
+		// Err is not nil.
+		Value cty.Value
+
+		// Err is the evaluation error of the expression, if any.
+		Err error
+
+		// Status tells how the definition affects the final value.
+		Status DefinitionStatus
+	}
+)
+
+// Explain explains how the global at the given accessor path is defined for
+// the stack, listing every definition which affects it, from the root
+// directory down to the stack directory.
+func Explain(root *config.Root, stack *config.Stack, path eval.ObjectPath) (Explanation, error) {
+	tree, ok := root.Lookup(stack.Dir)
+	if !ok {
+		return Explanation{}, errors.E("configuration at %s not found", stack.Dir)
+	}
+
+	ctx := newStackContext(root, stack)
+	exprs, report := evalTree(tree, ctx, nil)
+	if report.BootstrapErr != nil {
+		return Explanation{}, report.BootstrapErr
+	}
+
+	explanation := Explanation{
+		Path: path,
+	}
+	if value, ok := report.Globals.GetKeyPath(path); ok {
+		explanation.Value = ctyValue(value)
+		explanation.Found = true
+	}
+
+	type definition struct {
+		key GlobalPathKey
+		Definition
+	}
+
+	var defs []definition
+	for _, exprSet := range exprs.sort() {
+		keys := exprSet.sort()
+		sort.SliceStable(keys, func(i, j int) bool {
+			if len(keys[i].Path()) != len(keys[j].Path()) {
+				return len(keys[i].Path()) < len(keys[j].Path())
+			}
+			return keys[i].name() < keys[j].name()
+		})
+		for _, key := range keys {
+			if !isObjectPathPrefix(key.Path(), path) && !isObjectPathPrefix(path, key.Path()) {
+				continue
+			}
+			expr := exprSet.expressions[key]
+			if expr.ConfigDir.String() == "" {
+				expr.ConfigDir = exprSet.origin
+			}
+			defs = append(defs, definition{
+				key: key,
+				Definition: Definition{
+					Path: key.Path(),
+					Expr: expr,
+				},
+			})
+		}
+	}
+
+	sources := map[string][]byte{}
+	for i := range defs {
+		def := &defs[i]
+
+		source, err := exprSource(sources, def.Expr.Range())
+		if err != nil {
+			return Explanation{}, err
+		}
+		def.Source = source
+
+		target := path
+		if len(def.Path) > len(path) {
+			target = def.Path
+		}
+
+		overridden := false
+		for _, other := range defs[i+1:] {
+			if other.Expr.ConfigDir == def.Expr.ConfigDir || !other.key.isattr {
+				continue
+			}
+			if isObjectPathPrefix(other.Path, target) {
+				overridden = true
+				break
+			}
+		}
+
+		switch {
+		case overridden:
+			def.Status = StatusOverridden
+		case isUnset(def.Expr):
+			def.Status = StatusUnset
+		case !def.key.isattr || len(def.Path) > len(path):
+			def.Status = StatusMerged
+		default:
+			def.Status = StatusDefined
+		}
+
+		if !isUnset(def.Expr) {
+			def.Value, def.Err = ctx.Eval(def.Expr)
+		}
+		explanation.Definitions = append(explanation.Definitions, def.Definition)
+	}
+	return explanation, nil
+}
+
+func isUnset(expr Expr) bool {
+	traversal, diags := hhcl.AbsTraversalForExpr(expr.Expression)
+	return !diags.HasErrors() && len(traversal) == 1 && traversal.RootName() == "unset"
+}
+
+// isObjectPathPrefix tells if prefix is a prefix of (or the same as) path.
+func isObjectPathPrefix(prefix, path eval.ObjectPath) bool {
+	return len(prefix) <= len(path) && isSameObjectPath(prefix, path[:len(prefix)])
+}
+
+// exprSource returns the source code at the given range, caching the read
+// files into sources.
+func exprSource(sources map[string][]byte, rng hhcl.Range) (string, error) {
+	content, ok := sources[rng.Filename]
+	if !ok {
+		var err error
+		content, err = os.ReadFile(rng.Filename)
+		if err != nil {
+			return "", errors.E(err, "reading source of global expression")
+		}
+		sources[rng.Filename] = content
+	}
+	if rng.End.Byte > len(content) || rng.Start.Byte > rng.End.Byte {
+		return "", errors.E(errors.ErrInternal, rng, "invalid global expression range")
+	}
+	return string(content[rng.Start.Byte:rng.End.Byte]), nil
+}
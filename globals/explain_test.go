@@ -0,0 +1,211 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package globals_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/madlambda/spells/assert"
+	"github.com/terramate-io/terramate/config"
+	"github.com/terramate-io/terramate/globals"
+	"github.com/terramate-io/terramate/hcl/ast"
+	"github.com/terramate-io/terramate/hcl/eval"
+	"github.com/terramate-io/terramate/project"
+	"github.com/terramate-io/terramate/test"
+	. "github.com/terramate-io/terramate/test/hclwrite/hclutils"
+	"github.com/terramate-io/terramate/test/sandbox"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestExplainGlobal(t *testing.T) {
+	t.Parallel()
+
+	type wantDef struct {
+		dir    string
+		path   eval.ObjectPath
+		source string
+		status globals.DefinitionStatus
+		value  string
+	}
+
+	type testcase struct {
+		name      string
+		layout    []string
+		configs   []hclconfig
+		stack     string
+		path      eval.ObjectPath
+		wantValue string
+		want      []wantDef
+	}
+
+	for _, tc := range []testcase{
+		{
+			name:   "undefined global",
+			layout: []string{"s:stack"},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add:  Globals(Number("a", 1)),
+				},
+			},
+			stack: "/stack",
+			path:  eval.ObjectPath{"b"},
+		},
+		{
+			name:   "global overridden by child dirs",
+			layout: []string{"s:dir/stack"},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add:  Globals(Number("a", 1)),
+				},
+				{
+					path: "/dir",
+					add:  Globals(Number("a", 2)),
+				},
+				{
+					path: "/dir/stack",
+					add:  Globals(Expr("a", "global.b + 1"), Number("b", 2)),
+				},
+			},
+			stack:     "/dir/stack",
+			path:      eval.ObjectPath{"a"},
+			wantValue: "3",
+			want: []wantDef{
+				{dir: "/", path: eval.ObjectPath{"a"}, source: "1", status: globals.StatusOverridden, value: "1"},
+				{dir: "/dir", path: eval.ObjectPath{"a"}, source: "2", status: globals.StatusOverridden, value: "2"},
+				{dir: "/dir/stack", path: eval.ObjectPath{"a"}, source: "global.b + 1", status: globals.StatusDefined, value: "3"},
+			},
+		},
+		{
+			name:   "global object merged by labeled globals",
+			layout: []string{"s:stack"},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add:  Globals(Expr("obj", `{ a = 1 }`)),
+				},
+				{
+					path: "/stack",
+					add: Globals(
+						Labels("obj"),
+						Number("b", 2),
+					),
+				},
+			},
+			stack:     "/stack",
+			path:      eval.ObjectPath{"obj"},
+			wantValue: `{ a = 1, b = 2 }`,
+			want: []wantDef{
+				{dir: "/", path: eval.ObjectPath{"obj"}, source: "{ a = 1 }", status: globals.StatusDefined, value: `{ a = 1 }`},
+				{dir: "/stack", path: eval.ObjectPath{"obj", "b"}, source: "2", status: globals.StatusMerged, value: "2"},
+			},
+		},
+		{
+			name:   "nested global overridden by labeled globals",
+			layout: []string{"s:stack"},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add:  Globals(Expr("obj", `{ a = 1 }`)),
+				},
+				{
+					path: "/stack",
+					add: Globals(
+						Labels("obj"),
+						Number("a", 2),
+					),
+				},
+			},
+			stack:     "/stack",
+			path:      eval.ObjectPath{"obj", "a"},
+			wantValue: "2",
+			want: []wantDef{
+				{dir: "/", path: eval.ObjectPath{"obj"}, source: "{ a = 1 }", status: globals.StatusOverridden, value: `{ a = 1 }`},
+				{dir: "/stack", path: eval.ObjectPath{"obj", "a"}, source: "2", status: globals.StatusDefined, value: "2"},
+			},
+		},
+		{
+			name:   "unset global",
+			layout: []string{"s:stack"},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add:  Globals(Number("a", 1)),
+				},
+				{
+					path: "/stack",
+					add:  Globals(Expr("a", "unset")),
+				},
+			},
+			stack: "/stack",
+			path:  eval.ObjectPath{"a"},
+			want: []wantDef{
+				{dir: "/", path: eval.ObjectPath{"a"}, source: "1", status: globals.StatusOverridden, value: "1"},
+				{dir: "/stack", path: eval.ObjectPath{"a"}, source: "unset", status: globals.StatusUnset},
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := sandbox.New(t)
+			s.BuildTree(tc.layout)
+			for _, cfg := range tc.configs {
+				test.AppendFile(t, filepath.Join(s.RootDir(), cfg.path),
+					config.DefaultFilename, cfg.add.String())
+			}
+
+			root := s.ReloadConfig()
+			st, err := config.LoadStack(root, project.NewPath(tc.stack))
+			assert.NoError(t, err)
+
+			got, err := globals.Explain(root, st, tc.path)
+			assert.NoError(t, err)
+
+			if tc.wantValue == "" {
+				assert.IsTrue(t, !got.Found, "global.%v must be undefined", tc.path)
+			} else {
+				assert.IsTrue(t, got.Found, "global.%v must be defined", tc.path)
+				assertCtyEqual(t, got.Value, tc.wantValue)
+			}
+
+			assert.EqualInts(t, len(tc.want), len(got.Definitions),
+				"definitions mismatch: %v", got.Definitions)
+
+			for i, want := range tc.want {
+				def := got.Definitions[i]
+				assert.EqualStrings(t, want.dir, def.Expr.ConfigDir.String())
+				assert.EqualStrings(t, want.source, def.Source)
+				assert.EqualStrings(t, string(want.status), string(def.Status))
+				assert.EqualInts(t, len(want.path), len(def.Path))
+				for j := range want.path {
+					assert.EqualStrings(t, want.path[j], def.Path[j])
+				}
+				if want.value != "" {
+					assert.NoError(t, def.Err)
+					assertCtyEqual(t, def.Value, want.value)
+				}
+			}
+		})
+	}
+}
+
+func assertCtyEqual(t *testing.T, got cty.Value, wantExpr string) {
+	t.Helper()
+
+	expr, err := ast.ParseExpression(wantExpr, "<want>")
+	assert.NoError(t, err)
+
+	want, diags := expr.Value(nil)
+	if diags.HasErrors() {
+		t.Fatalf("evaluating wanted value: %v", diags)
+	}
+
+	if !got.RawEquals(want) {
+		t.Fatalf("got value %s but want %s", got.GoString(), want.GoString())
+	}
+}
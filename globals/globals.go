@@ -87,14 +87,40 @@ func ForDir(root *config.Root, cfgdir project.Path, ctx *eval.Context) EvalRepor
 
 	logger.Trace().Msg("loading expressions")
 
+	_, report := evalTree(tree, ctx, nil)
+	return report
+}
+
+// evalTree loads, evaluates and checks the schemas of the globals of the tree.
+// If selectExprs is not nil, only the expressions it returns are evaluated.
+// The returned expressions are nil if the report has a bootstrap error.
+func evalTree(
+	tree *config.Tree,
+	ctx *eval.Context,
+	selectExprs func(HierarchicalExprs) HierarchicalExprs,
+) (HierarchicalExprs, EvalReport) {
+	tree.SetFunctions(ctx)
+
 	exprs, err := LoadExprs(tree)
 	if err != nil {
 		report := NewEvalReport()
 		report.BootstrapErr = err
-		return report
+		return nil, report
 	}
 
-	return exprs.Eval(ctx)
+	if selectExprs != nil {
+		exprs = selectExprs(exprs)
+	}
+
+	report := exprs.Eval(ctx)
+
+	schemas, err := LoadSchemas(tree)
+	if err != nil {
+		report.BootstrapErr = err
+		return exprs, report
+	}
+	applySchemas(&report, exprs, schemas)
+	return exprs, report
 }
 
 // ExprSet represents a set of globals loaded from a dir.
@@ -153,13 +179,59 @@ func LoadExprs(tree *config.Tree) (HierarchicalExprs, error) {
 			}
 		}
 
+		// dataKeys maps the keys loaded from data files to their data file,
+		// so keys defined by more than one data file are reported.
+		dataKeys := map[string]project.Path{}
+		for _, rawDataBlock := range block.RawBlocks["data_file"] {
+			datafile, err := config.NewGlobalsDataFile(rawDataBlock)
+			if err != nil {
+				return nil, err
+			}
+
+			logger.Trace().Msgf("Loading data file %s into globals", datafile.Path)
+
+			dataExprs, err := loadDataFile(tree.RootDir(), datafile)
+			if err != nil {
+				return nil, err
+			}
+			for _, dataExpr := range dataExprs {
+				if _, ok := block.Attributes[dataExpr.name]; ok {
+					return nil, errors.E(ErrRedefined, dataExpr.origin,
+						"data file %s key %s conflicts with global.%s attribute",
+						datafile.Path, dataExpr.name, dataExpr.name)
+				}
+				if other, ok := dataKeys[dataExpr.name]; ok {
+					return nil, errors.E(ErrRedefined, dataExpr.origin,
+						"data file %s key %s conflicts with data file %s key %s",
+						datafile.Path, dataExpr.name, other, dataExpr.name)
+				}
+				dataKeys[dataExpr.name] = datafile.Path
+				key := NewGlobalAttrPath(block.Labels, dataExpr.name)
+				exprs.expressions[key] = Expr{
+					Origin:     dataExpr.origin,
+					ConfigDir:  tree.Dir(),
+					LabelPath:  key.Path(),
+					Expression: dataExpr.expr,
+				}
+			}
+		}
+
 		for _, varsBlock := range block.Blocks {
+			if varsBlock.Type == "data_file" {
+				continue
+			}
 			varName := varsBlock.Labels[0]
 			if _, ok := block.Attributes[varName]; ok {
 				return HierarchicalExprs{}, errors.E(
 					ErrRedefined,
 					"map label %s conflicts with global.%s attribute", varName, varName)
 			}
+			if datafile, ok := dataKeys[varName]; ok {
+				return HierarchicalExprs{}, errors.E(
+					ErrRedefined, varsBlock.RawOrigins[0].Range,
+					"map label %s conflicts with data file %s key %s",
+					varName, datafile, varName)
+			}
 
 			logger.Trace().Msgf("Add map.%s to globals", varName)
 
@@ -212,6 +284,27 @@ func LoadExprs(tree *config.Tree) (HierarchicalExprs, error) {
 	return globals, nil
 }
 
+// TraversalPath returns the static accessor path of the traversal, without
+// its root name, eg.: global.a["b"][local.x].c has the path a.b. The path
+// ends at the first step which is not an attribute or a string index.
+func TraversalPath(traversal hhcl.Traversal) eval.ObjectPath {
+	var path eval.ObjectPath
+	for _, step := range traversal[1:] {
+		switch s := step.(type) {
+		case hhcl.TraverseAttr:
+			path = append(path, s.Name)
+		case hhcl.TraverseIndex:
+			if !s.Key.IsKnown() || s.Key.IsNull() || !s.Key.Type().Equals(cty.String) {
+				return path
+			}
+			path = append(path, s.Key.AsString())
+		default:
+			return path
+		}
+	}
+	return path
+}
+
 // SetOverride sets a custom global at the specified directory, using the given
 // global path and expr. The origin is only used for debugging purposes.
 func (dirExprs HierarchicalExprs) SetOverride(
@@ -233,6 +326,68 @@ func (dirExprs HierarchicalExprs) SetOverride(
 	}
 }
 
+// Lookup returns the expression which defines the global at the given
+// accessor path, which can be the expression defining the whole global or
+// any of its parent objects. The config dirs are searched from the most
+// specific (closer to the stack) to the less specific (closer to the root).
+func (dirExprs HierarchicalExprs) Lookup(path eval.ObjectPath) (Expr, bool) {
+	sorted := dirExprs.sort()
+	for i := len(sorted) - 1; i >= 0; i-- {
+		if expr, ok := sorted[i].definition(path, ""); ok {
+			return expr, true
+		}
+	}
+	return Expr{}, false
+}
+
+// Origin returns the expression which defined the value of the global at the
+// given accessor path in the evaluation report of the expressions. The
+// expression can define the whole global or any of its parent objects.
+func (dirExprs HierarchicalExprs) Origin(report EvalReport, path eval.ObjectPath) (Expr, bool) {
+	if len(path) == 0 || report.Globals == nil {
+		return Expr{}, false
+	}
+	value, ok := report.Globals.GetKeyPath(path)
+	if !ok {
+		return Expr{}, false
+	}
+	info := value.Info()
+	exprSet, ok := dirExprs[info.Dir]
+	if !ok {
+		return Expr{}, false
+	}
+	return exprSet.definition(path, info.DefinedAt.String())
+}
+
+// definition returns the expression of the set defining the global at the
+// accessor path or the closest of its parent objects. Attributes are preferred
+// over the implicit objects of labeled globals blocks. If file is not empty,
+// only expressions defined in the file are considered.
+func (exprSet *ExprSet) definition(path eval.ObjectPath, file string) (Expr, bool) {
+	for size := len(path); size >= 1; size-- {
+		var (
+			found Expr
+			ok    bool
+		)
+		for key, expr := range exprSet.expressions {
+			if !isSameObjectPath(key.Path(), path[:size]) {
+				continue
+			}
+			if file != "" && expr.Origin.Path().String() != file {
+				continue
+			}
+			found, ok = expr, true
+			if key.isattr {
+				break
+			}
+		}
+		if ok {
+			return found, true
+		}
+	}
+	return Expr{}, false
+}
+
 // Returns a sorted loaded exprs, sorting it by config dir path.
 // The loaded expressions are sorted by the config dir path
 // from smaller (root) to more specific (stack). Eg:
@@ -0,0 +1,220 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package globals
+
+import (
+	"strings"
+
+	hhcl "github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/terramate-io/terramate/config"
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/hcl/eval"
+	"github.com/terramate-io/terramate/project"
+)
+
+// ForStackRefs loads from the config tree only the globals of the stack which
+// are transitively referenced by the given expressions.
+// See [HierarchicalExprs.Referenced] for details.
+func ForStackRefs(root *config.Root, stack *config.Stack, refs []hhcl.Expression) EvalReport {
+	return ForDirRefs(root, stack.Dir, newStackContext(root, stack), refs)
+}
+
+// ForStackLazy loads the globals of the stack like [ForStackRefs], for
+// evaluating the globals of the stack itself. The globals which are not
+// referenced are not evaluated but their expressions are still checked for
+// references to unknown namespaces, undefined globals and unknown functions,
+// so these errors are reported the same way as by [ForStack].
+func ForStackLazy(root *config.Root, stack *config.Stack, refs []hhcl.Expression) EvalReport {
+	return forDirRefs(root, stack.Dir, newStackContext(root, stack), refs, true)
+}
+
+// ForDirRefs loads from the cfgdir only the globals which are transitively
+// referenced by the given expressions. The evaluated globals are the same as
+// the ones evaluated by [ForDir] but globals not referenced are neither
+// evaluated nor reported as errors.
+func ForDirRefs(root *config.Root, cfgdir project.Path, ctx *eval.Context, refs []hhcl.Expression) EvalReport {
+	return forDirRefs(root, cfgdir, ctx, refs, false)
+}
+
+func forDirRefs(
+	root *config.Root,
+	cfgdir project.Path,
+	ctx *eval.Context,
+	refs []hhcl.Expression,
+	checkUnreferenced bool,
+) EvalReport {
+	tree, ok := root.Lookup(cfgdir)
+	if !ok {
+		return NewEvalReport()
+	}
+
+	var all, selected HierarchicalExprs
+	_, report := evalTree(tree, ctx, func(exprs HierarchicalExprs) HierarchicalExprs {
+		all = exprs
+		selected = exprs.Referenced(refs)
+		return selected
+	})
+	if !checkUnreferenced || all == nil {
+		return report
+	}
+	for key, err := range all.checkUnreferenced(selected, ctx) {
+		if _, ok := report.Errors[key]; !ok {
+			report.Errors[key] = err
+		}
+	}
+	return report
+}
+
+// checkUnreferenced statically checks the expressions of the globals which
+// are not part of the selected expressions, without evaluating them.
+func (dirExprs HierarchicalExprs) checkUnreferenced(selected HierarchicalExprs, ctx *eval.Context) map[GlobalPathKey]EvalError {
+	effective := dirExprs.effective()
+	evaluated := selected.effective()
+	funcs := ctx.Unwrap().Functions
+
+	isDefined := func(path eval.ObjectPath) bool {
+		for key := range effective {
+			if isObjectPathPrefix(key.Path(), path) || isObjectPathPrefix(path, key.Path()) {
+				return true
+			}
+		}
+		return false
+	}
+
+	errs := map[GlobalPathKey]EvalError{}
+	for key, expr := range effective {
+		if _, ok := evaluated[key]; ok {
+			continue
+		}
+		traversal, diags := hhcl.AbsTraversalForExpr(expr.Expression)
+		if !diags.HasErrors() && len(traversal) == 1 && traversal.RootName() == "unset" {
+			continue
+		}
+
+		exprErrs := errors.L()
+		for _, traversal := range expr.Variables() {
+			rootname := traversal.RootName()
+			if !ctx.HasNamespace(rootname) {
+				exprErrs.Append(errors.E(ErrEval, traversal.SourceRange(),
+					"unknown variable namespace: %s", rootname))
+				continue
+			}
+			if rootname != "global" {
+				continue
+			}
+			path := TraversalPath(traversal)
+			if len(path) > 0 && !isDefined(path) {
+				exprErrs.Append(errors.E(ErrEval, traversal.SourceRange(),
+					"undefined global %s", strings.Join(path, ".")))
+			}
+		}
+
+		if node, ok := expr.Expression.(hclsyntax.Node); ok {
+			_ = hclsyntax.VisitAll(node, func(n hclsyntax.Node) hhcl.Diagnostics {
+				call, ok := n.(*hclsyntax.FunctionCallExpr)
+				if ok {
+					if _, found := funcs[call.Name]; !found {
+						exprErrs.Append(errors.E(ErrEval, call.NameRange,
+							"unknown function %s", call.Name))
+					}
+				}
+				return nil
+			})
+		}
+
+		if err := exprErrs.AsError(); err != nil {
+			errs[key] = EvalError{
+				Expr: expr,
+				Err:  errors.E(ErrEval, err, "global.%s", key.name()),
+			}
+		}
+	}
+	return errs
+}
+
+// effective returns the expression of each global, with the expressions of
+// the more specific directories overriding the parent ones, like done by
+// [HierarchicalExprs.Eval].
+func (dirExprs HierarchicalExprs) effective() map[GlobalPathKey]Expr {
+	res := map[GlobalPathKey]Expr{}
+	for _, exprSet := range dirExprs.sort() {
+		for key, expr := range exprSet.expressions {
+			res[key] = expr
+		}
+	}
+	return res
+}
+
+// Referenced returns the subset of the expressions which define globals
+// transitively referenced by the given expressions. A global is referenced if
+// its accessor path is a prefix or an extension of a path accessed by any
+// of the expressions, eg.: global.a.b references the definitions of global.a,
+// global.a.b and global.a.b.c.
+//
+// If any expression accesses the global namespace dynamically
+// (eg.: global[local.name]), then all expressions are returned.
+func (dirExprs HierarchicalExprs) Referenced(refs []hhcl.Expression) HierarchicalExprs {
+	var pending []eval.ObjectPath
+	visited := map[string]struct{}{}
+
+	addRefs := func(expr hhcl.Expression) bool {
+		for _, traversal := range expr.Variables() {
+			if traversal.RootName() != "global" {
+				continue
+			}
+			path := TraversalPath(traversal)
+			if len(path) == 0 {
+				return false
+			}
+			key := strings.Join(path, ".")
+			if _, ok := visited[key]; ok {
+				continue
+			}
+			visited[key] = struct{}{}
+			pending = append(pending, path)
+		}
+		return true
+	}
+
+	for _, ref := range refs {
+		if !addRefs(ref) {
+			return dirExprs
+		}
+	}
+
+	selected := map[project.Path]map[GlobalPathKey]struct{}{}
+	for len(pending) > 0 {
+		path := pending[0]
+		pending = pending[1:]
+
+		for dir, exprSet := range dirExprs {
+			for key, expr := range exprSet.expressions {
+				if !isObjectPathPrefix(key.Path(), path) && !isObjectPathPrefix(path, key.Path()) {
+					continue
+				}
+				if _, ok := selected[dir][key]; ok {
+					continue
+				}
+				if selected[dir] == nil {
+					selected[dir] = map[GlobalPathKey]struct{}{}
+				}
+				selected[dir][key] = struct{}{}
+				if !addRefs(expr) {
+					return dirExprs
+				}
+			}
+		}
+	}
+
+	res := HierarchicalExprs{}
+	for dir, exprSet := range dirExprs {
+		newSet := newExprSet(exprSet.origin)
+		for key := range selected[dir] {
+			newSet.expressions[key] = exprSet.expressions[key]
+		}
+		res[dir] = newSet
+	}
+	return res
+}
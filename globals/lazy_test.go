@@ -0,0 +1,312 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package globals_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	hhcl "github.com/hashicorp/hcl/v2"
+	"github.com/madlambda/spells/assert"
+	"github.com/terramate-io/terramate/config"
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/globals"
+	"github.com/terramate-io/terramate/hcl/ast"
+	"github.com/terramate-io/terramate/project"
+	"github.com/terramate-io/terramate/test"
+	errtest "github.com/terramate-io/terramate/test/errors"
+	"github.com/terramate-io/terramate/test/hclwrite"
+	. "github.com/terramate-io/terramate/test/hclwrite/hclutils"
+	"github.com/terramate-io/terramate/test/sandbox"
+)
+
+func TestLoadGlobalsReferenced(t *testing.T) {
+	t.Parallel()
+
+	type testcase struct {
+		name    string
+		layout  []string
+		configs []hclconfig
+		refs    []string
+		want    *hclwrite.Block
+		wantErr error
+	}
+
+	for _, tc := range []testcase{
+		{
+			name:   "no references evaluates no globals",
+			layout: []string{"s:stack"},
+			configs: []hclconfig{
+				{
+					path: "/stack",
+					add: Globals(
+						Number("a", 1),
+						Expr("b", "undefined.value"),
+					),
+				},
+			},
+			want: Globals(),
+		},
+		{
+			name:   "unreferenced failing globals are ignored",
+			layout: []string{"s:stack"},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: Globals(
+						Number("a", 1),
+						Expr("fail", "global.undefined"),
+					),
+				},
+				{
+					path: "/stack",
+					add: Globals(
+						Expr("b", "global.a + 1"),
+						Expr("c", "global.fail"),
+					),
+				},
+			},
+			refs: []string{`"${global.b}"`},
+			want: Globals(
+				Number("a", 1),
+				Number("b", 2),
+			),
+		},
+		{
+			name:   "referenced failing globals are reported",
+			layout: []string{"s:stack"},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: Globals(
+						Number("a", 1),
+						Expr("fail", "global.undefined"),
+					),
+				},
+				{
+					path: "/stack",
+					add: Globals(
+						Expr("c", "global.fail"),
+					),
+				},
+			},
+			refs:    []string{`tm_upper(global.c)`},
+			wantErr: errors.E(globals.ErrEval),
+		},
+		{
+			name:   "nested references include parent and child definitions",
+			layout: []string{"s:stack"},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: Globals(
+						Expr("obj", `{ a = 1 }`),
+						Expr("other", `{ a = 1 }`),
+					),
+				},
+				{
+					path: "/stack",
+					add: Globals(
+						Labels("obj", "child"),
+						Expr("b", "global.obj.a"),
+					),
+				},
+			},
+			refs: []string{`global.obj.child`},
+			want: Globals(
+				EvalExpr(t, "obj", `{
+					a = 1
+					child = {
+						b = 1
+					}
+				}`),
+			),
+		},
+		{
+			name:   "dynamic reference evaluates all globals",
+			layout: []string{"s:stack"},
+			configs: []hclconfig{
+				{
+					path: "/stack",
+					add: Globals(
+						Number("a", 1),
+						Number("b", 2),
+					),
+				},
+			},
+			refs: []string{`global[tm_lower("A")]`},
+			want: Globals(
+				Number("a", 1),
+				Number("b", 2),
+			),
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := sandbox.New(t)
+			s.BuildTree(tc.layout)
+			for _, cfg := range tc.configs {
+				test.AppendFile(t, filepath.Join(s.RootDir(), cfg.path),
+					config.DefaultFilename, cfg.add.String())
+			}
+
+			var refs []hhcl.Expression
+			for _, ref := range tc.refs {
+				expr, err := ast.ParseExpression(ref, "<test>")
+				assert.NoError(t, err)
+				refs = append(refs, expr)
+			}
+
+			root := s.ReloadConfig()
+			st, err := config.LoadStack(root, project.NewPath("/stack"))
+			assert.NoError(t, err)
+
+			report := globals.ForStackRefs(root, st, refs)
+			errtest.Assert(t, report.AsError(), tc.wantErr)
+			if tc.wantErr != nil {
+				return
+			}
+
+			gotAttrs := report.Globals.AsValueMap()
+			wantAttrs := tc.want.AttributesValues()
+			assert.EqualInts(t, len(wantAttrs), len(gotAttrs),
+				"got globals %v", gotAttrs)
+			for name, want := range wantAttrs {
+				got, ok := gotAttrs[name]
+				assert.IsTrue(t, ok, "global %q not found", name)
+				if !got.RawEquals(want) {
+					t.Fatalf("global %q: got %s, want %s", name, got.GoString(), want.GoString())
+				}
+			}
+		})
+	}
+}
+
+func TestLoadGlobalsLazy(t *testing.T) {
+	t.Parallel()
+
+	type testcase struct {
+		name    string
+		configs []hclconfig
+		want    *hclwrite.Block
+		wantErr error
+	}
+
+	for _, tc := range []testcase{
+		{
+			name: "unreferenced globals are not evaluated",
+			configs: []hclconfig{
+				{
+					path: "/stack",
+					add: Globals(
+						Number("a", 1),
+						Expr("b", "tm_element([], 0)"),
+					),
+				},
+			},
+			want: Globals(
+				Number("a", 1),
+			),
+		},
+		{
+			name: "unreferenced global referencing undefined global fails",
+			configs: []hclconfig{
+				{
+					path: "/stack",
+					add: Globals(
+						Number("a", 1),
+						Expr("b", "global.undefined"),
+					),
+				},
+			},
+			wantErr: errors.E(globals.ErrEval),
+		},
+		{
+			name: "unreferenced global with unknown namespace fails",
+			configs: []hclconfig{
+				{
+					path: "/stack",
+					add: Globals(
+						Number("a", 1),
+						Expr("b", "undefined.value"),
+					),
+				},
+			},
+			wantErr: errors.E(globals.ErrEval),
+		},
+		{
+			name: "unreferenced global calling unknown function fails",
+			configs: []hclconfig{
+				{
+					path: "/stack",
+					add: Globals(
+						Number("a", 1),
+						Expr("b", "tm_undefined(1)"),
+					),
+				},
+			},
+			wantErr: errors.E(globals.ErrEval),
+		},
+		{
+			name: "overridden unreferenced global is not checked",
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: Globals(
+						Expr("b", "global.undefined"),
+					),
+				},
+				{
+					path: "/stack",
+					add: Globals(
+						Number("a", 1),
+						Number("b", 2),
+					),
+				},
+			},
+			want: Globals(
+				Number("a", 1),
+			),
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := sandbox.New(t)
+			s.BuildTree([]string{"s:stack"})
+			for _, cfg := range tc.configs {
+				test.AppendFile(t, filepath.Join(s.RootDir(), cfg.path),
+					config.DefaultFilename, cfg.add.String())
+			}
+
+			expr, err := ast.ParseExpression("global.a", "<test>")
+			assert.NoError(t, err)
+
+			root := s.ReloadConfig()
+			st, err := config.LoadStack(root, project.NewPath("/stack"))
+			assert.NoError(t, err)
+
+			report := globals.ForStackLazy(root, st, []hhcl.Expression{expr})
+			errtest.Assert(t, report.AsError(), tc.wantErr)
+			if tc.wantErr != nil {
+				return
+			}
+
+			gotAttrs := report.Globals.AsValueMap()
+			wantAttrs := tc.want.AttributesValues()
+			assert.EqualInts(t, len(wantAttrs), len(gotAttrs),
+				"got globals %v", gotAttrs)
+			for name, want := range wantAttrs {
+				got, ok := gotAttrs[name]
+				assert.IsTrue(t, ok, "global %q not found", name)
+				if !got.RawEquals(want) {
+					t.Fatalf("global %q: got %s, want %s", name, got.GoString(), want.GoString())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,519 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package globals
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	hhcl "github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/terramate-io/terramate/config"
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/fs"
+	"github.com/terramate-io/terramate/hcl/eval"
+	"github.com/terramate-io/terramate/project"
+)
+
+// ErrRename indicates that a global can't be renamed.
+const ErrRename errors.Kind = "renaming global"
+
+// RenamedFile is a file changed by renaming a global.
+type RenamedFile struct {
+	// Path is the host path of the file.
+	Path string
+
+	// Original is the content of the file before the rename.
+	Original []byte
+
+	// Content is the content of the file after the rename.
+	Content []byte
+}
+
+// Rename renames the last key of the global at the accessor path to newName.
+// The global is renamed in all its definitions and references, including the
+// ones inside generate blocks, in every directory whose configuration is
+// evaluated in the directories where the global is visible: the directory
+// defining it, all its child directories, all its parent directories and the
+// files imported by them. If the global is defined in more than one parent of
+// dir, the topmost one is used, so all the directories the definition is
+// visible are changed. References using the index syntax, eg.: global["a"],
+// and references to the globals of the affected stacks, eg.:
+// terramate.stacks.by_id["id"].global.a, are renamed too.
+//
+// It fails if the global is not defined by an attribute or a label of a
+// globals block in dir or its parents, like a key defined inside an object
+// expression, eg.: a = { b = 1 } when renaming global.a.b. It also fails if
+// a changed file is vendored from a remote source or is evaluated by another
+// directory defining the same global, as the rename would break it.
+//
+// The overlay has the content of files to be used instead of the content on
+// disk and can be nil. The changed files are returned but not saved.
+func Rename(
+	root *config.Root,
+	dir project.Path,
+	path eval.ObjectPath,
+	newName string,
+	overlay map[string][]byte,
+) ([]RenamedFile, error) {
+	if len(path) == 0 {
+		return nil, errors.E(ErrRename, "empty global path")
+	}
+	if !hclsyntax.ValidIdentifier(newName) {
+		return nil, errors.E(ErrRename, "%q is not a valid global name", newName)
+	}
+
+	newPath := make(eval.ObjectPath, len(path))
+	copy(newPath, path)
+	newPath[len(path)-1] = newName
+
+	tree, ok := root.Lookup(dir)
+	if !ok {
+		return nil, errors.E(ErrRename, "configuration at %s not found", dir)
+	}
+
+	r := renamer{
+		root:    root,
+		overlay: overlay,
+		files:   map[string]*renameFile{},
+	}
+
+	var scope *config.Tree
+	for parent := tree; parent != nil; parent = parent.Parent {
+		defines, err := r.dirDefines(parent, path)
+		if err != nil {
+			return nil, err
+		}
+		if defines {
+			scope = parent
+		}
+	}
+	if scope == nil {
+		return nil, errors.E(ErrRename,
+			"no definition of global.%s found in %s or its parent directories, only globals defined by an attribute or a label of a globals block can be renamed",
+			strings.Join(path, "."), dir)
+	}
+
+	// the parents are evaluated in all the directories of the scope, so
+	// their references are renamed too.
+	var trees config.List[*config.Tree]
+	for parent := scope.Parent; parent != nil; parent = parent.Parent {
+		trees = append(trees, parent)
+	}
+	scopeTrees := scope.AsList()
+	sort.Sort(scopeTrees)
+	trees = append(trees, scopeTrees...)
+
+	renamedFiles := map[string]bool{}
+	for _, tree := range trees {
+		files, err := r.dirFiles(tree)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if renamedFiles[file.path] {
+				continue
+			}
+			renamedFiles[file.path] = true
+			if fileDefines(file.body, newPath) {
+				return nil, errors.E(ErrRename, "global.%s is already defined in %s",
+					strings.Join(newPath, "."), file.path)
+			}
+			renameInBody(file.body, path, newPath)
+		}
+	}
+
+	if err := r.checkRedefinitions(scope, path, newPath); err != nil {
+		return nil, err
+	}
+
+	stacks := newStackKeys(root, scope)
+	for _, tree := range root.Tree().AsList() {
+		files, err := r.dirFiles(tree)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if !file.stacksRenamed {
+				file.stacksRenamed = true
+				renameStacksReferences(file.body, stacks, path, newName)
+			}
+		}
+	}
+
+	var renamed []RenamedFile
+	for _, file := range r.files {
+		if !file.changed() {
+			continue
+		}
+		if file.vendored {
+			return nil, errors.E(ErrRename,
+				"%s references global.%s but is vendored from a remote source",
+				file.path, strings.Join(path, "."))
+		}
+		renamed = append(renamed, RenamedFile{
+			Path:     file.path,
+			Original: file.original,
+			Content:  file.hcl.Bytes(),
+		})
+	}
+	sort.Slice(renamed, func(i, j int) bool {
+		return renamed[i].Path < renamed[j].Path
+	})
+	return renamed, nil
+}
+
+type renamer struct {
+	root    *config.Root
+	overlay map[string][]byte
+
+	// files caches the parsed files by host path, so the changes on files
+	// imported by multiple directories are shared.
+	files map[string]*renameFile
+}
+
+type renameFile struct {
+	path     string
+	original []byte
+	hcl      *hclwrite.File
+	body     *hclwrite.Body
+	vendored bool
+
+	stacksRenamed bool
+}
+
+// changed tells if the file was changed by the rename.
+func (file *renameFile) changed() bool {
+	return !bytes.Equal(file.hcl.Bytes(), file.original)
+}
+
+// dirFiles parses the Terramate files of the tree directory and the files
+// imported by it.
+func (r renamer) dirFiles(tree *config.Tree) ([]*renameFile, error) {
+	filenames, err := fs.ListTerramateFiles(tree.HostDir())
+	if err != nil {
+		return nil, errors.E(ErrRename, err)
+	}
+
+	var paths []string
+	for _, filename := range filenames {
+		paths = append(paths, filepath.Join(tree.HostDir(), filename))
+	}
+	for _, imported := range tree.Node.ImportedFiles {
+		paths = append(paths, imported.HostPath(r.root.HostDir()))
+	}
+
+	var files []*renameFile
+	for _, path := range paths {
+		file, err := r.file(path)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	for _, vendored := range tree.Node.VendoredImports {
+		if file, ok := r.files[vendored.HostPath(r.root.HostDir())]; ok {
+			file.vendored = true
+		}
+	}
+	return files, nil
+}
+
+// file parses the file at the host path, or returns it from the cache if it
+// was already parsed.
+func (r renamer) file(path string) (*renameFile, error) {
+	if file, ok := r.files[path]; ok {
+		return file, nil
+	}
+	content, ok := r.overlay[path]
+	if !ok {
+		var err error
+		content, err = os.ReadFile(path)
+		if err != nil {
+			return nil, errors.E(ErrRename, err, "reading %s", path)
+		}
+	}
+	hclfile, diags := hclwrite.ParseConfig(content, path, hhcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, errors.E(ErrRename, diags)
+	}
+	file := &renameFile{
+		path:     path,
+		original: content,
+		hcl:      hclfile,
+		body:     hclfile.Body(),
+	}
+	r.files[path] = file
+	return file, nil
+}
+
+// dirDefines tells if the global path is defined in the tree directory.
+func (r renamer) dirDefines(tree *config.Tree, path eval.ObjectPath) (bool, error) {
+	files, err := r.dirFiles(tree)
+	if err != nil {
+		return false, err
+	}
+	for _, file := range files {
+		if fileDefines(file.body, path) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// checkRedefinitions fails if a directory outside the scope evaluates a file
+// changed by the rename but has its own definition of the global path or of
+// the new path, like a sibling of the scope defining the same global
+// referenced by their common parent. It must be called after the rename, so
+// the renamed definitions are not taken into account.
+func (r renamer) checkRedefinitions(scope *config.Tree, path, newPath eval.ObjectPath) error {
+	for _, tree := range r.root.Tree().AsList() {
+		if isInsideDir(tree.Dir(), scope.Dir()) {
+			continue
+		}
+		var redefinedAt string
+		for parent := tree; parent != nil && redefinedAt == ""; parent = parent.Parent {
+			if isInsideDir(scope.Dir(), parent.Dir()) {
+				break
+			}
+			files, err := r.dirFiles(parent)
+			if err != nil {
+				return err
+			}
+			for _, file := range files {
+				if fileDefines(file.body, path) ||
+					(!file.changed() && fileDefines(file.body, newPath)) {
+					redefinedAt = file.path
+					break
+				}
+			}
+		}
+		if redefinedAt == "" {
+			continue
+		}
+		for parent := tree; parent != nil; parent = parent.Parent {
+			files, err := r.dirFiles(parent)
+			if err != nil {
+				return err
+			}
+			for _, file := range files {
+				if file.changed() {
+					return errors.E(ErrRename,
+						"%s is evaluated in %s, which has another definition in %s",
+						file.path, tree.Dir(), redefinedAt)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// stackKeys are the keys of terramate.stacks.by_id and terramate.stacks.by_path
+// accessing the stacks where the renamed global is visible.
+type stackKeys struct {
+	byID   map[string]bool
+	byPath map[string]bool
+}
+
+// newStackKeys returns the keys of the stacks inside the scope. Like the
+// terramate.stacks.by_id namespace, only the first stack with a given ID is
+// accessible by it.
+func newStackKeys(root *config.Root, scope *config.Tree) stackKeys {
+	keys := stackKeys{
+		byID:   map[string]bool{},
+		byPath: map[string]bool{},
+	}
+	seenIDs := map[string]bool{}
+	for _, stack := range root.Tree().Stacks() {
+		inScope := isInsideDir(stack.Dir(), scope.Dir())
+		if inScope {
+			keys.byPath[stack.Dir().String()] = true
+		}
+		id := stack.Node.Stack.ID
+		if id == "" || seenIDs[strings.ToLower(id)] {
+			continue
+		}
+		seenIDs[strings.ToLower(id)] = true
+		if inScope {
+			keys.byID[id] = true
+		}
+	}
+	return keys
+}
+
+// isInsideDir tells if the path is the dir or is inside it.
+func isInsideDir(path, dir project.Path) bool {
+	return path == dir || dir.String() == "/" || path.HasPrefix(dir.String()+"/")
+}
+
+// fileDefines tells if the body has a globals block defining the global path,
+// either as an attribute or as a label.
+func fileDefines(body *hclwrite.Body, path eval.ObjectPath) bool {
+	for _, block := range body.Blocks() {
+		if block.Type() != "globals" {
+			continue
+		}
+		labels := block.Labels()
+		if isObjectPathPrefix(path, labels) {
+			return true
+		}
+		if len(labels) == len(path)-1 && isObjectPathPrefix(labels, path) &&
+			block.Body().GetAttribute(path[len(path)-1]) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// renameInBody renames the global path to the new path in the references and
+// definitions of the body and all its nested blocks.
+func renameInBody(body *hclwrite.Body, path, newPath eval.ObjectPath) {
+	for _, attr := range body.Attributes() {
+		for _, traversal := range attr.Expr().Variables() {
+			renameReference(traversal, path, newPath[len(newPath)-1])
+		}
+	}
+
+	for _, block := range body.Blocks() {
+		if block.Type() == "globals" {
+			renameDefinition(block, path, newPath)
+		}
+		renameInBody(block.Body(), path, newPath)
+	}
+}
+
+// renameStacksReferences renames the last key of the global path to newName
+// in the terramate.stacks.by_id and terramate.stacks.by_path references to the
+// globals of the given stacks, in the body and all its nested blocks.
+func renameStacksReferences(body *hclwrite.Body, stacks stackKeys, path eval.ObjectPath, newName string) {
+	for _, attr := range body.Attributes() {
+		for _, traversal := range attr.Expr().Variables() {
+			renameStacksReference(traversal, stacks, path, newName)
+		}
+	}
+	for _, block := range body.Blocks() {
+		renameStacksReferences(block.Body(), stacks, path, newName)
+	}
+}
+
+// renameReference renames the last key of the global path to newName if the
+// traversal references the global path or any of its keys. The keys can be
+// accessed as attributes or indexed by a literal string.
+func renameReference(traversal *hclwrite.Traversal, path eval.ObjectPath, newName string) {
+	// WHY: hclwrite.Expression.RenameVariablePrefix only handles attribute
+	// access, so the tokens, which are shared with the file, are changed in
+	// place like in renameDefinition.
+	tokens := traversal.BuildTokens(nil)
+	if len(tokens) == 0 || tokens[0].Type != hclsyntax.TokenIdent ||
+		string(tokens[0].Bytes) != "global" {
+		return
+	}
+	renameKeys(tokens, 1, path, newName)
+}
+
+// renameStacksReference renames the last key of the global path to newName if
+// the traversal references the global path, or any of its keys, of one of the
+// stacks, eg.: terramate.stacks.by_id["id"].global.a.
+func renameStacksReference(traversal *hclwrite.Traversal, stacks stackKeys, path eval.ObjectPath, newName string) {
+	tokens := traversal.BuildTokens(nil)
+	if len(tokens) == 0 || tokens[0].Type != hclsyntax.TokenIdent ||
+		string(tokens[0].Bytes) != "terramate" {
+		return
+	}
+
+	var keys []string
+	i := 1
+	for len(keys) < 4 {
+		key, next, ok := traversalKey(tokens, i)
+		if !ok {
+			return
+		}
+		keys = append(keys, string(key.Bytes))
+		i = next
+	}
+	if keys[0] != "stacks" || keys[3] != "global" {
+		return
+	}
+	switch {
+	case keys[1] == "by_id" && stacks.byID[keys[2]]:
+	case keys[1] == "by_path" && stacks.byPath[keys[2]]:
+	default:
+		return
+	}
+	renameKeys(tokens, i, path, newName)
+}
+
+// renameKeys renames the last key of the path to newName if the keys accessed
+// by the tokens starting at index i match the path or any of its keys.
+func renameKeys(tokens hclwrite.Tokens, i int, path eval.ObjectPath, newName string) {
+	for depth, key := range path {
+		keyToken, next, ok := traversalKey(tokens, i)
+		if !ok || string(keyToken.Bytes) != key {
+			return
+		}
+		if depth == len(path)-1 {
+			keyToken.Bytes = []byte(newName)
+		}
+		i = next
+	}
+}
+
+// traversalKey returns the token of the key accessed at index i of the
+// traversal tokens, either as an attribute or indexed by a literal string, and
+// the index of the next key.
+func traversalKey(tokens hclwrite.Tokens, i int) (*hclwrite.Token, int, bool) {
+	switch {
+	case matchTokens(tokens[i:], hclsyntax.TokenDot, hclsyntax.TokenIdent):
+		return tokens[i+1], i + 2, true
+	case matchTokens(tokens[i:], hclsyntax.TokenOBrack, hclsyntax.TokenOQuote,
+		hclsyntax.TokenQuotedLit, hclsyntax.TokenCQuote, hclsyntax.TokenCBrack):
+		return tokens[i+2], i + 5, true
+	}
+	return nil, i, false
+}
+
+// matchTokens tells if the tokens start with the given token types.
+func matchTokens(tokens hclwrite.Tokens, types ...hclsyntax.TokenType) bool {
+	if len(tokens) < len(types) {
+		return false
+	}
+	for i, typ := range types {
+		if tokens[i].Type != typ {
+			return false
+		}
+	}
+	return true
+}
+
+// renameDefinition renames the global path defined by the globals block.
+func renameDefinition(block *hclwrite.Block, path, newPath eval.ObjectPath) {
+	labels := block.Labels()
+	if isObjectPathPrefix(path, labels) {
+		newLabels := append([]string{}, labels...)
+		newLabels[len(path)-1] = newPath[len(path)-1]
+		block.SetLabels(newLabels)
+		return
+	}
+
+	if len(labels) != len(path)-1 || !isObjectPathPrefix(labels, path) {
+		return
+	}
+	attr := block.Body().GetAttribute(path[len(path)-1])
+	if attr == nil {
+		return
+	}
+	// WHY: hclwrite has no API for renaming attributes, but the tokens are
+	// shared with the file, so the name token (the first identifier, after
+	// any leading comments) is changed in place.
+	for _, tok := range attr.BuildTokens(nil) {
+		if tok.Type == hclsyntax.TokenIdent {
+			tok.Bytes = []byte(newPath[len(path)-1])
+			return
+		}
+	}
+}
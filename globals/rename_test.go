@@ -0,0 +1,427 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package globals_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/madlambda/spells/assert"
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/globals"
+	"github.com/terramate-io/terramate/hcl/eval"
+	"github.com/terramate-io/terramate/project"
+	"github.com/terramate-io/terramate/test/sandbox"
+)
+
+func TestRenameGlobal(t *testing.T) {
+	t.Parallel()
+
+	type testcase struct {
+		name    string
+		layout  []string
+		dir     string
+		path    eval.ObjectPath
+		newName string
+		overlay map[string]string
+		want    map[string]string
+		wantErr error
+	}
+
+	for _, tc := range []testcase{
+		{
+			name: "attribute with references in child stacks",
+			layout: []string{
+				`f:globals.tm:# environment of the stacks
+globals {
+  env   = "prod" # inline comment
+  other = global.env
+}
+`,
+				`f:stacks/globals.tm:globals "cfg" {
+  region = "${global.env}-us"
+}
+`,
+				"s:stacks/a",
+				`f:stacks/a/gen.tm:generate_hcl "env.tf" {
+  content {
+    env = global.env
+    tm_dynamic "env" {
+      for_each = [global.env]
+      content {
+        name = global.environment_name
+      }
+    }
+  }
+}
+`,
+				`f:stacks/b/globals.tm:globals {
+  env = "dev"
+}
+`,
+			},
+			dir:     "/stacks/a",
+			path:    eval.ObjectPath{"env"},
+			newName: "environment",
+			want: map[string]string{
+				"globals.tm": `# environment of the stacks
+globals {
+  environment = "prod" # inline comment
+  other       = global.environment
+}
+`,
+				"stacks/globals.tm": `globals "cfg" {
+  region = "${global.environment}-us"
+}
+`,
+				"stacks/a/gen.tm": `generate_hcl "env.tf" {
+  content {
+    env = global.environment
+    tm_dynamic "env" {
+      for_each = [global.environment]
+      content {
+        name = global.environment_name
+      }
+    }
+  }
+}
+`,
+				"stacks/b/globals.tm": `globals {
+  environment = "dev"
+}
+`,
+			},
+		},
+		{
+			name: "labeled globals and nested keys",
+			layout: []string{
+				`f:globals.tm:globals "cfg" {
+  region = "us"
+}
+
+globals "cfg" "db" {
+  name = global.cfg.region
+}
+`,
+				"s:stack",
+				`f:stack/stack.tm:globals {
+  db = global.cfg.db.name
+  r  = global.cfg.region
+}
+`,
+			},
+			dir:     "/stack",
+			path:    eval.ObjectPath{"cfg"},
+			newName: "config",
+			want: map[string]string{
+				"globals.tm": `globals "config" {
+  region = "us"
+}
+
+globals "config" "db" {
+  name = global.config.region
+}
+`,
+				"stack/stack.tm": `globals {
+  db = global.config.db.name
+  r  = global.config.region
+}
+`,
+			},
+		},
+		{
+			name: "only the directories where the definition is visible",
+			layout: []string{
+				`f:a/globals.tm:globals {
+  name = "a"
+}
+`,
+				`f:a/child/globals.tm:globals {
+  ref = global.name
+}
+`,
+				`f:b/globals.tm:globals {
+  ref = global.name
+}
+`,
+			},
+			dir:     "/a/child",
+			path:    eval.ObjectPath{"name"},
+			newName: "label",
+			want: map[string]string{
+				"a/globals.tm": `globals {
+  label = "a"
+}
+`,
+				"a/child/globals.tm": `globals {
+  ref = global.label
+}
+`,
+			},
+		},
+		{
+			name: "references in parent directories",
+			layout: []string{
+				`f:generate.tm:generate_hcl "file.hcl" {
+  content {
+    name = global.name
+  }
+}
+`,
+				`f:globals.tm:globals {
+  upper = upper(global.name)
+}
+`,
+				"s:stacks/a",
+				`f:stacks/a/globals.tm:globals {
+  name = "a"
+}
+`,
+			},
+			dir:     "/stacks/a",
+			path:    eval.ObjectPath{"name"},
+			newName: "label",
+			want: map[string]string{
+				"generate.tm": `generate_hcl "file.hcl" {
+  content {
+    name = global.label
+  }
+}
+`,
+				"globals.tm": `globals {
+  upper = upper(global.label)
+}
+`,
+				"stacks/a/globals.tm": `globals {
+  label = "a"
+}
+`,
+			},
+		},
+		{
+			name: "imported definition",
+			layout: []string{
+				`f:imports/globals.tm.hcl:globals {
+  name = "imported"
+}
+`,
+				"s:stacks/a",
+				`f:stacks/a/import.tm:import {
+  source = "/imports/globals.tm.hcl"
+}
+`,
+				`f:stacks/a/globals.tm:globals {
+  ref = global.name
+}
+`,
+			},
+			dir:     "/stacks/a",
+			path:    eval.ObjectPath{"name"},
+			newName: "label",
+			want: map[string]string{
+				"imports/globals.tm.hcl": `globals {
+  label = "imported"
+}
+`,
+				"stacks/a/globals.tm": `globals {
+  ref = global.label
+}
+`,
+			},
+		},
+		{
+			name: "references to the globals of the stacks",
+			layout: []string{
+				"s:stacks/a:id=stack-a",
+				`f:stacks/a/globals.tm:globals {
+  name = "a"
+}
+`,
+				"s:stacks/b:id=stack-b",
+				`f:stacks/b/globals.tm:globals {
+  name = "b"
+  a    = terramate.stacks.by_id["stack-a"].global.name
+  b    = terramate.stacks.by_path["/stacks/a"].global["name"]
+  c    = terramate.stacks.by_id["stack-b"].global.name
+}
+`,
+			},
+			dir:     "/stacks/a",
+			path:    eval.ObjectPath{"name"},
+			newName: "label",
+			want: map[string]string{
+				"stacks/a/globals.tm": `globals {
+  label = "a"
+}
+`,
+				"stacks/b/globals.tm": `globals {
+  name = "b"
+  a    = terramate.stacks.by_id["stack-a"].global.label
+  b    = terramate.stacks.by_path["/stacks/a"].global["label"]
+  c    = terramate.stacks.by_id["stack-b"].global.name
+}
+`,
+			},
+		},
+		{
+			name: "fails if a parent reference is evaluated with another definition",
+			layout: []string{
+				`f:globals.tm:globals {
+  upper = upper(global.name)
+}
+`,
+				`f:stacks/a/globals.tm:globals {
+  name = "a"
+}
+`,
+				`f:stacks/b/globals.tm:globals {
+  name = "b"
+}
+`,
+			},
+			dir:     "/stacks/a",
+			path:    eval.ObjectPath{"name"},
+			newName: "label",
+			wantErr: errors.E(globals.ErrRename),
+		},
+		{
+			name: "overlay is used instead of disk content",
+			layout: []string{
+				`f:globals.tm:globals {
+  a = 1
+}
+`,
+			},
+			dir:     "/",
+			path:    eval.ObjectPath{"a"},
+			newName: "b",
+			overlay: map[string]string{
+				"globals.tm": "globals {\n  a = 2\n}\n",
+			},
+			want: map[string]string{
+				"globals.tm": "globals {\n  b = 2\n}\n",
+			},
+		},
+		{
+			name: "index references",
+			layout: []string{
+				`f:globals.tm:globals "cfg" {
+  region = "us"
+}
+`,
+				`f:dir/globals.tm:globals {
+  a = global["cfg"]["region"]
+  b = global.cfg["region"]
+  c = "${global["cfg"].region}-1"
+  d = global.cfg["other"]
+}
+`,
+			},
+			dir:     "/",
+			path:    eval.ObjectPath{"cfg", "region"},
+			newName: "location",
+			want: map[string]string{
+				"globals.tm": "globals \"cfg\" {\n  location = \"us\"\n}\n",
+				"dir/globals.tm": `globals {
+  a = global["cfg"]["location"]
+  b = global.cfg["location"]
+  c = "${global["cfg"].location}-1"
+  d = global.cfg["other"]
+}
+`,
+			},
+		},
+		{
+			name: "fails if global is not defined",
+			layout: []string{
+				`f:globals.tm:globals {
+  a = 1
+}
+`,
+			},
+			dir:     "/",
+			path:    eval.ObjectPath{"typo"},
+			newName: "b",
+			wantErr: errors.E(globals.ErrRename),
+		},
+		{
+			name: "fails if global is defined inside an object expression",
+			layout: []string{
+				`f:globals.tm:globals {
+  a = { b = 1 }
+  c = global.a.b
+}
+`,
+			},
+			dir:     "/",
+			path:    eval.ObjectPath{"a", "b"},
+			newName: "d",
+			wantErr: errors.E(globals.ErrRename),
+		},
+		{
+			name: "fails if new name is already defined",
+			layout: []string{
+				`f:globals.tm:globals {
+  a = 1
+}
+`,
+				`f:dir/globals.tm:globals {
+  b = global.a
+}
+`,
+			},
+			dir:     "/",
+			path:    eval.ObjectPath{"a"},
+			newName: "b",
+			wantErr: errors.E(globals.ErrRename),
+		},
+		{
+			name: "fails if new name is invalid",
+			layout: []string{
+				`f:globals.tm:globals {
+  a = 1
+}
+`,
+			},
+			dir:     "/",
+			path:    eval.ObjectPath{"a"},
+			newName: "1a",
+			wantErr: errors.E(globals.ErrRename),
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := sandbox.NoGit(t)
+			s.BuildTree(tc.layout)
+			root := s.ReloadConfig()
+
+			var overlay map[string][]byte
+			if tc.overlay != nil {
+				overlay = map[string][]byte{}
+				for path, content := range tc.overlay {
+					overlay[filepath.Join(s.RootDir(), path)] = []byte(content)
+				}
+			}
+
+			renamed, err := globals.Rename(root, project.NewPath(tc.dir), tc.path, tc.newName, overlay)
+			assert.IsError(t, err, tc.wantErr)
+			if tc.wantErr != nil {
+				return
+			}
+
+			got := map[string]string{}
+			for _, file := range renamed {
+				rel, err := filepath.Rel(s.RootDir(), file.Path)
+				assert.NoError(t, err)
+				got[filepath.ToSlash(rel)] = string(file.Content)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Fatalf("unexpected renamed files, want(-) got(+):\n%s", diff)
+			}
+		})
+	}
+}
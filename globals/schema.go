@@ -0,0 +1,242 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package globals
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/terramate-io/terramate/config"
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/hcl"
+	"github.com/terramate-io/terramate/hcl/eval"
+	"github.com/terramate-io/terramate/hcl/info"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+// ErrSchemaViolation indicates that an evaluated global does not conform to
+// its type constraint defined in a globals_schema block.
+const ErrSchemaViolation errors.Kind = "global schema violation"
+
+// Schema is the type constraint of a single global.
+type Schema struct {
+	// Path is the accessor path of the global.
+	Path eval.ObjectPath
+
+	// Type is the type constraint of the global.
+	Type cty.Type
+
+	// Defaults are the default values of optional object attributes.
+	Defaults *typeexpr.Defaults
+
+	// Origin is where the type constraint is defined.
+	Origin info.Range
+}
+
+// LoadSchemas loads all globals_schema definitions that apply to the given
+// tree, navigating from the tree up to the root dir. Type constraints defined
+// closer to the tree have precedence over the ones defined closer to the root.
+// The schemas are returned sorted by the global accessor path.
+func LoadSchemas(tree *config.Tree) ([]Schema, error) {
+	schemas := map[string]Schema{}
+	for cfg := tree; cfg != nil; cfg = cfg.Parent {
+		for _, block := range cfg.Node.GlobalsSchema.AsList() {
+			for _, attr := range block.Attributes.SortedList() {
+				path := append(eval.ObjectPath{}, block.Labels...)
+				path = append(path, attr.Name)
+
+				key := strings.Join(path, ".")
+				if _, ok := schemas[key]; ok {
+					continue
+				}
+
+				typ, defaults, diags := typeexpr.TypeConstraintWithDefaults(attr.Expr)
+				if diags.HasErrors() {
+					return nil, errors.E(hcl.ErrTerramateSchema, attr.Expr.Range(), diags,
+						"globals_schema attribute %q is not a valid type constraint", attr.Name)
+				}
+
+				schemas[key] = Schema{
+					Path:     path,
+					Type:     typ,
+					Defaults: defaults,
+					Origin:   attr.Range,
+				}
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(schemas))
+	for key := range schemas {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	res := make([]Schema, 0, len(keys))
+	for _, key := range keys {
+		res = append(res, schemas[key])
+	}
+	return res, nil
+}
+
+// applySchemas checks that the evaluated globals conform to the schemas.
+// Values are never coerced, a string global doesn't conform to a number type
+// even if it could be converted. Once a global conforms, it's set to its
+// declared type, so a tuple becomes a list and the defaults of the optional
+// object attributes are applied. Globals that are not defined are ignored.
+// Any violation is added to the report errors, pointing to the expression
+// which defines the offending global.
+func applySchemas(report *EvalReport, exprs HierarchicalExprs, schemas []Schema) {
+	for _, schema := range schemas {
+		value, ok := report.Globals.GetKeyPath(schema.Path)
+		if !ok {
+			continue
+		}
+
+		raw, _ := ctyValue(value).UnmarkDeep()
+		if schema.Defaults != nil {
+			raw = schema.Defaults.Apply(raw)
+		}
+
+		key := NewGlobalAttrPath(schema.Path[:len(schema.Path)-1], schema.Path[len(schema.Path)-1])
+		err := conforms(raw, schema.Type)
+		var converted cty.Value
+		if err == nil {
+			converted, err = convert.Convert(raw, schema.Type)
+		}
+		if err != nil {
+			expr, found := exprs.Lookup(schema.Path)
+			origin := expr.Origin
+			if !found {
+				origin = schema.Origin
+			}
+			report.Errors[key] = EvalError{
+				Expr: expr,
+				Err: errors.E(ErrSchemaViolation, origin,
+					"global.%s must be %s (defined at %s): %s",
+					strings.Join(schema.Path, "."),
+					typeexpr.TypeString(schema.Type),
+					schema.Origin, err.Error()),
+			}
+			continue
+		}
+
+		err = report.Globals.SetAt(schema.Path, eval.NewValue(converted, value.Info()))
+		if err != nil {
+			report.Errors[key] = EvalError{
+				Err: errors.E(ErrSchemaViolation, schema.Origin, err),
+			}
+		}
+	}
+}
+
+// conforms checks that the value conforms to the type constraint without
+// any conversion of primitive values. Tuples conform to lists and sets, and
+// objects conform to maps, if all their elements conform to the element type.
+func conforms(val cty.Value, typ cty.Type) error {
+	if typ == cty.DynamicPseudoType || val.IsNull() || !val.IsKnown() {
+		return nil
+	}
+
+	valtyp := val.Type()
+	switch {
+	case typ.IsPrimitiveType():
+		if !valtyp.Equals(typ) {
+			return errors.E("%s required, but have %s",
+				typ.FriendlyName(), valtyp.FriendlyName())
+		}
+		return nil
+
+	case typ.IsListType(), typ.IsSetType():
+		if !valtyp.IsListType() && !valtyp.IsSetType() && !valtyp.IsTupleType() {
+			return errors.E("%s required, but have %s",
+				typ.FriendlyName(), valtyp.FriendlyName())
+		}
+		i := 0
+		for it := val.ElementIterator(); it.Next(); i++ {
+			_, elem := it.Element()
+			if err := conforms(elem, typ.ElementType()); err != nil {
+				return errors.E(err, "element %d", i)
+			}
+		}
+		return nil
+
+	case typ.IsMapType():
+		if !valtyp.IsMapType() && !valtyp.IsObjectType() {
+			return errors.E("%s required, but have %s",
+				typ.FriendlyName(), valtyp.FriendlyName())
+		}
+		for it := val.ElementIterator(); it.Next(); {
+			key, elem := it.Element()
+			if err := conforms(elem, typ.ElementType()); err != nil {
+				return errors.E(err, "element %q", key.AsString())
+			}
+		}
+		return nil
+
+	case typ.IsObjectType():
+		if !valtyp.IsObjectType() && !valtyp.IsMapType() {
+			return errors.E("%s required, but have %s",
+				typ.FriendlyName(), valtyp.FriendlyName())
+		}
+		attrs := val.AsValueMap()
+		for name := range attrs {
+			if !typ.HasAttribute(name) {
+				return errors.E("unexpected attribute %q", name)
+			}
+		}
+		for name, attrtyp := range typ.AttributeTypes() {
+			attr, ok := attrs[name]
+			if !ok {
+				if typ.AttributeOptional(name) {
+					continue
+				}
+				return errors.E("attribute %q is required", name)
+			}
+			if err := conforms(attr, attrtyp); err != nil {
+				return errors.E(err, "attribute %q", name)
+			}
+		}
+		return nil
+
+	case typ.IsTupleType():
+		if !valtyp.IsTupleType() && !valtyp.IsListType() {
+			return errors.E("%s required, but have %s",
+				typ.FriendlyName(), valtyp.FriendlyName())
+		}
+		elemtypes := typ.TupleElementTypes()
+		if val.LengthInt() != len(elemtypes) {
+			return errors.E("tuple with %d elements required, but have %d",
+				len(elemtypes), val.LengthInt())
+		}
+		i := 0
+		for it := val.ElementIterator(); it.Next(); i++ {
+			_, elem := it.Element()
+			if err := conforms(elem, elemtypes[i]); err != nil {
+				return errors.E(err, "element %d", i)
+			}
+		}
+		return nil
+	}
+
+	if err := typ.TestConformance(valtyp); err != nil {
+		return errors.E("%s required, but have %s",
+			typ.FriendlyName(), valtyp.FriendlyName())
+	}
+	return nil
+}
+
+// ctyValue returns the cty representation of the evaluated global value.
+func ctyValue(value eval.Value) cty.Value {
+	switch v := value.(type) {
+	case *eval.Object:
+		return cty.ObjectVal(v.AsValueMap())
+	case eval.CtyValue:
+		return v.Raw()
+	default:
+		panic(errors.E(errors.ErrInternal, "unexpected global value type %T", value))
+	}
+}
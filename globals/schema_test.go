@@ -0,0 +1,274 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package globals_test
+
+import (
+	"testing"
+
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/globals"
+	"github.com/terramate-io/terramate/hcl"
+	"github.com/terramate-io/terramate/test/hclwrite"
+	. "github.com/terramate-io/terramate/test/hclwrite/hclutils"
+)
+
+func TestLoadGlobalsSchema(t *testing.T) {
+	t.Parallel()
+
+	tcases := []testcase{
+		{
+			name:   "globals matching the schema",
+			layout: []string{"s:stack"},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: GlobalsSchema(
+						Expr("replicas", "number"),
+						Expr("zones", "list(string)"),
+					),
+				},
+				{
+					path: "/stack",
+					add: Globals(
+						Number("replicas", 3),
+						Expr("zones", `["a", "b"]`),
+					),
+				},
+			},
+			want: map[string]*hclwrite.Block{
+				"/stack": Globals(
+					Number("replicas", 3),
+					EvalExpr(t, "zones", `tolist(["a", "b"])`),
+				),
+			},
+		},
+		{
+			name:   "globals are not converted to the schema type",
+			layout: []string{"s:stack"},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: GlobalsSchema(
+						Expr("replicas", "number"),
+						Expr("enabled", "bool"),
+					),
+				},
+				{
+					path: "/stack",
+					add: Globals(
+						Str("replicas", "3"),
+						Str("enabled", "true"),
+					),
+				},
+			},
+			wantErr: errors.E(globals.ErrSchemaViolation),
+		},
+		{
+			name:   "list elements are not converted to the schema type",
+			layout: []string{"s:stack"},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: GlobalsSchema(
+						Expr("ports", "list(number)"),
+					),
+				},
+				{
+					path: "/stack",
+					add: Globals(
+						Expr("ports", `[80, "443"]`),
+					),
+				},
+			},
+			wantErr: errors.E(globals.ErrSchemaViolation),
+		},
+		{
+			name:   "object with unexpected attribute fails",
+			layout: []string{"s:stack"},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: GlobalsSchema(
+						Expr("service", `object({ name = string })`),
+					),
+				},
+				{
+					path: "/stack",
+					add: Globals(
+						Expr("service", `{ name = "app", port = 80 }`),
+					),
+				},
+			},
+			wantErr: errors.E(globals.ErrSchemaViolation),
+		},
+		{
+			name:   "undefined globals are not checked",
+			layout: []string{"s:stack"},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: GlobalsSchema(
+						Expr("replicas", "number"),
+					),
+				},
+			},
+		},
+		{
+			name:   "object with optional attribute defaults",
+			layout: []string{"s:stack"},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: GlobalsSchema(
+						Expr("service", `object({
+						  name = string
+						  port = optional(number, 80)
+						})`),
+					),
+				},
+				{
+					path: "/stack",
+					add: Globals(
+						Expr("service", `{ name = "app" }`),
+					),
+				},
+			},
+			want: map[string]*hclwrite.Block{
+				"/stack": Globals(
+					EvalExpr(t, "service", `{ name = "app", port = 80 }`),
+				),
+			},
+		},
+		{
+			name:   "labeled schema applies to nested global",
+			layout: []string{"s:stack"},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: GlobalsSchema(
+						Labels("obj"),
+						Expr("count", "number"),
+					),
+				},
+				{
+					path: "/stack",
+					add: Globals(
+						Labels("obj"),
+						Number("count", 1),
+					),
+				},
+			},
+			want: map[string]*hclwrite.Block{
+				"/stack": Globals(
+					EvalExpr(t, "obj", `{ count = 1 }`),
+				),
+			},
+		},
+		{
+			name:   "child schema overrides parent schema",
+			layout: []string{"s:dir/stack"},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: GlobalsSchema(
+						Expr("value", "number"),
+					),
+				},
+				{
+					path: "/dir",
+					add: GlobalsSchema(
+						Expr("value", "list(string)"),
+					),
+				},
+				{
+					path: "/dir/stack",
+					add: Globals(
+						Expr("value", `["a"]`),
+					),
+				},
+			},
+			want: map[string]*hclwrite.Block{
+				"/dir/stack": Globals(
+					EvalExpr(t, "value", `tolist(["a"])`),
+				),
+			},
+		},
+		{
+			name:   "child overriding global with wrong type fails",
+			layout: []string{"s:stack"},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: GlobalsSchema(
+						Expr("replicas", "number"),
+					),
+				},
+				{
+					path: "/",
+					add: Globals(
+						Number("replicas", 1),
+					),
+				},
+				{
+					path: "/stack",
+					add: Globals(
+						Expr("replicas", `["3"]`),
+					),
+				},
+			},
+			wantErr: errors.E(globals.ErrSchemaViolation),
+		},
+		{
+			name:   "missing object attribute fails",
+			layout: []string{"s:stack"},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: GlobalsSchema(
+						Expr("service", `object({ name = string })`),
+					),
+				},
+				{
+					path: "/stack",
+					add: Globals(
+						Expr("service", `{ port = 80 }`),
+					),
+				},
+			},
+			wantErr: errors.E(globals.ErrSchemaViolation),
+		},
+		{
+			name:   "invalid type constraint",
+			layout: []string{"s:stack"},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: GlobalsSchema(
+						Expr("replicas", "integer"),
+					),
+				},
+			},
+			wantErr: errors.E(hcl.ErrTerramateSchema),
+		},
+		{
+			name:   "schema does not support sub blocks",
+			layout: []string{"s:stack"},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: GlobalsSchema(
+						Block("obj",
+							Expr("replicas", "number"),
+						),
+					),
+				},
+			},
+			wantErr: errors.E(hcl.ErrTerramateSchema),
+		},
+	}
+
+	for _, tcase := range tcases {
+		testGlobals(t, tcase)
+	}
+}
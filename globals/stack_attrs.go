@@ -0,0 +1,135 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package globals
+
+import (
+	hhcl "github.com/hashicorp/hcl/v2"
+	"github.com/terramate-io/terramate/config"
+	"github.com/terramate-io/terramate/errors"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// ErrStackAttrsCycle indicates that a computed stack attribute depends on
+// itself through the terramate.stack metadata.
+const ErrStackAttrsCycle errors.Kind = "stack computed attributes cycle"
+
+// EvalStackAttrs evaluates the computed attributes of the stack with the
+// globals and the terramate metadata of the stack. Only the globals referenced
+// by the attributes are evaluated. It implements [config.StackAttrsEvaluator].
+//
+// The metadata of the computed attributes is not available during the
+// evaluation, then it fails if the attributes, or any global they reference,
+// access the terramate.stack metadata of a computed attribute.
+func EvalStackAttrs(root *config.Root, stack *config.Stack, attrs []*hhcl.Attribute) (map[string]cty.Value, error) {
+	computed := map[string]struct{}{}
+	refs := make([]hhcl.Expression, len(attrs))
+	for i, attr := range attrs {
+		computed[attr.Name] = struct{}{}
+		refs[i] = attr.Expr
+	}
+
+	errs := errors.L()
+	for _, attr := range attrs {
+		errs.Append(checkStackMetaRefs("stack."+attr.Name, attr.Expr, computed))
+	}
+	if err := errs.AsError(); err != nil {
+		return nil, err
+	}
+
+	tree, ok := root.Lookup(stack.Dir)
+	if !ok {
+		return nil, errors.E(errors.ErrInternal, "stack %s not found", stack.Dir)
+	}
+
+	ctx := newStackContext(root, stack)
+	_, report := evalTree(tree, ctx, func(exprs HierarchicalExprs) HierarchicalExprs {
+		referenced := exprs.Referenced(refs)
+		for _, exprSet := range referenced.sort() {
+			for _, key := range exprSet.sort() {
+				errs.Append(checkStackMetaRefs("global."+key.name(),
+					exprSet.expressions[key], computed))
+			}
+		}
+		if errs.AsError() != nil {
+			return HierarchicalExprs{}
+		}
+		return referenced
+	})
+	if err := errs.AsError(); err != nil {
+		return nil, err
+	}
+	if err := report.AsError(); err != nil {
+		return nil, err
+	}
+
+	ctx.SetNamespace("global", report.Globals.AsValueMap())
+
+	vals := map[string]cty.Value{}
+	for _, attr := range attrs {
+		val, err := ctx.Eval(attr.Expr)
+		if err != nil {
+			errs.Append(errors.E(err, "evaluating stack.%s", attr.Name))
+			continue
+		}
+		vals[attr.Name] = val
+	}
+	if err := errs.AsError(); err != nil {
+		return nil, err
+	}
+	return vals, nil
+}
+
+// checkStackMetaRefs checks that the expression of the given name doesn't
+// reference the terramate.stack metadata of any of the computed attributes.
+func checkStackMetaRefs(name string, expr hhcl.Expression, computed map[string]struct{}) error {
+	errs := errors.L()
+	for _, traversal := range expr.Variables() {
+		attr, ok := stackMetaAttr(traversal)
+		if !ok {
+			continue
+		}
+		if attr == "" {
+			for _, metaAttr := range []string{"description", "tags"} {
+				if _, ok := computed[metaAttr]; ok {
+					attr = metaAttr
+					break
+				}
+			}
+		}
+		if _, ok := computed[attr]; !ok {
+			continue
+		}
+		errs.Append(errors.E(ErrStackAttrsCycle, traversal.SourceRange(),
+			"%s references the metadata of the computed stack.%s attribute",
+			name, attr))
+	}
+	return errs.AsError()
+}
+
+// stackMetaAttr returns the stack attribute accessed by the terramate.stack
+// traversal, including the deprecated terramate.description. If the whole
+// terramate.stack object is accessed then the attribute is empty.
+func stackMetaAttr(traversal hhcl.Traversal) (string, bool) {
+	if traversal.RootName() != "terramate" || len(traversal) < 2 {
+		return "", false
+	}
+	ns, ok := traversal[1].(hhcl.TraverseAttr)
+	if !ok {
+		return "", false
+	}
+	switch ns.Name {
+	case "description":
+		return "description", true
+	case "stack":
+		if len(traversal) == 2 {
+			return "", true
+		}
+		attr, ok := traversal[2].(hhcl.TraverseAttr)
+		if !ok {
+			return "", true
+		}
+		return attr.Name, true
+	}
+	return "", false
+}
@@ -0,0 +1,218 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package globals_test
+
+import (
+	"testing"
+
+	"github.com/madlambda/spells/assert"
+	"github.com/terramate-io/terramate/config"
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/globals"
+	"github.com/terramate-io/terramate/hcl"
+	"github.com/terramate-io/terramate/project"
+	"github.com/terramate-io/terramate/test"
+	errtest "github.com/terramate-io/terramate/test/errors"
+	"github.com/terramate-io/terramate/test/sandbox"
+)
+
+func TestEvalStackAttrs(t *testing.T) {
+	t.Parallel()
+
+	type want struct {
+		description string
+		tags        []string
+		after       []string
+		watch       []string
+	}
+
+	type testcase struct {
+		name    string
+		layout  []string
+		stack   string
+		want    want
+		wantErr error
+	}
+
+	for _, tc := range []testcase{
+		{
+			name: "stack without computed attributes",
+			layout: []string{
+				`s:stack:description=static;tags=["a"]`,
+			},
+			stack: "/stack",
+			want: want{
+				description: "static",
+				tags:        []string{"a"},
+			},
+		},
+		{
+			name: "attributes referencing globals",
+			layout: []string{
+				`f:globals.tm:globals {
+					env   = "prod"
+					after = ["/network", "/iam"]
+				}`,
+				`f:stack/stack.tm:stack {
+					description = "${terramate.stack.name} at ${global.env}"
+					tags        = ["env-${global.env}", "static"]
+					after       = global.after
+					watch       = ["/${global.env}.json"]
+				}`,
+			},
+			stack: "/stack",
+			want: want{
+				description: "stack at prod",
+				tags:        []string{"env-prod", "static"},
+				after:       []string{"/network", "/iam"},
+				watch:       []string{"/prod.json"},
+			},
+		},
+		{
+			name: "globals referencing non-computed stack metadata",
+			layout: []string{
+				`f:globals.tm:globals {
+					env = terramate.stack.name
+				}`,
+				`f:stack/stack.tm:stack {
+					description = "static"
+					tags        = ["env-${global.env}"]
+				}`,
+			},
+			stack: "/stack",
+			want: want{
+				description: "static",
+				tags:        []string{"env-stack"},
+			},
+		},
+		{
+			name: "unreferenced failing globals are ignored",
+			layout: []string{
+				`f:globals.tm:globals {
+					env  = "prod"
+					fail = global.undefined
+				}`,
+				`f:stack/stack.tm:stack {
+					tags = [global.env]
+				}`,
+			},
+			stack: "/stack",
+			want: want{
+				tags: []string{"prod"},
+			},
+		},
+		{
+			name: "attribute referencing its own metadata fails",
+			layout: []string{
+				`f:stack/stack.tm:stack {
+					description = "${terramate.stack.description} extended"
+				}`,
+			},
+			stack:   "/stack",
+			wantErr: errors.E(globals.ErrStackAttrsCycle),
+		},
+		{
+			name: "global referencing computed metadata fails",
+			layout: []string{
+				`f:globals.tm:globals {
+					env = tm_element(terramate.stack.tags, 0)
+				}`,
+				`f:stack/stack.tm:stack {
+					tags = ["env-${global.env}"]
+				}`,
+			},
+			stack:   "/stack",
+			wantErr: errors.E(globals.ErrStackAttrsCycle),
+		},
+		{
+			name: "global transitively referencing computed metadata fails",
+			layout: []string{
+				`f:globals.tm:globals {
+					desc = terramate.description
+					env  = global.desc
+				}`,
+				`f:stack/stack.tm:stack {
+					description = global.env
+				}`,
+			},
+			stack:   "/stack",
+			wantErr: errors.E(globals.ErrStackAttrsCycle),
+		},
+		{
+			name: "computed attribute with invalid type fails",
+			layout: []string{
+				`f:globals.tm:globals {
+					tags = 1
+				}`,
+				`f:stack/stack.tm:stack {
+					tags = global.tags
+				}`,
+			},
+			stack:   "/stack",
+			wantErr: errors.E(config.ErrStackComputedAttrs),
+		},
+		{
+			name: "computed attribute referencing undefined global fails",
+			layout: []string{
+				`f:stack/stack.tm:stack {
+					after = global.undefined
+				}`,
+			},
+			stack:   "/stack",
+			wantErr: errors.E(config.ErrStackComputedAttrs),
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := sandbox.NoGit(t)
+			s.BuildTree(tc.layout)
+			root, err := config.LoadRootWithEvaluator(s.RootDir(), globals.EvalStackAttrs)
+			errtest.Assert(t, err, tc.wantErr)
+			if tc.wantErr != nil {
+				return
+			}
+
+			st, err := config.LoadStack(root, project.NewPath(tc.stack))
+			assert.NoError(t, err)
+
+			assert.EqualStrings(t, tc.want.description, st.Description)
+			test.AssertDiff(t, st.Tags, tc.want.tags, "tags mismatch")
+			test.AssertDiff(t, st.After, tc.want.after, "after mismatch")
+			if len(st.Watch) > 0 || len(tc.want.watch) > 0 {
+				test.AssertDiff(t, project.Paths(st.Watch).Strings(), tc.want.watch, "watch mismatch")
+			}
+		})
+	}
+}
+
+func TestEvalStackAttrsLoadSubTree(t *testing.T) {
+	t.Parallel()
+
+	s := sandbox.NoGit(t)
+	s.BuildTree([]string{
+		`f:globals.tm:globals {
+			env = "prod"
+		}`,
+		"d:stacks",
+	})
+
+	root, err := config.LoadRootWithEvaluator(s.RootDir(), globals.EvalStackAttrs)
+	assert.NoError(t, err)
+
+	// stacks created after loading the project, eg.: by terramate create.
+	s.RootEntry().CreateFile("stacks/stack/stack.tm", `stack {
+		tags = ["env-${global.env}"]
+	}`)
+	assert.NoError(t, root.LoadSubTree(project.NewPath("/stacks/stack")))
+
+	st, err := config.LoadStack(root, project.NewPath("/stacks/stack"))
+	assert.NoError(t, err)
+	test.AssertDiff(t, st.Tags, []string{"env-prod"}, "tags mismatch")
+
+	// without an evaluator the computed attributes are not accepted.
+	_, err = config.LoadRoot(s.RootDir())
+	errtest.Assert(t, err, errors.E(hcl.ErrTerramateSchema))
+}
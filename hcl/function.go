@@ -0,0 +1,188 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl
+
+import (
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/hcl/ast"
+	"github.com/terramate-io/terramate/hcl/info"
+	"github.com/terramate-io/terramate/stdlib"
+)
+
+// Function is the parsed "function" block, which defines a user function.
+// The function is called as tm_<name> and its result expression can only
+// reference its parameters and call other functions.
+type Function struct {
+	// Name of the function, without the tm_ prefix.
+	Name string
+
+	// Params are the names of the function parameters.
+	Params []string
+
+	// Result is the expression evaluated as the result of the function.
+	Result hcl.Expression
+
+	// Range is the range of the entire block definition.
+	Range info.Range
+}
+
+// reservedParamNames are the names which cannot be used as function parameters
+// because they are Terramate namespaces.
+var reservedParamNames = map[string]struct{}{
+	"global":    {},
+	"terramate": {},
+	"env":       {},
+	"let":       {},
+}
+
+func (p *TerramateParser) parseFunctionBlock(block *ast.Block) (Function, error) {
+	errs := errors.L()
+	errs.Append(checkNoBlocks(block))
+
+	fn := Function{
+		Range: block.Range,
+	}
+
+	if len(block.Labels) != 1 {
+		errs.Append(errors.E(ErrTerramateSchema, block.DefRange(),
+			"function block must have a single label with the function name"))
+		return Function{}, errs.AsError()
+	}
+
+	fn.Name = block.Labels[0]
+	if !hclsyntax.ValidIdentifier(fn.Name) {
+		errs.Append(errors.E(ErrTerramateSchema, block.LabelRanges(),
+			"function name %q is not a valid identifier", fn.Name))
+	}
+	if _, ok := stdlib.Functions(p.dir)[stdlib.Name(fn.Name)]; ok {
+		errs.Append(errors.E(ErrTerramateSchema, block.LabelRanges(),
+			"function %s conflicts with the builtin function %s",
+			fn.Name, stdlib.Name(fn.Name)))
+	}
+
+	params := map[string]struct{}{}
+	for _, attr := range block.Attributes.SortedList() {
+		switch attr.Name {
+		case "params":
+			val, diags := attr.Expr.Value(nil)
+			if diags.HasErrors() {
+				errs.Append(errors.E(ErrTerramateSchema, diags,
+					"function.params must be a list of parameter names"))
+				continue
+			}
+			if err := assignSet(attr.Name, &fn.Params, val); err != nil {
+				errs.Append(errors.E(ErrTerramateSchema, attr.Expr.Range(), err))
+				continue
+			}
+			for _, param := range fn.Params {
+				if !hclsyntax.ValidIdentifier(param) {
+					errs.Append(errors.E(ErrTerramateSchema, attr.Expr.Range(),
+						"function parameter %q is not a valid identifier", param))
+				}
+				if _, ok := reservedParamNames[param]; ok {
+					errs.Append(errors.E(ErrTerramateSchema, attr.Expr.Range(),
+						"function parameter %q is a reserved namespace", param))
+				}
+				params[param] = struct{}{}
+			}
+		case "result":
+			fn.Result = attr.Expr
+		default:
+			errs.Append(unrecognizedAttrErr(ErrTerramateSchema, attr.NameRange,
+				"function.%s", attr.Name))
+		}
+	}
+
+	if fn.Result == nil {
+		errs.Append(errors.E(ErrTerramateSchema, block.Range,
+			"function.result is required"))
+	} else {
+		for _, traversal := range fn.Result.Variables() {
+			if _, ok := params[traversal.RootName()]; !ok {
+				errs.Append(errors.E(ErrTerramateSchema, traversal.SourceRange(),
+					"function.result can only reference the function parameters "+
+						"but references %s", traversal.RootName()))
+			}
+		}
+	}
+
+	if err := errs.AsError(); err != nil {
+		return Function{}, err
+	}
+	return fn, nil
+}
+
+// Calls returns the names of the functions called by the function result.
+func (fn Function) Calls() []string {
+	var names []string
+	expr, ok := fn.Result.(hclsyntax.Expression)
+	if !ok {
+		return nil
+	}
+	_ = hclsyntax.VisitAll(expr, func(node hclsyntax.Node) hcl.Diagnostics {
+		if call, ok := node.(*hclsyntax.FunctionCallExpr); ok {
+			names = append(names, call.Name)
+		}
+		return nil
+	})
+	return names
+}
+
+// checkFunctionsRecursion checks that the functions defined in the same
+// directory don't call themselves, directly or through other functions.
+// The functions of the parent directories are not checked because they cannot
+// call the functions defined in the child directories.
+func checkFunctionsRecursion(funcs []Function) error {
+	byName := map[string]Function{}
+	for _, fn := range funcs {
+		byName[stdlib.Name(fn.Name)] = fn
+	}
+
+	const (
+		visiting = iota + 1
+		visited
+	)
+	state := map[string]int{}
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		path = append(path, name)
+		switch state[name] {
+		case visiting:
+			for i := range path {
+				if path[i] == name {
+					path = path[i:]
+					break
+				}
+			}
+			fn := byName[name]
+			return errors.E(ErrTerramateSchema, fn.Range,
+				"function %s is recursive: %s", fn.Name, strings.Join(path, " -> "))
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		for _, call := range byName[name].Calls() {
+			if _, ok := byName[call]; !ok {
+				continue
+			}
+			if err := visit(call, path); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
+
+	for _, fn := range funcs {
+		if err := visit(stdlib.Name(fn.Name), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
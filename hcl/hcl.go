@@ -12,6 +12,7 @@ import (
 	"strings"
 
 	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
 	"github.com/hashicorp/hcl/v2/hclparse"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/rs/zerolog/log"
@@ -20,6 +21,7 @@ import (
 	"github.com/terramate-io/terramate/hcl/ast"
 	"github.com/terramate-io/terramate/hcl/eval"
 	"github.com/terramate-io/terramate/hcl/info"
+	"github.com/terramate-io/terramate/project"
 	"github.com/terramate-io/terramate/stdlib"
 	"github.com/zclconf/go-cty/cty"
 )
@@ -30,6 +32,13 @@ const (
 	ErrTerramateSchema     errors.Kind = "terramate schema error"
 	ErrImport              errors.Kind = "import error"
 	ErrUnexpectedTerramate errors.Kind = "`terramate` block is only allowed at the project root directory"
+
+	// ErrUnrecognizedAttribute, ErrDuplicatedAttribute and ErrDuplicatedBlock
+	// are wrapped by the ErrTerramateSchema and ErrHCLSyntax errors which are
+	// fixed by removing the attribute or block in the error range.
+	ErrUnrecognizedAttribute errors.Kind = "unrecognized attribute"
+	ErrDuplicatedAttribute   errors.Kind = "duplicated attribute"
+	ErrDuplicatedBlock       errors.Kind = "duplicated block"
 )
 
 const (
@@ -42,12 +51,30 @@ type Config struct {
 	Terramate *Terramate
 	Stack     *Stack
 	Globals   ast.MergedLabelBlocks
-	Vendor    *VendorConfig
-	Asserts   []AssertConfig
-	Generate  GenerateConfig
+	// GlobalsSchema are the globals_schema blocks, which define the type
+	// constraints of the globals.
+	GlobalsSchema ast.MergedLabelBlocks
+	Vendor        *VendorConfig
+	// StackDefaults is the stack_defaults block of the directory, if any.
+	StackDefaults *StackDefaults
+	Asserts       []AssertConfig
+	Generate      GenerateConfig
+
+	// Functions are the user functions defined in the directory.
+	Functions []Function
 
 	Imported RawConfig
 
+	// VendoredImports are the project paths of the files imported from remote
+	// sources, directly or by other imported files. The files are vendored
+	// inside the project vendor dir.
+	VendoredImports project.Paths
+
+	// ImportedFiles are the project paths of the files imported by the
+	// directory, directly or by other imported files, including the vendored
+	// ones.
+	ImportedFiles project.Paths
+
 	// absdir is the absolute path to the configuration directory.
 	absdir string
 }
@@ -109,11 +136,23 @@ type CloudConfig struct {
 	Organization string
 }
 
+// GlobalsConfig represents Terramate globals configuration.
+type GlobalsConfig struct {
+	// LazyEvaluation enables evaluating only the globals referenced by the
+	// expressions being evaluated.
+	LazyEvaluation bool
+
+	// CrossStackReferences enables exposing the globals of each stack in the
+	// terramate.stacks namespace when generating code.
+	CrossStackReferences bool
+}
+
 // RootConfig represents the root config block of a Terramate configuration.
 type RootConfig struct {
-	Git   *GitConfig
-	Run   *RunConfig
-	Cloud *CloudConfig
+	Git     *GitConfig
+	Run     *RunConfig
+	Cloud   *CloudConfig
+	Globals *GlobalsConfig
 }
 
 // ManifestDesc represents a parsed manifest description.
@@ -183,6 +222,54 @@ type Stack struct {
 
 	// Watch is a list of files to be watched for changes.
 	Watch []string
+
+	// Defaults are the stack_defaults blocks of the parent directories,
+	// sorted from the closest directory to the project root. They are set
+	// when the configuration tree is loaded.
+	Defaults []*StackDefaults
+
+	// Computed are the attributes which reference globals or the terramate
+	// metadata, sorted by name. They cannot be evaluated by the parser and
+	// are set into the stack with [Stack.SetComputedAttr] once evaluated.
+	Computed []*hcl.Attribute
+
+	// Range is the range of the entire stack block definition.
+	Range info.Range
+
+	// defined are the names of the attributes defined in the stack block,
+	// including the computed ones.
+	defined map[string]struct{}
+}
+
+// StackDefaults is the parsed "stack_defaults" HCL block, which defines the
+// default attributes of the stacks in the child directories.
+type StackDefaults struct {
+	// Dir is the configuration directory of the block. For imported blocks,
+	// it is the directory of the importing configuration.
+	Dir project.Path
+
+	// Range is the range of the entire block definition.
+	Range info.Range
+
+	// Description is the default description of the stacks. It is a template
+	// evaluated with the stack metadata.
+	Description hcl.Expression
+
+	// Tags is the default list of tags of the stacks.
+	Tags []string
+
+	// After is the default list of stacks which must run before the stacks.
+	After []string
+
+	// Before is the default list of stacks which must run after the stacks.
+	Before []string
+
+	// Wants is the default list of stacks which must be selected whenever
+	// the stacks are selected.
+	Wants []string
+
+	// Watch is the default list of files to be watched for changes.
+	Watch []string
 }
 
 // GenHCLBlock represents a parsed generate_hcl block.
@@ -197,6 +284,12 @@ type GenHCLBlock struct {
 	Condition *hclsyntax.Attribute
 	// Content block.
 	Content *hclsyntax.Block
+	// PostProcess attribute of the block, if any.
+	PostProcess *hclsyntax.Attribute
+	// Validate attribute of the block, if any.
+	Validate *hclsyntax.Attribute
+	// Context of the generation (stack by default).
+	Context string
 	// Asserts represents all assert blocks
 	Asserts []AssertConfig
 }
@@ -213,6 +306,13 @@ type GenFileBlock struct {
 	Condition *hclsyntax.Attribute
 	// Content attribute of the block
 	Content *hclsyntax.Attribute
+	// Template attribute of the block, which is the path of a template file
+	// used instead of the content attribute.
+	Template *hclsyntax.Attribute
+	// PostProcess attribute of the block, if any.
+	PostProcess *hclsyntax.Attribute
+	// Validate attribute of the block, if any.
+	Validate *hclsyntax.Attribute
 	// Context of the generation (stack by default).
 	Context string
 	// Asserts represents all assert blocks
@@ -254,6 +354,32 @@ type TerramateParser struct {
 	// parsedFiles stores a map of all parsed files
 	parsedFiles map[string]parsedFile
 
+	// vendoredImports stores the vendored files imported from remote sources.
+	vendoredImports []string
+
+	// importedFiles stores all the files imported by the parsed directory.
+	importedFiles []string
+
+	// if true, imports of remote sources are ignored.
+	skipRemoteImports bool
+
+	// if true, imports of remote sources not vendored yet are ignored and
+	// their sources stored in missingImports, instead of failing the parsing.
+	collectMissingImports bool
+	missingImports        []string
+
+	// importenv is the environment of import conditions. It is shared with
+	// the parsers of the imported files.
+	importenv *importEnv
+
+	// overlay has the content of files which is used instead of the content
+	// on disk. It is shared with the parsers of the imported files.
+	overlay map[string][]byte
+
+	// if true, the stack attributes referencing globals or the terramate
+	// metadata are postponed into [Stack.Computed] instead of failing.
+	computedStackAttrs bool
+
 	strict bool
 	// if true, calling Parse() or MinimalParse() will fail.
 	parsed bool
@@ -353,7 +479,7 @@ func (p *TerramateParser) AddDir(dir string) error {
 			Str("file", path).
 			Msg("Reading config file.")
 
-		data, err := os.ReadFile(path)
+		data, err := p.readFile(path)
 		if err != nil {
 			return errors.E(err, "reading config file %q", path)
 		}
@@ -373,13 +499,35 @@ func (p *TerramateParser) AddFile(path string) error {
 	if !strings.HasPrefix(path, p.dir) {
 		return errors.E("parser only allow files from directory %q", p.dir)
 	}
-	data, err := os.ReadFile(path)
+	data, err := p.readFile(path)
 	if err != nil {
 		return errors.E("adding file %q to parser", path, err)
 	}
 	return p.AddFileContent(path, data)
 }
 
+// SetOverlay sets the content of files to be used instead of their content on
+// disk, like the unsaved files of an editor. The overlay is also used when
+// parsing imported files.
+func (p *TerramateParser) SetOverlay(files map[string][]byte) {
+	p.overlay = files
+}
+
+// EnableComputedStackAttrs makes the parser accept stack attributes
+// referencing globals or the terramate metadata, which are not evaluated by
+// the parser but postponed into [Stack.Computed]. The caller is responsible for
+// evaluating them. By default, these attributes are schema errors.
+func (p *TerramateParser) EnableComputedStackAttrs() {
+	p.computedStackAttrs = true
+}
+
+func (p *TerramateParser) readFile(path string) ([]byte, error) {
+	if data, ok := p.overlay[path]; ok {
+		return data, nil
+	}
+	return os.ReadFile(path)
+}
+
 // AddFileContent adds a file to the set of files to be parsed.
 func (p *TerramateParser) AddFileContent(name string, data []byte) error {
 	if !strings.HasPrefix(name, p.dir) {
@@ -411,6 +559,13 @@ func (p *TerramateParser) ParseConfig() (Config, error) {
 	if err := errs.AsError(); err != nil {
 		return Config{}, err
 	}
+
+	for _, file := range p.vendoredImports {
+		cfg.VendoredImports = append(cfg.VendoredImports, project.PrjAbsPath(p.rootdir, file))
+	}
+	for _, file := range p.importedFiles {
+		cfg.ImportedFiles = append(cfg.ImportedFiles, project.PrjAbsPath(p.rootdir, file))
+	}
 	return cfg, nil
 }
 
@@ -487,7 +642,15 @@ func (p *TerramateParser) parseSyntax() error {
 		data := p.files[name]
 		_, diags := p.hclparser.ParseHCL(data, name)
 		if diags.HasErrors() {
-			errs.Append(errors.E(ErrHCLSyntax, diags))
+			for _, diag := range diags {
+				switch {
+				case diag.Severity != hcl.DiagError:
+				case diag.Summary == "Attribute redefined":
+					errs.Append(errors.E(ErrHCLSyntax, errors.E(ErrDuplicatedAttribute, diag)))
+				default:
+					errs.Append(errors.E(ErrHCLSyntax, diag))
+				}
+			}
 			continue
 		}
 		p.addParsedFile(p.dir, internal, name)
@@ -520,26 +683,53 @@ func (p *TerramateParser) handleImport(importBlock *ast.Block) error {
 		return attrErr(srcAttr, "import.source must be a string")
 	}
 
+	if condAttr, ok := importBlock.Attributes["condition"]; ok {
+		enabled, err := p.evalImportCondition(condAttr)
+		if err != nil {
+			return err
+		}
+		if !enabled {
+			return nil
+		}
+	}
+
 	src := srcVal.AsString()
-	srcBase := path.Base(src)
-	srcDir := path.Dir(src)
-	if path.IsAbs(srcDir) { // project-path
-		srcDir = filepath.Join(p.rootdir, srcDir)
+	remote := isRemoteImport(src)
+	if remote {
+		if p.skipRemoteImports {
+			return nil
+		}
+		vendored, ok, err := p.vendoredImport(srcAttr, src)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			p.missingImports = append(p.missingImports, src)
+			return nil
+		}
+		src = vendored
 	} else {
-		srcDir = filepath.Join(p.dir, srcDir)
-	}
+		srcBase := path.Base(src)
+		srcDir := path.Dir(src)
+		if path.IsAbs(srcDir) { // project-path
+			srcDir = filepath.Join(p.rootdir, srcDir)
+		} else {
+			srcDir = filepath.Join(p.dir, srcDir)
+		}
 
-	if srcDir == p.dir {
-		return errors.E(ErrImport, srcAttr.Expr.Range(),
-			"importing files in the same directory is not permitted")
-	}
+		if srcDir == p.dir {
+			return errors.E(ErrImport, srcAttr.Expr.Range(),
+				"importing files in the same directory is not permitted")
+		}
 
-	if strings.HasPrefix(p.dir, srcDir) {
-		return errors.E(ErrImport, srcAttr.Expr.Range(),
-			"importing files in the same tree is not permitted")
+		if strings.HasPrefix(p.dir, srcDir) {
+			return errors.E(ErrImport, srcAttr.Expr.Range(),
+				"importing files in the same tree is not permitted")
+		}
+
+		src = filepath.Join(srcDir, srcBase)
 	}
 
-	src = filepath.Join(srcDir, srcBase)
 	matches, err := filepath.Glob(src)
 	if err != nil {
 		return errors.E(ErrTerramateSchema, srcAttr.Expr.Range(),
@@ -581,16 +771,27 @@ func (p *TerramateParser) handleImport(importBlock *ast.Block) error {
 				err, "failed to create sub parser: %s", fileDir)
 		}
 
+		importParser.overlay = p.overlay
 		err = importParser.AddFile(file)
 		if err != nil {
 			return errors.E(ErrImport, srcAttr.Expr.Range(),
 				err)
 		}
 		importParser.addParsedFile(p.dir, external, p.internalParsedFiles()...)
+		importParser.skipRemoteImports = p.skipRemoteImports
+		importParser.collectMissingImports = p.collectMissingImports
+		importParser.importenv = p.importEnv()
 		err = importParser.Parse()
 		if err != nil {
 			return err
 		}
+		if remote {
+			p.vendoredImports = append(p.vendoredImports, file)
+		}
+		p.vendoredImports = append(p.vendoredImports, importParser.vendoredImports...)
+		p.importedFiles = append(p.importedFiles, file)
+		p.importedFiles = append(p.importedFiles, importParser.importedFiles...)
+		p.missingImports = append(p.missingImports, importParser.missingImports...)
 		errs := errors.L()
 		for _, block := range importParser.Config.UnmergedBlocks {
 			if block.Type == "stack" {
@@ -649,13 +850,45 @@ func (p *TerramateParser) parseStack(stackblock *ast.Block) (*Stack, error) {
 		)
 	}
 
-	stack := &Stack{}
+	stack := &Stack{
+		Range:   stackblock.Range,
+		defined: map[string]struct{}{},
+	}
 
 	logger.Debug().Msg("Get stack attributes.")
 	attrs := ast.AsHCLAttributes(stackblock.Body.Attributes)
 	for _, attr := range ast.SortRawAttributes(attrs) {
 		logger.Trace().Msg("Get attribute value.")
 
+		stack.defined[attr.Name] = struct{}{}
+
+		if isComputedStackAttr(attr) {
+			switch attr.Name {
+			case "id", "name":
+				errs.Append(hclAttrErr(attr,
+					"field stack.%s cannot reference globals or the terramate metadata",
+					attr.Name))
+			case "description", "tags", "after", "before", "wants", "wanted_by", "watch":
+				if !p.computedStackAttrs {
+					errs.Append(hclAttrErr(attr,
+						"field stack.%s cannot reference globals or the terramate metadata "+
+							"because the computed stack attributes are not enabled",
+						attr.Name))
+					continue
+				}
+
+				logger.Trace().
+					Str("attribute", attr.Name).
+					Msg("Postponing evaluation of computed attribute.")
+
+				stack.Computed = append(stack.Computed, attr)
+			default:
+				errs.Append(unrecognizedAttrErr(ErrTerramateSchema, attr.NameRange,
+					"stack.%q", attr.Name))
+			}
+			continue
+		}
+
 		attrVal, err := p.evalctx.Eval(attr.Expr)
 		if err != nil {
 			errs.Append(
@@ -688,57 +921,129 @@ func (p *TerramateParser) parseStack(stackblock *ast.Block) (*Stack, error) {
 			}
 			stack.Name = attrVal.AsString()
 
-		case "description":
-			logger.Trace().Msg("parsing stack description.")
-			if attrVal.Type() != cty.String {
-				errs.Append(hclAttrErr(attr,
-					"field stack.\"description\" must be a \"string\" but given %q",
-					attrVal.Type().FriendlyName(),
-				))
+		default:
+			errs.Append(stack.SetComputedAttr(attr, attrVal))
+		}
+	}
 
-				continue
-			}
-			stack.Description = attrVal.AsString()
+	if err := errs.AsError(); err != nil {
+		return nil, err
+	}
 
-			// The `tags`, `after`, `before`, `wants`, `wanted_by` and `watch`
-			// have all the same parsing rules.
-			// By the spec, they must be a `set(string)`.
+	return stack, nil
+}
 
-			// In order to speed up the tests, only the `after` attribute is
-			// extensively tested for all error cases.
-			// **So have this in mind if the specification of any of the attributes
-			// below change in the future**.
+// IsDefined tells if the attribute is defined in the stack block, even if
+// it is defined with an empty value.
+func (stack *Stack) IsDefined(name string) bool {
+	_, ok := stack.defined[name]
+	return ok
+}
 
-		case "tags":
-			errs.Append(assignSet(attr.Name, &stack.Tags, attrVal))
+// SetComputedAttr sets the stack attribute attr to the evaluated value val.
+// The id and name attributes cannot be computed, so they are not accepted.
+func (stack *Stack) SetComputedAttr(attr *hcl.Attribute, val cty.Value) error {
+	switch attr.Name {
+	case "description":
+		if val.Type() != cty.String {
+			return hclAttrErr(attr,
+				"field stack.\"description\" must be a \"string\" but given %q",
+				val.Type().FriendlyName(),
+			)
+		}
+		stack.Description = val.AsString()
 
-		case "after":
-			errs.Append(assignSet(attr.Name, &stack.After, attrVal))
+		// The `tags`, `after`, `before`, `wants`, `wanted_by` and `watch`
+		// have all the same parsing rules.
+		// By the spec, they must be a `set(string)`.
 
-		case "before":
-			errs.Append(assignSet(attr.Name, &stack.Before, attrVal))
+		// In order to speed up the tests, only the `after` attribute is
+		// extensively tested for all error cases.
+		// **So have this in mind if the specification of any of the attributes
+		// below change in the future**.
 
-		case "wants":
-			errs.Append(assignSet(attr.Name, &stack.Wants, attrVal))
+	case "tags":
+		return assignSet(attr.Name, &stack.Tags, val)
 
-		case "wanted_by":
-			errs.Append(assignSet(attr.Name, &stack.WantedBy, attrVal))
+	case "after":
+		return assignSet(attr.Name, &stack.After, val)
 
-		case "watch":
-			errs.Append(assignSet(attr.Name, &stack.Watch, attrVal))
+	case "before":
+		return assignSet(attr.Name, &stack.Before, val)
+
+	case "wants":
+		return assignSet(attr.Name, &stack.Wants, val)
+
+	case "wanted_by":
+		return assignSet(attr.Name, &stack.WantedBy, val)
+
+	case "watch":
+		return assignSet(attr.Name, &stack.Watch, val)
+
+	default:
+		return unrecognizedAttrErr(ErrTerramateSchema, attr.NameRange,
+			"stack.%q", attr.Name)
+	}
+	return nil
+}
+
+// isComputedStackAttr tells if the stack attribute references the global or
+// terramate namespaces, which are not available when parsing.
+func isComputedStackAttr(attr *hcl.Attribute) bool {
+	for _, traversal := range attr.Expr.Variables() {
+		switch traversal.RootName() {
+		case "global", "terramate":
+			return true
+		}
+	}
+	return false
+}
+
+func (p *TerramateParser) parseStackDefaults(block *ast.Block) (*StackDefaults, error) {
+	errs := errors.L()
+	for _, subBlock := range block.Blocks {
+		errs.Append(errors.E(subBlock.TypeRange,
+			"unrecognized block %q", subBlock.Type))
+	}
+
+	defaults := &StackDefaults{
+		Dir:   project.PrjAbsPath(p.rootdir, p.dir),
+		Range: block.Range,
+	}
+	for _, attr := range block.Attributes.SortedList() {
+		if attr.Name == "description" {
+			defaults.Description = attr.Expr
+			continue
+		}
 
+		attrVal, err := p.evalctx.Eval(attr.Expr)
+		if err != nil {
+			errs.Append(errors.E(err,
+				"failed to evaluate stack_defaults.%s attribute", attr.Name))
+			continue
+		}
+
+		switch attr.Name {
+		case "tags":
+			errs.Append(assignSet(attr.Name, &defaults.Tags, attrVal))
+		case "after":
+			errs.Append(assignSet(attr.Name, &defaults.After, attrVal))
+		case "before":
+			errs.Append(assignSet(attr.Name, &defaults.Before, attrVal))
+		case "wants":
+			errs.Append(assignSet(attr.Name, &defaults.Wants, attrVal))
+		case "watch":
+			errs.Append(assignSet(attr.Name, &defaults.Watch, attrVal))
 		default:
-			errs.Append(errors.E(
-				attr.NameRange, "unrecognized attribute stack.%q", attr.Name,
-			))
+			errs.Append(unrecognizedAttrErr(ErrTerramateSchema, attr.NameRange,
+				"stack_defaults.%s", attr.Name))
 		}
 	}
 
 	if err := errs.AsError(); err != nil {
 		return nil, err
 	}
-
-	return stack, nil
+	return defaults, nil
 }
 
 // NewConfig creates a new HCL config with dir as config directory path.
@@ -766,14 +1071,33 @@ func (c Config) HasRunEnv() bool {
 		c.Terramate.Config.Run.Env != nil
 }
 
+// HasLazyGlobals returns true if the config has the
+// terramate.config.globals.lazy_evaluation attribute enabled.
+func (c Config) HasLazyGlobals() bool {
+	return c.Terramate != nil &&
+		c.Terramate.Config != nil &&
+		c.Terramate.Config.Globals != nil &&
+		c.Terramate.Config.Globals.LazyEvaluation
+}
+
+// HasCrossStackReferences returns true if the config has the
+// terramate.config.globals.cross_stack_references attribute enabled.
+func (c Config) HasCrossStackReferences() bool {
+	return c.Terramate != nil &&
+		c.Terramate.Config != nil &&
+		c.Terramate.Config.Globals != nil &&
+		c.Terramate.Config.Globals.CrossStackReferences
+}
+
 // AbsDir returns the absolute path of the configuration directory.
 func (c Config) AbsDir() string { return c.absdir }
 
 // IsEmpty returns true if the config is empty, false otherwise.
 func (c Config) IsEmpty() bool {
 	return c.Stack == nil && c.Terramate == nil &&
-		c.Vendor == nil && len(c.Asserts) == 0 &&
-		len(c.Globals) == 0 &&
+		c.Vendor == nil && c.StackDefaults == nil && len(c.Asserts) == 0 &&
+		len(c.Functions) == 0 &&
+		len(c.Globals) == 0 && len(c.GlobalsSchema) == 0 &&
 		len(c.Generate.Files) == 0 && len(c.Generate.HCLs) == 0
 }
 
@@ -808,8 +1132,15 @@ func (c Config) Save(filename string) (err error) {
 // .tm.hcl. It parses in non-strict mode for compatibility with older versions.
 // Note: it does not recurse into child directories.
 func ParseDir(root string, dir string) (Config, error) {
+	return ParseDirOverlay(root, dir, nil)
+}
+
+// ParseDirOverlay parses the configuration of the dir like [ParseDir] but the
+// content of the files in the overlay is used instead of their content on
+// disk. See [TerramateParser.SetOverlay].
+func ParseDirOverlay(root string, dir string, overlay map[string][]byte) (Config, error) {
 	logger := log.With().
-		Str("action", "ParseDir()").
+		Str("action", "ParseDirOverlay()").
 		Str("dir", dir).
 		Logger()
 
@@ -819,6 +1150,7 @@ func ParseDir(root string, dir string) (Config, error) {
 	if err != nil {
 		return Config{}, err
 	}
+	p.SetOverlay(overlay)
 	err = p.AddDir(dir)
 	if err != nil {
 		return Config{}, errors.E("adding files to parser", err)
@@ -826,6 +1158,29 @@ func ParseDir(root string, dir string) (Config, error) {
 	return p.ParseConfig()
 }
 
+// ParseDirMissingImports parses the configuration of the dir like [ParseDir]
+// but the imports of remote sources which are not vendored yet are ignored
+// instead of failing the parsing. The sources of these imports are returned,
+// so they can be vendored. The computed stack attributes are accepted but not
+// evaluated.
+func ParseDirMissingImports(root string, dir string) (Config, []string, error) {
+	p, err := NewTerramateParser(root, dir)
+	if err != nil {
+		return Config{}, nil, err
+	}
+	p.collectMissingImports = true
+	p.computedStackAttrs = true
+	err = p.AddDir(dir)
+	if err != nil {
+		return Config{}, nil, errors.E("adding files to parser", err)
+	}
+	cfg, err := p.ParseConfig()
+	if err != nil {
+		return Config{}, nil, err
+	}
+	return cfg, p.missingImports, nil
+}
+
 // parseGenerateHCLBlock the generate_hcl block.
 // generate_hcl blocks are validated, so the caller can expect valid blocks only or an error.
 func parseGenerateHCLBlock(block *ast.Block) (GenHCLBlock, error) {
@@ -874,6 +1229,9 @@ func parseGenerateHCLBlock(block *ast.Block) (GenHCLBlock, error) {
 			errors.E(ErrTerramateSchema, `"generate_hcl" block requires a content block`, block.Range))
 	}
 
+	context, err := parseGenerateContext(block)
+	errs.Append(err)
+
 	mergedLets := ast.MergedLabelBlocks{}
 	for labelType, mergedBlock := range letsConfig.MergedLabelBlocks {
 		if labelType.Type == "lets" {
@@ -893,12 +1251,15 @@ func parseGenerateHCLBlock(block *ast.Block) (GenHCLBlock, error) {
 	}
 
 	return GenHCLBlock{
-		Range:     block.Range,
-		Label:     block.Labels[0],
-		Lets:      lets,
-		Asserts:   asserts,
-		Content:   content,
-		Condition: block.Body.Attributes["condition"],
+		Range:       block.Range,
+		Label:       block.Labels[0],
+		Lets:        lets,
+		Asserts:     asserts,
+		Content:     content,
+		Condition:   block.Body.Attributes["condition"],
+		PostProcess: block.Body.Attributes["post_process"],
+		Validate:    block.Body.Attributes["validate"],
+		Context:     context,
 	}, nil
 }
 
@@ -934,15 +1295,8 @@ func parseGenerateFileBlock(block *ast.Block) (GenFileBlock, error) {
 		}
 	}
 
-	context := "stack"
-	if contextAttr, ok := block.Body.Attributes["context"]; ok {
-		context = hcl.ExprAsKeyword(contextAttr.Expr)
-		if context != "stack" && context != "root" {
-			errs.Append(errors.E(contextAttr.Expr.Range(),
-				"generate_file.context supported values are \"stack\" and \"root\""+
-					" but given %q", context))
-		}
-	}
+	context, err := parseGenerateContext(block)
+	errs.Append(err)
 
 	mergedLets := ast.MergedLabelBlocks{}
 	for labelType, mergedBlock := range letsConfig.MergedLabelBlocks {
@@ -963,16 +1317,45 @@ func parseGenerateFileBlock(block *ast.Block) (GenFileBlock, error) {
 	}
 
 	return GenFileBlock{
-		Range:     block.Range,
-		Label:     block.Labels[0],
-		Lets:      lets,
-		Asserts:   asserts,
-		Content:   block.Body.Attributes["content"],
-		Condition: block.Body.Attributes["condition"],
-		Context:   context,
+		Range:       block.Range,
+		Label:       block.Labels[0],
+		Lets:        lets,
+		Asserts:     asserts,
+		Content:     block.Body.Attributes["content"],
+		Template:    block.Body.Attributes["template"],
+		Condition:   block.Body.Attributes["condition"],
+		PostProcess: block.Body.Attributes["post_process"],
+		Validate:    block.Body.Attributes["validate"],
+		Context:     context,
 	}, nil
 }
 
+const (
+	// StackContext is the context of the generate blocks generated for each
+	// stack.
+	StackContext = "stack"
+
+	// RootContext is the context of the generate blocks generated once, with
+	// the label relative to the project root.
+	RootContext = "root"
+)
+
+// parseGenerateContext parses the context attribute of the generate_hcl and
+// generate_file blocks, defaulting to [StackContext] if the attribute is absent.
+func parseGenerateContext(block *ast.Block) (string, error) {
+	contextAttr, ok := block.Body.Attributes["context"]
+	if !ok {
+		return StackContext, nil
+	}
+	context := hcl.ExprAsKeyword(contextAttr.Expr)
+	if context != StackContext && context != RootContext {
+		return context, errors.E(ErrTerramateSchema, contextAttr.Expr.Range(),
+			"%s.context supported values are \"stack\" and \"root\""+
+				" but given %q", block.Type, context)
+	}
+	return context, nil
+}
+
 func validateImportBlock(block *ast.Block) error {
 	errs := errors.L()
 	if len(block.Labels) != 0 {
@@ -987,6 +1370,10 @@ func validateImportBlock(block *ast.Block) error {
 				Name:     "source",
 				Required: true,
 			},
+			{
+				Name:     "condition",
+				Required: false,
+			},
 		},
 	}
 
@@ -1023,6 +1410,18 @@ func validateGenerateHCLBlock(block *ast.Block) error {
 				Name:     "condition",
 				Required: false,
 			},
+			{
+				Name:     "context",
+				Required: false,
+			},
+			{
+				Name:     "post_process",
+				Required: false,
+			},
+			{
+				Name:     "validate",
+				Required: false,
+			},
 		},
 		Blocks: []hcl.BlockHeaderSchema{
 			{
@@ -1081,7 +1480,11 @@ func validateGenerateFileBlock(block *ast.Block) error {
 		Attributes: []hcl.AttributeSchema{
 			{
 				Name:     "content",
-				Required: true,
+				Required: false,
+			},
+			{
+				Name:     "template",
+				Required: false,
 			},
 			{
 				Name:     "condition",
@@ -1091,6 +1494,14 @@ func validateGenerateFileBlock(block *ast.Block) error {
 				Name:     "context",
 				Required: false,
 			},
+			{
+				Name:     "post_process",
+				Required: false,
+			},
+			{
+				Name:     "validate",
+				Required: false,
+			},
 		},
 		Blocks: []hcl.BlockHeaderSchema{
 			{
@@ -1108,6 +1519,17 @@ func validateGenerateFileBlock(block *ast.Block) error {
 	if diags.HasErrors() {
 		errs.Append(errors.E(ErrTerramateSchema, diags))
 	}
+
+	_, hasContent := block.Body.Attributes["content"]
+	templateAttr, hasTemplate := block.Body.Attributes["template"]
+	switch {
+	case hasContent && hasTemplate:
+		errs.Append(errors.E(ErrTerramateSchema, templateAttr.NameRange,
+			"generate_file must have either a content or a template attribute, not both"))
+	case !hasContent && !hasTemplate:
+		errs.Append(errors.E(ErrTerramateSchema, block.OpenBraceRange,
+			"generate_file must have a content or a template attribute"))
+	}
 	err := errs.AsError()
 	if err != nil {
 		return err
@@ -1213,9 +1635,8 @@ func checkNoAttributes(block *ast.Block) error {
 	errs := errors.L()
 
 	for _, got := range block.Attributes.SortedList() {
-		errs.Append(errors.E(got.NameRange,
-			"unrecognized attribute %s.%s", block.Type, got.Name,
-		))
+		errs.Append(unrecognizedAttrErr(ErrTerramateSchema, got.NameRange,
+			"%s.%s", block.Type, got.Name))
 	}
 
 	return errs.AsError()
@@ -1261,8 +1682,7 @@ checkBlocks:
 				if found {
 					errs.Append(errors.E(ErrTerramateSchema,
 						got.DefRange(),
-						"duplicated block %s",
-						got.Type),
+						errors.E(ErrDuplicatedBlock, got.Type)),
 					)
 					continue checkBlocks
 				}
@@ -1300,9 +1720,8 @@ func parseAssertConfig(assert *ast.Block) (AssertConfig, error) {
 		case "warning":
 			cfg.Warning = attr.Expr
 		default:
-			errs.Append(errors.E(ErrTerramateSchema, attr.NameRange,
-				"unrecognized attribute %s.%s", assert.Type, attr.Name,
-			))
+			errs.Append(unrecognizedAttrErr(ErrTerramateSchema, attr.NameRange,
+				"%s.%s", assert.Type, attr.Name))
 		}
 	}
 
@@ -1347,9 +1766,8 @@ func parseVendorConfig(cfg *VendorConfig, vendor *ast.Block) error {
 			}
 			cfg.Dir = attrVal.AsString()
 		default:
-			errs.Append(errors.E(ErrTerramateSchema, attr.NameRange,
-				"unrecognized attribute %s.%s", vendor.Type, attr.Name,
-			))
+			errs.Append(unrecognizedAttrErr(ErrTerramateSchema, attr.NameRange,
+				"%s.%s", vendor.Type, attr.Name))
 		}
 	}
 	errs.Append(checkNoLabels(vendor))
@@ -1399,9 +1817,8 @@ func parseVendorConfig(cfg *VendorConfig, vendor *ast.Block) error {
 				errs.Append(errors.E(err, attr.NameRange))
 			}
 		default:
-			errs.Append(errors.E(ErrTerramateSchema, attr.NameRange,
-				"unrecognized attribute %s.%s", defaultBlock.Type, attr.Name,
-			))
+			errs.Append(unrecognizedAttrErr(ErrTerramateSchema, attr.NameRange,
+				"%s.%s", defaultBlock.Type, attr.Name))
 		}
 	}
 
@@ -1418,12 +1835,11 @@ func parseRootConfig(cfg *RootConfig, block *ast.MergedBlock) error {
 	logger.Trace().Msg("Range over block attributes.")
 
 	for _, attr := range block.Attributes.SortedList() {
-		errs.Append(errors.E(attr.NameRange,
-			"unrecognized attribute terramate.config.%s", attr.Name,
-		))
+		errs.Append(unrecognizedAttrErr(ErrTerramateSchema, attr.NameRange,
+			"terramate.config.%s", attr.Name))
 	}
 
-	errs.AppendWrap(ErrTerramateSchema, block.ValidateSubBlocks("git", "run", "cloud"))
+	errs.AppendWrap(ErrTerramateSchema, block.ValidateSubBlocks("git", "run", "cloud", "globals"))
 
 	gitBlock, ok := block.Blocks[ast.NewEmptyLabelBlockType("git")]
 	if ok {
@@ -1460,6 +1876,63 @@ func parseRootConfig(cfg *RootConfig, block *ast.MergedBlock) error {
 		errs.Append(parseCloudConfig(cfg.Cloud, cloudBlock))
 	}
 
+	globalsBlock, ok := block.Blocks[ast.NewEmptyLabelBlockType("globals")]
+	if ok {
+		logger.Trace().Msg("Type is 'globals'")
+
+		cfg.Globals = &GlobalsConfig{}
+
+		logger.Trace().Msg("Parse globals config.")
+
+		errs.Append(parseGlobalsConfig(cfg.Globals, globalsBlock))
+	}
+
+	return errs.AsError()
+}
+
+func parseGlobalsConfig(globalsCfg *GlobalsConfig, globalsBlock *ast.MergedBlock) error {
+	errs := errors.L()
+
+	errs.AppendWrap(ErrTerramateSchema, globalsBlock.ValidateSubBlocks())
+
+	for _, attr := range globalsBlock.Attributes.SortedList() {
+		value, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			errs.Append(errors.E(diags,
+				"failed to evaluate terramate.config.globals.%s attribute", attr.Name,
+			))
+			continue
+		}
+
+		switch attr.Name {
+		case "lazy_evaluation":
+			if value.Type() != cty.Bool {
+				errs.Append(attrErr(attr,
+					"terramate.config.globals.lazy_evaluation is not a bool but %q",
+					value.Type().FriendlyName(),
+				))
+				continue
+			}
+			globalsCfg.LazyEvaluation = value.True()
+		case "cross_stack_references":
+			if value.Type() != cty.Bool {
+				errs.Append(attrErr(attr,
+					"terramate.config.globals.cross_stack_references is not a bool but %q",
+					value.Type().FriendlyName(),
+				))
+				continue
+			}
+			globalsCfg.CrossStackReferences = value.True()
+		default:
+			errs.Append(unrecognizedAttrErr(
+				ErrTerramateSchema,
+				attr.NameRange,
+				"terramate.config.globals.%s",
+				attr.Name,
+			))
+		}
+	}
+
 	return errs.AsError()
 }
 
@@ -1609,9 +2082,10 @@ func parseGitConfig(git *GitConfig, gitBlock *ast.MergedBlock) error {
 			git.CheckRemote = value.True()
 
 		default:
-			errs.Append(errors.E(
+			errs.Append(unrecognizedAttrErr(
+				ErrTerramateSchema,
 				attr.NameRange,
-				"unrecognized attribute terramate.config.git.%s",
+				"terramate.config.git.%s",
 				attr.Name,
 			))
 		}
@@ -1659,9 +2133,10 @@ func parseCloudConfig(cloud *CloudConfig, cloudBlock *ast.MergedBlock) error {
 			cloud.Organization = value.AsString()
 
 		default:
-			errs.Append(errors.E(
+			errs.Append(unrecognizedAttrErr(
+				ErrTerramateSchema,
 				attr.NameRange,
-				"unrecognized attribute terramate.config.cloud.%s",
+				"terramate.config.cloud.%s",
 				attr.Name,
 			))
 		}
@@ -1692,14 +2167,15 @@ func (p *TerramateParser) parseTerramateSchema() (Config, error) {
 	}
 
 	for _, attr := range rawconfig.MergedAttributes.SortedList() {
-		errs.Append(errors.E(errKind, attr.NameRange,
-			"unrecognized attribute %q", attr.Name))
+		errs.Append(unrecognizedAttrErr(errKind, attr.NameRange,
+			"%q", attr.Name))
 	}
 
 	logger.Trace().Msg("Range over unmerged blocks.")
 
-	var foundstack, foundVendor bool
-	var stackblock, vendorBlock *ast.Block
+	var foundstack, foundVendor, foundStackDefaults bool
+	var stackblock, vendorBlock, stackDefaultsBlock *ast.Block
+	functions := map[string]struct{}{}
 
 	for _, block := range rawconfig.UnmergedBlocks {
 		// unmerged blocks
@@ -1714,7 +2190,7 @@ func (p *TerramateParser) parseTerramateSchema() (Config, error) {
 
 			if foundstack {
 				errs.Append(errors.E(errKind, block.DefRange(),
-					"duplicated stack block"))
+					errors.E(ErrDuplicatedBlock, "stack")))
 				continue
 			}
 
@@ -1741,6 +2217,18 @@ func (p *TerramateParser) parseTerramateSchema() (Config, error) {
 			foundVendor = true
 			vendorBlock = block
 
+		case "stack_defaults":
+			logger.Trace().Msg("found stack_defaults block")
+
+			if foundStackDefaults {
+				errs.Append(errors.E(errKind, block.DefRange(),
+					"duplicated stack_defaults block"))
+				continue
+			}
+
+			foundStackDefaults = true
+			stackDefaultsBlock = block
+
 		case "generate_hcl":
 			logger.Trace().Msg("Found \"generate_hcl\" block")
 
@@ -1758,9 +2246,27 @@ func (p *TerramateParser) parseTerramateSchema() (Config, error) {
 			if err == nil {
 				config.Generate.Files = append(config.Generate.Files, genfile)
 			}
+
+		case "function":
+			logger.Trace().Msg("Found \"function\" block")
+
+			fn, err := p.parseFunctionBlock(block)
+			if err != nil {
+				errs.Append(err)
+				continue
+			}
+			if _, ok := functions[fn.Name]; ok {
+				errs.Append(errors.E(errKind, block.DefRange(),
+					errors.E(ErrDuplicatedBlock, "function %s", fn.Name)))
+				continue
+			}
+			functions[fn.Name] = struct{}{}
+			config.Functions = append(config.Functions, fn)
 		}
 	}
 
+	errs.Append(checkFunctionsRecursion(config.Functions))
+
 	tmBlock, ok := rawconfig.MergedBlocks["terramate"]
 	if ok {
 		var tmconfig Terramate
@@ -1796,6 +2302,30 @@ func (p *TerramateParser) parseTerramateSchema() (Config, error) {
 
 	config.Globals = globals
 
+	globalsSchema := ast.MergedLabelBlocks{}
+	for labelType, mergedBlock := range rawconfig.MergedLabelBlocks {
+		if labelType.Type == "globals_schema" {
+			globalsSchema[labelType] = mergedBlock
+
+			errs.AppendWrap(ErrTerramateSchema, validateGlobalsSchema(mergedBlock))
+		}
+	}
+
+	config.GlobalsSchema = globalsSchema
+
+	if foundStackDefaults {
+		logger.Debug().Msg("parsing stack_defaults")
+
+		if foundstack {
+			errs.Append(errors.E(errKind, stackDefaultsBlock.DefRange(),
+				"stack_defaults block is not allowed in a stack directory"))
+		}
+		config.StackDefaults, err = p.parseStackDefaults(stackDefaultsBlock)
+		if err != nil {
+			errs.AppendWrap(errKind, err)
+		}
+	}
+
 	if foundstack {
 		logger.Debug().Msg("Parsing stack cfg.")
 
@@ -1858,10 +2388,64 @@ func validateGlobals(block *ast.MergedBlock) error {
 		return errors.E(ErrTerramateSchema,
 			block.RawOrigins[0].TypeRange, "unexpected block type %q", block.Type)
 	}
-	errs.Append(block.ValidateSubBlocks("map"))
+	errs.Append(block.ValidateSubBlocks("map", "data_file"))
 	for _, raw := range block.RawOrigins {
 		for _, subBlock := range raw.Blocks {
-			errs.Append(validateMap(subBlock))
+			switch subBlock.Type {
+			case "map":
+				errs.Append(validateMap(subBlock))
+			case "data_file":
+				errs.Append(validateDataFile(subBlock))
+			}
+		}
+	}
+	return errs.AsError()
+}
+
+func validateDataFile(block *ast.Block) error {
+	errs := errors.L()
+	if len(block.Labels) != 1 || block.Labels[0] == "" {
+		errs.Append(errors.E(block.LabelRanges(),
+			"data_file block requires a single label with the file path"))
+	}
+	if len(block.Blocks) > 0 {
+		errs.Append(errors.E(block.Blocks[0].TypeRange,
+			"data_file block does not support sub blocks"))
+	}
+	for _, attr := range block.Attributes.SortedList() {
+		if attr.Name != "format" {
+			errs.Append(unrecognizedAttrErr(ErrTerramateSchema, attr.NameRange,
+				"%q in data_file block", attr.Name))
+			continue
+		}
+		format := ""
+		val, diags := attr.Expr.Value(nil)
+		if !diags.HasErrors() && val.Type() == cty.String {
+			format = val.AsString()
+		}
+		switch format {
+		case "json", "yaml", "tfvars":
+		default:
+			errs.Append(errors.E(attr.Expr.Range(),
+				"data_file.format must be one of \"json\", \"yaml\" or \"tfvars\""))
+		}
+	}
+	return errs.AsError()
+}
+
+func validateGlobalsSchema(block *ast.MergedBlock) error {
+	errs := errors.L()
+	errs.Append(block.ValidateSubBlocks())
+	if len(block.Labels) > 0 && !hclsyntax.ValidIdentifier(block.Labels[0]) {
+		errs.Append(errors.E(block.RawOrigins[0].LabelRanges(),
+			"first globals_schema label must be a valid identifier but got %s",
+			block.Labels[0]))
+	}
+	for _, attr := range block.Attributes.SortedList() {
+		_, _, diags := typeexpr.TypeConstraintWithDefaults(attr.Expr)
+		if diags.HasErrors() {
+			errs.Append(errors.E(attr.Expr.Range(), diags,
+				"globals_schema attribute %q is not a valid type constraint", attr.Name))
 		}
 	}
 	return errs.AsError()
@@ -1947,7 +2531,7 @@ func parseTerramateBlock(block *ast.MergedBlock) (Terramate, error) {
 			}
 			if foundReqVersion {
 				errs.Append(errors.E(errKind, attr.NameRange,
-					"duplicated attribute"))
+					errors.E(ErrDuplicatedAttribute, attr.Name)))
 			}
 			foundReqVersion = true
 			tm.RequiredVersion = value.AsString()
@@ -1964,7 +2548,7 @@ func parseTerramateBlock(block *ast.MergedBlock) (Terramate, error) {
 
 			if foundAllowPrereleases {
 				errs.Append(errors.E(errKind, attr.NameRange,
-					"duplicated attribute"))
+					errors.E(ErrDuplicatedAttribute, attr.Name)))
 			}
 
 			foundAllowPrereleases = true
@@ -2000,6 +2584,12 @@ func parseTerramateBlock(block *ast.MergedBlock) (Terramate, error) {
 	return tm, nil
 }
 
+// unrecognizedAttrErr returns the error of kind for the attribute not supported
+// by its block, wrapping an ErrUnrecognizedAttribute error.
+func unrecognizedAttrErr(kind errors.Kind, rng hcl.Range, format string, args ...interface{}) error {
+	return errors.E(kind, rng, errors.E(ErrUnrecognizedAttribute, fmt.Sprintf(format, args...)))
+}
+
 func hclAttrErr(attr *hcl.Attribute, msg string, args ...interface{}) error {
 	return errors.E(ErrTerramateSchema, attr.Expr.Range(), fmt.Sprintf(msg, args...))
 }
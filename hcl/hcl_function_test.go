@@ -0,0 +1,323 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl_test
+
+import (
+	"testing"
+
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/hcl"
+	"github.com/terramate-io/terramate/test"
+)
+
+func TestHCLParserFunction(t *testing.T) {
+	for _, tc := range []testcase{
+		{
+			name: "function with params",
+			input: []cfgfile{
+				{
+					filename: "funcs.tm",
+					body: `
+						function "slug" {
+							params = ["name", "sep"]
+							result = tm_replace(tm_lower(name), " ", sep)
+						}
+					`,
+				},
+			},
+			want: want{
+				config: hcl.Config{
+					Functions: []hcl.Function{
+						{
+							Name:   "slug",
+							Params: []string{"name", "sep"},
+							Result: test.NewExpr(t, `tm_replace(tm_lower(name), " ", sep)`),
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "function without params",
+			input: []cfgfile{
+				{
+					filename: "funcs.tm",
+					body: `
+						function "regions" {
+							result = ["us-east-1", "eu-west-1"]
+						}
+					`,
+				},
+			},
+			want: want{
+				config: hcl.Config{
+					Functions: []hcl.Function{
+						{
+							Name:   "regions",
+							Result: test.NewExpr(t, `["us-east-1", "eu-west-1"]`),
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "functions calling each other",
+			input: []cfgfile{
+				{
+					filename: "funcs.tm",
+					body: `
+						function "a" {
+							params = ["x"]
+							result = tm_b(x) + 1
+						}
+						function "b" {
+							params = ["x"]
+							result = x * 2
+						}
+					`,
+				},
+			},
+			want: want{
+				config: hcl.Config{
+					Functions: []hcl.Function{
+						{
+							Name:   "a",
+							Params: []string{"x"},
+							Result: test.NewExpr(t, `tm_b(x) + 1`),
+						},
+						{
+							Name:   "b",
+							Params: []string{"x"},
+							Result: test.NewExpr(t, `x * 2`),
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "function without label fails",
+			input: []cfgfile{
+				{
+					filename: "funcs.tm",
+					body: `
+						function {
+							result = 1
+						}
+					`,
+				},
+			},
+			want: want{
+				errs: []error{
+					errors.E(hcl.ErrTerramateSchema),
+				},
+			},
+		},
+		{
+			name: "function without result fails",
+			input: []cfgfile{
+				{
+					filename: "funcs.tm",
+					body: `
+						function "f" {
+							params = ["a"]
+						}
+					`,
+				},
+			},
+			want: want{
+				errs: []error{
+					errors.E(hcl.ErrTerramateSchema),
+				},
+			},
+		},
+		{
+			name: "function with unrecognized attribute fails",
+			input: []cfgfile{
+				{
+					filename: "funcs.tm",
+					body: `
+						function "f" {
+							result = 1
+							other  = 2
+						}
+					`,
+				},
+			},
+			want: want{
+				errs: []error{
+					errors.E(hcl.ErrTerramateSchema),
+				},
+			},
+		},
+		{
+			name: "function with sub block fails",
+			input: []cfgfile{
+				{
+					filename: "funcs.tm",
+					body: `
+						function "f" {
+							result = 1
+							block {}
+						}
+					`,
+				},
+			},
+			want: want{
+				errs: []error{
+					errors.E(hcl.ErrTerramateSchema),
+				},
+			},
+		},
+		{
+			name: "function with invalid params fails",
+			input: []cfgfile{
+				{
+					filename: "funcs.tm",
+					body: `
+						function "f" {
+							params = "a"
+							result = 1
+						}
+					`,
+				},
+			},
+			want: want{
+				errs: []error{
+					errors.E(hcl.ErrTerramateSchema),
+				},
+			},
+		},
+		{
+			name: "function with reserved param name fails",
+			input: []cfgfile{
+				{
+					filename: "funcs.tm",
+					body: `
+						function "f" {
+							params = ["global"]
+							result = global
+						}
+					`,
+				},
+			},
+			want: want{
+				errs: []error{
+					errors.E(hcl.ErrTerramateSchema),
+				},
+			},
+		},
+		{
+			name: "function result referencing globals fails",
+			input: []cfgfile{
+				{
+					filename: "funcs.tm",
+					body: `
+						function "f" {
+							params = ["a"]
+							result = "${a}-${global.env}"
+						}
+					`,
+				},
+			},
+			want: want{
+				errs: []error{
+					errors.E(hcl.ErrTerramateSchema),
+				},
+			},
+		},
+		{
+			name: "function conflicting with builtin fails",
+			input: []cfgfile{
+				{
+					filename: "funcs.tm",
+					body: `
+						function "upper" {
+							params = ["a"]
+							result = a
+						}
+					`,
+				},
+			},
+			want: want{
+				errs: []error{
+					errors.E(hcl.ErrTerramateSchema),
+				},
+			},
+		},
+		{
+			name: "duplicated function fails",
+			input: []cfgfile{
+				{
+					filename: "funcs.tm",
+					body: `
+						function "f" {
+							result = 1
+						}
+					`,
+				},
+				{
+					filename: "other.tm",
+					body: `
+						function "f" {
+							result = 2
+						}
+					`,
+				},
+			},
+			want: want{
+				errs: []error{
+					errors.E(hcl.ErrTerramateSchema),
+				},
+			},
+		},
+		{
+			name: "recursive function fails",
+			input: []cfgfile{
+				{
+					filename: "funcs.tm",
+					body: `
+						function "fact" {
+							params = ["n"]
+							result = n <= 1 ? 1 : n * tm_fact(n - 1)
+						}
+					`,
+				},
+			},
+			want: want{
+				errs: []error{
+					errors.E(hcl.ErrTerramateSchema),
+				},
+			},
+		},
+		{
+			name: "mutually recursive functions fails",
+			input: []cfgfile{
+				{
+					filename: "funcs.tm",
+					body: `
+						function "a" {
+							params = ["x"]
+							result = tm_b(x)
+						}
+						function "b" {
+							params = ["x"]
+							result = tm_c(x)
+						}
+						function "c" {
+							params = ["x"]
+							result = tm_a(x)
+						}
+					`,
+				},
+			},
+			want: want{
+				errs: []error{
+					errors.E(hcl.ErrTerramateSchema),
+				},
+			},
+		},
+	} {
+		testParser(t, tc)
+	}
+}
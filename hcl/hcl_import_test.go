@@ -4,11 +4,18 @@
 package hcl_test
 
 import (
+	"path/filepath"
 	"testing"
 
+	"github.com/madlambda/spells/assert"
 	"github.com/terramate-io/terramate/errors"
 	"github.com/terramate-io/terramate/hcl"
+	"github.com/terramate-io/terramate/project"
+	"github.com/terramate-io/terramate/test"
+	errtest "github.com/terramate-io/terramate/test/errors"
 	. "github.com/terramate-io/terramate/test/hclutils"
+	"github.com/terramate-io/terramate/test/sandbox"
+	"github.com/terramate-io/terramate/tf"
 )
 
 func TestHCLImport(t *testing.T) {
@@ -372,3 +379,230 @@ func TestHCLImport(t *testing.T) {
 		testParser(t, tc)
 	}
 }
+
+func TestHCLImportRemoteSource(t *testing.T) {
+	t.Parallel()
+
+	s := sandbox.NoGit(t)
+	s.BuildTree([]string{"d:stack"})
+
+	const source = "git::https://example.com/shared.git//shared/*.tm.hcl?ref=v1"
+	s.DirEntry("stack").CreateFile("import.tm", `import {
+		source = "`+source+`"
+	}`)
+
+	stackdir := filepath.Join(s.RootDir(), "stack")
+
+	// the parser never fetches remote sources.
+	_, err := hcl.ParseDir(s.RootDir(), stackdir)
+	assert.IsTrue(t, errors.IsKind(err, hcl.ErrImport), "unexpected error: %v", err)
+
+	_, missing, err := hcl.ParseDirMissingImports(s.RootDir(), stackdir)
+	assert.NoError(t, err)
+	assert.EqualInts(t, 1, len(missing))
+	assert.EqualStrings(t, source, missing[0])
+
+	modsrc, err := tf.ParseSource(source)
+	assert.NoError(t, err)
+	vendoredAt := modsrc.VendorDir(project.NewPath(hcl.DefaultVendorDir))
+	test.WriteFile(t, filepath.Join(s.RootDir(), vendoredAt.String(), "shared"),
+		"globals.tm.hcl", `globals {
+		origin = "vendored"
+	}`)
+
+	_, missing, err = hcl.ParseDirMissingImports(s.RootDir(), stackdir)
+	assert.NoError(t, err)
+	assert.EqualInts(t, 0, len(missing))
+
+	cfg, err := hcl.ParseDir(s.RootDir(), stackdir)
+	assert.NoError(t, err)
+
+	globals := cfg.Globals.AsList()
+	assert.EqualInts(t, 1, len(globals))
+	attr, ok := globals[0].Attributes["origin"]
+	assert.IsTrue(t, ok, "global origin not imported")
+	val, diags := attr.Expr.Value(nil)
+	assert.IsTrue(t, !diags.HasErrors(), diags.Error())
+	assert.EqualStrings(t, "vendored", val.AsString())
+
+	assert.EqualInts(t, 1, len(cfg.VendoredImports))
+	assert.EqualStrings(t, vendoredAt.Join("shared/globals.tm.hcl").String(),
+		cfg.VendoredImports[0].String())
+}
+
+func TestHCLImportRemoteSourceWithoutRef(t *testing.T) {
+	t.Parallel()
+
+	s := sandbox.NoGit(t)
+	s.BuildTree([]string{"d:stack"})
+	s.DirEntry("stack").CreateFile("import.tm", `import {
+		source = "git::https://example.com/repo.git//shared/globals.tm.hcl"
+	}`)
+
+	_, err := hcl.ParseDir(s.RootDir(), filepath.Join(s.RootDir(), "stack"))
+	assert.IsTrue(t, errors.IsKind(err, hcl.ErrImport), "unexpected error: %v", err)
+}
+
+func TestHCLParserOverlay(t *testing.T) {
+	t.Parallel()
+
+	s := sandbox.NoGit(t)
+	s.BuildTree([]string{
+		`f:shared/globals.tm:globals {
+			origin = "disk"
+		}`,
+		`f:stack/import.tm:import {
+			source = "/shared/globals.tm"
+		}`,
+		`f:stack/globals.tm:globals {
+			local = "disk"
+		}`,
+	})
+
+	stackdir := filepath.Join(s.RootDir(), "stack")
+	p, err := hcl.NewTerramateParser(s.RootDir(), stackdir)
+	assert.NoError(t, err)
+	p.SetOverlay(map[string][]byte{
+		filepath.Join(s.RootDir(), "shared/globals.tm"): []byte(`globals {
+			origin = "overlay"
+		}`),
+		filepath.Join(stackdir, "globals.tm"): []byte(`globals {
+			local = "overlay"
+		}`),
+	})
+	assert.NoError(t, p.AddDir(stackdir))
+	cfg, err := p.ParseConfig()
+	assert.NoError(t, err)
+
+	globals := cfg.Globals.AsList()
+	assert.EqualInts(t, 1, len(globals))
+	for _, name := range []string{"origin", "local"} {
+		attr, ok := globals[0].Attributes[name]
+		assert.IsTrue(t, ok, "global %s not found", name)
+		val, diags := attr.Expr.Value(nil)
+		assert.IsTrue(t, !diags.HasErrors(), diags.Error())
+		assert.EqualStrings(t, "overlay", val.AsString())
+	}
+}
+
+func TestHCLImportCondition(t *testing.T) {
+	t.Parallel()
+
+	type testcase struct {
+		name      string
+		rootcfg   string
+		stack     string
+		condition string
+		want      bool
+		wantErr   error
+	}
+
+	for _, tc := range []testcase{
+		{
+			name:      "true condition imports",
+			condition: `true`,
+			want:      true,
+		},
+		{
+			name:      "false condition does not import",
+			condition: `false`,
+		},
+		{
+			name:      "condition with stack tags",
+			stack:     `stack { tags = ["aws"] }`,
+			condition: `tm_contains(terramate.stack.tags, "aws")`,
+			want:      true,
+		},
+		{
+			name:      "condition with non-matching stack tags",
+			stack:     `stack { tags = ["gcp"] }`,
+			condition: `tm_contains(terramate.stack.tags, "aws")`,
+		},
+		{
+			name:      "condition with stack metadata",
+			stack:     `stack { id = "my-stack" }`,
+			condition: `terramate.stack.id == "my-stack" && terramate.stack.name == "dir" && terramate.stack.path.to_root == ".."`,
+			want:      true,
+		},
+		{
+			name:      "condition with stack_defaults tags",
+			rootcfg:   `stack_defaults { tags = ["aws"] }`,
+			stack:     `stack {}`,
+			condition: `tm_contains(terramate.stack.tags, "aws")`,
+			want:      true,
+		},
+		{
+			name:      "condition with stack tags overriding stack_defaults tags",
+			rootcfg:   `stack_defaults { tags = ["aws"] }`,
+			stack:     `stack { tags = ["gcp"] }`,
+			condition: `tm_contains(terramate.stack.tags, "aws")`,
+		},
+		{
+			name:      "condition with stack_defaults description",
+			rootcfg:   `stack_defaults { description = "${terramate.stack.name} on aws" }`,
+			stack:     `stack {}`,
+			condition: `terramate.stack.description == "dir on aws"`,
+			want:      true,
+		},
+		{
+			name:      "condition with computed stack tags fails",
+			rootcfg:   `stack_defaults { tags = ["aws"] }`,
+			stack:     `stack { tags = [global.cloud] }`,
+			condition: `tm_contains(terramate.stack.tags, "aws")`,
+			wantErr:   errors.E(hcl.ErrImport),
+		},
+		{
+			name:      "condition with root metadata and env",
+			condition: `terramate.root.path.fs.basename != "" && tm_try(env.TM_TEST_UNDEFINED_ENV, "") == ""`,
+			want:      true,
+		},
+		{
+			name:      "condition with stack metadata outside stack fails",
+			condition: `tm_contains(terramate.stack.tags, "aws")`,
+			wantErr:   errors.E(hcl.ErrImport),
+		},
+		{
+			name:      "condition with globals fails",
+			condition: `global.enabled`,
+			wantErr:   errors.E(hcl.ErrImport),
+		},
+		{
+			name:      "non-boolean condition fails",
+			condition: `"true"`,
+			wantErr:   errors.E(hcl.ErrImport),
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := sandbox.NoGit(t)
+			s.BuildTree([]string{
+				`f:imports/aws.tm.hcl:globals {
+					cloud = "aws"
+				}`,
+			})
+			if tc.rootcfg != "" {
+				s.RootEntry().CreateFile("terramate.tm", tc.rootcfg)
+			}
+			dir := s.RootEntry().CreateDir("dir")
+			if tc.stack != "" {
+				dir.CreateFile("stack.tm", tc.stack)
+			}
+			dir.CreateFile("import.tm", `import {
+				source    = "/imports/aws.tm.hcl"
+				condition = `+tc.condition+`
+			}`)
+
+			cfg, err := hcl.ParseDir(s.RootDir(), dir.Path())
+			errtest.Assert(t, err, tc.wantErr)
+			if tc.wantErr != nil {
+				return
+			}
+			imported := len(cfg.Globals.AsList()) > 0
+			if imported != tc.want {
+				t.Fatalf("imported = %t but want %t", imported, tc.want)
+			}
+		})
+	}
+}
@@ -6,9 +6,12 @@ package hcl_test
 import (
 	"testing"
 
+	hhcl "github.com/hashicorp/hcl/v2"
+
 	"github.com/terramate-io/terramate/errors"
 	"github.com/terramate-io/terramate/hcl"
 	"github.com/terramate-io/terramate/hcl/eval"
+	"github.com/terramate-io/terramate/test"
 	. "github.com/terramate-io/terramate/test/hclutils"
 )
 
@@ -384,6 +387,112 @@ func TestHCLParserStack(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:               "'after' referencing terramate.stacks.list is computed",
+			computedStackAttrs: true,
+			input: []cfgfile{
+				{
+					filename: "stack.tm",
+					body: `
+						stack {
+							after = terramate.stacks.list
+						}
+					`,
+				},
+			},
+			want: want{
+				config: hcl.Config{
+					Stack: &hcl.Stack{
+						Computed: []*hhcl.Attribute{{Name: "after"}},
+					},
+				},
+			},
+		},
+		{
+			name:               "attributes referencing globals are computed",
+			computedStackAttrs: true,
+			input: []cfgfile{
+				{
+					filename: "stack.tm",
+					body: `
+						stack {
+							name        = "stack"
+							description = "stack of ${global.env}"
+							tags        = ["env:${global.env}", "static"]
+							after       = global.after
+							before      = ["/other"]
+						}
+					`,
+				},
+			},
+			want: want{
+				config: hcl.Config{
+					Stack: &hcl.Stack{
+						Name:   "stack",
+						Before: []string{"/other"},
+						Computed: []*hhcl.Attribute{
+							{Name: "after"},
+							{Name: "description"},
+							{Name: "tags"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "'id' referencing globals - fails",
+			input: []cfgfile{
+				{
+					filename: "stack.tm",
+					body: `
+						stack {
+							id = global.id
+						}
+					`,
+				},
+			},
+			want: want{
+				errs: []error{
+					errors.E(hcl.ErrTerramateSchema),
+				},
+			},
+		},
+		{
+			name: "'name' referencing terramate metadata - fails",
+			input: []cfgfile{
+				{
+					filename: "stack.tm",
+					body: `
+						stack {
+							name = terramate.stack.path.basename
+						}
+					`,
+				},
+			},
+			want: want{
+				errs: []error{
+					errors.E(hcl.ErrTerramateSchema),
+				},
+			},
+		},
+		{
+			name: "unrecognized attribute referencing globals - fails",
+			input: []cfgfile{
+				{
+					filename: "stack.tm",
+					body: `
+						stack {
+							unknown = global.value
+						}
+					`,
+				},
+			},
+			want: want{
+				errs: []error{
+					errors.E(hcl.ErrTerramateSchema),
+				},
+			},
+		},
 		{
 			name: "'after' invalid type",
 			input: []cfgfile{
@@ -585,3 +694,127 @@ func TestHCLParserStack(t *testing.T) {
 		testParser(t, tc)
 	}
 }
+
+func TestHCLParserStackDefaults(t *testing.T) {
+	for _, tc := range []testcase{
+		{
+			name: "stack_defaults with all attributes",
+			input: []cfgfile{
+				{
+					filename: "defaults.tm",
+					body: `
+						stack_defaults {
+							description = "stack ${terramate.stack.name}"
+							tags        = ["aws", "prod"]
+							after       = ["/network"]
+							before      = ["/apps"]
+							wants       = ["/monitoring"]
+							watch       = ["/policies/default.json"]
+						}
+					`,
+				},
+			},
+			want: want{
+				config: hcl.Config{
+					StackDefaults: &hcl.StackDefaults{
+						Description: test.NewExpr(t, `"stack ${terramate.stack.name}"`),
+						Tags:        []string{"aws", "prod"},
+						After:       []string{"/network"},
+						Before:      []string{"/apps"},
+						Wants:       []string{"/monitoring"},
+						Watch:       []string{"/policies/default.json"},
+					},
+				},
+			},
+		},
+		{
+			name: "empty stack_defaults",
+			input: []cfgfile{
+				{
+					filename: "defaults.tm",
+					body:     `stack_defaults {}`,
+				},
+			},
+			want: want{
+				config: hcl.Config{
+					StackDefaults: &hcl.StackDefaults{},
+				},
+			},
+		},
+		{
+			name: "stack_defaults in stack directory fails",
+			input: []cfgfile{
+				{
+					filename: "stack.tm",
+					body: `
+						stack {}
+						stack_defaults {
+							tags = ["aws"]
+						}
+					`,
+				},
+			},
+			want: want{
+				errs: []error{
+					errors.E(hcl.ErrTerramateSchema),
+				},
+			},
+		},
+		{
+			name: "duplicated stack_defaults fails",
+			input: []cfgfile{
+				{
+					filename: "defaults.tm",
+					body:     `stack_defaults {}`,
+				},
+				{
+					filename: "other.tm",
+					body:     `stack_defaults {}`,
+				},
+			},
+			want: want{
+				errs: []error{
+					errors.E(hcl.ErrTerramateSchema),
+				},
+			},
+		},
+		{
+			name: "stack_defaults with unrecognized attribute fails",
+			input: []cfgfile{
+				{
+					filename: "defaults.tm",
+					body: `
+						stack_defaults {
+							wanted_by = ["/other"]
+						}
+					`,
+				},
+			},
+			want: want{
+				errs: []error{
+					errors.E(hcl.ErrTerramateSchema),
+				},
+			},
+		},
+		{
+			name: "stack_defaults with invalid tags fails",
+			input: []cfgfile{
+				{
+					filename: "defaults.tm",
+					body: `
+						stack_defaults {
+							tags = "aws"
+						}
+					`,
+				},
+			},
+			want: want{
+				errs: []error{
+					errors.E(hcl.ErrTerramateSchema),
+				},
+			},
+		},
+	} {
+		testParser(t, tc)
+	}
+}
@@ -30,12 +30,13 @@ type (
 	}
 
 	testcase struct {
-		name      string
-		nonStrict bool
-		parsedir  string
-		rootdir   string
-		input     []cfgfile
-		want      want
+		name               string
+		nonStrict          bool
+		computedStackAttrs bool
+		parsedir           string
+		rootdir            string
+		input              []cfgfile
+		want               want
 	}
 )
 
@@ -633,6 +634,88 @@ func TestHCLParserRootConfig(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "config.globals with lazy_evaluation",
+			input: []cfgfile{
+				{
+					filename: "cfg.tm",
+					body: `
+						terramate {
+							config {
+								globals {
+									lazy_evaluation = true
+								}
+							}
+						}
+					`,
+				},
+			},
+			want: want{
+				config: hcl.Config{
+					Terramate: &hcl.Terramate{
+						Config: &hcl.RootConfig{
+							Globals: &hcl.GlobalsConfig{
+								LazyEvaluation: true,
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "config.globals with cross_stack_references",
+			input: []cfgfile{
+				{
+					filename: "cfg.tm",
+					body: `
+						terramate {
+							config {
+								globals {
+									cross_stack_references = true
+								}
+							}
+						}
+					`,
+				},
+			},
+			want: want{
+				config: hcl.Config{
+					Terramate: &hcl.Terramate{
+						Config: &hcl.RootConfig{
+							Globals: &hcl.GlobalsConfig{
+								CrossStackReferences: true,
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "config.globals with invalid attributes",
+			input: []cfgfile{
+				{
+					filename: "cfg.tm",
+					body: `
+						terramate {
+							config {
+								globals {
+									lazy_evaluation = "yes"
+									unknown = true
+								}
+							}
+						}
+					`,
+				},
+			},
+			want: want{
+				errs: []error{
+					errors.E(hcl.ErrTerramateSchema,
+						Mkrange("cfg.tm", Start(5, 28, 80), End(5, 33, 85))),
+					errors.E(hcl.ErrTerramateSchema,
+						Mkrange("cfg.tm", Start(6, 10, 95), End(6, 17, 102))),
+				},
+			},
+		},
 	} {
 		testParser(t, tc)
 	}
@@ -1100,6 +1183,10 @@ func parse(tc testcase) (hcl.Config, error) {
 		return hcl.Config{}, err
 	}
 
+	if tc.computedStackAttrs {
+		parser.EnableComputedStackAttrs()
+	}
+
 	err = parser.AddDir(tc.parsedir)
 	if err != nil {
 		return hcl.Config{}, errors.E("adding files to parser", err)
@@ -4,10 +4,14 @@
 package hcl_test
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/madlambda/spells/assert"
 	"github.com/terramate-io/terramate/errors"
 	"github.com/terramate-io/terramate/hcl"
+	errtest "github.com/terramate-io/terramate/test/errors"
 	. "github.com/terramate-io/terramate/test/hclutils"
 )
 
@@ -508,3 +512,70 @@ func TestHCLParserVendor(t *testing.T) {
 		testParser(t, tc)
 	}
 }
+
+func TestLoadVendorDir(t *testing.T) {
+	t.Parallel()
+
+	type testcase struct {
+		name    string
+		files   map[string]string
+		want    string
+		wantErr error
+	}
+
+	for _, tc := range []testcase{
+		{
+			name: "default vendor dir",
+			want: hcl.DefaultVendorDir,
+		},
+		{
+			name: "vendor dir on root",
+			files: map[string]string{
+				"vendor.tm": `vendor {
+					dir = "/root/vendor"
+				}`,
+			},
+			want: "/root/vendor",
+		},
+		{
+			name: ".terramate has precedence over root",
+			files: map[string]string{
+				"vendor.tm": `vendor {
+					dir = "/root/vendor"
+				}`,
+				".terramate/vendor.tm": `vendor {
+					dir = "/dot/vendor"
+				}`,
+			},
+			want: "/dot/vendor",
+		},
+		{
+			name: "relative vendor dir fails",
+			files: map[string]string{
+				"vendor.tm": `vendor {
+					dir = "vendor"
+				}`,
+			},
+			wantErr: errors.E(hcl.ErrTerramateSchema),
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rootdir := t.TempDir()
+			for name, body := range tc.files {
+				path := filepath.Join(rootdir, filepath.FromSlash(name))
+				assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+				assert.NoError(t, os.WriteFile(path, []byte(body), 0644))
+			}
+
+			got, err := hcl.LoadVendorDir(rootdir)
+			errtest.Assert(t, err, tc.wantErr)
+			if tc.wantErr != nil {
+				return
+			}
+			assert.EqualStrings(t, tc.want, got.String())
+		})
+	}
+}
@@ -0,0 +1,384 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/hcl/ast"
+	"github.com/terramate-io/terramate/hcl/eval"
+	"github.com/terramate-io/terramate/project"
+	"github.com/terramate-io/terramate/stdlib"
+	"github.com/terramate-io/terramate/tf"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// DefaultVendorDir is the project vendor dir used when no vendor.dir is
+// configured.
+const DefaultVendorDir = "/modules"
+
+// isRemoteImport tells if the import source is a remote Git source.
+// The supported sources are the same supported by module vendoring.
+func isRemoteImport(src string) bool {
+	return strings.HasPrefix(src, "git::") ||
+		strings.HasPrefix(src, "git@") ||
+		strings.HasPrefix(src, "github.com/")
+}
+
+// vendoredImport returns the host path (possibly a glob) of the files
+// imported by the remote source, which must be vendored inside the project
+// vendor dir at <vendordir>/<source path>/<ref>. The parser never fetches
+// remote sources, they are vendored with the
+// `terramate experimental vendor imports` command.
+//
+// If the source is not vendored yet, it returns false when the parser is
+// collecting the missing imports or an error otherwise.
+func (p *TerramateParser) vendoredImport(srcAttr ast.Attribute, src string) (string, bool, error) {
+	modsrc, err := tf.ParseSource(src)
+	if err != nil {
+		return "", false, errors.E(ErrImport, srcAttr.Expr.Range(), err,
+			"invalid remote import source")
+	}
+	if modsrc.Ref == "" {
+		return "", false, errors.E(ErrImport, srcAttr.Expr.Range(),
+			"remote import source %q must be pinned with a ?ref", src)
+	}
+	if modsrc.Subdir == "" {
+		return "", false, errors.E(ErrImport, srcAttr.Expr.Range(),
+			"remote import source %q must have the path of the imported files", src)
+	}
+
+	vendorDir, err := LoadVendorDir(p.rootdir)
+	if err != nil {
+		return "", false, errors.E(ErrImport, srcAttr.Expr.Range(), err,
+			"loading vendor dir for remote import")
+	}
+
+	moddir := filepath.Join(p.rootdir, filepath.FromSlash(modsrc.VendorDir(vendorDir).String()))
+	if _, err := os.Stat(moddir); err != nil {
+		if !os.IsNotExist(err) {
+			return "", false, errors.E(ErrImport, srcAttr.Expr.Range(), err,
+				"checking vendored import %q", src)
+		}
+		if p.collectMissingImports {
+			return "", false, nil
+		}
+		return "", false, errors.E(ErrImport, srcAttr.Expr.Range(),
+			"remote import %q is not vendored at %s, run `terramate experimental vendor imports` to vendor it",
+			src, modsrc.VendorDir(vendorDir))
+	}
+	return filepath.Join(moddir, filepath.FromSlash(modsrc.Subdir)), true, nil
+}
+
+// LoadVendorDir loads the vendor dir configured in the .terramate dir or in
+// the root dir of the project, in this order of precedence. The remote
+// imports of these directories are ignored. If no vendor dir is configured,
+// it returns [DefaultVendorDir].
+//
+// It's the vendor dir used by the remote imports and by the CLI commands when
+// no vendor dir is given on the command line. The remote imports are resolved
+// whenever the configuration is parsed, so they always use the configured
+// vendor dir.
+func LoadVendorDir(rootdir string) (project.Path, error) {
+	for _, dir := range []string{filepath.Join(rootdir, ".terramate"), rootdir} {
+		st, err := os.Stat(dir)
+		if err != nil || !st.IsDir() {
+			continue
+		}
+		p, err := NewTerramateParser(rootdir, dir)
+		if err != nil {
+			return project.Path{}, err
+		}
+		p.skipRemoteImports = true
+		if err := p.AddDir(dir); err != nil {
+			return project.Path{}, err
+		}
+		cfg, err := p.ParseConfig()
+		if err != nil {
+			return project.Path{}, err
+		}
+		if cfg.Vendor != nil && cfg.Vendor.Dir != "" {
+			if !path.IsAbs(cfg.Vendor.Dir) {
+				return project.Path{}, errors.E(ErrTerramateSchema,
+					"vendor.dir %q is not an absolute path", cfg.Vendor.Dir)
+			}
+			return project.NewPath(cfg.Vendor.Dir), nil
+		}
+	}
+	return project.NewPath(DefaultVendorDir), nil
+}
+
+// importEnv is the environment of the import conditions. It is created for
+// the directory of the parser where the import chain started, so nested
+// imports are evaluated with the same environment.
+type importEnv struct {
+	ctx       *eval.Context
+	terramate map[string]cty.Value
+
+	// parser is the parser of the importing directory.
+	parser *TerramateParser
+
+	// stackLoaded tells if the terramate.stack metadata was already loaded.
+	// It is loaded only when a condition references it.
+	stackLoaded bool
+
+	// computed are the names of the stack attributes which are computed
+	// from globals or the terramate metadata.
+	computed map[string]struct{}
+}
+
+// evalImportCondition evaluates the import.condition attribute, which must be
+// a boolean. See [TerramateParser.importEnv] for the available namespaces.
+func (p *TerramateParser) evalImportCondition(condAttr ast.Attribute) (bool, error) {
+	env := p.importEnv()
+
+	var stackAttrs []string
+	refsStack := false
+	for _, traversal := range condAttr.Expr.Variables() {
+		if traversal.RootName() != "terramate" || len(traversal) < 2 {
+			continue
+		}
+		if attr, ok := traversal[1].(hcl.TraverseAttr); !ok || attr.Name != "stack" {
+			continue
+		}
+		refsStack = true
+		if len(traversal) > 2 {
+			if attr, ok := traversal[2].(hcl.TraverseAttr); ok {
+				stackAttrs = append(stackAttrs, attr.Name)
+			}
+		}
+	}
+
+	if refsStack {
+		err := env.loadStack()
+		if err != nil {
+			return false, errors.E(ErrImport, condAttr.Expr.Range(), err,
+				"loading the terramate.stack metadata for import.condition")
+		}
+		if _, ok := env.terramate["stack"]; !ok {
+			return false, errors.E(ErrImport, condAttr.Expr.Range(),
+				"terramate.stack is only available in the import.condition of "+
+					"stack directories, the import is resolved once for %s and "+
+					"not for each stack of its child directories",
+				project.PrjAbsPath(env.parser.rootdir, env.parser.dir))
+		}
+		for _, name := range stackAttrs {
+			if _, ok := env.computed[name]; ok {
+				return false, errors.E(ErrImport, condAttr.Expr.Range(),
+					"terramate.stack.%s cannot be used in import.condition because "+
+						"stack.%s references globals or the terramate metadata",
+					name, name)
+			}
+		}
+	}
+
+	val, err := env.ctx.Eval(condAttr.Expr)
+	if err != nil {
+		return false, errors.E(ErrImport, condAttr.Expr.Range(), err,
+			"evaluating import.condition")
+	}
+	if !val.Type().Equals(cty.Bool) || !val.IsKnown() || val.IsNull() {
+		return false, errors.E(ErrImport, condAttr.Expr.Range(),
+			"import.condition must be a boolean but got %s", val.Type().FriendlyName())
+	}
+	return val.True(), nil
+}
+
+// importEnv returns the environment of the import conditions. It has the env
+// namespace and the terramate.root metadata. When the parsed directory is a
+// stack, the terramate.stack metadata is loaded once referenced.
+func (p *TerramateParser) importEnv() *importEnv {
+	if p.importenv != nil {
+		return p.importenv
+	}
+
+	terramate := map[string]cty.Value{
+		"root": cty.ObjectVal(map[string]cty.Value{
+			"path": cty.ObjectVal(map[string]cty.Value{
+				"fs": cty.ObjectVal(map[string]cty.Value{
+					"absolute": cty.StringVal(p.rootdir),
+					"basename": cty.StringVal(filepath.Base(p.rootdir)),
+				}),
+			}),
+		}),
+	}
+
+	ctx := eval.NewContext(stdlib.Functions(p.dir))
+	ctx.SetEnv(os.Environ())
+	ctx.SetNamespace("terramate", terramate)
+	p.importenv = &importEnv{
+		ctx:       ctx,
+		terramate: terramate,
+		parser:    p,
+	}
+	return p.importenv
+}
+
+// loadStack loads the terramate.stack metadata into the environment if the
+// importing directory is a stack.
+func (env *importEnv) loadStack() error {
+	if env.stackLoaded {
+		return nil
+	}
+	env.stackLoaded = true
+
+	stack, computed, err := env.parser.importStackMetadata()
+	if err != nil {
+		return err
+	}
+	if stack == cty.NilVal {
+		return nil
+	}
+	env.computed = computed
+	env.terramate["stack"] = stack
+	env.ctx.SetNamespace("terramate", env.terramate)
+	return nil
+}
+
+// importStackMetadata returns the effective terramate.stack metadata if the
+// parsed directory has a stack block or cty.NilVal otherwise. The tags and
+// description not defined in the stack block are set from the stack_defaults
+// of the parent directories. The names of the computed stack attributes are
+// also returned, as their values are not available at parsing time.
+func (p *TerramateParser) importStackMetadata() (cty.Value, map[string]struct{}, error) {
+	var stackblock *ast.Block
+	bodies := p.ParsedBodies()
+findStack:
+	for _, filename := range p.sortedParsedFilenames() {
+		for _, rawBlock := range bodies[filename].Blocks {
+			if rawBlock.Type == StackBlockType {
+				stackblock = ast.NewBlock(p.rootdir, rawBlock)
+				break findStack
+			}
+		}
+	}
+	if stackblock == nil {
+		return cty.NilVal, nil, nil
+	}
+
+	stack, err := p.parseStack(stackblock)
+	if err != nil {
+		return cty.NilVal, nil, err
+	}
+
+	computed := map[string]struct{}{}
+	for _, attr := range stack.Computed {
+		computed[attr.Name] = struct{}{}
+	}
+
+	stackdir := project.PrjAbsPath(p.rootdir, p.dir)
+	name := stack.Name
+	if name == "" {
+		name = filepath.Base(p.dir)
+	}
+	torel, err := filepath.Rel(p.dir, p.rootdir)
+	if err != nil {
+		return cty.NilVal, nil, errors.E(errors.ErrInternal, err)
+	}
+
+	stackVals := map[string]cty.Value{
+		"name": cty.StringVal(name),
+		"path": cty.ObjectVal(map[string]cty.Value{
+			"absolute": cty.StringVal(stackdir.String()),
+			"relative": cty.StringVal(stackdir.String()[1:]),
+			"basename": cty.StringVal(path.Base(stackdir.String())),
+			"to_root":  cty.StringVal(filepath.ToSlash(torel)),
+		}),
+	}
+	if stack.ID != "" {
+		stackVals["id"] = cty.StringVal(stack.ID)
+	}
+
+	tags := stack.Tags
+	description := stack.Description
+	if !stack.IsDefined("tags") || !stack.IsDefined("description") {
+		defaults, err := p.parentStackDefaults()
+		if err != nil {
+			return cty.NilVal, nil, err
+		}
+		tagsSet, descSet := stack.IsDefined("tags"), stack.IsDefined("description")
+		for _, def := range defaults {
+			if !tagsSet && def.Tags != nil {
+				tags = def.Tags
+				tagsSet = true
+			}
+			if !descSet && def.Description != nil {
+				description, err = evalImportDefaultDescription(p.dir, stackVals, def)
+				if err != nil {
+					return cty.NilVal, nil, err
+				}
+				descSet = true
+			}
+		}
+	}
+
+	stackVals["description"] = cty.StringVal(description)
+	stackVals["tags"] = cty.ListValEmpty(cty.String)
+	if len(tags) > 0 {
+		var vals []cty.Value
+		for _, tag := range tags {
+			vals = append(vals, cty.StringVal(tag))
+		}
+		stackVals["tags"] = cty.ListVal(vals)
+	}
+	return cty.ObjectVal(stackVals), computed, nil
+}
+
+// parentStackDefaults returns the stack_defaults blocks of the parent
+// directories of the parsed directory, sorted from the closest directory.
+func (p *TerramateParser) parentStackDefaults() ([]*StackDefaults, error) {
+	var defaults []*StackDefaults
+	if p.dir == p.rootdir {
+		return nil, nil
+	}
+	for dir := filepath.Dir(p.dir); ; dir = filepath.Dir(dir) {
+		parser, err := NewTerramateParser(p.rootdir, dir)
+		if err != nil {
+			return nil, err
+		}
+		parser.overlay = p.overlay
+		parser.skipRemoteImports = p.skipRemoteImports
+		parser.collectMissingImports = p.collectMissingImports
+		parser.computedStackAttrs = p.computedStackAttrs
+		err = parser.AddDir(dir)
+		if err != nil {
+			return nil, errors.E("adding files to parser", err)
+		}
+		cfg, err := parser.ParseConfig()
+		if err != nil {
+			return nil, err
+		}
+		if cfg.StackDefaults != nil {
+			defaults = append(defaults, cfg.StackDefaults)
+		}
+		if dir == p.rootdir {
+			break
+		}
+	}
+	return defaults, nil
+}
+
+// evalImportDefaultDescription evaluates the description template of the
+// stack_defaults block with the terramate.stack metadata of the stack.
+func evalImportDefaultDescription(stackdir string, stackVals map[string]cty.Value, def *StackDefaults) (string, error) {
+	ctx := eval.NewContext(stdlib.Functions(stackdir))
+	ctx.SetNamespace("terramate", map[string]cty.Value{
+		"stack": cty.ObjectVal(stackVals),
+	})
+	val, err := ctx.Eval(def.Description)
+	if err != nil {
+		return "", errors.E(err, "evaluating stack_defaults.description defined at %s", def.Dir)
+	}
+	if val.Type() != cty.String || val.IsNull() {
+		return "", errors.E(def.Description.Range(),
+			"stack_defaults.description must be a string but is %s",
+			val.Type().FriendlyName())
+	}
+	return val.AsString(), nil
+}
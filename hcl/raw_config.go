@@ -38,14 +38,17 @@ type mergeHandler func(r *RawConfig, block *ast.Block) error
 // Terramate top-level attributes and blocks.
 func NewTopLevelRawConfig() RawConfig {
 	return NewCustomRawConfig(map[string]mergeHandler{
-		"terramate":     (*RawConfig).mergeBlock,
-		"globals":       (*RawConfig).mergeLabeledBlock,
-		"stack":         (*RawConfig).addBlock,
-		"vendor":        (*RawConfig).addBlock,
-		"generate_file": (*RawConfig).addBlock,
-		"generate_hcl":  (*RawConfig).addBlock,
-		"assert":        (*RawConfig).addBlock,
-		"import":        func(r *RawConfig, b *ast.Block) error { return nil },
+		"terramate":      (*RawConfig).mergeBlock,
+		"globals":        (*RawConfig).mergeLabeledBlock,
+		"globals_schema": (*RawConfig).mergeLabeledBlock,
+		"stack":          (*RawConfig).addBlock,
+		"vendor":         (*RawConfig).addBlock,
+		"stack_defaults": (*RawConfig).addBlock,
+		"function":       (*RawConfig).addBlock,
+		"generate_file":  (*RawConfig).addBlock,
+		"generate_hcl":   (*RawConfig).addBlock,
+		"assert":         (*RawConfig).addBlock,
+		"import":         func(r *RawConfig, b *ast.Block) error { return nil },
 	})
 }
 
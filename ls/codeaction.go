@@ -0,0 +1,327 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package tmls
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/rs/zerolog"
+	"github.com/terramate-io/terramate/config"
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/generate"
+	tmhcl "github.com/terramate-io/terramate/hcl"
+	"github.com/terramate-io/terramate/project"
+	"github.com/zclconf/go-cty/cty"
+	"go.lsp.dev/jsonrpc2"
+	lsp "go.lsp.dev/protocol"
+)
+
+// generateStackCommand is the command generating the code of the stack in the
+// directory given as argument. Like terramate generate, it runs the post_process
+// and validate commands of the generate blocks, so it's only offered as a code
+// action the user must pick, which title tells when commands are run.
+const generateStackCommand = "terramate.generateStack"
+
+func (s *Server) handleCodeAction(
+	ctx context.Context,
+	reply jsonrpc2.Replier,
+	r jsonrpc2.Request,
+	log zerolog.Logger,
+) error {
+	var params lsp.CodeActionParams
+	if err := json.Unmarshal(r.Params(), &params); err != nil {
+		log.Error().Err(err).Msg("failed to unmarshal params")
+		return jsonrpc2.ErrParse
+	}
+
+	fname := params.TextDocument.URI.Filename()
+	content, err := s.readFile(fname)
+	if err != nil {
+		log.Error().Err(err).Msg("reading file.")
+		return reply(ctx, nil, nil)
+	}
+
+	return reply(ctx, s.codeActions(fname, content, params), nil)
+}
+
+// codeActions returns the actions available for the file, which are the quick
+// fixes of the diagnostics in the request context and the source actions of
+// the stack defined in the file directory.
+func (s *Server) codeActions(fname string, content []byte, params lsp.CodeActionParams) []lsp.CodeAction {
+	actions := []lsp.CodeAction{}
+
+	body, err := parseBody(fname, content)
+	if err != nil {
+		return actions
+	}
+
+	for _, diag := range params.Context.Diagnostics {
+		if action, ok := quickFix(params.TextDocument.URI, content, body, diag); ok {
+			actions = append(actions, action)
+		}
+	}
+
+	if action, ok := stackIDAction(params.TextDocument.URI, content, body); ok {
+		actions = append(actions, action)
+	}
+
+	dir := filepath.Dir(fname)
+	if _, tree, err := s.lookupTree(s.projectRoot(dir), dir); err == nil && tree.IsStack() {
+		title := "Run terramate generate for this stack"
+		if hasGenerateCommands(tree) {
+			title += " (runs the post_process and validate commands)"
+		}
+		actions = append(actions, lsp.CodeAction{
+			Title: title,
+			Kind:  lsp.Source,
+			Command: &lsp.Command{
+				Title:     title,
+				Command:   generateStackCommand,
+				Arguments: []interface{}{dir},
+			},
+		})
+	}
+	return actions
+}
+
+// hasGenerateCommands tells if the stack context generate blocks of the stack
+// tree and its parents define post_process or validate commands.
+func hasGenerateCommands(tree *config.Tree) bool {
+	for ; tree != nil; tree = tree.Parent {
+		for _, block := range tree.Node.Generate.Files {
+			if block.Context == tmhcl.RootContext {
+				// only generated by terramate generate for the whole project.
+				continue
+			}
+			if block.PostProcess != nil || block.Validate != nil {
+				return true
+			}
+		}
+		for _, block := range tree.Node.Generate.HCLs {
+			if block.PostProcess != nil || block.Validate != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stackIDAction returns the action adding a generated ID to the stack block of
+// the file, if it has no ID.
+func stackIDAction(docURI lsp.DocumentURI, content []byte, body *hclsyntax.Body) (lsp.CodeAction, bool) {
+	var stackBlock *hclsyntax.Block
+	for _, block := range body.Blocks {
+		if block.Type == tmhcl.StackBlockType {
+			stackBlock = block
+			break
+		}
+	}
+	if stackBlock == nil {
+		return lsp.CodeAction{}, false
+	}
+	if _, ok := stackBlock.Body.Attributes["id"]; ok {
+		return lsp.CodeAction{}, false
+	}
+
+	file, diags := hclwrite.ParseConfig(content, docURI.Filename(), hcl.InitialPos)
+	if diags.HasErrors() {
+		return lsp.CodeAction{}, false
+	}
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return lsp.CodeAction{}, false
+	}
+	for _, block := range file.Body().Blocks() {
+		if block.Type() == tmhcl.StackBlockType {
+			block.Body().SetAttributeValue("id", cty.StringVal(id.String()))
+			break
+		}
+	}
+
+	return lsp.CodeAction{
+		Title: "Generate stack ID",
+		Kind:  lsp.Source,
+		Edit: &lsp.WorkspaceEdit{
+			Changes: map[lsp.DocumentURI][]lsp.TextEdit{
+				docURI: replaceContentEdits(content, file.Bytes()),
+			},
+		},
+	}, true
+}
+
+// quickFix returns the action fixing the diagnostic, if it's known how to fix
+// it.
+func quickFix(docURI lsp.DocumentURI, content []byte, body *hclsyntax.Body, diag lsp.Diagnostic) (lsp.CodeAction, bool) {
+	pos := hclPos(diag.Range.Start)
+
+	var (
+		title string
+		rng   hcl.Range
+	)
+	// the diagnostic code is the kind of the error, see errorCode.
+	switch diag.Code {
+	case string(tmhcl.ErrUnrecognizedAttribute), string(tmhcl.ErrDuplicatedAttribute):
+		offset := byteOffset(content, diag.Range.Start)
+		if name, attrRng, ok := attrRange(docURI.Filename(), content, offset); ok {
+			title = fmt.Sprintf("Remove attribute %s", name)
+			rng = attrRng
+		}
+	case string(tmhcl.ErrDuplicatedBlock):
+		_ = hclsyntax.VisitAll(body, func(node hclsyntax.Node) hcl.Diagnostics {
+			if block, ok := node.(*hclsyntax.Block); ok && rangeContains(block.DefRange(), pos) {
+				title = fmt.Sprintf("Remove duplicated %s block", block.Type)
+				rng = block.Range()
+			}
+			return nil
+		})
+	}
+	if title == "" {
+		return lsp.CodeAction{}, false
+	}
+
+	return lsp.CodeAction{
+		Title:       title,
+		Kind:        lsp.QuickFix,
+		Diagnostics: []lsp.Diagnostic{diag},
+		IsPreferred: true,
+		Edit: &lsp.WorkspaceEdit{
+			Changes: map[lsp.DocumentURI][]lsp.TextEdit{
+				docURI: {
+					{
+						Range:   removalRange(content, rng),
+						NewText: "",
+					},
+				},
+			},
+		},
+	}, true
+}
+
+// attrRange returns the name and range of the attribute which name starts at
+// the offset. The tokens are used instead of the syntax tree because the later
+// doesn't have the attributes set more than once.
+func attrRange(fname string, content []byte, offset int) (string, hcl.Range, bool) {
+	tokens, _ := hclsyntax.LexConfig(content, fname, hcl.InitialPos)
+	for i, tok := range tokens {
+		if tok.Range.Start.Byte != offset {
+			continue
+		}
+		if tok.Type != hclsyntax.TokenIdent {
+			return "", hcl.Range{}, false
+		}
+
+		end := tok.Range
+		depth := 0
+	tokensLoop:
+		for _, next := range tokens[i+1:] {
+			switch next.Type {
+			case hclsyntax.TokenOBrace, hclsyntax.TokenOBrack, hclsyntax.TokenOParen,
+				hclsyntax.TokenTemplateInterp, hclsyntax.TokenTemplateControl,
+				hclsyntax.TokenOHeredoc:
+				depth++
+			case hclsyntax.TokenCBrace, hclsyntax.TokenCBrack, hclsyntax.TokenCParen,
+				hclsyntax.TokenTemplateSeqEnd, hclsyntax.TokenCHeredoc:
+				depth--
+				if depth < 0 {
+					break tokensLoop
+				}
+			case hclsyntax.TokenNewline, hclsyntax.TokenEOF:
+				if depth == 0 {
+					break tokensLoop
+				}
+			}
+			end = next.Range
+		}
+		return string(tok.Bytes), hcl.RangeBetween(tok.Range, end), true
+	}
+	return "", hcl.Range{}, false
+}
+
+// removalRange returns the range for removing the given range. If the range is
+// alone in its lines, the whole lines are removed.
+func removalRange(content []byte, rng hcl.Range) lsp.Range {
+	before := content[:rng.Start.Byte]
+	if i := strings.LastIndexByte(string(before), '\n'); i >= 0 {
+		before = before[i+1:]
+	}
+	after := content[rng.End.Byte:]
+	if i := strings.IndexByte(string(after), '\n'); i >= 0 {
+		after = after[:i]
+	}
+	if strings.TrimSpace(string(before)) != "" || strings.TrimSpace(string(after)) != "" {
+		return lspRange(rng)
+	}
+	return lsp.Range{
+		Start: lsp.Position{Line: uint32(rng.Start.Line) - 1},
+		End:   lsp.Position{Line: uint32(rng.End.Line)},
+	}
+}
+
+func (s *Server) handleExecuteCommand(
+	ctx context.Context,
+	reply jsonrpc2.Replier,
+	r jsonrpc2.Request,
+	log zerolog.Logger,
+) error {
+	var params lsp.ExecuteCommandParams
+	if err := json.Unmarshal(r.Params(), &params); err != nil {
+		log.Error().Err(err).Msg("failed to unmarshal params")
+		return jsonrpc2.ErrParse
+	}
+
+	if params.Command != generateStackCommand || len(params.Arguments) != 1 {
+		return reply(ctx, nil, jsonrpc2.ErrInvalidParams)
+	}
+	dir, ok := params.Arguments[0].(string)
+	if !ok {
+		return reply(ctx, nil, jsonrpc2.ErrInvalidParams)
+	}
+
+	msg := lsp.ShowMessageParams{Type: lsp.MessageTypeInfo}
+	report, err := s.generateStack(dir)
+	switch {
+	case err != nil:
+		msg.Type = lsp.MessageTypeError
+		msg.Message = err.Error()
+	case report.HasFailures():
+		msg.Type = lsp.MessageTypeError
+		msg.Message = report.Minimal()
+	default:
+		msg.Message = report.Minimal()
+		if msg.Message == "" {
+			// the full report explains that nothing was generated.
+			msg.Message = report.Full()
+		}
+	}
+
+	if err := s.conn.Notify(ctx, lsp.MethodWindowShowMessage, msg); err != nil {
+		log.Error().Err(err).Msg("failed to notify client")
+	}
+	return reply(ctx, nil, nil)
+}
+
+// generateStack generates the code of the stack in the dir, running the
+// post_process and validate commands of the generate blocks.
+func (s *Server) generateStack(dir string) (generate.Report, error) {
+	rootdir := s.projectRoot(dir)
+	if dir != rootdir && !strings.HasPrefix(dir, rootdir+string(filepath.Separator)) {
+		return generate.Report{}, errors.E("directory %s is not inside the project %s", dir, rootdir)
+	}
+	root, err := s.loadRoot(rootdir)
+	if err != nil {
+		return generate.Report{}, err
+	}
+	report := generate.DoStack(root, project.PrjAbsPath(rootdir, dir), projectVendorDir(root), nil)
+	// the generated files can be Terramate configuration files.
+	s.invalidateRoots()
+	return report, nil
+}
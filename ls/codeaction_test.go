@@ -0,0 +1,270 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package tmls_test
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/madlambda/spells/assert"
+	"github.com/terramate-io/terramate/test"
+	lstest "github.com/terramate-io/terramate/test/ls"
+	lsp "go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
+)
+
+func TestFormatting(t *testing.T) {
+	t.Parallel()
+
+	f := lstest.Setup(t, "f:globals.tm:globals {\na=1\n  b   = [1,2]\n}")
+	f.Editor.CheckInitialize(f.Sandbox.RootDir())
+
+	want := []lsp.TextEdit{
+		{
+			Range: lsp.Range{
+				End: lsp.Position{Line: 3, Character: 1},
+			},
+			NewText: "globals {\n  a = 1\n  b = [1, 2]\n}",
+		},
+	}
+	if diff := cmp.Diff(want, f.Editor.Formatting("globals.tm")); diff != "" {
+		t.Fatalf("unexpected edits, want(-) got(+):\n%s", diff)
+	}
+
+	f.Editor.Open("globals.tm")
+	waitDiagnostics(t, f, "globals.tm")
+	f.Editor.Change("globals.tm", want[0].NewText)
+	waitDiagnostics(t, f, "globals.tm")
+	if got := f.Editor.Formatting("globals.tm"); len(got) != 0 {
+		t.Fatalf("unexpected edits for formatted document: %+v", got)
+	}
+}
+
+func TestCodeActionStack(t *testing.T) {
+	t.Parallel()
+
+	f := lstest.Setup(t,
+		"f:stack/stack.tm:stack {\n  name = \"stack\"\n}\n",
+		`f:stack/gen.tm:generate_file "file.txt" {
+  content = terramate.stack.name
+}`,
+	)
+	f.Editor.CheckInitialize(f.Sandbox.RootDir())
+
+	actions := f.Editor.CodeAction("stack/stack.tm", lsp.Range{}, nil)
+	assert.EqualInts(t, 2, len(actions), "unexpected actions: %+v", actions)
+
+	genID := actions[0]
+	assert.EqualStrings(t, "Generate stack ID", genID.Title)
+	stackURI := lsp.DocumentURI(uri.File(filepath.Join(f.Sandbox.RootDir(), "stack/stack.tm")))
+	edits := genID.Edit.Changes[stackURI]
+	assert.EqualInts(t, 1, len(edits))
+	idRegex := regexp.MustCompile(`^stack \{\n  name = "stack"\n  id   = "[0-9a-f-]{36}"\n\}\n$`)
+	if !idRegex.MatchString(edits[0].NewText) {
+		t.Fatalf("unexpected stack with ID:\n%s", edits[0].NewText)
+	}
+
+	generate := actions[1]
+	assert.EqualStrings(t, "Run terramate generate for this stack", generate.Title)
+	f.Editor.ExecuteCommand(*generate.Command)
+
+	r := <-f.Editor.Requests
+	assert.EqualStrings(t, lsp.MethodWindowShowMessage, r.Method())
+	var msg lsp.ShowMessageParams
+	assert.NoError(t, json.Unmarshal(r.Params(), &msg))
+	assert.EqualStrings(t, "Created file /stack/file.txt", msg.Message)
+	test.AssertFileContentEquals(t,
+		filepath.Join(f.Sandbox.RootDir(), "stack/file.txt"), "stack")
+}
+
+func TestCodeActionQuickFix(t *testing.T) {
+	t.Parallel()
+
+	type testcase struct {
+		name      string
+		file      string
+		wantTitle string
+		wantRange lsp.Range
+	}
+
+	for _, tc := range []testcase{
+		{
+			name:      "unrecognized attribute",
+			file:      "stack {\n  name = \"a\"\n  foo  = 1\n}\n",
+			wantTitle: "Remove attribute foo",
+			wantRange: lsp.Range{
+				Start: lsp.Position{Line: 2},
+				End:   lsp.Position{Line: 3},
+			},
+		},
+		{
+			name:      "unrecognized multiline attribute",
+			file:      "stack {\n  foo = {\n    a = \"${1 + 1}\"\n  }\n  name = \"a\"\n}\n",
+			wantTitle: "Remove attribute foo",
+			wantRange: lsp.Range{
+				Start: lsp.Position{Line: 1},
+				End:   lsp.Position{Line: 4},
+			},
+		},
+		{
+			name:      "duplicated attribute",
+			file:      "terramate {\n  required_version = \"> 0.1\"\n  required_version = \"> 0.2\"\n}\n",
+			wantTitle: "Remove attribute required_version",
+			wantRange: lsp.Range{
+				Start: lsp.Position{Line: 2},
+				End:   lsp.Position{Line: 3},
+			},
+		},
+		{
+			name: "duplicated function label",
+			file: `function "f" {
+  result = 1
+}
+
+function "f" {
+  result = 2
+}
+`,
+			wantTitle: "Remove duplicated function block",
+			wantRange: lsp.Range{
+				Start: lsp.Position{Line: 4},
+				End:   lsp.Position{Line: 7},
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			f := lstest.Setup(t, "f:dir/config.tm:"+tc.file)
+			f.Editor.CheckInitialize(f.Sandbox.RootDir())
+			f.Editor.Open("dir/config.tm")
+
+			diags := fileDiagnostics(t, f, "dir/config.tm")
+			assert.EqualInts(t, 1, len(diags), "unexpected diagnostics: %+v", diags)
+
+			var actions []lsp.CodeAction
+			for _, action := range f.Editor.CodeAction("dir/config.tm", diags[0].Range, diags) {
+				if action.Kind == lsp.QuickFix {
+					actions = append(actions, action)
+				}
+			}
+			assert.EqualInts(t, 1, len(actions), "unexpected actions: %+v", actions)
+			assert.EqualStrings(t, tc.wantTitle, actions[0].Title)
+
+			docURI := lsp.DocumentURI(uri.File(filepath.Join(f.Sandbox.RootDir(), "dir/config.tm")))
+			want := []lsp.TextEdit{{Range: tc.wantRange}}
+			if diff := cmp.Diff(want, actions[0].Edit.Changes[docURI]); diff != "" {
+				t.Fatalf("unexpected edits, want(-) got(+):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCodeActionQuickFixUsesErrorKind(t *testing.T) {
+	t.Parallel()
+
+	const file = "globals {\n  a = 1\n}\n"
+
+	f := lstest.Setup(t, "f:dir/globals.tm:"+file)
+	f.Editor.CheckInitialize(f.Sandbox.RootDir())
+
+	// the messages of errors not fixed by removing the attribute or block,
+	// like redefined globals or tm_dynamic_attributes generating an attribute
+	// twice, are similar to the ones fixed.
+	diags := []lsp.Diagnostic{
+		{
+			Range:    lsp.Range{End: lsp.Position{Character: 7}},
+			Message:  "global.a is already defined",
+			Code:     "globals error",
+			Severity: lsp.DiagnosticSeverityError,
+		},
+		{
+			Range:    lsp.Range{Start: lsp.Position{Line: 1, Character: 2}},
+			Message:  "tm_dynamic_attributes generates duplicated attributes",
+			Code:     "tm_dynamic_attributes generates duplicated attributes",
+			Severity: lsp.DiagnosticSeverityError,
+		},
+	}
+	for _, diag := range diags {
+		for _, action := range f.Editor.CodeAction("dir/globals.tm", diag.Range, []lsp.Diagnostic{diag}) {
+			if action.Kind == lsp.QuickFix {
+				t.Fatalf("unexpected quick fix for %q: %+v", diag.Message, action)
+			}
+		}
+	}
+}
+
+func TestCodeActionGenerateStackWithCommands(t *testing.T) {
+	t.Parallel()
+
+	f := lstest.Setup(t,
+		"s:stack",
+		`f:stack/gen.tm:generate_file "file.txt" {
+  content      = "stack"
+  post_process = ["cat"]
+}`,
+	)
+	f.Editor.CheckInitialize(f.Sandbox.RootDir())
+
+	var titles []string
+	for _, action := range f.Editor.CodeAction("stack/gen.tm", lsp.Range{}, nil) {
+		if action.Command != nil {
+			titles = append(titles, action.Title)
+		}
+	}
+	want := []string{"Run terramate generate for this stack (runs the post_process and validate commands)"}
+	if diff := cmp.Diff(want, titles); diff != "" {
+		t.Fatalf("unexpected commands, want(-) got(+):\n%s", diff)
+	}
+}
+
+func TestExecuteCommandGenerateStackOutsideProject(t *testing.T) {
+	t.Parallel()
+
+	f := lstest.Setup(t, "s:stack")
+	f.Editor.CheckInitialize(f.Sandbox.RootDir())
+
+	// a sibling directory sharing the prefix of the project root.
+	f.Editor.ExecuteCommand(lsp.Command{
+		Command:   "terramate.generateStack",
+		Arguments: []interface{}{f.Sandbox.RootDir() + "-other/stack"},
+	})
+
+	r := <-f.Editor.Requests
+	assert.EqualStrings(t, lsp.MethodWindowShowMessage, r.Method())
+	var msg lsp.ShowMessageParams
+	assert.NoError(t, json.Unmarshal(r.Params(), &msg))
+	if msg.Type != lsp.MessageTypeError || !strings.Contains(msg.Message, "is not inside the project") {
+		t.Fatalf("expected error for directory outside the project but got %+v", msg)
+	}
+}
+
+// fileDiagnostics returns the last diagnostics published for the file.
+func fileDiagnostics(t *testing.T, f lstest.Fixture, path string) []lsp.Diagnostic {
+	t.Helper()
+
+	fname := filepath.Join(f.Sandbox.RootDir(), path)
+	var diags []lsp.Diagnostic
+	for {
+		select {
+		case r := <-f.Editor.Requests:
+			if r.Method() != lsp.MethodTextDocumentPublishDiagnostics {
+				continue
+			}
+			var params lsp.PublishDiagnosticsParams
+			assert.NoError(t, json.Unmarshal(r.Params(), &params))
+			if params.URI.Filename() == fname {
+				diags = params.Diagnostics
+			}
+		case <-time.After(100 * time.Millisecond):
+			return diags
+		}
+	}
+}
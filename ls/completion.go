@@ -0,0 +1,498 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package tmls
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/rs/zerolog"
+	"github.com/terramate-io/terramate/config"
+	"github.com/terramate-io/terramate/globals"
+	"github.com/terramate-io/terramate/hcl/eval"
+	"github.com/terramate-io/terramate/stdlib"
+	"github.com/zclconf/go-cty/cty"
+	"go.lsp.dev/jsonrpc2"
+	lsp "go.lsp.dev/protocol"
+)
+
+// blockSchema describes the attributes and sub blocks of a Terramate block
+// offered as completion. A nil schema denotes a block with free-form content.
+type blockSchema struct {
+	attrs  []string
+	blocks map[string]*blockSchema
+}
+
+var (
+	letsSchema = &blockSchema{
+		blocks: map[string]*blockSchema{
+			"map": nil,
+		},
+	}
+
+	assertSchema = &blockSchema{
+		attrs: []string{"assertion", "message", "warning"},
+	}
+
+	// topLevelSchema is the schema of the Terramate configuration files.
+	topLevelSchema = &blockSchema{
+		blocks: map[string]*blockSchema{
+			"terramate": {
+				attrs: []string{"required_version", "required_version_allow_prereleases"},
+				blocks: map[string]*blockSchema{
+					"config": {
+						blocks: map[string]*blockSchema{
+							"git": {
+								attrs: []string{
+									"default_branch",
+									"default_branch_base_ref",
+									"default_remote",
+									"check_untracked",
+									"check_uncommitted",
+									"check_remote",
+								},
+							},
+							"run": {
+								attrs: []string{"check_gen_code"},
+								blocks: map[string]*blockSchema{
+									"env": nil,
+								},
+							},
+							"cloud": {
+								attrs: []string{"organization"},
+							},
+							"globals": {
+								attrs: []string{"lazy_evaluation", "cross_stack_references"},
+							},
+						},
+					},
+				},
+			},
+			"stack": {
+				attrs: []string{
+					"id", "name", "description", "tags",
+					"after", "before", "wants", "wanted_by", "watch",
+				},
+			},
+			"stack_defaults": {
+				attrs: []string{"description", "tags", "after", "before", "wants", "watch"},
+			},
+			"globals": {
+				blocks: map[string]*blockSchema{
+					"map": nil,
+					"data_file": {
+						attrs: []string{"format"},
+					},
+				},
+			},
+			"globals_schema": {},
+			"generate_hcl": {
+				attrs: []string{"condition", "context", "post_process", "validate"},
+				blocks: map[string]*blockSchema{
+					"lets":    letsSchema,
+					"assert":  assertSchema,
+					"content": nil,
+				},
+			},
+			"generate_file": {
+				attrs: []string{
+					"condition", "content", "context", "template",
+					"post_process", "validate",
+				},
+				blocks: map[string]*blockSchema{
+					"lets":   letsSchema,
+					"assert": assertSchema,
+				},
+			},
+			"assert": assertSchema,
+			"import": {
+				attrs: []string{"source", "condition"},
+			},
+			"function": {
+				attrs: []string{"params", "result"},
+			},
+			"vendor": {
+				attrs: []string{"dir"},
+				blocks: map[string]*blockSchema{
+					"manifest": {
+						blocks: map[string]*blockSchema{
+							"default": {
+								attrs: []string{"files"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+)
+
+var (
+	// stackRefRegex matches an unterminated string inside the list of a stack
+	// attribute referencing other stacks.
+	stackRefRegex = regexp.MustCompile(
+		`(?:after|before|wants|wanted_by)\s*=\s*\[(?:[^\]"]|"[^"]*")*"[^"]*$`)
+
+	// traversalRegex matches a (possibly partial) traversal being typed.
+	traversalRegex = regexp.MustCompile(`[A-Za-z_][\w-]*(?:\.[\w-]*)*$`)
+)
+
+func (s *Server) handleCompletion(
+	ctx context.Context,
+	reply jsonrpc2.Replier,
+	r jsonrpc2.Request,
+	log zerolog.Logger,
+) error {
+	var params lsp.CompletionParams
+	if err := json.Unmarshal(r.Params(), &params); err != nil {
+		log.Error().Err(err).Msg("failed to unmarshal params")
+		return jsonrpc2.ErrParse
+	}
+
+	fname := params.TextDocument.URI.Filename()
+	content, err := s.readFile(fname)
+	if err != nil {
+		log.Error().Err(err).Msg("reading file.")
+		return reply(ctx, nil, nil)
+	}
+
+	items, err := s.completions(fname, content, params.Position)
+	if err != nil {
+		log.Debug().Err(err).Msg("no completion items")
+		return reply(ctx, nil, nil)
+	}
+	return reply(ctx, items, nil)
+}
+
+// completions returns the completion items for the given position of the
+// file, depending on its context:
+//   - Inside the path list of after, before, wants and wanted_by: the stacks.
+//   - Inside a block body: the attributes and blocks of the Terramate schema.
+//   - Inside an expression: the keys of global and terramate objects, or the
+//     functions.
+func (s *Server) completions(fname string, content []byte, pos lsp.Position) ([]lsp.CompletionItem, error) {
+	prefix := content[:byteOffset(content, pos)]
+	dir := filepath.Dir(fname)
+	rootdir := s.projectRoot(dir)
+
+	if stackRefRegex.Match(prefix) {
+		return s.stackPathCompletions(rootdir, dir)
+	}
+
+	blocks, inExpr := completionContext(prefix)
+	if !inExpr {
+		return schemaCompletions(blocks), nil
+	}
+
+	parts := strings.Split(string(traversalRegex.Find(prefix)), ".")
+	if len(parts) == 1 {
+		return s.functionCompletions(rootdir, dir), nil
+	}
+
+	objPath := parts[1 : len(parts)-1]
+	switch parts[0] {
+	case "global":
+		return s.globalCompletions(rootdir, dir, objPath)
+	case "terramate":
+		return s.metadataCompletions(rootdir, dir, objPath)
+	}
+	return nil, nil
+}
+
+// completionContext returns the types of the blocks enclosing the end of the
+// content and if it ends inside an expression.
+func completionContext(content []byte) ([]string, bool) {
+	type frame struct {
+		block string
+		expr  bool
+	}
+
+	tokens, _ := hclsyntax.LexConfig(content, "", hcl.InitialPos)
+
+	var frames []frame
+	lineStart := 0
+	for i, tok := range tokens {
+		switch tok.Type {
+		case hclsyntax.TokenNewline:
+			lineStart = i + 1
+		case hclsyntax.TokenOBrace:
+			header := tokens[lineStart:i]
+			if isBlockHeader(header) {
+				frames = append(frames, frame{block: string(header[0].Bytes)})
+			} else {
+				frames = append(frames, frame{expr: true})
+			}
+			lineStart = i + 1
+		case hclsyntax.TokenOBrack, hclsyntax.TokenOParen,
+			hclsyntax.TokenTemplateInterp, hclsyntax.TokenTemplateControl:
+			frames = append(frames, frame{expr: true})
+		case hclsyntax.TokenCBrace, hclsyntax.TokenCBrack, hclsyntax.TokenCParen,
+			hclsyntax.TokenTemplateSeqEnd:
+			if len(frames) > 0 {
+				frames = frames[:len(frames)-1]
+			}
+		}
+	}
+
+	var blocks []string
+	for _, f := range frames {
+		if f.expr {
+			return nil, true
+		}
+		blocks = append(blocks, f.block)
+	}
+	for _, tok := range tokens[lineStart:] {
+		if tok.Type == hclsyntax.TokenEqual {
+			return nil, true
+		}
+	}
+	return blocks, false
+}
+
+// isBlockHeader tells if the tokens are the type and labels of a block.
+func isBlockHeader(tokens hclsyntax.Tokens) bool {
+	if len(tokens) == 0 || tokens[0].Type != hclsyntax.TokenIdent {
+		return false
+	}
+	for _, tok := range tokens[1:] {
+		switch tok.Type {
+		case hclsyntax.TokenIdent, hclsyntax.TokenOQuote,
+			hclsyntax.TokenQuotedLit, hclsyntax.TokenCQuote:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// schemaCompletions returns the attributes and blocks accepted inside the
+// given nested blocks.
+func schemaCompletions(blocks []string) []lsp.CompletionItem {
+	schema := topLevelSchema
+	for _, block := range blocks {
+		if schema == nil {
+			return nil
+		}
+		schema = schema.blocks[block]
+	}
+	if schema == nil {
+		return nil
+	}
+
+	var items []lsp.CompletionItem
+	for _, attr := range schema.attrs {
+		items = append(items, lsp.CompletionItem{
+			Label:  attr,
+			Kind:   lsp.CompletionItemKindProperty,
+			Detail: "attribute",
+		})
+	}
+
+	var blockNames []string
+	for name := range schema.blocks {
+		blockNames = append(blockNames, name)
+	}
+	sort.Strings(blockNames)
+	for _, name := range blockNames {
+		items = append(items, lsp.CompletionItem{
+			Label:  name,
+			Kind:   lsp.CompletionItemKindModule,
+			Detail: "block",
+		})
+	}
+	return items
+}
+
+// functionCompletions returns the builtin functions and the user functions
+// visible in the dir.
+func (s *Server) functionCompletions(rootdir, dir string) []lsp.CompletionItem {
+	funcs := stdlib.Functions(dir)
+	if _, tree, err := s.lookupTree(rootdir, dir); err == nil {
+		for name, fn := range tree.Functions() {
+			funcs[name] = fn
+		}
+	}
+
+	var names []string
+	for name := range funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var items []lsp.CompletionItem
+	for _, name := range names {
+		fn := funcs[name]
+		item := lsp.CompletionItem{
+			Label:  name,
+			Kind:   lsp.CompletionItemKindFunction,
+			Detail: functionSignature(name, fn),
+		}
+		if desc := fn.Description(); desc != "" {
+			item.Documentation = desc
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// globalCompletions returns the keys of the global object at the given path,
+// evaluated for the enclosing stack of the dir, or for the dir itself if it
+// is not inside a stack.
+func (s *Server) globalCompletions(rootdir, dir string, objPath []string) ([]lsp.CompletionItem, error) {
+	root, tree, err := s.lookupTree(rootdir, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var report globals.EvalReport
+	if stackTree, ok := enclosingStack(tree); ok {
+		st, err := config.LoadStack(root, stackTree.Dir())
+		if err != nil {
+			return nil, err
+		}
+		report = globals.ForStack(root, st)
+	} else {
+		ctx := eval.NewContext(stdlib.Functions(tree.HostDir()))
+		ctx.SetNamespace("terramate", root.Runtime())
+		report = globals.ForDir(root, tree.Dir(), ctx)
+	}
+
+	// globals that failed evaluation are missing but the rest are still
+	// worth completing.
+	return objectKeyCompletions(
+		cty.ObjectVal(report.Globals.AsValueMap()),
+		objPath,
+		lsp.CompletionItemKindVariable,
+	), nil
+}
+
+// metadataCompletions returns the keys of the terramate metadata object at the
+// given path.
+func (s *Server) metadataCompletions(rootdir, dir string, objPath []string) ([]lsp.CompletionItem, error) {
+	root, tree, err := s.lookupTree(rootdir, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var st *config.Stack
+	if stackTree, ok := enclosingStack(tree); ok {
+		st, err = config.LoadStack(root, stackTree.Dir())
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// configuration outside stacks (eg.: generate blocks) is evaluated
+		// for the stacks in child directories, so the stack metadata keys
+		// are completed from a placeholder stack with all the fields set.
+		st = &config.Stack{
+			Dir: tree.Dir(),
+			ID:  "id",
+		}
+	}
+
+	runtime := root.Runtime()
+	runtime.Merge(st.RuntimeValues(root))
+	return objectKeyCompletions(
+		cty.ObjectVal(runtime),
+		objPath,
+		lsp.CompletionItemKindField,
+	), nil
+}
+
+// objectKeyCompletions returns the keys of the object (or map) found at the
+// given path of the value.
+func objectKeyCompletions(val cty.Value, objPath []string, kind lsp.CompletionItemKind) []lsp.CompletionItem {
+	for _, key := range objPath {
+		if !isKeyable(val) {
+			return nil
+		}
+		if val.Type().IsObjectType() {
+			if !val.Type().HasAttribute(key) {
+				return nil
+			}
+			val = val.GetAttr(key)
+			continue
+		}
+		keyVal := cty.StringVal(key)
+		if val.HasIndex(keyVal).False() {
+			return nil
+		}
+		val = val.Index(keyVal)
+	}
+	if !isKeyable(val) {
+		return nil
+	}
+
+	var items []lsp.CompletionItem
+	for it := val.ElementIterator(); it.Next(); {
+		key, elem := it.Element()
+		items = append(items, lsp.CompletionItem{
+			Label:  key.AsString(),
+			Kind:   kind,
+			Detail: elem.Type().FriendlyName(),
+		})
+	}
+	return items
+}
+
+func isKeyable(val cty.Value) bool {
+	return (val.Type().IsObjectType() || val.Type().IsMapType()) &&
+		val.IsKnown() && !val.IsNull()
+}
+
+// stackPathCompletions returns the paths of the stacks of the project, except
+// the stack enclosing the dir.
+func (s *Server) stackPathCompletions(rootdir, dir string) ([]lsp.CompletionItem, error) {
+	root, tree, err := s.lookupTree(rootdir, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []lsp.CompletionItem
+	stackTree, inStack := enclosingStack(tree)
+	for _, stackPath := range root.Stacks() {
+		if inStack && stackPath == stackTree.Dir() {
+			continue
+		}
+		items = append(items, lsp.CompletionItem{
+			Label:  stackPath.String(),
+			Kind:   lsp.CompletionItemKindFolder,
+			Detail: "stack",
+		})
+	}
+	return items, nil
+}
+
+// byteOffset returns the offset of the position in the content, where the
+// position character is counted in UTF-16 code units as defined by LSP.
+func byteOffset(content []byte, pos lsp.Position) int {
+	offset := 0
+	for line := uint32(0); line < pos.Line; line++ {
+		next := strings.IndexByte(string(content[offset:]), '\n')
+		if next == -1 {
+			return len(content)
+		}
+		offset += next + 1
+	}
+
+	for units := uint32(0); units < pos.Character && offset < len(content); {
+		r, size := utf8.DecodeRune(content[offset:])
+		if r == '\n' {
+			break
+		}
+		units++
+		if r >= 0x10000 {
+			units++
+		}
+		offset += size
+	}
+	return offset
+}
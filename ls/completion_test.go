@@ -0,0 +1,226 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package tmls_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/madlambda/spells/assert"
+	lstest "github.com/terramate-io/terramate/test/ls"
+	lsp "go.lsp.dev/protocol"
+)
+
+func TestCompletion(t *testing.T) {
+	t.Parallel()
+
+	type testcase struct {
+		name string
+		file string
+		// text is the unsaved content of the file, completed at its end.
+		text string
+		want []string
+		// partial indicates want is a subset of the completion labels.
+		partial bool
+	}
+
+	layout := []string{
+		`f:globals.tm:globals {
+  env = "prod"
+  obj = {
+    a = 1
+    b = "b"
+  }
+}
+globals "cloud" {
+  region = "us"
+}
+function "slug" {
+  params = ["name"]
+  result = tm_lower(name)
+}`,
+		"s:stacks/a",
+		"s:stacks/b",
+		"s:stacks/b/c",
+	}
+
+	for _, tc := range []testcase{
+		{
+			name: "top level blocks",
+			file: "stacks/a/edit.tm",
+			text: "st",
+			want: []string{
+				"assert", "function", "generate_file", "generate_hcl",
+				"globals", "globals_schema", "import", "stack",
+				"stack_defaults", "terramate", "vendor",
+			},
+		},
+		{
+			name: "stack attributes",
+			file: "stacks/a/edit.tm",
+			text: "stack {\n  ",
+			want: []string{
+				"id", "name", "description", "tags",
+				"after", "before", "wants", "wanted_by", "watch",
+			},
+		},
+		{
+			name: "terramate config blocks",
+			file: "stacks/a/edit.tm",
+			text: "terramate {\n  config {\n    ",
+			want: []string{"cloud", "git", "globals", "run"},
+		},
+		{
+			name: "generate_hcl after closed blocks",
+			file: "stacks/a/edit.tm",
+			text: "stack {\n}\ngenerate_hcl \"a.tf\" {\n  lets {\n  }\n  ",
+			want: []string{
+				"condition", "context", "post_process", "validate",
+				"assert", "content", "lets",
+			},
+		},
+		{
+			name: "generate_hcl content is free-form",
+			file: "stacks/a/edit.tm",
+			text: "generate_hcl \"a.tf\" {\n  content {\n    ",
+		},
+		{
+			name: "global names",
+			file: "stacks/a/edit.tm",
+			text: "generate_hcl \"a.tf\" {\n  content {\n    a = global.",
+			want: []string{"cloud", "env", "obj"},
+		},
+		{
+			name: "global object keys",
+			file: "stacks/a/edit.tm",
+			text: "generate_hcl \"a.tf\" {\n  content {\n    a = global.obj.",
+			want: []string{"a", "b"},
+		},
+		{
+			name: "labeled global keys inside template",
+			file: "stacks/a/edit.tm",
+			text: "generate_hcl \"a.tf\" {\n  content {\n    a = \"${global.cloud.re",
+			want: []string{"region"},
+		},
+		{
+			name: "global names outside stacks",
+			file: "modules/edit.tm",
+			text: "generate_hcl \"a.tf\" {\n  content {\n    a = global.",
+			want: []string{"cloud", "env", "obj"},
+		},
+		{
+			name: "stack metadata keys",
+			file: "stacks/a/edit.tm",
+			text: "generate_hcl \"a.tf\" {\n  content {\n    a = terramate.stack.",
+			want: []string{"description", "name", "path", "tags"},
+		},
+		{
+			name: "stack metadata keys outside stacks",
+			file: "modules/edit.tm",
+			text: "generate_hcl \"a.tf\" {\n  content {\n    a = terramate.stack.",
+			want: []string{"description", "id", "name", "path", "tags"},
+		},
+		{
+			name: "functions",
+			file: "stacks/a/edit.tm",
+			text: "generate_hcl \"a.tf\" {\n  content {\n    a = tm_",
+			want: []string{
+				"tm_upper(str string)",
+				"tm_slug(name dynamic)",
+			},
+			partial: true,
+		},
+		{
+			name: "stack paths in after",
+			file: "stacks/a/edit.tm",
+			text: "stack {\n  after = [\"/stacks/b\", \"",
+			want: []string{"/stacks/b", "/stacks/b/c"},
+		},
+		{
+			name: "stack paths in multiline before",
+			file: "stacks/b/edit.tm",
+			text: "stack {\n  before = [\n    \"/stacks/a\",\n    \"/sta",
+			want: []string{"/stacks/a", "/stacks/b/c"},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			f := lstest.Setup(t, append(layout, "d:modules")...)
+			f.Editor.CheckInitialize(f.Sandbox.RootDir())
+			f.Editor.Change(tc.file, tc.text)
+			waitDiagnostics(t, f, tc.file)
+
+			lines := strings.Split(tc.text, "\n")
+			line := uint32(len(lines) - 1)
+			char := uint32(len(lines[line]))
+
+			var got []string
+			for _, item := range f.Editor.Completion(tc.file, line, char) {
+				if item.Kind == lsp.CompletionItemKindFunction {
+					got = append(got, item.Detail)
+				} else {
+					got = append(got, item.Label)
+				}
+			}
+
+			if !tc.partial {
+				if diff := cmp.Diff(tc.want, got); diff != "" {
+					t.Fatalf("unexpected completion, want(-) got(+):\n%s", diff)
+				}
+				return
+			}
+
+			gotSet := map[string]bool{}
+			for _, label := range got {
+				gotSet[label] = true
+			}
+			for _, want := range tc.want {
+				if !gotSet[want] {
+					t.Errorf("completion %q not found in %v", want, got)
+				}
+			}
+		})
+	}
+}
+
+// waitDiagnostics waits for the diagnostics published after the document is
+// opened or changed, which are published for the document and each Terramate
+// file of its directory. The other requests sent to the editor are discarded.
+// The test fails if the diagnostics are not published in time.
+func waitDiagnostics(t *testing.T, f lstest.Fixture, document string) {
+	t.Helper()
+
+	abspath := filepath.Join(f.Sandbox.RootDir(), document)
+	pending := map[string]bool{abspath: true}
+	entries, err := os.ReadDir(filepath.Dir(abspath))
+	assert.NoError(t, err)
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && (strings.HasSuffix(name, ".tm") || strings.HasSuffix(name, ".tm.hcl")) {
+			pending[filepath.Join(filepath.Dir(abspath), name)] = true
+		}
+	}
+
+	deadline := time.After(5 * time.Second)
+	for len(pending) > 0 {
+		select {
+		case r := <-f.Editor.Requests:
+			if r.Method() != lsp.MethodTextDocumentPublishDiagnostics {
+				continue
+			}
+			var params lsp.PublishDiagnosticsParams
+			assert.NoError(t, json.Unmarshal(r.Params(), &params))
+			delete(pending, params.URI.Filename())
+		case <-deadline:
+			t.Fatalf("diagnostics not published for %v", pending)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package tmls
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/rs/zerolog"
+	"github.com/terramate-io/terramate/config"
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/globals"
+	"github.com/terramate-io/terramate/project"
+	"github.com/zclconf/go-cty/cty"
+	"go.lsp.dev/jsonrpc2"
+	lsp "go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
+)
+
+// stackRefAttrs are the stack attributes which reference other stacks.
+var stackRefAttrs = map[string]struct{}{
+	"after":     {},
+	"before":    {},
+	"wants":     {},
+	"wanted_by": {},
+}
+
+func (s *Server) handleDefinition(
+	ctx context.Context,
+	reply jsonrpc2.Replier,
+	r jsonrpc2.Request,
+	log zerolog.Logger,
+) error {
+	var params lsp.DefinitionParams
+	if err := json.Unmarshal(r.Params(), &params); err != nil {
+		log.Error().Err(err).Msg("failed to unmarshal params")
+		return jsonrpc2.ErrParse
+	}
+
+	fname := params.TextDocument.URI.Filename()
+	content, err := s.readFile(fname)
+	if err != nil {
+		log.Error().Err(err).Msg("reading file.")
+		return reply(ctx, nil, nil)
+	}
+
+	locations, err := s.findDefinitions(fname, content, params.Position)
+	if err != nil {
+		log.Debug().Err(err).Msg("definition not found")
+		return reply(ctx, nil, nil)
+	}
+	return reply(ctx, locations, nil)
+}
+
+// findDefinitions returns the locations of the definitions referenced at the
+// given position of the file. It resolves:
+//   - global.<path> to the closest definition in the hierarchy of the file dir.
+//   - import.source to the imported files.
+//   - stack.after, stack.before, stack.wants and stack.wanted_by paths to the
+//     stack block of the referenced stacks.
+func (s *Server) findDefinitions(fname string, content []byte, pos lsp.Position) ([]lsp.Location, error) {
+	body, err := parseBody(fname, content)
+	if err != nil {
+		return nil, err
+	}
+	hclpos := hclPos(pos)
+
+	dir := filepath.Dir(fname)
+	rootdir := s.projectRoot(dir)
+
+	for _, block := range body.Blocks {
+		switch block.Type {
+		case "import":
+			src, ok := block.Body.Attributes["source"]
+			if ok && rangeContains(src.Expr.Range(), hclpos) {
+				return importDefinitions(rootdir, dir, src.Expr)
+			}
+		case "stack":
+			for name, attr := range block.Body.Attributes {
+				if _, ok := stackRefAttrs[name]; !ok || !rangeContains(attr.Expr.Range(), hclpos) {
+					continue
+				}
+				return s.stackDefinitions(rootdir, dir, attr.Expr, hclpos)
+			}
+		}
+	}
+
+	var traversal hcl.Traversal
+	_ = hclsyntax.VisitAll(body, func(node hclsyntax.Node) hcl.Diagnostics {
+		expr, ok := node.(*hclsyntax.ScopeTraversalExpr)
+		if ok && expr.Traversal.RootName() == "global" && rangeContains(expr.Range(), hclpos) {
+			traversal = expr.Traversal
+		}
+		return nil
+	})
+	if traversal == nil {
+		return nil, errors.E("no definition at %s:%d:%d", fname, hclpos.Line, hclpos.Column)
+	}
+	return s.globalDefinitions(rootdir, dir, traversal, hclpos)
+}
+
+// globalDefinitions returns the location of the closest definition of the
+// global referenced by the traversal, as seen from the given dir. The global
+// path is cut at the traversal segment at the given position, so the
+// definition of a parent object can be reached from its child references.
+func (s *Server) globalDefinitions(rootdir, dir string, traversal hcl.Traversal, pos hcl.Pos) ([]lsp.Location, error) {
+	globalPath := globals.TraversalPath(cutTraversal(traversal, pos))
+	if len(globalPath) == 0 {
+		return nil, errors.E("global path not found in the traversal")
+	}
+
+	_, tree, err := s.lookupTree(rootdir, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	exprs, err := globals.LoadExprs(tree)
+	if err != nil {
+		return nil, err
+	}
+
+	expr, ok := exprs.Lookup(globalPath)
+	if !ok {
+		return nil, errors.E("global.%s is not defined", strings.Join(globalPath, "."))
+	}
+	return []lsp.Location{
+		{
+			URI:   lsp.URI(uri.File(expr.Origin.HostPath())),
+			Range: lspRange(expr.Origin.ToHCLRange()),
+		},
+	}, nil
+}
+
+// importDefinitions returns the locations of the files imported by the given
+// import.source expression. Remote sources are not resolved.
+func importDefinitions(rootdir, dir string, srcExpr hcl.Expression) ([]lsp.Location, error) {
+	srcVal, diags := srcExpr.Value(nil)
+	if diags.HasErrors() {
+		return nil, errors.E(diags, "evaluating import.source")
+	}
+	if srcVal.Type() != cty.String {
+		return nil, errors.E("import.source must be a string")
+	}
+
+	src := srcVal.AsString()
+	if path.IsAbs(src) {
+		src = filepath.Join(rootdir, filepath.FromSlash(src))
+	} else {
+		src = filepath.Join(dir, filepath.FromSlash(src))
+	}
+
+	matches, err := filepath.Glob(src)
+	if err != nil {
+		return nil, errors.E(err, "invalid import.source")
+	}
+
+	var locations []lsp.Location
+	for _, file := range matches {
+		locations = append(locations, lsp.Location{
+			URI: lsp.URI(uri.File(file)),
+		})
+	}
+	return locations, nil
+}
+
+// stackDefinitions returns the location of the stack blocks referenced by the
+// path at the given position of the stack attribute expression.
+func (s *Server) stackDefinitions(rootdir, dir string, expr hcl.Expression, pos hcl.Pos) ([]lsp.Location, error) {
+	list, ok := expr.(*hclsyntax.TupleConsExpr)
+	if !ok {
+		return nil, errors.E("stack attribute is not a list")
+	}
+
+	var stackPath string
+	for _, elem := range list.Exprs {
+		if !rangeContains(elem.Range(), pos) {
+			continue
+		}
+		val, diags := elem.Value(nil)
+		if diags.HasErrors() || val.Type() != cty.String {
+			return nil, errors.E("stack path is not a literal string")
+		}
+		stackPath = val.AsString()
+	}
+	if stackPath == "" || strings.HasPrefix(stackPath, "tag:") {
+		return nil, errors.E("no stack path at the position")
+	}
+
+	root, tree, err := s.lookupTree(rootdir, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var locations []lsp.Location
+	for _, stackTree := range root.StacksByPaths(tree.Dir(), stackPath) {
+		stackRange := stackTree.Node.Stack.Range
+		locations = append(locations, lsp.Location{
+			URI:   lsp.URI(uri.File(stackRange.HostPath())),
+			Range: lspRange(stackRange.ToHCLRange()),
+		})
+	}
+	return locations, nil
+}
+
+// lookupTree loads the project configuration and returns it together with
+// the tree of the dir.
+func (s *Server) lookupTree(rootdir, dir string) (*config.Root, *config.Tree, error) {
+	root, err := s.loadRoot(rootdir)
+	if err != nil {
+		return nil, nil, err
+	}
+	tree, ok := root.Lookup(project.PrjAbsPath(rootdir, dir))
+	if !ok {
+		return nil, nil, errors.E("directory %s not found in the configuration", dir)
+	}
+	return root, tree, nil
+}
+
+// enclosingStack returns the stack tree containing the tree, if any.
+func enclosingStack(tree *config.Tree) (*config.Tree, bool) {
+	for parent := tree; parent != nil; parent = parent.Parent {
+		if parent.IsStack() {
+			return parent, true
+		}
+	}
+	return nil, false
+}
+
+// parseBody parses the file content, returning the partial body if the file
+// has syntax errors, so references can still be found in the valid parts.
+func parseBody(fname string, content []byte) (*hclsyntax.Body, error) {
+	file, _ := hclsyntax.ParseConfig(content, fname, hcl.InitialPos)
+	if file == nil {
+		return nil, errors.E("failed to parse file %s", fname)
+	}
+	return file.Body.(*hclsyntax.Body), nil
+}
+
+// cutTraversal returns the traversal up to the step at the given position.
+func cutTraversal(traversal hcl.Traversal, pos hcl.Pos) hcl.Traversal {
+	for i, step := range traversal[1:] {
+		if rangeContains(step.SourceRange(), pos) {
+			return traversal[:i+2]
+		}
+	}
+	return traversal
+}
+
+// hclPos converts the LSP position into a HCL position.
+func hclPos(pos lsp.Position) hcl.Pos {
+	return hcl.Pos{
+		Line:   int(pos.Line) + 1,
+		Column: int(pos.Character) + 1,
+	}
+}
+
+// rangeContains tells if the position is inside the range, including its end,
+// so a cursor placed right after a word still references it.
+func rangeContains(r hcl.Range, pos hcl.Pos) bool {
+	if pos.Line < r.Start.Line || pos.Line > r.End.Line {
+		return false
+	}
+	if pos.Line == r.Start.Line && pos.Column < r.Start.Column {
+		return false
+	}
+	if pos.Line == r.End.Line && pos.Column > r.End.Column {
+		return false
+	}
+	return true
+}
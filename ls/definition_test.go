@@ -0,0 +1,209 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package tmls_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	lstest "github.com/terramate-io/terramate/test/ls"
+	lsp "go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
+)
+
+func TestDefinition(t *testing.T) {
+	t.Parallel()
+
+	type want struct {
+		file  string
+		start lsp.Position
+		end   lsp.Position
+	}
+	type testcase struct {
+		name   string
+		layout []string
+		file   string
+		line   uint32
+		char   uint32
+		want   []want
+	}
+
+	const stackFile = `stack {
+  after = ["/stacks/other", "../other", "tag:prod"]
+}
+import {
+  source = "/modules/*.tm"
+}
+generate_hcl "main.tf" {
+  content {
+    env    = global.env
+    region = global.cloud.region
+    name   = "${global.name}-${global.undefined}"
+  }
+}
+`
+	const rootGlobals = `globals {
+  env  = "prod"
+  name = "root"
+}
+globals "cloud" {
+  region = "us"
+}
+`
+
+	layout := []string{
+		"f:globals.tm:" + rootGlobals,
+		"f:stacks/main/stack.tm:" + stackFile,
+		"f:stacks/other/stack.tm:stack {}",
+		"f:modules/a.tm:globals {}",
+		"f:modules/b.tm:globals {}",
+	}
+
+	for _, tc := range []testcase{
+		{
+			name:   "global defined in parent dir",
+			layout: layout,
+			file:   "stacks/main/stack.tm",
+			line:   8,
+			char:   19,
+			want: []want{
+				{
+					file:  "globals.tm",
+					start: lsp.Position{Line: 1, Character: 2},
+					end:   lsp.Position{Line: 1, Character: 15},
+				},
+			},
+		},
+		{
+			name: "global redefined in closer dir",
+			layout: append(layout,
+				`f:stacks/globals.tm:globals {
+  env = "dev"
+}`),
+			file: "stacks/main/stack.tm",
+			line: 8,
+			char: 19,
+			want: []want{
+				{
+					file:  "stacks/globals.tm",
+					start: lsp.Position{Line: 1, Character: 2},
+					end:   lsp.Position{Line: 1, Character: 13},
+				},
+			},
+		},
+		{
+			name:   "global inside template",
+			layout: layout,
+			file:   "stacks/main/stack.tm",
+			line:   10,
+			char:   21,
+			want: []want{
+				{
+					file:  "globals.tm",
+					start: lsp.Position{Line: 2, Character: 2},
+					end:   lsp.Position{Line: 2, Character: 15},
+				},
+			},
+		},
+		{
+			name:   "labeled global attribute",
+			layout: layout,
+			file:   "stacks/main/stack.tm",
+			line:   9,
+			char:   26,
+			want: []want{
+				{
+					file:  "globals.tm",
+					start: lsp.Position{Line: 5, Character: 2},
+					end:   lsp.Position{Line: 5, Character: 15},
+				},
+			},
+		},
+		{
+			name:   "undefined global",
+			layout: layout,
+			file:   "stacks/main/stack.tm",
+			line:   10,
+			char:   36,
+		},
+		{
+			name:   "stack after project path",
+			layout: layout,
+			file:   "stacks/main/stack.tm",
+			line:   1,
+			char:   15,
+			want: []want{
+				{
+					file:  "stacks/other/stack.tm",
+					start: lsp.Position{Line: 0, Character: 0},
+					end:   lsp.Position{Line: 0, Character: 8},
+				},
+			},
+		},
+		{
+			name:   "stack after relative path",
+			layout: layout,
+			file:   "stacks/main/stack.tm",
+			line:   1,
+			char:   33,
+			want: []want{
+				{
+					file:  "stacks/other/stack.tm",
+					start: lsp.Position{Line: 0, Character: 0},
+					end:   lsp.Position{Line: 0, Character: 8},
+				},
+			},
+		},
+		{
+			name:   "stack after tag filter",
+			layout: layout,
+			file:   "stacks/main/stack.tm",
+			line:   1,
+			char:   44,
+		},
+		{
+			name:   "import source",
+			layout: layout,
+			file:   "stacks/main/stack.tm",
+			line:   4,
+			char:   14,
+			want: []want{
+				{file: "modules/a.tm"},
+				{file: "modules/b.tm"},
+			},
+		},
+		{
+			name:   "no reference at position",
+			layout: layout,
+			file:   "stacks/main/stack.tm",
+			line:   6,
+			char:   2,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			f := lstest.Setup(t, tc.layout...)
+			f.Editor.CheckInitialize(f.Sandbox.RootDir())
+
+			var wantLocations []lsp.Location
+			for _, w := range tc.want {
+				wantLocations = append(wantLocations, lsp.Location{
+					URI: uri.File(filepath.Join(f.Sandbox.RootDir(), w.file)),
+					Range: lsp.Range{
+						Start: w.start,
+						End:   w.end,
+					},
+				})
+			}
+
+			got := f.Editor.Definition(tc.file, tc.line, tc.char)
+			if diff := cmp.Diff(wantLocations, got); diff != "" {
+				t.Fatalf("unexpected locations, want(-) got(+):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package tmls
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"unicode/utf16"
+
+	"github.com/rs/zerolog"
+	"github.com/terramate-io/terramate/hcl/fmt"
+	"go.lsp.dev/jsonrpc2"
+	lsp "go.lsp.dev/protocol"
+)
+
+func (s *Server) handleFormatting(
+	ctx context.Context,
+	reply jsonrpc2.Replier,
+	r jsonrpc2.Request,
+	log zerolog.Logger,
+) error {
+	var params lsp.DocumentFormattingParams
+	if err := json.Unmarshal(r.Params(), &params); err != nil {
+		log.Error().Err(err).Msg("failed to unmarshal params")
+		return jsonrpc2.ErrParse
+	}
+
+	fname := params.TextDocument.URI.Filename()
+	content, err := s.readFile(fname)
+	if err != nil {
+		log.Error().Err(err).Msg("reading file.")
+		return reply(ctx, nil, nil)
+	}
+
+	formatted, err := fmt.Format(string(content), fname)
+	if err != nil {
+		// the syntax errors are reported by the diagnostics.
+		log.Debug().Err(err).Msg("formatting file.")
+		return reply(ctx, nil, nil)
+	}
+
+	return reply(ctx, replaceContentEdits(content, []byte(formatted)), nil)
+}
+
+// replaceContentEdits returns the edits replacing the whole content with the
+// new content, if they are different.
+func replaceContentEdits(content, newContent []byte) []lsp.TextEdit {
+	if bytes.Equal(content, newContent) {
+		return []lsp.TextEdit{}
+	}
+	return []lsp.TextEdit{
+		{
+			Range: lsp.Range{
+				End: endPosition(content),
+			},
+			NewText: string(newContent),
+		},
+	}
+}
+
+// endPosition returns the position of the end of the content.
+func endPosition(content []byte) lsp.Position {
+	var pos lsp.Position
+	lineStart := 0
+	for i, b := range content {
+		if b == '\n' {
+			pos.Line++
+			lineStart = i + 1
+		}
+	}
+	pos.Character = uint32(len(utf16.Encode([]rune(string(content[lineStart:])))))
+	return pos
+}
@@ -0,0 +1,313 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package tmls
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/rs/zerolog"
+	"github.com/terramate-io/terramate/config"
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/globals"
+	"github.com/terramate-io/terramate/hcl/ast"
+	"github.com/terramate-io/terramate/lets"
+	"github.com/terramate-io/terramate/stack"
+	"github.com/terramate-io/terramate/stdlib"
+	"github.com/zclconf/go-cty/cty/function"
+	"go.lsp.dev/jsonrpc2"
+	lsp "go.lsp.dev/protocol"
+)
+
+// hoverMaxStacks is the maximum number of stacks the reference is evaluated
+// for on hover, as a directory can apply to all the stacks of the project.
+const hoverMaxStacks = 5
+
+// hoverNamespaces are the namespaces which references show their evaluated
+// value on hover.
+var hoverNamespaces = map[string]struct{}{
+	"global":    {},
+	"terramate": {},
+	"let":       {},
+}
+
+func (s *Server) handleHover(
+	ctx context.Context,
+	reply jsonrpc2.Replier,
+	r jsonrpc2.Request,
+	log zerolog.Logger,
+) error {
+	var params lsp.HoverParams
+	if err := json.Unmarshal(r.Params(), &params); err != nil {
+		log.Error().Err(err).Msg("failed to unmarshal params")
+		return jsonrpc2.ErrParse
+	}
+
+	fname := params.TextDocument.URI.Filename()
+	content, err := s.readFile(fname)
+	if err != nil {
+		log.Error().Err(err).Msg("reading file.")
+		return reply(ctx, nil, nil)
+	}
+
+	hover, err := s.hover(fname, content, params.Position)
+	if err != nil {
+		log.Debug().Err(err).Msg("no hover information")
+		return reply(ctx, nil, nil)
+	}
+	return reply(ctx, hover, nil)
+}
+
+// hover returns the hover information of the reference at the given position
+// of the file. For global, terramate and let references it shows their value
+// evaluated for each stack the file applies to, and for function calls it
+// shows the function signature and description.
+func (s *Server) hover(fname string, content []byte, pos lsp.Position) (*lsp.Hover, error) {
+	body, err := parseBody(fname, content)
+	if err != nil {
+		return nil, err
+	}
+	hclpos := hclPos(pos)
+
+	var (
+		traversal hcl.Traversal
+		call      *hclsyntax.FunctionCallExpr
+		hoverRng  hcl.Range
+	)
+	_ = hclsyntax.VisitAll(body, func(node hclsyntax.Node) hcl.Diagnostics {
+		switch node := node.(type) {
+		case *hclsyntax.ScopeTraversalExpr:
+			if _, ok := hoverNamespaces[node.Traversal.RootName()]; ok &&
+				rangeContains(node.Range(), hclpos) {
+				traversal = cutTraversal(node.Traversal, hclpos)
+				hoverRng = traversal.SourceRange()
+				call = nil
+			}
+		case *hclsyntax.FunctionCallExpr:
+			if rangeContains(node.NameRange, hclpos) {
+				call = node
+				hoverRng = node.NameRange
+				traversal = nil
+			}
+		}
+		return nil
+	})
+
+	dir := filepath.Dir(fname)
+	rootdir := s.projectRoot(dir)
+
+	var markdown string
+	switch {
+	case call != nil:
+		markdown, err = s.functionHover(rootdir, dir, call.Name)
+	case traversal != nil:
+		markdown, err = s.traversalHover(rootdir, dir, fname, traversal, hclpos)
+	default:
+		return nil, errors.E("no reference at %s:%d:%d", fname, hclpos.Line, hclpos.Column)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rng := lspRange(hoverRng)
+	return &lsp.Hover{
+		Contents: lsp.MarkupContent{
+			Kind:  lsp.Markdown,
+			Value: markdown,
+		},
+		Range: &rng,
+	}, nil
+}
+
+// functionHover returns the signature and description of the function, which
+// can be a builtin or a user function visible in the dir.
+func (s *Server) functionHover(rootdir, dir, name string) (string, error) {
+	funcs := stdlib.Functions(dir)
+	if _, tree, err := s.lookupTree(rootdir, dir); err == nil {
+		for userName, fn := range tree.Functions() {
+			funcs[userName] = fn
+		}
+	}
+
+	fn, ok := funcs[name]
+	if !ok {
+		return "", errors.E("unknown function %s", name)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "```hcl\n%s\n```\n", functionSignature(name, fn))
+	if desc := fn.Description(); desc != "" {
+		fmt.Fprintf(&b, "\n%s\n", desc)
+	}
+	return b.String(), nil
+}
+
+// functionSignature returns the signature of the function with its parameter
+// names and types.
+func functionSignature(name string, fn function.Function) string {
+	var params []string
+	for _, param := range fn.Params() {
+		params = append(params, paramSignature(param))
+	}
+	if varParam := fn.VarParam(); varParam != nil {
+		params = append(params, "..."+paramSignature(*varParam))
+	}
+	return fmt.Sprintf("%s(%s)", name, strings.Join(params, ", "))
+}
+
+func paramSignature(param function.Parameter) string {
+	return param.Name + " " + param.Type.FriendlyName()
+}
+
+// traversalHover returns the value of the traversal evaluated for each stack
+// the dir applies to, together with the file defining it. Only the first
+// [hoverMaxStacks] stacks are evaluated, the others are just counted.
+func (s *Server) traversalHover(rootdir, dir, fname string, traversal hcl.Traversal, pos hcl.Pos) (string, error) {
+	root, tree, err := s.lookupTree(rootdir, dir)
+	if err != nil {
+		return "", err
+	}
+
+	var letsBlock *ast.MergedBlock
+	if traversal.RootName() == "let" {
+		var ok bool
+		letsBlock, ok = enclosingLets(tree, fname, pos)
+		if !ok {
+			return "", errors.E("let reference outside of a generate block")
+		}
+	}
+
+	refName := traversal.RootName()
+	if path := globals.TraversalPath(traversal); len(path) > 0 {
+		refName += "." + strings.Join(path, ".")
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "**%s**\n", refName)
+
+	stacks := hoverStacks(tree)
+	if len(stacks) == 0 {
+		fmt.Fprintf(&b, "\nno stacks in `%s`\n", tree.Dir())
+		return b.String(), nil
+	}
+
+	more := 0
+	if len(stacks) > hoverMaxStacks {
+		more = len(stacks) - hoverMaxStacks
+		stacks = stacks[:hoverMaxStacks]
+	}
+
+	for _, stackTree := range stacks {
+		fmt.Fprintf(&b, "\n`%s`", stackTree.Dir())
+		val, origin, err := evalForStack(root, stackTree, traversal, letsBlock)
+		if origin != "" {
+			fmt.Fprintf(&b, " defined in `%s`", origin)
+		}
+		if err != nil {
+			fmt.Fprintf(&b, "\n```\n%s\n```\n", err)
+			continue
+		}
+		fmt.Fprintf(&b, "\n```hcl\n%s\n```\n", val)
+	}
+	if more > 0 {
+		fmt.Fprintf(&b, "\n%d more stacks\n", more)
+	}
+	return b.String(), nil
+}
+
+// evalForStack evaluates the traversal in the context of the stack, returning
+// the formatted value and the origin of its definition, if known.
+func evalForStack(
+	root *config.Root,
+	stackTree *config.Tree,
+	traversal hcl.Traversal,
+	letsBlock *ast.MergedBlock,
+) (string, string, error) {
+	st, err := config.LoadStack(root, stackTree.Dir())
+	if err != nil {
+		return "", "", err
+	}
+
+	var origin string
+	switch traversal.RootName() {
+	case "global":
+		exprs, err := globals.LoadExprs(stackTree)
+		if err != nil {
+			return "", "", err
+		}
+		if expr, ok := exprs.Lookup(globals.TraversalPath(traversal)); ok {
+			origin = expr.Origin.String()
+		}
+	case "let":
+		if path := globals.TraversalPath(traversal); letsBlock != nil && len(path) > 0 {
+			if attr, ok := letsBlock.Attributes[path[0]]; ok {
+				origin = attr.Range.String()
+			}
+		}
+	}
+
+	expr := &hclsyntax.ScopeTraversalExpr{
+		Traversal: traversal,
+		SrcRange:  traversal.SourceRange(),
+	}
+
+	// the lets can reference any global, otherwise only the globals
+	// referenced by the traversal are evaluated.
+	var report globals.EvalReport
+	if letsBlock != nil {
+		report = globals.ForStack(root, st)
+	} else {
+		report = globals.ForStackRefs(root, st, []hcl.Expression{expr})
+	}
+	if err := report.AsError(); err != nil {
+		return "", origin, err
+	}
+
+	evalctx := stack.NewEvalCtx(root, st, report.Globals)
+	if letsBlock != nil {
+		if err := lets.Load(letsBlock, evalctx.Context); err != nil {
+			return "", origin, err
+		}
+	}
+
+	val, err := evalctx.Eval(expr)
+	if err != nil {
+		return "", origin, err
+	}
+	return string(ast.TokensForValue(val).Bytes()), origin, nil
+}
+
+// hoverStacks returns the stacks which the configuration of the tree applies
+// to, which is the enclosing stack of the tree or, if there's none, all the
+// stacks inside the tree.
+func hoverStacks(tree *config.Tree) config.List[*config.Tree] {
+	if stackTree, ok := enclosingStack(tree); ok {
+		return config.List[*config.Tree]{stackTree}
+	}
+	stacks := tree.Stacks()
+	sort.Sort(stacks)
+	return stacks
+}
+
+// enclosingLets returns the lets of the generate block of the tree containing
+// the position of the file.
+func enclosingLets(tree *config.Tree, fname string, pos hcl.Pos) (*ast.MergedBlock, bool) {
+	for _, block := range tree.Node.Generate.HCLs {
+		if block.Range.HostPath() == fname && rangeContains(block.Range.ToHCLRange(), pos) {
+			return block.Lets, true
+		}
+	}
+	for _, block := range tree.Node.Generate.Files {
+		if block.Range.HostPath() == fname && rangeContains(block.Range.ToHCLRange(), pos) {
+			return block.Lets, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,258 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package tmls_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	lstest "github.com/terramate-io/terramate/test/ls"
+)
+
+func TestHover(t *testing.T) {
+	t.Parallel()
+
+	type testcase struct {
+		name   string
+		layout []string
+		file   string
+		line   uint32
+		char   uint32
+		want   string
+	}
+
+	const genFile = `generate_hcl "main.tf" {
+  lets {
+    greeting = "hello ${global.env}"
+  }
+  content {
+    env      = global.env
+    name     = terramate.stack.name
+    greeting = let.greeting
+    upper    = tm_upper(global.env)
+  }
+}
+`
+
+	layout := []string{
+		`f:globals.tm:globals {
+  env = "prod"
+}`,
+		"f:stacks/gen.tm:" + genFile,
+		"s:stacks/a",
+		`f:stacks/b/globals.tm:globals {
+  env = "dev"
+}`,
+		"s:stacks/b",
+		"d:modules",
+	}
+
+	for _, tc := range []testcase{
+		{
+			name:   "global for all stacks of a non-stack dir",
+			layout: layout,
+			file:   "stacks/gen.tm",
+			line:   5,
+			char:   22,
+			want: "**global.env**\n" +
+				"\n`/stacks/a` defined in `/globals.tm:2,3-15`\n```hcl\n\"prod\"\n```\n" +
+				"\n`/stacks/b` defined in `/stacks/b/globals.tm:2,3-14`\n```hcl\n\"dev\"\n```\n",
+		},
+		{
+			name:   "stack metadata",
+			layout: layout,
+			file:   "stacks/gen.tm",
+			line:   6,
+			char:   32,
+			want: "**terramate.stack.name**\n" +
+				"\n`/stacks/a`\n```hcl\n\"a\"\n```\n" +
+				"\n`/stacks/b`\n```hcl\n\"b\"\n```\n",
+		},
+		{
+			name:   "metadata object",
+			layout: layout,
+			file:   "stacks/gen.tm",
+			line:   6,
+			char:   30,
+			want: "**terramate.stack**\n" +
+				"\n`/stacks/a`\n```hcl\n" +
+				`{
+  description = ""
+  name        = "a"
+  path = {
+    absolute = "/stacks/a"
+    basename = "a"
+    relative = "stacks/a"
+    to_root  = "../.."
+  }
+  tags = []
+}` + "\n```\n" +
+				"\n`/stacks/b`\n```hcl\n" +
+				`{
+  description = ""
+  name        = "b"
+  path = {
+    absolute = "/stacks/b"
+    basename = "b"
+    relative = "stacks/b"
+    to_root  = "../.."
+  }
+  tags = []
+}` + "\n```\n",
+		},
+		{
+			name:   "let",
+			layout: layout,
+			file:   "stacks/gen.tm",
+			line:   7,
+			char:   21,
+			want: "**let.greeting**\n" +
+				"\n`/stacks/a` defined in `/stacks/gen.tm:3,5-37`\n```hcl\n\"hello prod\"\n```\n" +
+				"\n`/stacks/b` defined in `/stacks/gen.tm:3,5-37`\n```hcl\n\"hello dev\"\n```\n",
+		},
+		{
+			name: "global in stack",
+			layout: append(layout,
+				`f:stacks/a/stack.tm:generate_hcl "a.tf" {
+  content {
+    env = global.env
+  }
+}`),
+			file: "stacks/a/stack.tm",
+			line: 2,
+			char: 18,
+			want: "**global.env**\n" +
+				"\n`/stacks/a` defined in `/globals.tm:2,3-15`\n```hcl\n\"prod\"\n```\n",
+		},
+		{
+			name: "no stacks in dir",
+			layout: append(layout,
+				`f:modules/gen.tm:generate_hcl "a.tf" {
+  content {
+    env = global.env
+  }
+}`),
+			file: "modules/gen.tm",
+			line: 2,
+			char: 18,
+			want: "**global.env**\n\nno stacks in `/modules`\n",
+		},
+		{
+			name: "global for more stacks than evaluated",
+			layout: []string{
+				`f:globals.tm:globals {
+  env = "prod"
+}`,
+				`f:many/gen.tm:generate_hcl "a.tf" {
+  content {
+    env = global.env
+  }
+}`,
+				"s:many/s1", "s:many/s2", "s:many/s3", "s:many/s4",
+				"s:many/s5", "s:many/s6", "s:many/s7",
+			},
+			file: "many/gen.tm",
+			line: 2,
+			char: 18,
+			want: "**global.env**\n" +
+				"\n`/many/s1` defined in `/globals.tm:2,3-15`\n```hcl\n\"prod\"\n```\n" +
+				"\n`/many/s2` defined in `/globals.tm:2,3-15`\n```hcl\n\"prod\"\n```\n" +
+				"\n`/many/s3` defined in `/globals.tm:2,3-15`\n```hcl\n\"prod\"\n```\n" +
+				"\n`/many/s4` defined in `/globals.tm:2,3-15`\n```hcl\n\"prod\"\n```\n" +
+				"\n`/many/s5` defined in `/globals.tm:2,3-15`\n```hcl\n\"prod\"\n```\n" +
+				"\n2 more stacks\n",
+		},
+		{
+			name:   "function",
+			layout: layout,
+			file:   "stacks/gen.tm",
+			line:   8,
+			char:   17,
+			want: "```hcl\ntm_upper(str string)\n```\n" +
+				"\nReturns the given string with all Unicode letters translated to their uppercase equivalents.\n",
+		},
+		{
+			name: "user function",
+			layout: append(layout,
+				`f:funcs.tm:function "slug" {
+  params = ["name"]
+  result = tm_lower(name)
+}`,
+				`f:stacks/a/stack.tm:generate_hcl "a.tf" {
+  content {
+    slug = tm_slug(global.env)
+  }
+}`),
+			file: "stacks/a/stack.tm",
+			line: 2,
+			char: 12,
+			want: "```hcl\ntm_slug(name dynamic)\n```\n",
+		},
+		{
+			name:   "no reference",
+			layout: layout,
+			file:   "stacks/gen.tm",
+			line:   0,
+			char:   2,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			f := lstest.Setup(t, tc.layout...)
+			f.Editor.CheckInitialize(f.Sandbox.RootDir())
+
+			got := f.Editor.Hover(tc.file, tc.line, tc.char)
+			if tc.want == "" {
+				if got != nil {
+					t.Fatalf("unexpected hover: %+v", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("expected hover but got none")
+			}
+			if diff := cmp.Diff(tc.want, got.Contents.Value); diff != "" {
+				t.Fatalf("unexpected hover contents, want(-) got(+):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHoverReloadsConfigWhenSaved(t *testing.T) {
+	t.Parallel()
+
+	f := lstest.Setup(t,
+		"f:globals.tm:globals {\n  env = \"prod\"\n}\n",
+		"s:stack",
+		"f:stack/gen.tm:generate_hcl \"main.tf\" {\n  content {\n    env = global.env\n  }\n}\n",
+	)
+	f.Editor.CheckInitialize(f.Sandbox.RootDir())
+
+	hover := func(want string) {
+		t.Helper()
+		got := f.Editor.Hover("stack/gen.tm", 2, 18)
+		if got == nil {
+			t.Fatal("expected hover but got none")
+		}
+		want = fmt.Sprintf("**global.env**\n"+
+			"\n`/stack` defined in `/globals.tm:2,3-%d`\n```hcl\n%q\n```\n",
+			len(want)+11, want)
+		if diff := cmp.Diff(want, got.Contents.Value); diff != "" {
+			t.Fatalf("unexpected hover contents, want(-) got(+):\n%s", diff)
+		}
+	}
+
+	hover("prod")
+
+	// the configuration is cached until a document changes or is saved.
+	f.Sandbox.RootEntry().CreateFile("globals.tm", "globals {\n  env = \"dev\"\n}\n")
+	hover("prod")
+
+	f.Editor.Save("globals.tm")
+	collectDiagnostics(t, f)
+	hover("dev")
+}
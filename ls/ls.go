@@ -7,16 +7,18 @@ package tmls
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 
+	hhcl "github.com/hashicorp/hcl/v2"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/terramate-io/terramate/config"
 	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/globals"
 	"github.com/terramate-io/terramate/hcl"
 	"go.lsp.dev/jsonrpc2"
 	lsp "go.lsp.dev/protocol"
@@ -29,6 +31,26 @@ type Server struct {
 	workspace string
 	handlers  handlers
 
+	// documents are the last known contents of the documents opened in the
+	// editor, which can be unsaved.
+	documents map[string]string
+
+	// mu guards the project analysis state below, which is updated
+	// asynchronously.
+	mu sync.Mutex
+	// projectDiags are the diagnostics of the last project analysis.
+	projectDiags       map[string][]lsp.Diagnostic
+	cancelProjectCheck context.CancelFunc
+
+	// rootsMu guards the cache of the project configurations below.
+	rootsMu sync.Mutex
+	// roots are the project configurations loaded from disk, by project root
+	// directory. They are dropped whenever a document changes or is saved.
+	roots map[string]*config.Root
+	// rootsVersion is incremented whenever the roots are dropped, so a
+	// configuration loaded concurrently is not cached.
+	rootsVersion int
+
 	log zerolog.Logger
 }
 
@@ -50,8 +72,10 @@ func NewServer(conn jsonrpc2.Conn) *Server {
 // ServerWithLogger creates a new language server with a custom logger.
 func ServerWithLogger(conn jsonrpc2.Conn, l zerolog.Logger) *Server {
 	s := &Server{
-		conn: conn,
-		log:  l,
+		conn:      conn,
+		log:       l,
+		documents: map[string]string{},
+		roots:     map[string]*config.Root{},
 	}
 	s.buildHandlers()
 	return s
@@ -59,12 +83,22 @@ func ServerWithLogger(conn jsonrpc2.Conn, l zerolog.Logger) *Server {
 
 func (s *Server) buildHandlers() {
 	s.handlers = map[string]handler{
-		lsp.MethodInitialize:             s.handleInitialize,
-		lsp.MethodInitialized:            s.handleInitialized,
-		lsp.MethodTextDocumentDidOpen:    s.handleDocumentOpen,
-		lsp.MethodTextDocumentDidChange:  s.handleDocumentChange,
-		lsp.MethodTextDocumentDidSave:    s.handleDocumentSaved,
-		lsp.MethodTextDocumentCompletion: s.handleCompletion,
+		lsp.MethodInitialize:                 s.handleInitialize,
+		lsp.MethodInitialized:                s.handleInitialized,
+		lsp.MethodTextDocumentDidOpen:        s.handleDocumentOpen,
+		lsp.MethodTextDocumentDidChange:      s.handleDocumentChange,
+		lsp.MethodTextDocumentDidSave:        s.handleDocumentSaved,
+		lsp.MethodTextDocumentDidClose:       s.handleDocumentClose,
+		lsp.MethodTextDocumentCompletion:     s.handleCompletion,
+		lsp.MethodTextDocumentDefinition:     s.handleDefinition,
+		lsp.MethodTextDocumentHover:          s.handleHover,
+		lsp.MethodTextDocumentFormatting:     s.handleFormatting,
+		lsp.MethodTextDocumentCodeAction:     s.handleCodeAction,
+		lsp.MethodTextDocumentRename:         s.handleRename,
+		lsp.MethodTextDocumentDocumentSymbol: s.handleDocumentSymbol,
+		lsp.MethodWorkspaceSymbol:            s.handleWorkspaceSymbol,
+		MethodGeneratePreview:                s.handleGeneratePreview,
+		lsp.MethodWorkspaceExecuteCommand:    s.handleExecuteCommand,
 	}
 }
 
@@ -114,14 +148,35 @@ func (s *Server) handleInitialize(
 			CompletionProvider: &lsp.CompletionOptions{},
 
 			// if we support `goto` definition.
-			DefinitionProvider: false,
+			DefinitionProvider: true,
 
 			// If we support `hover` info.
-			HoverProvider: false,
+			HoverProvider: true,
+
+			// if we support formatting the whole document.
+			DocumentFormattingProvider: true,
+
+			// if we support renaming globals.
+			RenameProvider: true,
+
+			// if we support listing the symbols of the document and
+			// searching the symbols of the whole project.
+			DocumentSymbolProvider:  true,
+			WorkspaceSymbolProvider: true,
+
+			CodeActionProvider: &lsp.CodeActionOptions{
+				CodeActionKinds: []lsp.CodeActionKind{
+					lsp.QuickFix,
+					lsp.Source,
+				},
+			},
+			ExecuteCommandProvider: &lsp.ExecuteCommandOptions{
+				Commands: []string{generateStackCommand},
+			},
 
 			TextDocumentSync: lsp.TextDocumentSyncOptions{
-				// Send all file content on every change (can be optimized later).
-				Change: lsp.TextDocumentSyncKindFull,
+				// Send only the changed ranges of the document.
+				Change: lsp.TextDocumentSyncKindIncremental,
 
 				// if we want to be notified about open/close of Terramate files.
 				OpenClose: true,
@@ -134,9 +189,9 @@ func (s *Server) handleInitialize(
 	}, nil)
 
 	if err != nil {
-		// WHY(i4k): in stdio mode it's impossible to have network issues.
-		// TODO(i4k): improve this for the networked server.
-		log.Fatal().Err(err).Msg("failed to reply")
+		// the client is gone, which only affects this connection.
+		log.Error().Err(err).Msg("failed to reply")
+		return err
 	}
 
 	log.Info().Msgf("client connected using workspace %q", s.workspace)
@@ -147,7 +202,8 @@ func (s *Server) handleInitialize(
 	})
 
 	if err != nil {
-		log.Fatal().Err(err).Msg("failed to notify client")
+		log.Error().Err(err).Msg("failed to notify client")
+		return err
 	}
 	return nil
 }
@@ -174,9 +230,9 @@ func (s *Server) handleDocumentOpen(
 	}
 
 	fname := params.TextDocument.URI.Filename()
-	content := params.TextDocument.Text
+	s.documents[fname] = params.TextDocument.Text
 
-	return s.checkAndReply(ctx, reply, fname, content)
+	return s.checkAndReply(ctx, reply, fname)
 }
 
 func (s *Server) handleDocumentChange(
@@ -185,22 +241,55 @@ func (s *Server) handleDocumentChange(
 	r jsonrpc2.Request,
 	log zerolog.Logger,
 ) error {
-	var params lsp.DidChangeTextDocumentParams
+	// WHY: the Range of lsp.TextDocumentContentChangeEvent is not a pointer,
+	// then it's not possible to distinguish a change of the whole document
+	// from a change at the start of the document.
+	type contentChange struct {
+		Range *lsp.Range `json:"range,omitempty"`
+		Text  string     `json:"text"`
+	}
+	type changeParams struct {
+		TextDocument   lsp.VersionedTextDocumentIdentifier `json:"textDocument"`
+		ContentChanges []contentChange                     `json:"contentChanges"`
+	}
+
+	var params changeParams
 	if err := json.Unmarshal(r.Params(), &params); err != nil {
 		log.Error().Err(err).Msg("failed to unmarshal params")
 		return err
 	}
 
-	if len(params.ContentChanges) != 1 {
-		err := fmt.Errorf("expected content changes = 1, got = %d", len(params.ContentChanges))
-		log.Error().Err(err).Send()
-		return err
+	fname := params.TextDocument.URI.Filename()
+	content, err := s.readFile(fname)
+	if err != nil {
+		// the document was not opened and doesn't exist on disk, then the
+		// changes are applied to an empty document.
+		log.Debug().Err(err).Msg("reading changed file.")
+		content = nil
 	}
 
-	content := params.ContentChanges[0].Text
-	fname := params.TextDocument.URI.Filename()
+	// the changes must be applied in order, as each change range refers to
+	// the document after the previous change.
+	for _, change := range params.ContentChanges {
+		if change.Range == nil {
+			content = []byte(change.Text)
+			continue
+		}
+		start := byteOffset(content, change.Range.Start)
+		end := byteOffset(content, change.Range.End)
+		if end < start {
+			end = start
+		}
+		newContent := make([]byte, 0, len(content)-(end-start)+len(change.Text))
+		newContent = append(newContent, content[:start]...)
+		newContent = append(newContent, change.Text...)
+		newContent = append(newContent, content[end:]...)
+		content = newContent
+	}
+	s.documents[fname] = string(content)
+	s.invalidateRoots()
 
-	return s.checkAndReply(ctx, reply, fname, content)
+	return s.checkAndReply(ctx, reply, fname)
 }
 
 func (s *Server) handleDocumentSaved(
@@ -215,20 +304,68 @@ func (s *Server) handleDocumentSaved(
 		return jsonrpc2.ErrParse
 	}
 
+	// the content of an opened document is the saved content, otherwise the
+	// content is read from disk.
 	fname := params.TextDocument.URI.Filename()
-	content, err := os.ReadFile(fname)
-	if err != nil {
-		log.Error().Err(err).Msg("reading saved file.")
-		return nil
+	s.invalidateRoots()
+
+	err := s.checkAndReply(ctx, reply, fname)
+	s.scheduleProjectCheck(s.projectRoot(filepath.Dir(fname)))
+	return err
+}
+
+func (s *Server) handleDocumentClose(
+	ctx context.Context,
+	reply jsonrpc2.Replier,
+	r jsonrpc2.Request,
+	log zerolog.Logger,
+) error {
+	var params lsp.DidCloseTextDocumentParams
+	if err := json.Unmarshal(r.Params(), &params); err != nil {
+		log.Error().Err(err).Msg("failed to unmarshal params")
+		return jsonrpc2.ErrParse
 	}
 
-	return s.checkAndReply(ctx, reply, fname, string(content))
+	fname := params.TextDocument.URI.Filename()
+	delete(s.documents, fname)
+
+	// the unsaved changes are discarded, so the diagnostics of the directory
+	// are checked again using the content on disk.
+	if _, err := os.Stat(fname); err != nil {
+		return reply(ctx, nil,
+			s.sendDiagnostics(ctx, params.TextDocument.URI, []lsp.Diagnostic{}),
+		)
+	}
+	return s.checkAndReply(ctx, reply, fname)
 }
 
 // sendErrorDiagnostics sends diagnostics for each provided file, the ones with
 // no reported error gets an empty list of diagnostics, so the editor can clean
-// up its problems panel for it.
+// up its problems panel for it. The diagnostics of the last project analysis
+// are sent together, so they are not cleared by the directory check.
 func (s *Server) sendErrorDiagnostics(ctx context.Context, files []string, err error) error {
+	diagsMap := errorDiagnostics(err)
+
+	s.mu.Lock()
+	for _, filename := range files {
+		diagsMap[filename] = mergeDiagnostics(diagsMap[filename], s.projectDiags[filename])
+	}
+	s.mu.Unlock()
+
+	for _, filename := range files {
+		diags := diagsMap[filename]
+		filePath := lsp.URI(uri.File(filepath.ToSlash(filename)))
+		if err := s.sendDiagnostics(ctx, filePath, diags); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// errorDiagnostics returns the diagnostics for the errors providing a file
+// range, grouped by file.
+func errorDiagnostics(err error) map[string][]lsp.Diagnostic {
 	errs := errors.L()
 	switch e := err.(type) {
 	case *errors.Error:
@@ -243,10 +380,6 @@ func (s *Server) sendErrorDiagnostics(ctx context.Context, files []string, err e
 	}
 
 	diagsMap := map[string][]lsp.Diagnostic{}
-	for _, filename := range files {
-		diagsMap[filename] = []lsp.Diagnostic{}
-	}
-
 	for _, err := range errs.Errors() {
 		e, ok := err.(*errors.Error)
 		if !ok || e.FileRange.Empty() {
@@ -256,67 +389,76 @@ func (s *Server) sendErrorDiagnostics(ctx context.Context, files []string, err e
 
 		log.Debug().Str("error", e.Detailed()).Msg("sending diagnostics")
 
-		filename := e.FileRange.Filename
-		fileRange := lsp.Range{}
-		fileRange.Start.Line = uint32(e.FileRange.Start.Line) - 1
-		fileRange.Start.Character = uint32(e.FileRange.Start.Column) - 1
-		fileRange.End.Line = uint32(e.FileRange.End.Line) - 1
-		fileRange.End.Character = uint32(e.FileRange.End.Column) - 1
-
-		diagsMap[filename] = append(diagsMap[filename], lsp.Diagnostic{
+		diag := lsp.Diagnostic{
 			Message:  e.Message(),
-			Range:    fileRange,
+			Range:    lspRange(e.FileRange),
 			Severity: lsp.DiagnosticSeverityError,
 			Source:   "terramate",
-		})
-	}
+		}
+		if code := errorCode(e); code != "" {
+			diag.Code = code
+		}
 
-	for _, filename := range files {
-		diags := diagsMap[filename]
-		filePath := lsp.URI(uri.File(filepath.ToSlash(filename)))
-		s.sendDiagnostics(ctx, filePath, diags)
+		filename := e.FileRange.Filename
+		diagsMap[filename] = mergeDiagnostics(diagsMap[filename], []lsp.Diagnostic{diag})
 	}
+	return diagsMap
+}
 
-	return nil
+// errorCode returns the most specific kind of the error, which is the kind of
+// the innermost wrapped error defining one. It's used as the diagnostic code,
+// so the quick fixes don't depend on the error messages.
+func errorCode(err *errors.Error) string {
+	var code errors.Kind
+	for e, ok := err, true; ok; e, ok = e.Err.(*errors.Error) {
+		if e.Kind != "" {
+			code = e.Kind
+		}
+	}
+	return string(code)
 }
 
-func (s *Server) handleCompletion(
-	ctx context.Context,
-	reply jsonrpc2.Replier,
-	r jsonrpc2.Request,
-	log zerolog.Logger,
-) error {
-	var params lsp.CompletionParams
-	if err := json.Unmarshal(r.Params(), &params); err != nil {
-		log.Error().Err(err).Msg("failed to unmarshal params")
-		return jsonrpc2.ErrParse
+// mergeDiagnostics appends the diagnostics of b not present in a. It never
+// returns nil, as the editor expects a list.
+func mergeDiagnostics(a, b []lsp.Diagnostic) []lsp.Diagnostic {
+	res := append([]lsp.Diagnostic{}, a...)
+	for _, diag := range b {
+		found := false
+		for _, other := range res {
+			if other.Range == diag.Range && other.Message == diag.Message {
+				found = true
+				break
+			}
+		}
+		if !found {
+			res = append(res, diag)
+		}
 	}
-	log.Debug().Str("params", string(r.Params()))
-	return reply(ctx, nil, nil)
+	return res
 }
 
-func (s *Server) sendDiagnostics(ctx context.Context, uri lsp.URI, diags []lsp.Diagnostic) {
+func (s *Server) sendDiagnostics(ctx context.Context, uri lsp.URI, diags []lsp.Diagnostic) error {
 	err := s.conn.Notify(ctx, lsp.MethodTextDocumentPublishDiagnostics, lsp.PublishDiagnosticsParams{
 		URI:         uri,
 		Diagnostics: diags,
 	})
 
 	if err != nil {
-		log.Fatal().Err(err).Msg("failed to send diagnostics to the client.")
+		log.Error().Err(err).Msg("failed to send diagnostics to the client.")
 	}
+	return err
 }
 
 func (s *Server) checkAndReply(
 	ctx context.Context,
 	reply jsonrpc2.Replier,
 	fname string,
-	content string,
 ) error {
 	files, err := listFiles(fname)
 	files = append(files, fname)
 	sort.Strings(files)
 	if err == nil {
-		err = s.checkFiles(files, fname, content)
+		err = s.checkFiles(files, fname)
 	}
 
 	return reply(ctx, nil,
@@ -324,6 +466,25 @@ func (s *Server) checkAndReply(
 	)
 }
 
+// overlay returns a copy of the content of the documents opened in the editor,
+// by file name, to be used instead of their content on disk.
+func (s *Server) overlay() map[string][]byte {
+	overlay := map[string][]byte{}
+	for fname, content := range s.documents {
+		overlay[fname] = []byte(content)
+	}
+	return overlay
+}
+
+// readFile returns the content of the file, which is the editor content if the
+// document is opened or the content on disk otherwise.
+func (s *Server) readFile(fname string) ([]byte, error) {
+	if content, ok := s.documents[fname]; ok {
+		return []byte(content), nil
+	}
+	return os.ReadFile(fname)
+}
+
 func listFiles(fromFile string) ([]string, error) {
 	dir := filepath.Dir(fromFile)
 	dirEntries, err := os.ReadDir(dir)
@@ -360,34 +521,22 @@ func listFiles(fromFile string) ([]string, error) {
 	return files, nil
 }
 
-// checkFiles checks if the given provided files have errors but the currentFile
-// is handled separately because it can be unsaved.
-func (s *Server) checkFiles(files []string, currentFile string, currentContent string) error {
+// checkFiles checks if the given provided files have errors. The content of
+// the documents opened in the editor is used instead of the content on disk,
+// as they can be unsaved.
+func (s *Server) checkFiles(files []string, currentFile string) error {
 	dir := filepath.Dir(currentFile)
-	_, rootdir, found, _ := config.TryLoadConfig(dir)
-	if !found {
-		rootdir = s.workspace
-	}
-
-	log.Trace().Msgf("using project root: %s (found: %t)", rootdir, found)
-
+	rootdir := s.projectRoot(dir)
 	parser, err := hcl.NewTerramateParser(rootdir, dir)
 	if err != nil {
 		return errors.E(err, "failed to create terramate parser")
 	}
 
-	for _, fname := range files {
-		var (
-			contents []byte
-			err      error
-		)
-
-		if currentFile == fname {
-			contents = []byte(currentContent)
-		} else {
-			contents, err = os.ReadFile(fname)
-		}
+	parser.SetOverlay(s.overlay())
+	parser.EnableComputedStackAttrs()
 
+	for _, fname := range files {
+		contents, err := s.readFile(fname)
 		if err != nil {
 			return err
 		}
@@ -402,3 +551,64 @@ func (s *Server) checkFiles(files []string, currentFile string, currentContent s
 	_, err = parser.ParseConfig()
 	return err
 }
+
+// projectRoot returns the root directory of the project containing the dir.
+// If the dir is not inside a Terramate project, the workspace is used.
+func (s *Server) projectRoot(dir string) string {
+	_, rootdir, found, _ := config.TryLoadConfigWithEvaluator(dir, globals.EvalStackAttrs)
+	if !found {
+		rootdir = s.workspace
+	}
+
+	log.Trace().Msgf("using project root: %s (found: %t)", rootdir, found)
+	return rootdir
+}
+
+// loadRoot returns the project configuration of the rootdir, with the computed
+// stack attributes evaluated. The configuration is loaded from disk once and
+// cached until a document changes or is saved, see [Server.invalidateRoots].
+// The returned configuration is shared, so it must not be modified.
+func (s *Server) loadRoot(rootdir string) (*config.Root, error) {
+	s.rootsMu.Lock()
+	root, ok := s.roots[rootdir]
+	version := s.rootsVersion
+	s.rootsMu.Unlock()
+	if ok {
+		return root, nil
+	}
+
+	root, err := config.LoadRootWithEvaluator(rootdir, globals.EvalStackAttrs)
+	if err != nil {
+		return nil, err
+	}
+
+	s.rootsMu.Lock()
+	defer s.rootsMu.Unlock()
+	if version == s.rootsVersion {
+		s.roots[rootdir] = root
+	}
+	return root, nil
+}
+
+// invalidateRoots drops the cached project configurations, so they are loaded
+// again from disk.
+func (s *Server) invalidateRoots() {
+	s.rootsMu.Lock()
+	defer s.rootsMu.Unlock()
+	s.roots = map[string]*config.Root{}
+	s.rootsVersion++
+}
+
+// lspRange converts the HCL range into a LSP range.
+func lspRange(r hhcl.Range) lsp.Range {
+	return lsp.Range{
+		Start: lsp.Position{
+			Line:      uint32(r.Start.Line) - 1,
+			Character: uint32(r.Start.Column) - 1,
+		},
+		End: lsp.Position{
+			Line:      uint32(r.End.Line) - 1,
+			Character: uint32(r.End.Column) - 1,
+		},
+	}
+}
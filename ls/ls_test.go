@@ -465,3 +465,95 @@ stack {
 func init() {
 	zerolog.SetGlobalLevel(zerolog.Disabled)
 }
+
+func TestDocumentIncrementalChange(t *testing.T) {
+	t.Parallel()
+
+	f := lstest.Setup(t, "f:stack/stack.tm:stack {\n  name = \"a\"\n}\n")
+	f.Editor.CheckInitialize(f.Sandbox.RootDir())
+	f.Editor.Open("stack/stack.tm")
+	assertDiagnostics(t, f, map[string][]string{"stack/stack.tm": nil})
+
+	// name = a: a reference to an unknown variable.
+	f.Editor.ChangeRange("stack/stack.tm", lsp.Range{
+		Start: lsp.Position{Line: 1, Character: 9},
+		End:   lsp.Position{Line: 1, Character: 12},
+	}, "a")
+	assertDiagnostics(t, f, map[string][]string{
+		"stack/stack.tm": {"1:9-1:10"},
+	})
+
+	f.Editor.ChangeRange("stack/stack.tm", lsp.Range{
+		Start: lsp.Position{Line: 1, Character: 9},
+		End:   lsp.Position{Line: 1, Character: 10},
+	}, `"𝄞"`)
+	assertDiagnostics(t, f, map[string][]string{"stack/stack.tm": nil})
+
+	// positions are in UTF-16 code units and 𝄞 takes two of them.
+	f.Editor.ChangeRange("stack/stack.tm", lsp.Range{
+		Start: lsp.Position{Line: 1, Character: 13},
+		End:   lsp.Position{Line: 1, Character: 13},
+	}, "\n  description = \"d\"")
+	assertDiagnostics(t, f, map[string][]string{"stack/stack.tm": nil})
+}
+
+func TestDocumentUnsavedSiblings(t *testing.T) {
+	t.Parallel()
+
+	f := lstest.Setup(t,
+		"f:stack/stack.tm:stack {\n}\n",
+		"f:stack/globals.tm:globals {\n  a = 1\n}\n",
+	)
+	f.Editor.CheckInitialize(f.Sandbox.RootDir())
+	f.Editor.Open("stack/globals.tm")
+	waitDiagnostics(t, f, "stack/globals.tm")
+
+	f.Editor.Change("stack/globals.tm", "globals {\n  a = \n}\n")
+	waitDiagnostics(t, f, "stack/globals.tm")
+
+	// the unsaved globals.tm is checked together with the stack file.
+	f.Editor.Open("stack/stack.tm")
+	assertDiagnostics(t, f, map[string][]string{
+		"stack/globals.tm": {"1:6-2:0"},
+		"stack/stack.tm":   nil,
+	})
+
+	// closing discards the unsaved changes.
+	f.Editor.Close("stack/globals.tm")
+	assertDiagnostics(t, f, map[string][]string{
+		"stack/globals.tm": nil,
+		"stack/stack.tm":   nil,
+	})
+}
+
+// assertDiagnostics checks the ranges of the diagnostics published for each
+// file, formatted as "line:char-line:char".
+func assertDiagnostics(t *testing.T, f lstest.Fixture, want map[string][]string) {
+	t.Helper()
+
+	got := map[string][]string{}
+	for {
+		select {
+		case r := <-f.Editor.Requests:
+			if r.Method() != lsp.MethodTextDocumentPublishDiagnostics {
+				continue
+			}
+			var params lsp.PublishDiagnosticsParams
+			assert.NoError(t, json.Unmarshal(r.Params(), &params))
+			rel, err := filepath.Rel(f.Sandbox.RootDir(), params.URI.Filename())
+			assert.NoError(t, err)
+			var ranges []string
+			for _, diag := range params.Diagnostics {
+				ranges = append(ranges, fmt.Sprintf("%d:%d-%d:%d",
+					diag.Range.Start.Line, diag.Range.Start.Character,
+					diag.Range.End.Line, diag.Range.End.Character))
+			}
+			got[filepath.ToSlash(rel)] = ranges
+		case <-time.After(100 * time.Millisecond):
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Fatalf("unexpected diagnostics, want(-) got(+):\n%s", diff)
+			}
+			return
+		}
+	}
+}
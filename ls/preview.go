@@ -0,0 +1,204 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package tmls
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"path/filepath"
+
+	"github.com/rs/zerolog"
+	"github.com/terramate-io/terramate/config"
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/generate/genfile"
+	"github.com/terramate-io/terramate/generate/genhcl"
+	"github.com/terramate-io/terramate/globals"
+	"github.com/terramate-io/terramate/hcl"
+	"github.com/terramate-io/terramate/hcl/info"
+	"github.com/terramate-io/terramate/project"
+	"github.com/terramate-io/terramate/stack"
+	"github.com/terramate-io/terramate/stdlib"
+	"go.lsp.dev/jsonrpc2"
+	lsp "go.lsp.dev/protocol"
+)
+
+// MethodGeneratePreview is the custom request returning the content generated
+// by the generate_hcl or generate_file block at a position of a document.
+const MethodGeneratePreview = "terramate/generatePreview"
+
+// GeneratePreviewParams are the params of the [MethodGeneratePreview] request.
+type GeneratePreviewParams struct {
+	lsp.TextDocumentPositionParams
+
+	// Stack is the project path of the stack used for evaluating the block.
+	// If empty, the first stack the block applies to is used.
+	Stack string `json:"stack,omitempty"`
+}
+
+// GeneratePreview is the result of the [MethodGeneratePreview] request.
+type GeneratePreview struct {
+	// Label is the label of the generate block, which is the generated file
+	// name.
+	Label string `json:"label"`
+
+	// Stack is the project path of the stack used for evaluating the block.
+	Stack string `json:"stack"`
+
+	// Stacks are the project paths of all the stacks the block applies to,
+	// so the client can choose the stack to preview.
+	Stacks []string `json:"stacks"`
+
+	// Condition is the result of the block condition. If false, the file is
+	// not generated and the content is empty.
+	Condition bool `json:"condition"`
+
+	// Content is the generated content, including the Terramate header of
+	// generate_hcl blocks.
+	Content string `json:"content"`
+}
+
+func (s *Server) handleGeneratePreview(
+	ctx context.Context,
+	reply jsonrpc2.Replier,
+	r jsonrpc2.Request,
+	log zerolog.Logger,
+) error {
+	var params GeneratePreviewParams
+	if err := json.Unmarshal(r.Params(), &params); err != nil {
+		log.Error().Err(err).Msg("failed to unmarshal params")
+		return jsonrpc2.ErrParse
+	}
+
+	preview, err := s.generatePreview(params)
+	if err != nil {
+		log.Debug().Err(err).Msg("previewing generate block")
+		// the error is shown to the user by the client.
+		return reply(ctx, nil, jsonrpc2.NewError(jsonrpc2.InvalidRequest, err.Error()))
+	}
+	return reply(ctx, preview, nil)
+}
+
+// generatePreview evaluates the generate block at the position of the
+// document for the chosen stack. The block is parsed from the unsaved content
+// of the documents opened in the editor, but the stack and its globals are
+// loaded from disk. The post_process and validate commands are not run.
+func (s *Server) generatePreview(params GeneratePreviewParams) (GeneratePreview, error) {
+	fname := params.TextDocument.URI.Filename()
+	dir := filepath.Dir(fname)
+	rootdir := s.projectRoot(dir)
+
+	parser, err := hcl.NewTerramateParser(rootdir, dir)
+	if err != nil {
+		return GeneratePreview{}, err
+	}
+	parser.SetOverlay(s.overlay())
+	parser.EnableComputedStackAttrs()
+	if err := parser.AddDir(dir); err != nil {
+		return GeneratePreview{}, err
+	}
+	cfg, err := parser.ParseConfig()
+	if err != nil {
+		return GeneratePreview{}, err
+	}
+
+	pos := hclPos(params.Position)
+	inBlock := func(r info.Range) bool {
+		return r.HostPath() == fname && rangeContains(r.ToHCLRange(), pos)
+	}
+
+	var (
+		genhclBlock  *hcl.GenHCLBlock
+		genfileBlock *hcl.GenFileBlock
+		label        string
+		genContext   string
+	)
+	for i := range cfg.Generate.HCLs {
+		if block := &cfg.Generate.HCLs[i]; inBlock(block.Range) {
+			genhclBlock, label, genContext = block, block.Label, block.Context
+		}
+	}
+	for i := range cfg.Generate.Files {
+		if block := &cfg.Generate.Files[i]; inBlock(block.Range) {
+			genfileBlock, label, genContext = block, block.Label, block.Context
+		}
+	}
+	if genhclBlock == nil && genfileBlock == nil {
+		return GeneratePreview{}, errors.E("no generate block at %s:%d:%d", fname, pos.Line, pos.Column)
+	}
+	if genContext != genhcl.StackContext {
+		return GeneratePreview{}, errors.E("generate block %q has context %s, only blocks with stack context can be previewed", label, genContext)
+	}
+
+	root, err := s.loadRoot(rootdir)
+	if err != nil {
+		return GeneratePreview{}, err
+	}
+	tree, ok := root.Lookup(project.PrjAbsPath(rootdir, dir))
+	if !ok {
+		return GeneratePreview{}, errors.E("configuration at %s not found", dir)
+	}
+
+	preview := GeneratePreview{
+		Label:  label,
+		Stack:  params.Stack,
+		Stacks: []string{},
+	}
+	for _, stackTree := range tree.Stacks() {
+		preview.Stacks = append(preview.Stacks, stackTree.Dir().String())
+	}
+	if len(preview.Stacks) == 0 {
+		return GeneratePreview{}, errors.E("generate block %q applies to no stack", label)
+	}
+	if preview.Stack == "" {
+		preview.Stack = preview.Stacks[0]
+	} else if !contains(preview.Stacks, preview.Stack) {
+		return GeneratePreview{}, errors.E("generate block %q doesn't apply to stack %s", label, preview.Stack)
+	}
+
+	st, err := config.LoadStack(root, project.NewPath(preview.Stack))
+	if err != nil {
+		return GeneratePreview{}, err
+	}
+	report := globals.ForStack(root, st)
+	if err := report.AsError(); err != nil {
+		return GeneratePreview{}, err
+	}
+
+	evalctx := stack.NewEvalCtx(root, st, report.Globals)
+	vendorTargetDir := project.NewPath(path.Join(st.Dir.String(), path.Dir(label)))
+	evalctx.SetFunction(stdlib.Name("vendor"),
+		stdlib.VendorFunc(vendorTargetDir, projectVendorDir(root), nil))
+
+	if genhclBlock != nil {
+		file, err := genhcl.Eval(*genhclBlock, evalctx.Context)
+		if err != nil {
+			return GeneratePreview{}, err
+		}
+		preview.Condition = file.Condition()
+		if preview.Condition {
+			preview.Content = file.Header() + file.Body()
+		}
+		return preview, nil
+	}
+
+	file, err := genfile.Eval(root, *genfileBlock, evalctx.Context)
+	if err != nil {
+		return GeneratePreview{}, err
+	}
+	preview.Condition = file.Condition()
+	if preview.Condition {
+		preview.Content = file.Header() + file.Body()
+	}
+	return preview, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, elem := range list {
+		if elem == s {
+			return true
+		}
+	}
+	return false
+}
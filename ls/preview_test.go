@@ -0,0 +1,141 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package tmls_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/madlambda/spells/assert"
+	"github.com/terramate-io/terramate/generate/genhcl"
+	tmls "github.com/terramate-io/terramate/ls"
+	lstest "github.com/terramate-io/terramate/test/ls"
+	lsp "go.lsp.dev/protocol"
+)
+
+func TestGeneratePreview(t *testing.T) {
+	t.Parallel()
+
+	const genConfig = `generate_hcl "main.tf" {
+  content {
+    env = global.env
+  }
+}
+
+generate_file "name.txt" {
+  condition = terramate.stack.name != "b"
+  content   = terramate.stack.name
+}
+
+generate_hcl "root.tf" {
+  context = root
+  content {}
+}
+`
+
+	type testcase struct {
+		name    string
+		pos     lsp.Position
+		stack   string
+		unsaved string
+		want    tmls.GeneratePreview
+		wantErr bool
+	}
+
+	for _, tc := range []testcase{
+		{
+			name: "generate_hcl for first stack",
+			pos:  lsp.Position{Line: 2, Character: 6},
+			want: tmls.GeneratePreview{
+				Label:     "main.tf",
+				Stack:     "/stacks/a",
+				Stacks:    []string{"/stacks/a", "/stacks/b"},
+				Condition: true,
+				Content:   genhcl.Header + "\n\nenv = \"prod\"\n",
+			},
+		},
+		{
+			name:  "generate_file for chosen stack",
+			pos:   lsp.Position{Line: 8, Character: 3},
+			stack: "/stacks/a",
+			want: tmls.GeneratePreview{
+				Label:     "name.txt",
+				Stack:     "/stacks/a",
+				Stacks:    []string{"/stacks/a", "/stacks/b"},
+				Condition: true,
+				Content:   "a",
+			},
+		},
+		{
+			name:  "generate_file with false condition",
+			pos:   lsp.Position{Line: 8, Character: 3},
+			stack: "/stacks/b",
+			want: tmls.GeneratePreview{
+				Label:     "name.txt",
+				Stack:     "/stacks/b",
+				Stacks:    []string{"/stacks/a", "/stacks/b"},
+				Condition: false,
+			},
+		},
+		{
+			name:    "unsaved content",
+			pos:     lsp.Position{Line: 2, Character: 6},
+			stack:   "/stacks/b",
+			unsaved: "generate_hcl \"main.tf\" {\n  content {\n    env = \"${global.env}-${terramate.stack.name}\"\n  }\n}\n",
+			want: tmls.GeneratePreview{
+				Label:     "main.tf",
+				Stack:     "/stacks/b",
+				Stacks:    []string{"/stacks/a", "/stacks/b"},
+				Condition: true,
+				Content:   genhcl.Header + "\n\nenv = \"prod-b\"\n",
+			},
+		},
+		{
+			name:    "stack outside the block directory",
+			pos:     lsp.Position{Line: 2, Character: 6},
+			stack:   "/other",
+			wantErr: true,
+		},
+		{
+			name:    "root context",
+			pos:     lsp.Position{Line: 13, Character: 3},
+			wantErr: true,
+		},
+		{
+			name:    "not a generate block",
+			pos:     lsp.Position{Line: 5, Character: 0},
+			wantErr: true,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			f := lstest.Setup(t,
+				"f:globals.tm:globals {\n  env = \"prod\"\n}\n",
+				"f:stacks/gen.tm:"+genConfig,
+				"f:stacks/a/stack.tm:stack {\n  name = \"a\"\n}\n",
+				"f:stacks/b/stack.tm:stack {\n  name = \"b\"\n}\n",
+				"s:other",
+			)
+			f.Editor.CheckInitialize(f.Sandbox.RootDir())
+			if tc.unsaved != "" {
+				f.Editor.Open("stacks/gen.tm")
+				waitDiagnostics(t, f, "stacks/gen.tm")
+				f.Editor.Change("stacks/gen.tm", tc.unsaved)
+				waitDiagnostics(t, f, "stacks/gen.tm")
+			}
+
+			got, err := f.Editor.GeneratePreview("stacks/gen.tm", tc.pos, tc.stack)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Fatalf("unexpected preview, want(-) got(+):\n%s", diff)
+			}
+		})
+	}
+}
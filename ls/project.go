@@ -0,0 +1,306 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package tmls
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/terramate-io/terramate/config"
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/generate"
+	"github.com/terramate-io/terramate/generate/genfile"
+	"github.com/terramate-io/terramate/generate/genhcl"
+	"github.com/terramate-io/terramate/globals"
+	tmhcl "github.com/terramate-io/terramate/hcl"
+	"github.com/terramate-io/terramate/hcl/eval"
+	"github.com/terramate-io/terramate/project"
+	"github.com/terramate-io/terramate/stack"
+	"github.com/zclconf/go-cty/cty"
+	lsp "go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
+)
+
+// projectCheckDelay is how long the project analysis waits after a file is
+// saved, so a burst of saves triggers a single analysis.
+const projectCheckDelay = 300 * time.Millisecond
+
+// scheduleProjectCheck schedules the analysis of the whole project, cancelling
+// any analysis scheduled or running. The analysis uses the content of the
+// documents opened in the editor at the time it is scheduled.
+func (s *Server) scheduleProjectCheck(rootdir string) {
+	// the documents are only accessed by the handlers, so they are copied
+	// before the analysis runs asynchronously.
+	overlay := s.overlay()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancelProjectCheck != nil {
+		s.cancelProjectCheck()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancelProjectCheck = cancel
+
+	time.AfterFunc(projectCheckDelay, func() {
+		if ctx.Err() != nil {
+			return
+		}
+		s.checkProject(ctx, rootdir, overlay)
+	})
+}
+
+// checkProject analyzes the project and publishes the diagnostics of every
+// file with errors, clearing the diagnostics of the files fixed since the last
+// analysis.
+func (s *Server) checkProject(ctx context.Context, rootdir string, overlay map[string][]byte) {
+	logger := s.log.With().
+		Str("action", "server.checkProject()").
+		Str("rootdir", rootdir).
+		Logger()
+
+	logger.Debug().Msg("analyzing project")
+
+	diagsMap := errorDiagnostics(analyzeProject(ctx, rootdir, overlay))
+
+	s.mu.Lock()
+	if ctx.Err() != nil {
+		s.mu.Unlock()
+		logger.Debug().Msg("project analysis cancelled")
+		return
+	}
+	var files []string
+	for filename := range s.projectDiags {
+		if _, ok := diagsMap[filename]; !ok {
+			files = append(files, filename)
+		}
+	}
+	for filename := range diagsMap {
+		files = append(files, filename)
+	}
+	s.projectDiags = diagsMap
+	s.mu.Unlock()
+
+	sort.Strings(files)
+	for _, filename := range files {
+		diags := diagsMap[filename]
+		if diags == nil {
+			diags = []lsp.Diagnostic{}
+		}
+		err := s.sendDiagnostics(ctx, lsp.URI(uri.File(filepath.ToSlash(filename))), diags)
+		if err != nil {
+			return
+		}
+	}
+}
+
+// analyzeProject checks the whole project configuration, which includes the
+// errors reported by the commands but not by parsing a single directory:
+// stacks with duplicated IDs, stack references to missing stacks, globals
+// evaluation, failed assertions and code generation. The configuration is
+// loaded with the content of the files in the overlay. It never runs the
+// post_process and validate commands of the generate blocks.
+func analyzeProject(ctx context.Context, rootdir string, overlay map[string][]byte) error {
+	root, err := config.LoadRootOverlayWithEvaluator(rootdir, overlay, globals.EvalStackAttrs)
+	if err != nil {
+		return err
+	}
+
+	errs := errors.L()
+	errs.Append(checkStackIDs(root, overlay))
+	errs.Append(checkStackRefs(root, overlay))
+
+	vendorDir := projectVendorDir(root)
+	for _, stackTree := range root.Tree().Stacks() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		st, err := config.NewStackFromHCL(root.HostDir(), stackTree.Node)
+		if err != nil {
+			errs.Append(err)
+			continue
+		}
+		report := globals.ForStack(root, st)
+		if err := report.AsError(); err != nil {
+			errs.Append(err)
+			continue
+		}
+		errs.Append(checkAsserts(root, st, report.Globals, vendorDir))
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	results, err := generate.Load(root, vendorDir)
+	if err != nil {
+		errs.Append(err)
+		return errs.AsError()
+	}
+	for _, res := range results {
+		// failed assertions are reported by checkAsserts with their ranges.
+		if res.Err != nil && !errors.IsKind(res.Err, generate.ErrAssertion) {
+			errs.Append(res.Err)
+		}
+	}
+	return errs.AsError()
+}
+
+// projectVendorDir returns the vendor dir configured in the project root or the
+// default one.
+func projectVendorDir(root *config.Root) project.Path {
+	if vendor := root.Tree().Node.Vendor; vendor != nil && vendor.Dir != "" {
+		return project.NewPath(vendor.Dir)
+	}
+	return project.NewPath(tmhcl.DefaultVendorDir)
+}
+
+// checkStackIDs checks that the stacks have unique IDs, reporting all the
+// stacks sharing an ID.
+func checkStackIDs(root *config.Root, overlay map[string][]byte) error {
+	byID := map[string]config.List[*config.Tree]{}
+	for _, stackTree := range root.Tree().Stacks() {
+		if id := stackTree.Node.Stack.ID; id != "" {
+			byID[strings.ToLower(id)] = append(byID[strings.ToLower(id)], stackTree)
+		}
+	}
+
+	errs := errors.L()
+	for _, stacks := range byID {
+		if len(stacks) < 2 {
+			continue
+		}
+		for _, stackTree := range stacks {
+			var others []string
+			for _, other := range stacks {
+				if other != stackTree {
+					others = append(others, other.Dir().String())
+				}
+			}
+			errs.Append(errors.E(config.ErrStackDuplicatedID,
+				stackAttrRange(stackTree, "id", "", overlay),
+				"stack ID %q is also used by %s",
+				stackTree.Node.Stack.ID, strings.Join(others, ", ")))
+		}
+	}
+	return errs.AsError()
+}
+
+// checkStackRefs checks that the paths referenced by the stacks in after,
+// before, wants and wanted_by have stacks.
+func checkStackRefs(root *config.Root, overlay map[string][]byte) error {
+	errs := errors.L()
+	for _, stackTree := range root.Tree().Stacks() {
+		stackcfg := stackTree.Node.Stack
+		for attr, paths := range map[string][]string{
+			"after":     stackcfg.After,
+			"before":    stackcfg.Before,
+			"wants":     stackcfg.Wants,
+			"wanted_by": stackcfg.WantedBy,
+		} {
+			for _, path := range paths {
+				if strings.HasPrefix(path, "tag:") {
+					continue
+				}
+				if len(root.StacksByPaths(stackTree.Dir(), path)) > 0 {
+					continue
+				}
+				errs.Append(errors.E(config.ErrStackValidation,
+					stackAttrRange(stackTree, attr, path, overlay),
+					"stack.%s references %q but no stack is found there", attr, path))
+			}
+		}
+	}
+	return errs.AsError()
+}
+
+// checkAsserts evaluates the assert blocks of the stack, from its directory and
+// parent directories, and from its generate blocks.
+func checkAsserts(root *config.Root, st *config.Stack, globals *eval.Object, vendorDir project.Path) error {
+	evalctx := stack.NewEvalCtx(root, st, globals)
+
+	var asserts []config.Assert
+	errs := errors.L()
+
+	tree, _ := root.Lookup(st.Dir)
+	for ; tree != nil; tree = tree.Parent {
+		for _, assertCfg := range tree.Node.Asserts {
+			assert, err := config.EvalAssert(evalctx.Context, assertCfg)
+			if err != nil {
+				errs.Append(err)
+				continue
+			}
+			asserts = append(asserts, assert)
+		}
+	}
+
+	// generation failures are reported by generate.Load.
+	if genhcls, err := genhcl.Load(root, st, globals, vendorDir, nil); err == nil {
+		for _, gen := range genhcls {
+			asserts = append(asserts, gen.Asserts()...)
+		}
+	}
+	if genfiles, err := genfile.Load(root, st, globals, vendorDir, nil); err == nil {
+		for _, gen := range genfiles {
+			asserts = append(asserts, gen.Asserts()...)
+		}
+	}
+
+	for _, assert := range asserts {
+		if !assert.Assertion && !assert.Warning {
+			errs.Append(errors.E(generate.ErrAssertion, assert.Range,
+				"%s", assert.Message))
+		}
+	}
+	return errs.AsError()
+}
+
+// stackAttrRange returns the range of the stack attribute, or of the given
+// element of the attribute list if elem is not empty. If the attribute is
+// not found, the range of the stack block is returned. The content of the
+// stack file is taken from the overlay, if present.
+func stackAttrRange(stackTree *config.Tree, name string, elem string, overlay map[string][]byte) hcl.Range {
+	stackRange := stackTree.Node.Stack.Range
+	fallback := stackRange.ToHCLRange()
+
+	content, ok := overlay[stackRange.HostPath()]
+	if !ok {
+		var err error
+		content, err = os.ReadFile(stackRange.HostPath())
+		if err != nil {
+			return fallback
+		}
+	}
+	body, err := parseBody(stackRange.HostPath(), content)
+	if err != nil {
+		return fallback
+	}
+
+	for _, block := range body.Blocks {
+		if block.Type != "stack" || block.Range().Start != fallback.Start {
+			continue
+		}
+		attr, ok := block.Body.Attributes[name]
+		if !ok {
+			return fallback
+		}
+		if list, ok := attr.Expr.(*hclsyntax.TupleConsExpr); ok && elem != "" {
+			for _, elemExpr := range list.Exprs {
+				val, diags := elemExpr.Value(nil)
+				if !diags.HasErrors() && val.Type() == cty.String && val.AsString() == elem {
+					return elemExpr.Range()
+				}
+			}
+		}
+		return attr.Expr.Range()
+	}
+	return fallback
+}
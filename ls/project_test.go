@@ -0,0 +1,241 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package tmls_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/madlambda/spells/assert"
+	lstest "github.com/terramate-io/terramate/test/ls"
+	lsp "go.lsp.dev/protocol"
+)
+
+func TestProjectDiagnostics(t *testing.T) {
+	t.Parallel()
+
+	type wantDiag struct {
+		Range   lsp.Range
+		Message string
+	}
+	type testcase struct {
+		name   string
+		layout []string
+		save   string
+		want   map[string][]wantDiag
+	}
+
+	rng := func(line, startChar, endChar uint32) lsp.Range {
+		return lsp.Range{
+			Start: lsp.Position{Line: line, Character: startChar},
+			End:   lsp.Position{Line: line, Character: endChar},
+		}
+	}
+
+	for _, tc := range []testcase{
+		{
+			name: "duplicated stack IDs",
+			layout: []string{
+				"f:a/stack.tm:stack {\n  id = \"same\"\n}",
+				"f:b/stack.tm:stack {\n  id = \"same\"\n}",
+			},
+			save: "a/stack.tm",
+			want: map[string][]wantDiag{
+				"a/stack.tm": {
+					{
+						Range:   rng(1, 7, 13),
+						Message: `duplicated ID found on stacks: stack ID "same" is also used by /b`,
+					},
+				},
+				"b/stack.tm": {
+					{
+						Range:   rng(1, 7, 13),
+						Message: `duplicated ID found on stacks: stack ID "same" is also used by /a`,
+					},
+				},
+			},
+		},
+		{
+			name: "after references missing stack",
+			layout: []string{
+				"f:a/stack.tm:stack {\n  after = [\"/b\", \"/missing\"]\n}",
+				"f:b/stack.tm:stack {\n}",
+			},
+			save: "b/stack.tm",
+			want: map[string][]wantDiag{
+				"a/stack.tm": {
+					{
+						Range:   rng(1, 17, 27),
+						Message: `validating stack fields: stack.after references "/missing" but no stack is found there`,
+					},
+				},
+			},
+		},
+		{
+			name: "failed assertion in parent dir",
+			layout: []string{
+				"f:assert.tm:assert {\n  assertion = global.env == \"prod\"\n  message   = \"env must be prod\"\n}",
+				"f:globals.tm:globals {\n  env = \"prod\"\n}",
+				"f:a/globals.tm:globals {\n  env = \"dev\"\n}",
+				"f:a/stack.tm:stack {\n}",
+			},
+			save: "a/globals.tm",
+			want: map[string][]wantDiag{
+				"assert.tm": {
+					{
+						Range:   rng(1, 14, 34),
+						Message: "assertion failed: env must be prod",
+					},
+				},
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			f := lstest.Setup(t, tc.layout...)
+			f.Editor.CheckInitialize(f.Sandbox.RootDir())
+			f.Editor.Save(tc.save)
+
+			got := map[string][]wantDiag{}
+			for _, params := range collectDiagnostics(t, f) {
+				rel, err := filepath.Rel(f.Sandbox.RootDir(), params.URI.Filename())
+				assert.NoError(t, err)
+				if len(params.Diagnostics) == 0 {
+					continue
+				}
+				var diags []wantDiag
+				for _, diag := range params.Diagnostics {
+					diags = append(diags, wantDiag{
+						Range:   diag.Range,
+						Message: diag.Message,
+					})
+				}
+				got[filepath.ToSlash(rel)] = diags
+			}
+
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Fatalf("unexpected diagnostics, want(-) got(+):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestProjectDiagnosticsAreClearedWhenFixed(t *testing.T) {
+	t.Parallel()
+
+	f := lstest.Setup(t,
+		"f:a/stack.tm:stack {\n  after = [\"/missing\"]\n}",
+	)
+	f.Editor.CheckInitialize(f.Sandbox.RootDir())
+	f.Editor.Save("a/stack.tm")
+
+	var found bool
+	for _, params := range collectDiagnostics(t, f) {
+		if len(params.Diagnostics) > 0 {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("expected diagnostics for the missing stack")
+	}
+
+	f.Sandbox.RootEntry().CreateFile("a/stack.tm", "stack {\n}")
+	f.Editor.Save("a/stack.tm")
+
+	// the directory check is published first, so the last diagnostics are the
+	// ones of the project analysis.
+	stackFile := filepath.Join(f.Sandbox.RootDir(), "a/stack.tm")
+	var last *lsp.PublishDiagnosticsParams
+	for _, params := range collectDiagnostics(t, f) {
+		params := params
+		if params.URI.Filename() == stackFile {
+			last = &params
+		}
+	}
+	if last == nil {
+		t.Fatal("expected diagnostics to be published")
+	}
+	if len(last.Diagnostics) > 0 {
+		t.Fatalf("unexpected diagnostics after fix: %+v", last.Diagnostics)
+	}
+}
+
+func TestProjectDiagnosticsUseUnsavedDocuments(t *testing.T) {
+	t.Parallel()
+
+	f := lstest.Setup(t,
+		"f:a/stack.tm:stack {\n}",
+		"f:b/stack.tm:stack {\n}",
+	)
+	f.Editor.CheckInitialize(f.Sandbox.RootDir())
+	f.Editor.Open("a/stack.tm")
+	waitDiagnostics(t, f, "a/stack.tm")
+	f.Editor.Change("a/stack.tm", "stack {\n  after = [\"/missing\"]\n}")
+	waitDiagnostics(t, f, "a/stack.tm")
+
+	f.Editor.Save("b/stack.tm")
+
+	stackFile := filepath.Join(f.Sandbox.RootDir(), "a/stack.tm")
+	var found bool
+	for _, params := range collectDiagnostics(t, f) {
+		if params.URI.Filename() == stackFile && len(params.Diagnostics) > 0 {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("expected diagnostics for the unsaved missing stack reference")
+	}
+}
+
+func TestProjectDiagnosticsDontRunCommands(t *testing.T) {
+	t.Parallel()
+
+	f := lstest.Setup(t,
+		"s:stack",
+		`f:stack/gen.tm:generate_file "file.txt" {
+  content      = "hello"
+  post_process = [["touch", "post_process_ran"]]
+  validate     = ["false"]
+}`,
+	)
+	f.Editor.CheckInitialize(f.Sandbox.RootDir())
+	f.Editor.Save("stack/gen.tm")
+
+	for _, params := range collectDiagnostics(t, f) {
+		if len(params.Diagnostics) > 0 {
+			t.Fatalf("unexpected diagnostics: %+v", params.Diagnostics)
+		}
+	}
+	_, err := os.Stat(filepath.Join(f.Sandbox.RootDir(), "stack", "post_process_ran"))
+	if err == nil {
+		t.Fatal("project analysis must not run the post_process commands")
+	}
+}
+
+// collectDiagnostics returns the diagnostics published until the server is
+// idle for longer than the project analysis delay.
+func collectDiagnostics(t *testing.T, f lstest.Fixture) []lsp.PublishDiagnosticsParams {
+	t.Helper()
+
+	var res []lsp.PublishDiagnosticsParams
+	for {
+		select {
+		case r := <-f.Editor.Requests:
+			if r.Method() != lsp.MethodTextDocumentPublishDiagnostics {
+				continue
+			}
+			var params lsp.PublishDiagnosticsParams
+			assert.NoError(t, json.Unmarshal(r.Params(), &params))
+			res = append(res, params)
+		case <-time.After(time.Second):
+			return res
+		}
+	}
+}
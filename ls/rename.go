@@ -0,0 +1,113 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package tmls
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/rs/zerolog"
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/globals"
+	"github.com/terramate-io/terramate/hcl/eval"
+	"github.com/terramate-io/terramate/project"
+	"go.lsp.dev/jsonrpc2"
+	lsp "go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
+)
+
+func (s *Server) handleRename(
+	ctx context.Context,
+	reply jsonrpc2.Replier,
+	r jsonrpc2.Request,
+	log zerolog.Logger,
+) error {
+	var params lsp.RenameParams
+	if err := json.Unmarshal(r.Params(), &params); err != nil {
+		log.Error().Err(err).Msg("failed to unmarshal params")
+		return jsonrpc2.ErrParse
+	}
+
+	edit, err := s.renameGlobal(params.TextDocument.URI.Filename(), params.Position, params.NewName)
+	if err != nil {
+		log.Debug().Err(err).Msg("renaming global")
+		// the error is shown to the user by the client.
+		return reply(ctx, nil, jsonrpc2.NewError(jsonrpc2.InvalidRequest, err.Error()))
+	}
+	return reply(ctx, edit, nil)
+}
+
+// renameGlobal returns the edits renaming the global referenced or defined at
+// the given position of the file in the whole project.
+func (s *Server) renameGlobal(fname string, pos lsp.Position, newName string) (*lsp.WorkspaceEdit, error) {
+	content, err := s.readFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	body, err := parseBody(fname, content)
+	if err != nil {
+		return nil, err
+	}
+
+	hclpos := hclPos(pos)
+	globalPath, ok := globalAt(body, hclpos)
+	if !ok {
+		return nil, errors.E("no global at %s:%d:%d", fname, hclpos.Line, hclpos.Column)
+	}
+
+	dir := filepath.Dir(fname)
+	rootdir := s.projectRoot(dir)
+	root, err := s.loadRoot(rootdir)
+	if err != nil {
+		return nil, err
+	}
+
+	renamed, err := globals.Rename(root, project.PrjAbsPath(rootdir, dir), globalPath, newName, s.overlay())
+	if err != nil {
+		return nil, err
+	}
+
+	edit := &lsp.WorkspaceEdit{
+		Changes: map[lsp.DocumentURI][]lsp.TextEdit{},
+	}
+	for _, file := range renamed {
+		docURI := lsp.DocumentURI(uri.File(file.Path))
+		edit.Changes[docURI] = replaceContentEdits(file.Original, file.Content)
+	}
+	return edit, nil
+}
+
+// globalAt returns the path of the global at the given position. The position
+// can be at a global.<path> reference, in which case the path is cut at the
+// referenced key, or at the attribute name or label of a globals block.
+func globalAt(body *hclsyntax.Body, pos hcl.Pos) (eval.ObjectPath, bool) {
+	for _, block := range body.Blocks {
+		if block.Type != "globals" {
+			continue
+		}
+		for i, labelRange := range block.LabelRanges {
+			if rangeContains(labelRange, pos) {
+				return append(eval.ObjectPath{}, block.Labels[:i+1]...), true
+			}
+		}
+		for name, attr := range block.Body.Attributes {
+			if rangeContains(attr.NameRange, pos) {
+				return append(append(eval.ObjectPath{}, block.Labels...), name), true
+			}
+		}
+	}
+
+	var globalPath eval.ObjectPath
+	_ = hclsyntax.VisitAll(body, func(node hclsyntax.Node) hcl.Diagnostics {
+		expr, ok := node.(*hclsyntax.ScopeTraversalExpr)
+		if ok && expr.Traversal.RootName() == "global" && rangeContains(expr.Range(), pos) {
+			globalPath = globals.TraversalPath(cutTraversal(expr.Traversal, pos))
+		}
+		return nil
+	})
+	return globalPath, len(globalPath) > 0
+}
@@ -0,0 +1,136 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package tmls_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/madlambda/spells/assert"
+	lstest "github.com/terramate-io/terramate/test/ls"
+	lsp "go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
+)
+
+func TestRenameGlobal(t *testing.T) {
+	t.Parallel()
+
+	type testcase struct {
+		name    string
+		file    string
+		pos     lsp.Position
+		unsaved string
+		want    map[string]string
+		wantErr bool
+	}
+
+	const (
+		rootGlobals = "globals {\n  env = \"prod\"\n}\n"
+		stackFile   = "stack {}\n\nglobals {\n  name = global.env\n}\n"
+	)
+
+	for _, tc := range []testcase{
+		{
+			name: "from reference",
+			file: "stack/stack.tm",
+			pos:  lsp.Position{Line: 3, Character: 17},
+			want: map[string]string{
+				"globals.tm":     "globals {\n  environment = \"prod\"\n}\n",
+				"stack/stack.tm": "stack {}\n\nglobals {\n  name = global.environment\n}\n",
+			},
+		},
+		{
+			name: "from definition",
+			file: "globals.tm",
+			pos:  lsp.Position{Line: 1, Character: 3},
+			want: map[string]string{
+				"globals.tm":     "globals {\n  environment = \"prod\"\n}\n",
+				"stack/stack.tm": "stack {}\n\nglobals {\n  name = global.environment\n}\n",
+			},
+		},
+		{
+			name:    "with unsaved changes",
+			file:    "stack/stack.tm",
+			pos:     lsp.Position{Line: 3, Character: 17},
+			unsaved: "stack {}\n\nglobals {\n  name = global.env\n  other = global.env\n}\n",
+			want: map[string]string{
+				"globals.tm":     "globals {\n  environment = \"prod\"\n}\n",
+				"stack/stack.tm": "stack {}\n\nglobals {\n  name  = global.environment\n  other = global.environment\n}\n",
+			},
+		},
+		{
+			name:    "not a global",
+			file:    "stack/stack.tm",
+			pos:     lsp.Position{Line: 0, Character: 1},
+			wantErr: true,
+		},
+		{
+			name:    "name already defined",
+			file:    "stack/stack.tm",
+			pos:     lsp.Position{Line: 3, Character: 17},
+			unsaved: "stack {}\n\nglobals {\n  environment = global.env\n}\n",
+			wantErr: true,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			f := lstest.Setup(t,
+				"f:globals.tm:"+rootGlobals,
+				"f:stack/stack.tm:"+stackFile,
+			)
+			f.Editor.CheckInitialize(f.Sandbox.RootDir())
+			if tc.unsaved != "" {
+				f.Editor.Open("stack/stack.tm")
+				waitDiagnostics(t, f, "stack/stack.tm")
+				f.Editor.Change("stack/stack.tm", tc.unsaved)
+				waitDiagnostics(t, f, "stack/stack.tm")
+			}
+
+			edit, err := f.Editor.Rename(tc.file, tc.pos, "environment")
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			want := map[lsp.DocumentURI][]lsp.TextEdit{}
+			for path, content := range tc.want {
+				original := rootGlobals
+				if path == "stack/stack.tm" {
+					original = stackFile
+					if tc.unsaved != "" {
+						original = tc.unsaved
+					}
+				}
+				docURI := lsp.DocumentURI(uri.File(filepath.Join(f.Sandbox.RootDir(), path)))
+				want[docURI] = []lsp.TextEdit{
+					{
+						Range:   lsp.Range{End: endOf(original)},
+						NewText: content,
+					},
+				}
+			}
+			if diff := cmp.Diff(want, edit.Changes); diff != "" {
+				t.Fatalf("unexpected edits, want(-) got(+):\n%s", diff)
+			}
+		})
+	}
+}
+
+// endOf returns the position of the end of the ASCII content.
+func endOf(content string) lsp.Position {
+	var pos lsp.Position
+	for _, c := range content {
+		if c == '\n' {
+			pos.Line++
+			pos.Character = 0
+		} else {
+			pos.Character++
+		}
+	}
+	return pos
+}
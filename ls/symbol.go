@@ -0,0 +1,230 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package tmls
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/rs/zerolog"
+	"github.com/terramate-io/terramate/config"
+	"github.com/terramate-io/terramate/hcl/info"
+	"github.com/zclconf/go-cty/cty"
+	"go.lsp.dev/jsonrpc2"
+	lsp "go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
+)
+
+// maxWorkspaceSymbols is the maximum number of symbols returned by a
+// workspace symbol search, so big projects don't flood the editor.
+const maxWorkspaceSymbols = 500
+
+// symbolKinds are the kinds of the blocks listed as document symbols.
+var symbolKinds = map[string]lsp.SymbolKind{
+	"stack":         lsp.SymbolKindModule,
+	"globals":       lsp.SymbolKindNamespace,
+	"generate_hcl":  lsp.SymbolKindFile,
+	"generate_file": lsp.SymbolKindFile,
+	"assert":        lsp.SymbolKindBoolean,
+	"import":        lsp.SymbolKindPackage,
+}
+
+func (s *Server) handleDocumentSymbol(
+	ctx context.Context,
+	reply jsonrpc2.Replier,
+	r jsonrpc2.Request,
+	log zerolog.Logger,
+) error {
+	var params lsp.DocumentSymbolParams
+	if err := json.Unmarshal(r.Params(), &params); err != nil {
+		log.Error().Err(err).Msg("failed to unmarshal params")
+		return jsonrpc2.ErrParse
+	}
+
+	fname := params.TextDocument.URI.Filename()
+	content, err := s.readFile(fname)
+	if err != nil {
+		log.Error().Err(err).Msg("reading file.")
+		return reply(ctx, nil, nil)
+	}
+
+	body, err := parseBody(fname, content)
+	if err != nil {
+		log.Debug().Err(err).Msg("parsing file.")
+		return reply(ctx, nil, nil)
+	}
+	return reply(ctx, documentSymbols(body), nil)
+}
+
+// documentSymbols returns the symbols of the stack, globals, generate, assert
+// and import blocks of the body. The globals attributes are children of their
+// block.
+func documentSymbols(body *hclsyntax.Body) []lsp.DocumentSymbol {
+	symbols := []lsp.DocumentSymbol{}
+	for _, block := range body.Blocks {
+		kind, ok := symbolKinds[block.Type]
+		if !ok {
+			continue
+		}
+
+		name := block.Type
+		for _, label := range block.Labels {
+			name += fmt.Sprintf(" %q", label)
+		}
+		symbol := lsp.DocumentSymbol{
+			Name:           name,
+			Kind:           kind,
+			Range:          lspRange(block.Range()),
+			SelectionRange: lspRange(block.DefRange()),
+		}
+
+		switch block.Type {
+		case "stack":
+			symbol.Detail = literalAttr(block.Body, "name")
+		case "import":
+			symbol.Detail = literalAttr(block.Body, "source")
+		case "globals":
+			for _, attr := range sortedAttrs(block.Body) {
+				path := append(append([]string{"global"}, block.Labels...), attr.Name)
+				symbol.Children = append(symbol.Children, lsp.DocumentSymbol{
+					Name:           attr.Name,
+					Detail:         strings.Join(path, "."),
+					Kind:           lsp.SymbolKindVariable,
+					Range:          lspRange(attr.Range()),
+					SelectionRange: lspRange(attr.NameRange),
+				})
+			}
+		}
+		symbols = append(symbols, symbol)
+	}
+	return symbols
+}
+
+// literalAttr returns the value of the attribute if it's a literal string.
+func literalAttr(body *hclsyntax.Body, name string) string {
+	attr, ok := body.Attributes[name]
+	if !ok {
+		return ""
+	}
+	val, diags := attr.Expr.Value(nil)
+	if diags.HasErrors() || !val.IsKnown() || val.IsNull() || val.Type() != cty.String {
+		return ""
+	}
+	return val.AsString()
+}
+
+// sortedAttrs returns the attributes of the body in the order they are
+// defined.
+func sortedAttrs(body *hclsyntax.Body) []*hclsyntax.Attribute {
+	attrs := make([]*hclsyntax.Attribute, 0, len(body.Attributes))
+	for _, attr := range body.Attributes {
+		attrs = append(attrs, attr)
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		return attrs[i].SrcRange.Start.Byte < attrs[j].SrcRange.Start.Byte
+	})
+	return attrs
+}
+
+func (s *Server) handleWorkspaceSymbol(
+	ctx context.Context,
+	reply jsonrpc2.Replier,
+	r jsonrpc2.Request,
+	log zerolog.Logger,
+) error {
+	var params lsp.WorkspaceSymbolParams
+	if err := json.Unmarshal(r.Params(), &params); err != nil {
+		log.Error().Err(err).Msg("failed to unmarshal params")
+		return jsonrpc2.ErrParse
+	}
+
+	root, err := s.loadRoot(s.projectRoot(s.workspace))
+	if err != nil {
+		log.Debug().Err(err).Msg("loading project.")
+		return reply(ctx, nil, nil)
+	}
+	return reply(ctx, workspaceSymbols(root, params.Query), nil)
+}
+
+// workspaceSymbols returns the stacks which name, ID, tags or directory match
+// the query, followed by the globals which path matches it. The query is
+// matched case insensitively as a substring.
+func workspaceSymbols(root *config.Root, query string) []lsp.SymbolInformation {
+	query = strings.ToLower(query)
+	matches := func(values ...string) bool {
+		for _, val := range values {
+			if strings.Contains(strings.ToLower(val), query) {
+				return true
+			}
+		}
+		return false
+	}
+
+	symbols := []lsp.SymbolInformation{}
+	add := func(symbol lsp.SymbolInformation) bool {
+		if len(symbols) == maxWorkspaceSymbols {
+			return false
+		}
+		symbols = append(symbols, symbol)
+		return true
+	}
+
+	for _, stackTree := range root.Tree().Stacks() {
+		st := stackTree.Node.Stack
+		dir := stackTree.Dir().String()
+		if !matches(append([]string{st.Name, st.ID, dir}, st.Tags...)...) {
+			continue
+		}
+		if !add(lsp.SymbolInformation{
+			Name:          st.Name,
+			Kind:          lsp.SymbolKindModule,
+			Location:      lspLocation(st.Range),
+			ContainerName: dir,
+		}) {
+			return symbols
+		}
+	}
+
+	trees := root.Tree().AsList()
+	sort.Sort(trees)
+	for _, tree := range trees {
+		var globals []lsp.SymbolInformation
+		for _, block := range tree.Node.Globals {
+			for name, attr := range block.Attributes {
+				path := strings.Join(append(append([]string{"global"}, block.Labels...), name), ".")
+				if !matches(path) {
+					continue
+				}
+				globals = append(globals, lsp.SymbolInformation{
+					Name:          path,
+					Kind:          lsp.SymbolKindVariable,
+					Location:      lspLocation(attr.Range),
+					ContainerName: tree.Dir().String(),
+				})
+			}
+		}
+		// the globals are kept in maps, so they are sorted for a stable output.
+		sort.Slice(globals, func(i, j int) bool {
+			return globals[i].Name < globals[j].Name
+		})
+		for _, symbol := range globals {
+			if !add(symbol) {
+				return symbols
+			}
+		}
+	}
+	return symbols
+}
+
+// lspLocation converts the range into a LSP location.
+func lspLocation(r info.Range) lsp.Location {
+	return lsp.Location{
+		URI:   lsp.DocumentURI(uri.File(r.HostPath())),
+		Range: lspRange(r.ToHCLRange()),
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package tmls_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	lstest "github.com/terramate-io/terramate/test/ls"
+	lsp "go.lsp.dev/protocol"
+)
+
+func TestDocumentSymbol(t *testing.T) {
+	t.Parallel()
+
+	f := lstest.Setup(t, `f:stack/stack.tm:stack {
+  name = "app"
+}
+
+import {
+  source = "/modules/*.tm"
+}
+
+globals "cfg" {
+  region = "us"
+  zone   = "a"
+}
+
+generate_hcl "main.tf" {
+  content {}
+}
+
+assert {
+  assertion = true
+  message   = "ok"
+}
+
+terramate {
+  required_version = "> 0.1"
+}
+`)
+	f.Editor.CheckInitialize(f.Sandbox.RootDir())
+
+	// the symbols are formatted as "<kind> <name> [<detail>] @ <selection line>"
+	// so the test is not tied to every range.
+	var got []string
+	var format func(indent string, symbols []lsp.DocumentSymbol)
+	format = func(indent string, symbols []lsp.DocumentSymbol) {
+		for _, sym := range symbols {
+			got = append(got, fmt.Sprintf("%s%d %s [%s] @ %d",
+				indent, int(sym.Kind), sym.Name, sym.Detail, sym.SelectionRange.Start.Line))
+			format(indent+"  ", sym.Children)
+		}
+	}
+	format("", f.Editor.DocumentSymbol("stack/stack.tm"))
+
+	want := []string{
+		fmt.Sprintf(`%d stack [app] @ 0`, int(lsp.SymbolKindModule)),
+		fmt.Sprintf(`%d import [/modules/*.tm] @ 4`, int(lsp.SymbolKindPackage)),
+		fmt.Sprintf(`%d globals "cfg" [] @ 8`, int(lsp.SymbolKindNamespace)),
+		fmt.Sprintf(`  %d region [global.cfg.region] @ 9`, int(lsp.SymbolKindVariable)),
+		fmt.Sprintf(`  %d zone [global.cfg.zone] @ 10`, int(lsp.SymbolKindVariable)),
+		fmt.Sprintf(`%d generate_hcl "main.tf" [] @ 13`, int(lsp.SymbolKindFile)),
+		fmt.Sprintf(`%d assert [] @ 17`, int(lsp.SymbolKindBoolean)),
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected symbols, want(-) got(+):\n%s", diff)
+	}
+}
+
+func TestWorkspaceSymbol(t *testing.T) {
+	t.Parallel()
+
+	f := lstest.Setup(t,
+		`f:globals.tm:globals {
+  db_host = "localhost"
+}
+`,
+		`f:app/stack.tm:stack {
+  name = "app"
+  tags = ["prod"]
+}
+
+globals "cfg" {
+  region = "us"
+}
+`,
+		`f:db/stack.tm:stack {
+  name = "database"
+  id   = "some-id"
+}
+`,
+	)
+	f.Editor.CheckInitialize(f.Sandbox.RootDir())
+
+	type symbol struct {
+		Name      string
+		Kind      lsp.SymbolKind
+		Container string
+		File      string
+		Line      uint32
+	}
+
+	type testcase struct {
+		query string
+		want  []symbol
+	}
+
+	for _, tc := range []testcase{
+		{
+			query: "APP",
+			want: []symbol{
+				{Name: "app", Kind: lsp.SymbolKindModule, Container: "/app", File: "app/stack.tm"},
+			},
+		},
+		{
+			query: "prod",
+			want: []symbol{
+				{Name: "app", Kind: lsp.SymbolKindModule, Container: "/app", File: "app/stack.tm"},
+			},
+		},
+		{
+			query: "some-id",
+			want: []symbol{
+				{Name: "database", Kind: lsp.SymbolKindModule, Container: "/db", File: "db/stack.tm"},
+			},
+		},
+		{
+			query: "db",
+			want: []symbol{
+				{Name: "database", Kind: lsp.SymbolKindModule, Container: "/db", File: "db/stack.tm"},
+				{Name: "global.db_host", Kind: lsp.SymbolKindVariable, Container: "/", File: "globals.tm", Line: 1},
+			},
+		},
+		{
+			query: "global.cfg",
+			want: []symbol{
+				{Name: "global.cfg.region", Kind: lsp.SymbolKindVariable, Container: "/app", File: "app/stack.tm", Line: 6},
+			},
+		},
+		{
+			query: "",
+			want: []symbol{
+				{Name: "app", Kind: lsp.SymbolKindModule, Container: "/app", File: "app/stack.tm"},
+				{Name: "database", Kind: lsp.SymbolKindModule, Container: "/db", File: "db/stack.tm"},
+				{Name: "global.db_host", Kind: lsp.SymbolKindVariable, Container: "/", File: "globals.tm", Line: 1},
+				{Name: "global.cfg.region", Kind: lsp.SymbolKindVariable, Container: "/app", File: "app/stack.tm", Line: 6},
+			},
+		},
+		{
+			query: "unknown",
+			want:  []symbol{},
+		},
+	} {
+		got := []symbol{}
+		for _, sym := range f.Editor.WorkspaceSymbol(tc.query) {
+			file, err := filepath.Rel(f.Sandbox.RootDir(), sym.Location.URI.Filename())
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, symbol{
+				Name:      sym.Name,
+				Kind:      sym.Kind,
+				Container: sym.ContainerName,
+				File:      filepath.ToSlash(file),
+				Line:      sym.Location.Range.Start.Line,
+			})
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Fatalf("query %q: unexpected symbols, want(-) got(+):\n%s", tc.query, diff)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package modvendor
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/fs"
+	"github.com/terramate-io/terramate/git"
+	"github.com/terramate-io/terramate/project"
+	"github.com/terramate-io/terramate/tf"
+)
+
+// FilterFactory creates the filter for the files copied from the cloned
+// repository directory into the vendor directory.
+type FilterFactory func(clonedRepoDir string) (fs.CopyFilterFunc, error)
+
+// Download clones the modsrc at its ref and copies it into the vendor dir
+// of the project, at the path given by [AbsVendorDir], which is returned.
+// The .git directory of the clone is never copied and, if newFilter is
+// not nil, only the files accepted by the created filter are copied.
+//
+// Download does not check if the source is already vendored, the caller
+// is responsible for not downloading the same source twice.
+func Download(
+	rootdir string,
+	vendorDir project.Path,
+	modsrc tf.Source,
+	newFilter FilterFactory,
+) (string, error) {
+	logger := log.With().
+		Str("action", "modvendor.Download()").
+		Str("rootdir", rootdir).
+		Stringer("vendordir", vendorDir).
+		Str("url", modsrc.URL).
+		Str("ref", modsrc.Ref).
+		Logger()
+
+	modVendorDir := AbsVendorDir(rootdir, vendorDir, modsrc)
+
+	logger.Trace().Msg("setting up temp dir where module will be cloned")
+
+	// We want an initial temporary dir outside of the Terramate project
+	// to do the clone since some git setups will assume that any
+	// git clone inside a repo is a submodule.
+	clonedRepoDir, err := os.MkdirTemp("", ".tmvendor")
+	if err != nil {
+		return "", errors.E(err, "creating tmp clone dir")
+	}
+	defer func() {
+		if err := os.RemoveAll(clonedRepoDir); err != nil {
+			log.Warn().Err(err).
+				Msg("deleting tmp clone dir")
+		}
+	}()
+
+	// We want a temporary dir inside the project to where we are going to copy
+	// the cloned module first. The idea is that if the copying fails we won't
+	// leave any changes in the project vendor dir. The final step then will
+	// be an atomic op using rename, which probably wont fail since the temp dir is
+	// inside the project and the whole project is most likely on the same fs/device.
+	tmTempDir, err := os.MkdirTemp(rootdir, ".tmvendor")
+	if err != nil {
+		return "", errors.E(err, "creating tmp dir inside project")
+	}
+	defer func() {
+		if err := os.RemoveAll(tmTempDir); err != nil {
+			log.Warn().Err(err).
+				Msg("deleting temp dir inside terramate project")
+		}
+	}()
+
+	logger = logger.With().
+		Str("clonedRepoDir", clonedRepoDir).
+		Str("modVendorDir", modVendorDir).
+		Str("tmTempDir", tmTempDir).
+		Logger()
+
+	logger.Trace().Msg("setting up git wrapper")
+
+	// Same strategy used on the Go toolchain:
+	// - https://github.com/golang/go/blob/2ebe77a2fda1ee9ff6fd9a3e08933ad1ebaea039/src/cmd/go/internal/get/get.go#L129
+
+	env := append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	g, err := git.WithConfig(git.Config{
+		WorkingDir:     clonedRepoDir,
+		AllowPorcelain: true,
+		Env:            env,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	logger.Trace().Msg("cloning to workdir")
+
+	if err := g.Clone(modsrc.URL, clonedRepoDir); err != nil {
+		return "", err
+	}
+
+	const create = false
+
+	if err := g.Checkout(modsrc.Ref, create); err != nil {
+		return "", errors.E(err, "checking ref %s", modsrc.Ref)
+	}
+
+	if err := os.RemoveAll(filepath.Join(clonedRepoDir, ".git")); err != nil {
+		return "", errors.E(err, "removing .git dir from cloned repo")
+	}
+
+	fileFilter := func(string, os.DirEntry) bool { return true }
+	if newFilter != nil {
+		fileFilter, err = newFilter(clonedRepoDir)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	logger.Trace().Msg("copying cloned mod to terramate temp vendor dir")
+	if err := fs.CopyDir(tmTempDir, clonedRepoDir, fileFilter); err != nil {
+		return "", errors.E(err, "copying cloned module")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(modVendorDir), 0775); err != nil {
+		return "", errors.E(err, "creating mod dir inside vendor")
+	}
+
+	logger.Trace().Msg("moving cloned mod from terramate temp vendor to final vendor")
+	if err := os.Rename(tmTempDir, modVendorDir); err != nil {
+		// Assuming that the whole Terramate project is inside the
+		// same fs/mount/dev.
+		return "", errors.E(err, "moving module from tmp dir to vendor")
+	}
+	return modVendorDir, nil
+}
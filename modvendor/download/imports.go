@@ -0,0 +1,106 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package download
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+	"github.com/terramate-io/terramate/config"
+	"github.com/terramate-io/terramate/errors"
+	"github.com/terramate-io/terramate/event"
+	"github.com/terramate-io/terramate/modvendor"
+	"github.com/terramate-io/terramate/project"
+	"github.com/terramate-io/terramate/tf"
+)
+
+// VendorImports vendors the remote sources of the import blocks of the
+// project which are not vendored yet, including the remote sources imported
+// by the vendored files. Each source is vendored at:
+//
+// - <rootdir>/<vendordir>/<Source.Path>/<Source.Ref>
+//
+// A skip file is created inside each vendored source, so its files are only
+// loaded through the import blocks and not as configuration of the project.
+// Vendored sources are never updated.
+//
+// It returns a report of everything vendored and ignored (with a reason).
+func VendorImports(
+	rootdir string,
+	vendorDir project.Path,
+	events ProgressEventStream,
+) Report {
+	report := NewReport(vendorDir)
+	// each source is handled only once, so a source which fails to be
+	// vendored is not retried.
+	handled := map[string]struct{}{}
+	for {
+		sources, err := config.MissingRemoteImports(rootdir)
+		if err != nil {
+			report.Error = err
+			return report
+		}
+
+		pending := 0
+		for _, src := range sources {
+			if _, ok := handled[src]; ok {
+				continue
+			}
+			handled[src] = struct{}{}
+			pending++
+
+			modsrc, err := tf.ParseSource(src)
+			if err != nil {
+				report.addIgnored(src, errors.E(ErrUnsupportedModSrc, err))
+				continue
+			}
+			// sources with different paths of the same repository and ref
+			// share the vendored dir.
+			if _, err := os.Stat(modvendor.AbsVendorDir(rootdir, vendorDir, modsrc)); err == nil {
+				continue
+			}
+			if err := vendorImport(rootdir, vendorDir, modsrc, events); err != nil {
+				report.addIgnored(src, errors.E(ErrDownloadMod, err,
+					"vendoring import %q with ref %q", modsrc.URL, modsrc.Ref))
+				continue
+			}
+			report.addVendored(modsrc)
+		}
+
+		// the vendored files may import other remote sources.
+		if pending == 0 {
+			return report
+		}
+	}
+}
+
+func vendorImport(
+	rootdir string,
+	vendorDir project.Path,
+	modsrc tf.Source,
+	events ProgressEventStream,
+) error {
+	event := event.VendorProgress{
+		Message:   "downloading",
+		TargetDir: modsrc.VendorDir(vendorDir),
+		Module:    modsrc,
+	}
+	if !events.Send(event) {
+		log.Debug().
+			Str("message", event.Message).
+			Stringer("targetDir", event.TargetDir).
+			Str("module", event.Module.Raw).
+			Msg("dropped progress event, event handler is not fast enough or absent")
+	}
+
+	moddir, err := modvendor.Download(rootdir, vendorDir, modsrc, nil)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(moddir, config.SkipFilename), nil, 0644); err != nil {
+		return errors.E(err, "creating skip file")
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+package download_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/madlambda/spells/assert"
+	"github.com/terramate-io/terramate/config"
+	"github.com/terramate-io/terramate/hcl"
+	"github.com/terramate-io/terramate/modvendor/download"
+	"github.com/terramate-io/terramate/project"
+	"github.com/terramate-io/terramate/test/sandbox"
+	"github.com/terramate-io/terramate/tf"
+	"go.lsp.dev/uri"
+)
+
+func TestVendorImports(t *testing.T) {
+	t.Parallel()
+
+	base := sandbox.New(t)
+	base.RootEntry().CreateFile("base/globals.tm.hcl", `globals {
+		base = "main"
+	}`)
+	base.Git().CommitAll("add base globals")
+	baseSource := fmt.Sprintf("git::%s//base/*.tm.hcl?ref=main", uri.File(base.RootDir()))
+
+	shared := sandbox.New(t)
+	shared.RootEntry().CreateFile("shared/globals.tm.hcl", `globals {
+		shared = "main"
+	}`)
+	shared.RootEntry().CreateFile("shared/import.tm.hcl", `import {
+		source = "`+baseSource+`"
+	}`)
+	shared.Git().CommitAll("add shared globals")
+	sharedSource := fmt.Sprintf("git::%s//shared/globals.tm.hcl?ref=main", uri.File(shared.RootDir()))
+
+	s := sandbox.NoGit(t)
+	s.BuildTree([]string{"s:stack"})
+	s.DirEntry("stack").CreateFile("import.tm", `import {
+		source = "`+sharedSource+`"
+	}
+	import {
+		source = "`+fmt.Sprintf("git::%s//shared/import.tm.hcl?ref=main", uri.File(shared.RootDir()))+`"
+	}`)
+
+	_, err := config.LoadRoot(s.RootDir())
+	assert.IsTrue(t, err != nil, "loading a project with remote imports not vendored must fail")
+
+	vendorDir := project.NewPath(hcl.DefaultVendorDir)
+	report := download.VendorImports(s.RootDir(), vendorDir, nil)
+	assert.NoError(t, report.Error)
+	assert.EqualInts(t, 0, len(report.Ignored))
+	assert.EqualInts(t, 2, len(report.Vendored))
+
+	for _, src := range []string{sharedSource, baseSource} {
+		modsrc, err := tf.ParseSource(src)
+		assert.NoError(t, err)
+		dir := modsrc.VendorDir(vendorDir)
+		_, ok := report.Vendored[dir]
+		assert.IsTrue(t, ok, "source %s not vendored", src)
+		_, err = os.Stat(filepath.Join(s.RootDir(), dir.String(), config.SkipFilename))
+		assert.NoError(t, err, "vendored import must have a skip file")
+	}
+
+	root, err := config.LoadRoot(s.RootDir())
+	assert.NoError(t, err)
+	tree, ok := root.Lookup(project.NewPath("/stack"))
+	assert.IsTrue(t, ok)
+	globals := tree.Node.Globals.AsList()
+	assert.EqualInts(t, 1, len(globals))
+	for _, name := range []string{"shared", "base"} {
+		_, ok := globals[0].Attributes[name]
+		assert.IsTrue(t, ok, "global %s not imported", name)
+	}
+
+	// vendored imports are never updated.
+	report = download.VendorImports(s.RootDir(), vendorDir, nil)
+	assert.NoError(t, report.Error)
+	assert.EqualInts(t, 0, len(report.Vendored))
+	assert.EqualInts(t, 0, len(report.Ignored))
+}
@@ -18,7 +18,7 @@ import (
 // On Windows, when modsrc.Scheme is "file" it replaces the volume “:“ by `$` because
 // `:` is disallowed as path component in such system.
 func TargetDir(vendorDir project.Path, modsrc tf.Source) project.Path {
-	return targetPathDir(vendorDir, modsrc)
+	return modsrc.VendorDir(vendorDir)
 }
 
 // SourceDir returns the source directory from a target directory (installed module).
@@ -7,6 +7,7 @@ import (
 	"os"
 	"strings"
 
+	hhcl "github.com/hashicorp/hcl/v2"
 	"github.com/terramate-io/terramate/config"
 	"github.com/terramate-io/terramate/errors"
 	"github.com/terramate-io/terramate/globals"
@@ -54,12 +55,26 @@ func LoadEnv(root *config.Root, st *config.Stack) (EnvVars, error) {
 
 	logger.Trace().Msg("loading globals")
 
-	globalsReport := globals.ForStack(root, st)
+	attrs := root.Tree().Node.Terramate.Config.Run.Env.Attributes.SortedList()
+
+	var globalsReport globals.EvalReport
+	if root.Tree().Node.HasLazyGlobals() {
+		refs := make([]hhcl.Expression, 0, len(attrs))
+		for _, attr := range attrs {
+			refs = append(refs, attr.Expr)
+		}
+		globalsReport = globals.ForStackLazy(root, st, refs)
+	} else {
+		globalsReport = globals.ForStack(root, st)
+	}
 	if err := globalsReport.AsError(); err != nil {
 		return nil, errors.E(ErrLoadingGlobals, err)
 	}
 
 	evalctx := eval.NewContext(stdlib.Functions(st.HostDir(root)))
+	if tree, ok := root.Lookup(st.Dir); ok {
+		tree.SetFunctions(evalctx)
+	}
 	runtime := root.Runtime()
 	runtime.Merge(st.RuntimeValues(root))
 	evalctx.SetNamespace("terramate", runtime)
@@ -68,8 +83,6 @@ func LoadEnv(root *config.Root, st *config.Stack) (EnvVars, error) {
 
 	envVars := EnvVars{}
 
-	attrs := root.Tree().Node.Terramate.Config.Run.Env.Attributes.SortedList()
-
 	for _, attr := range attrs {
 		logger = logger.With().
 			Str("attribute", attr.Name).
@@ -176,6 +176,74 @@ func TestLoadRunEnv(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "lazy globals evaluation reports unreferenced globals errors",
+			layout: []string{
+				"s:stack",
+			},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: Terramate(
+						Config(
+							Run(Env(
+								Expr("env", "global.a"),
+							)),
+							Block("globals",
+								Bool("lazy_evaluation", true),
+							),
+						),
+					),
+				},
+				{
+					path: "/stack",
+					add: Globals(
+						Str("a", "value"),
+						Expr("unused", "undefined"),
+					),
+				},
+			},
+			want: map[string]result{
+				"stack": {
+					enverr: errors.E(run.ErrLoadingGlobals),
+				},
+			},
+		},
+		{
+			name: "lazy globals evaluation ignores unreferenced globals values",
+			layout: []string{
+				"s:stack",
+			},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: Terramate(
+						Config(
+							Run(Env(
+								Expr("env", "global.a"),
+							)),
+							Block("globals",
+								Bool("lazy_evaluation", true),
+							),
+						),
+					),
+				},
+				{
+					path: "/stack",
+					add: Globals(
+						Str("a", "value"),
+						Expr("unused", "tm_element([], 0)"),
+					),
+				},
+			},
+			want: map[string]result{
+				"stack": {
+					env: run.EnvVars{
+						"env=value",
+					},
+				},
+			},
+		},
 		{
 			name: "fails evaluating undefined attribute",
 			layout: []string{
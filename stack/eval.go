@@ -19,6 +19,9 @@ type EvalCtx struct {
 // NewEvalCtx creates a new stack evaluation context.
 func NewEvalCtx(root *config.Root, stack *config.Stack, globals *eval.Object) *EvalCtx {
 	evalctx := eval.NewContext(stdlib.Functions(stack.HostDir(root)))
+	if tree, ok := root.Lookup(stack.Dir); ok {
+		tree.SetFunctions(evalctx)
+	}
 	evalwrapper := &EvalCtx{
 		Context: evalctx,
 		root:    root,
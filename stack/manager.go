@@ -255,7 +255,12 @@ rangeStacks:
 			Stringer("stack", stack).
 			Msg("Check for changed watch files.")
 
-		if changed, ok := hasChangedWatchedFiles(stack, changedFiles); ok {
+		watchFiles, err := stackWatchFiles(m.root, stack)
+		if err != nil {
+			return nil, errors.E(errListChanged, err)
+		}
+
+		if changed, ok := hasChangedWatchedFiles(watchFiles, changedFiles); ok {
 			logger.Debug().
 				Stringer("stack", stack).
 				Stringer("watchfile", changed).
@@ -276,7 +281,7 @@ rangeStacks:
 			Stringer("stack", stack).
 			Msg("Apply function to stack.")
 
-		err := m.filesApply(stack.HostDir(m.root), func(file fs.DirEntry) error {
+		err = m.filesApply(stack.HostDir(m.root), func(file fs.DirEntry) error {
 			if path.Ext(file.Name()) != ".tf" {
 				return nil
 			}
@@ -629,8 +634,26 @@ func listChangedFiles(dir string, gitBaseRef string) ([]string, error) {
 	return g.DiffNames(baseRef, headRef)
 }
 
-func hasChangedWatchedFiles(stack *config.Stack, changedFiles []string) (project.Path, bool) {
-	for _, watchFile := range stack.Watch {
+// stackWatchFiles returns the files explicitly watched by the stack plus the
+// globals data files, the vendored remote imports and the generate_file
+// templates loaded by the stack hierarchy, which are implicitly watched.
+func stackWatchFiles(root *config.Root, stack *config.Stack) (project.Paths, error) {
+	watchFiles := append(project.Paths{}, stack.Watch...)
+	tree, ok := root.Lookup(stack.Dir)
+	if !ok {
+		return watchFiles, nil
+	}
+	dataFiles, err := tree.GlobalsDataFiles()
+	if err != nil {
+		return nil, err
+	}
+	watchFiles = append(watchFiles, dataFiles...)
+	watchFiles = append(watchFiles, tree.VendoredImports()...)
+	return append(watchFiles, tree.GenerateTemplates()...), nil
+}
+
+func hasChangedWatchedFiles(watchFiles project.Paths, changedFiles []string) (project.Path, bool) {
+	for _, watchFile := range watchFiles {
 		for _, file := range changedFiles {
 			if file == watchFile.String()[1:] { // project paths
 				return watchFile, true
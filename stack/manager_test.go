@@ -11,9 +11,14 @@ import (
 
 	"github.com/madlambda/spells/assert"
 	"github.com/terramate-io/terramate/config"
+	"github.com/terramate-io/terramate/hcl"
+	"github.com/terramate-io/terramate/modvendor/download"
 	"github.com/terramate-io/terramate/project"
 	"github.com/terramate-io/terramate/stack"
 	"github.com/terramate-io/terramate/test"
+	. "github.com/terramate-io/terramate/test/hclwrite/hclutils"
+	"github.com/terramate-io/terramate/test/sandbox"
+	"go.lsp.dev/uri"
 )
 
 type repository struct {
@@ -189,6 +194,129 @@ func TestListChangedStackReason(t *testing.T) {
 	}
 }
 
+func TestListChangedGlobalsDataFile(t *testing.T) {
+	t.Parallel()
+
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack-a",
+		"s:stack-b",
+		`f:data/env.json:{"region": "eu-west-1"}`,
+	})
+	s.DirEntry("stack-a").CreateFile("globals.tm", Globals(
+		DataFile(
+			Labels("/data/env.json"),
+		),
+	).String())
+
+	git := s.Git()
+	git.CommitAll("all")
+	git.Push("main")
+	git.CheckoutNew("change-data-file")
+
+	s.DirEntry("data").CreateFile("env.json", `{"region": "us-east-1"}`)
+	git.CommitAll("data file changed")
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	report, err := m.ListChanged()
+	assert.NoError(t, err)
+
+	assertStacks(t, []string{"/stack-a"}, report.Stacks, true)
+	if !strings.Contains(report.Stacks[0].Reason, "/data/env.json") {
+		t.Fatalf("unexpected reason %q", report.Stacks[0].Reason)
+	}
+}
+
+func TestListChangedVendoredImportRef(t *testing.T) {
+	t.Parallel()
+
+	repo := sandbox.New(t)
+	repo.RootEntry().CreateFile("shared/globals.tm.hcl", Globals(
+		Str("version", "v1"),
+	).String())
+	repogit := repo.Git()
+	repogit.CommitAll("add shared globals")
+	repogit.CheckoutNew("v2")
+	repo.RootEntry().CreateFile("shared/globals.tm.hcl", Globals(
+		Str("version", "v2"),
+	).String())
+	repogit.CommitAll("update shared globals")
+
+	importAt := func(ref string) string {
+		return Import(
+			Str("source", fmt.Sprintf("git::%s//shared/globals.tm.hcl?ref=%s",
+				uri.File(repo.RootDir()), ref)),
+		).String()
+	}
+
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:envs/stack-a",
+		"s:stack-b",
+	})
+	s.DirEntry("envs").CreateFile("import.tm", importAt("main"))
+	vendorImports(t, s)
+	s.ReloadConfig()
+
+	git := s.Git()
+	git.CommitAll("all")
+	git.Push("main")
+	git.CheckoutNew("change-import-ref")
+
+	s.DirEntry("envs").CreateFile("import.tm", importAt("v2"))
+	vendorImports(t, s)
+	s.ReloadConfig()
+	git.CommitAll("import ref changed")
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	report, err := m.ListChanged()
+	assert.NoError(t, err)
+
+	assertStacks(t, []string{"/envs/stack-a"}, report.Stacks, true)
+	if !strings.Contains(report.Stacks[0].Reason, "/v2/shared/globals.tm.hcl") {
+		t.Fatalf("unexpected reason %q", report.Stacks[0].Reason)
+	}
+}
+
+func TestListChangedGenerateFileTemplate(t *testing.T) {
+	t.Parallel()
+
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack-a",
+		"s:stack-b",
+		"f:templates/file.tpl:${terramate.stack.name}",
+	})
+	s.DirEntry("stack-a").CreateFile("gen.tm", GenerateFile(
+		Labels("file.txt"),
+		Str("template", "/templates/file.tpl"),
+	).String())
+
+	git := s.Git()
+	git.CommitAll("all")
+	git.Push("main")
+	git.CheckoutNew("change-template")
+
+	s.DirEntry("templates").CreateFile("file.tpl", "name: ${terramate.stack.name}")
+	git.CommitAll("template changed")
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	report, err := m.ListChanged()
+	assert.NoError(t, err)
+
+	assertStacks(t, []string{"/stack-a"}, report.Stacks, true)
+	if !strings.Contains(report.Stacks[0].Reason, "/templates/file.tpl") {
+		t.Fatalf("unexpected reason %q", report.Stacks[0].Reason)
+	}
+}
+
+func vendorImports(t *testing.T, s sandbox.S) {
+	t.Helper()
+	report := download.VendorImports(s.RootDir(), project.NewPath(hcl.DefaultVendorDir), nil)
+	assert.NoError(t, report.Error)
+	assert.IsTrue(t, !report.HasFailures(), report.String())
+}
+
 func assertStacks(
 	t *testing.T, want []string, got []stack.Entry, wantReason bool,
 ) {
@@ -65,6 +65,8 @@ func AssertTerramateConfig(t *testing.T, got, want hcl.Config) {
 	assertStackBlock(t, got.Stack, want.Stack)
 	assertAssertsBlock(t, got.Asserts, want.Asserts, "terramate asserts")
 	AssertDiff(t, got.Vendor, want.Vendor, "terramate vendor")
+	assertStackDefaultsBlock(t, got.StackDefaults, want.StackDefaults)
+	assertFunctionBlocks(t, got.Functions, want.Functions)
 	assertGenHCLBlocks(t, got.Generate.HCLs, want.Generate.HCLs)
 	assertGenFileBlocks(t, got.Generate.Files, want.Generate.Files)
 }
@@ -240,6 +242,14 @@ func assertTerramateConfigBlock(t *testing.T, got, want *hcl.RootConfig) {
 
 	assertTerramateRunBlock(t, got.Run, want.Run)
 	assertTerramateCloudBlock(t, got.Cloud, want.Cloud)
+
+	if (want.Globals == nil) != (got.Globals == nil) {
+		t.Fatalf("want.Globals[%+v] != got.Globals[%+v]", want.Globals, got.Globals)
+	}
+
+	if want.Globals != nil && *want.Globals != *got.Globals {
+		t.Fatalf("want.Globals[%+v] != got.Globals[%+v]", want.Globals, got.Globals)
+	}
 }
 
 func assertGenHCLBlocks(t *testing.T, got, want []hcl.GenHCLBlock) {
@@ -352,6 +362,49 @@ func assertStackBlock(t *testing.T, got, want *hcl.Stack) {
 	for i, w := range want.After {
 		assert.EqualStrings(t, w, got.After[i], "stack after mismatch")
 	}
+
+	assert.EqualInts(t, len(got.Computed), len(want.Computed), "Computed length mismatch")
+
+	for i, w := range want.Computed {
+		assert.EqualStrings(t, w.Name, got.Computed[i].Name, "stack computed attribute mismatch")
+	}
+}
+
+func assertStackDefaultsBlock(t *testing.T, got, want *hcl.StackDefaults) {
+	t.Helper()
+
+	if (got == nil) != (want == nil) {
+		t.Fatalf("want[%+v] != got[%+v]", want, got)
+	}
+
+	if want == nil {
+		return
+	}
+
+	AssertDiff(t, got.Tags, want.Tags, "stack_defaults tags mismatch")
+	AssertDiff(t, got.After, want.After, "stack_defaults after mismatch")
+	AssertDiff(t, got.Before, want.Before, "stack_defaults before mismatch")
+	AssertDiff(t, got.Wants, want.Wants, "stack_defaults wants mismatch")
+	AssertDiff(t, got.Watch, want.Watch, "stack_defaults watch mismatch")
+	if (got.Description == nil) != (want.Description == nil) {
+		t.Fatalf("stack_defaults description mismatch: want[%v] != got[%v]",
+			want.Description, got.Description)
+	}
+}
+
+func assertFunctionBlocks(t *testing.T, got, want []hcl.Function) {
+	t.Helper()
+
+	assert.EqualInts(t, len(want), len(got), "functions length mismatch")
+
+	for i, w := range want {
+		g := got[i]
+		assert.EqualStrings(t, w.Name, g.Name, "function name mismatch")
+		AssertDiff(t, g.Params, w.Params, "function %s params mismatch", w.Name)
+		assert.EqualStrings(t,
+			exprAsStr(t, w.Result), exprAsStr(t, g.Result),
+			"function %s result expr mismatch", w.Name)
+	}
 }
 
 // WriteRootConfig writes a basic terramate root config.
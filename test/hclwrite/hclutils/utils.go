@@ -66,6 +66,11 @@ func TmDynamic(builders ...hclwrite.BlockBuilder) *hclwrite.Block {
 	return Block("tm_dynamic", builders...)
 }
 
+// TmDynamicAttributes is a helper for a "tm_dynamic_attributes" block.
+func TmDynamicAttributes(builders ...hclwrite.BlockBuilder) *hclwrite.Block {
+	return Block("tm_dynamic_attributes", builders...)
+}
+
 // GenerateFile is a helper for a "generate_file" block.
 func GenerateFile(builders ...hclwrite.BlockBuilder) *hclwrite.Block {
 	return Block("generate_file", builders...)
@@ -121,6 +126,16 @@ func Globals(builders ...hclwrite.BlockBuilder) *hclwrite.Block {
 	return Block("globals", builders...)
 }
 
+// GlobalsSchema is a helper for a "globals_schema" block.
+func GlobalsSchema(builders ...hclwrite.BlockBuilder) *hclwrite.Block {
+	return Block("globals_schema", builders...)
+}
+
+// DataFile is a helper for a "data_file" block.
+func DataFile(builders ...hclwrite.BlockBuilder) *hclwrite.Block {
+	return Block("data_file", builders...)
+}
+
 // Map is a helper for a "map" block.
 func Map(builders ...hclwrite.BlockBuilder) *hclwrite.Block {
 	return Block("map", builders...)
@@ -13,6 +13,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/madlambda/spells/assert"
 	"github.com/rs/zerolog"
+	tmls "github.com/terramate-io/terramate/ls"
 	"github.com/terramate-io/terramate/test/sandbox"
 	"go.lsp.dev/jsonrpc2"
 	lsp "go.lsp.dev/protocol"
@@ -106,8 +107,36 @@ func (e *Editor) Open(path string) {
 	}
 }
 
-// Change sends a didChange request to the language server.
+// Change sends a didChange request to the language server replacing the whole
+// content of the document.
 func (e *Editor) Change(path, content string) {
+	t := e.t
+	t.Helper()
+	// the range must be omitted for replacing the whole document but
+	// lsp.TextDocumentContentChangeEvent always has one.
+	type contentChange struct {
+		Text string `json:"text"`
+	}
+	abspath := filepath.Join(e.sandbox.RootDir(), path)
+	var changeResult interface{}
+	_, err := e.call(lsp.MethodTextDocumentDidChange, map[string]interface{}{
+		"textDocument": lsp.VersionedTextDocumentIdentifier{
+			TextDocumentIdentifier: lsp.TextDocumentIdentifier{
+				URI: uri.File(abspath),
+			},
+		},
+		"contentChanges": []contentChange{
+			{
+				Text: content,
+			},
+		},
+	}, &changeResult)
+	assert.NoError(t, err, "call %q", lsp.MethodTextDocumentDidChange)
+}
+
+// ChangeRange sends a didChange request to the language server replacing the
+// range of the document with the text.
+func (e *Editor) ChangeRange(path string, rng lsp.Range, text string) {
 	t := e.t
 	t.Helper()
 	abspath := filepath.Join(e.sandbox.RootDir(), path)
@@ -120,23 +149,238 @@ func (e *Editor) Change(path, content string) {
 		},
 		ContentChanges: []lsp.TextDocumentContentChangeEvent{
 			{
-				Text: content,
+				Range: rng,
+				Text:  text,
 			},
 		},
 	}, &changeResult)
 	assert.NoError(t, err, "call %q", lsp.MethodTextDocumentDidChange)
 }
 
+// Close sends a didClose request to the language server.
+func (e *Editor) Close(path string) {
+	t := e.t
+	t.Helper()
+	abspath := filepath.Join(e.sandbox.RootDir(), path)
+	var closeResult interface{}
+	_, err := e.call(lsp.MethodTextDocumentDidClose, lsp.DidCloseTextDocumentParams{
+		TextDocument: lsp.TextDocumentIdentifier{
+			URI: uri.File(abspath),
+		},
+	}, &closeResult)
+	assert.NoError(t, err, "call %q", lsp.MethodTextDocumentDidClose)
+}
+
+// Save sends a didSave request to the language server.
+func (e *Editor) Save(path string) {
+	t := e.t
+	t.Helper()
+	abspath := filepath.Join(e.sandbox.RootDir(), path)
+	var saveResult interface{}
+	_, err := e.call(lsp.MethodTextDocumentDidSave, lsp.DidSaveTextDocumentParams{
+		TextDocument: lsp.TextDocumentIdentifier{
+			URI: uri.File(abspath),
+		},
+	}, &saveResult)
+	assert.NoError(t, err, "call %q", lsp.MethodTextDocumentDidSave)
+}
+
+// Definition sends a definition request to the language server and returns
+// the found locations.
+func (e *Editor) Definition(path string, line, char uint32) []lsp.Location {
+	t := e.t
+	t.Helper()
+	abspath := filepath.Join(e.sandbox.RootDir(), path)
+	var got []lsp.Location
+	_, err := e.call(lsp.MethodTextDocumentDefinition, lsp.DefinitionParams{
+		TextDocumentPositionParams: lsp.TextDocumentPositionParams{
+			TextDocument: lsp.TextDocumentIdentifier{
+				URI: uri.File(abspath),
+			},
+			Position: lsp.Position{
+				Line:      line,
+				Character: char,
+			},
+		},
+	}, &got)
+	assert.NoError(t, err, "call %q", lsp.MethodTextDocumentDefinition)
+	return got
+}
+
+// Hover sends a hover request to the language server and returns its result.
+func (e *Editor) Hover(path string, line, char uint32) *lsp.Hover {
+	t := e.t
+	t.Helper()
+	abspath := filepath.Join(e.sandbox.RootDir(), path)
+	var got *lsp.Hover
+	_, err := e.call(lsp.MethodTextDocumentHover, lsp.HoverParams{
+		TextDocumentPositionParams: lsp.TextDocumentPositionParams{
+			TextDocument: lsp.TextDocumentIdentifier{
+				URI: uri.File(abspath),
+			},
+			Position: lsp.Position{
+				Line:      line,
+				Character: char,
+			},
+		},
+	}, &got)
+	assert.NoError(t, err, "call %q", lsp.MethodTextDocumentHover)
+	return got
+}
+
+// Completion sends a completion request to the language server and returns
+// the completion items.
+func (e *Editor) Completion(path string, line, char uint32) []lsp.CompletionItem {
+	t := e.t
+	t.Helper()
+	abspath := filepath.Join(e.sandbox.RootDir(), path)
+	var got []lsp.CompletionItem
+	_, err := e.call(lsp.MethodTextDocumentCompletion, lsp.CompletionParams{
+		TextDocumentPositionParams: lsp.TextDocumentPositionParams{
+			TextDocument: lsp.TextDocumentIdentifier{
+				URI: uri.File(abspath),
+			},
+			Position: lsp.Position{
+				Line:      line,
+				Character: char,
+			},
+		},
+	}, &got)
+	assert.NoError(t, err, "call %q", lsp.MethodTextDocumentCompletion)
+	return got
+}
+
+// Formatting sends a formatting request to the language server and returns
+// the edits formatting the document.
+func (e *Editor) Formatting(path string) []lsp.TextEdit {
+	t := e.t
+	t.Helper()
+	abspath := filepath.Join(e.sandbox.RootDir(), path)
+	var got []lsp.TextEdit
+	_, err := e.call(lsp.MethodTextDocumentFormatting, lsp.DocumentFormattingParams{
+		TextDocument: lsp.TextDocumentIdentifier{
+			URI: uri.File(abspath),
+		},
+	}, &got)
+	assert.NoError(t, err, "call %q", lsp.MethodTextDocumentFormatting)
+	return got
+}
+
+// CodeAction sends a code action request to the language server for the range
+// of the document and returns the available actions.
+func (e *Editor) CodeAction(path string, rng lsp.Range, diags []lsp.Diagnostic) []lsp.CodeAction {
+	t := e.t
+	t.Helper()
+	abspath := filepath.Join(e.sandbox.RootDir(), path)
+	var got []lsp.CodeAction
+	_, err := e.call(lsp.MethodTextDocumentCodeAction, lsp.CodeActionParams{
+		TextDocument: lsp.TextDocumentIdentifier{
+			URI: uri.File(abspath),
+		},
+		Range: rng,
+		Context: lsp.CodeActionContext{
+			Diagnostics: diags,
+		},
+	}, &got)
+	assert.NoError(t, err, "call %q", lsp.MethodTextDocumentCodeAction)
+	return got
+}
+
+// ExecuteCommand sends an execute command request to the language server.
+func (e *Editor) ExecuteCommand(cmd lsp.Command) {
+	t := e.t
+	t.Helper()
+	var result interface{}
+	_, err := e.call(lsp.MethodWorkspaceExecuteCommand, lsp.ExecuteCommandParams{
+		Command:   cmd.Command,
+		Arguments: cmd.Arguments,
+	}, &result)
+	assert.NoError(t, err, "call %q", lsp.MethodWorkspaceExecuteCommand)
+}
+
+// Rename sends a rename request to the language server for the position of the
+// document and returns the resulting edits.
+func (e *Editor) Rename(path string, pos lsp.Position, newName string) (lsp.WorkspaceEdit, error) {
+	abspath := filepath.Join(e.sandbox.RootDir(), path)
+	var got lsp.WorkspaceEdit
+	_, err := e.call(lsp.MethodTextDocumentRename, lsp.RenameParams{
+		TextDocumentPositionParams: lsp.TextDocumentPositionParams{
+			TextDocument: lsp.TextDocumentIdentifier{
+				URI: uri.File(abspath),
+			},
+			Position: pos,
+		},
+		NewName: newName,
+	}, &got)
+	return got, err
+}
+
+// DocumentSymbol sends a document symbol request to the language server and
+// returns the symbols of the document.
+func (e *Editor) DocumentSymbol(path string) []lsp.DocumentSymbol {
+	t := e.t
+	t.Helper()
+	abspath := filepath.Join(e.sandbox.RootDir(), path)
+	var got []lsp.DocumentSymbol
+	_, err := e.call(lsp.MethodTextDocumentDocumentSymbol, lsp.DocumentSymbolParams{
+		TextDocument: lsp.TextDocumentIdentifier{
+			URI: uri.File(abspath),
+		},
+	}, &got)
+	assert.NoError(t, err, "call %q", lsp.MethodTextDocumentDocumentSymbol)
+	return got
+}
+
+// WorkspaceSymbol sends a workspace symbol request to the language server and
+// returns the symbols matching the query.
+func (e *Editor) WorkspaceSymbol(query string) []lsp.SymbolInformation {
+	t := e.t
+	t.Helper()
+	var got []lsp.SymbolInformation
+	_, err := e.call(lsp.MethodWorkspaceSymbol, lsp.WorkspaceSymbolParams{
+		Query: query,
+	}, &got)
+	assert.NoError(t, err, "call %q", lsp.MethodWorkspaceSymbol)
+	return got
+}
+
+// GeneratePreview sends a generate preview request to the language server for
+// the generate block at the position of the document.
+func (e *Editor) GeneratePreview(path string, pos lsp.Position, stack string) (tmls.GeneratePreview, error) {
+	abspath := filepath.Join(e.sandbox.RootDir(), path)
+	var got tmls.GeneratePreview
+	_, err := e.call(tmls.MethodGeneratePreview, tmls.GeneratePreviewParams{
+		TextDocumentPositionParams: lsp.TextDocumentPositionParams{
+			TextDocument: lsp.TextDocumentIdentifier{
+				URI: uri.File(abspath),
+			},
+			Position: pos,
+		},
+		Stack: stack,
+	}, &got)
+	return got, err
+}
+
 // DefaultInitializeResult is the default server response for the initialization
 // request.
 func DefaultInitializeResult() lsp.InitializeResult {
 	return lsp.InitializeResult{
 		Capabilities: lsp.ServerCapabilities{
 			CompletionProvider: &lsp.CompletionOptions{},
-			DefinitionProvider: false,
-			HoverProvider:      false,
+			DefinitionProvider: true,
+			HoverProvider:      true,
+			CodeActionProvider: map[string]interface{}{
+				"codeActionKinds": []interface{}{"quickfix", "source"},
+			},
+			DocumentFormattingProvider: true,
+			RenameProvider:             true,
+			DocumentSymbolProvider:     true,
+			WorkspaceSymbolProvider:    true,
+			ExecuteCommandProvider: &lsp.ExecuteCommandOptions{
+				Commands: []string{"terramate.generateStack"},
+			},
 			TextDocumentSync: map[string]interface{}{
-				"change":    float64(1),
+				"change":    float64(2),
 				"openClose": true,
 				"save":      map[string]interface{}{},
 			},
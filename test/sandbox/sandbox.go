@@ -274,7 +274,7 @@ func (s *S) Config() *config.Root {
 	if s.cfg != nil {
 		return s.cfg
 	}
-	cfg, err := config.LoadRoot(s.RootDir())
+	cfg, err := config.LoadRootWithEvaluator(s.RootDir(), globals.EvalStackAttrs)
 	assert.NoError(s.t, err)
 	s.cfg = cfg
 	return cfg
@@ -0,0 +1,20 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build aix || android || darwin || dragonfly || freebsd || hurd || illumos || ios || linux || netbsd || openbsd || solaris || js
+
+package tf
+
+import (
+	"path"
+
+	"github.com/terramate-io/terramate/project"
+)
+
+// VendorDir returns the directory where the source is vendored, relative to
+// the project root: <vendorDir>/<Path>/<Ref>.
+func (s Source) VendorDir(vendorDir project.Path) project.Path {
+	return project.NewPath(
+		path.Join(vendorDir.String(), s.Path, s.Ref),
+	)
+}
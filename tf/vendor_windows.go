@@ -0,0 +1,40 @@
+// Copyright 2023 Terramate GmbH
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build windows
+
+package tf
+
+import (
+	"path"
+	"strings"
+
+	"github.com/terramate-io/terramate/project"
+)
+
+// VendorDir returns the directory where the source is vendored, relative to
+// the project root, for Windows systems.
+// On Windows, the colon (:) is prohibited in path components other than volume,
+// then it needs to be replaced by something else when vendoring file:// deps.
+func (s Source) VendorDir(vendorDir project.Path) project.Path {
+	tpath := s.Path
+	if s.PathScheme == "file" {
+		// Windows Path in File URI has the form: /<winpath>
+		tpath = tpath[1:]
+		colonPos := strings.Index(tpath, ":")
+		slashPos := strings.Index(tpath, "/")
+
+		// if : is before / (if found)
+		// This checks that we replace if:
+		//   D:/<etc>
+		// But not if:
+		//   test/D:/etc
+		if colonPos > 0 && (slashPos == -1 || slashPos > colonPos) {
+			tpath = tpath[0:colonPos] + "$" + tpath[colonPos+1:]
+		}
+	}
+
+	return project.NewPath(
+		path.Join(vendorDir.String(), tpath, s.Ref),
+	)
+}